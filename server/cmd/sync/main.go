@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/vlahanam/company-management/internal/initialize"
+	"github.com/vlahanam/company-management/internal/registry"
+)
+
+// sync reconciles the roles, permissions, default grants and role hierarchy
+// defined in code with the database, and prints the drift it found.
+//
+//	go run cmd/sync/main.go            apply the code-defined registry
+//	go run cmd/sync/main.go -dry-run   only report the drift
+//	go run cmd/sync/main.go -check     report and exit 1 on drift, for CI
+//	go run cmd/sync/main.go -restore   also give back removed default grants
+//	go run cmd/sync/main.go -prune     also delete what code does not define
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report the drift without changing the database")
+	check := flag.Bool("check", false, "like -dry-run, and exit with status 1 when there is drift")
+	restore := flag.Bool("restore", false, "give back the default grants and parents removed from existing built-in roles")
+	prune := flag.Bool("prune", false, "delete custom roles and permissions and the non-default grants and parents of built-in roles")
+	flag.Parse()
+
+	cfg := initialize.LoadConfig()
+	db := initialize.InitMysql(cfg)
+
+	report, err := registry.Sync(context.Background(), db, registry.Options{
+		DryRun:  *dryRun || *check,
+		Restore: *restore,
+		Prune:   *prune,
+	})
+	if err != nil {
+		log.Fatalf("Failed to sync the role and permission registry: %v", err)
+	}
+
+	if !report.Drift() {
+		fmt.Println("Roles and permissions are in sync")
+		return
+	}
+
+	for _, c := range report.Changes {
+		fmt.Println(c)
+	}
+	fmt.Printf("%d differences, %d left in the database\n", len(report.Changes), report.Pending())
+
+	if *check {
+		os.Exit(1)
+	}
+}
@@ -114,6 +114,10 @@ var ErrorValidation = &rootError{
 	Key:     "VALIDATION_ERROR",
 	Message: "validation error",
 }
+var ErrorForbidden = &rootError{
+	Key:     "PERMISSION_DENIED",
+	Message: "you do not have permission to access this resource",
+}
 var ErrorNotFound = &rootError{
 	Key:     "NOT_FOUND_ERROR",
 	Message: "resource not found",
@@ -138,3 +142,7 @@ var ErrorCreateFailed = &rootError{
 	Key:     "CREATE_FAILED",
 	Message: "failed to create resource",
 }
+var ErrorTooManyRequests = &rootError{
+	Key:     "TOO_MANY_REQUESTS",
+	Message: "too many requests",
+}
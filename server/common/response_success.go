@@ -90,8 +90,92 @@ var LoginSuccessful = &successResponse{
 	Key: "LOGIN_SUCCESSFUL",
 	Message: "Login successful",
 }
+var LogoutSuccessful = &successResponse{
+	Key: "LOGOUT_SUCCESSFUL",
+	Message: "Logout successful",
+}
+var PasswordResetRequested = &successResponse{
+	Key: "PASSWORD_RESET_REQUESTED",
+	Message: "If the email is registered, a password reset link has been sent",
+}
+var PasswordResetSuccessful = &successResponse{
+	Key: "PASSWORD_RESET_SUCCESSFUL",
+	Message: "Password has been reset",
+}
+var PasswordChanged = &successResponse{
+	Key: "PASSWORD_CHANGED",
+	Message: "Password has been changed and your other sessions were signed out",
+}
+var TOTPSetupStarted = &successResponse{
+	Key: "TOTP_SETUP_STARTED",
+	Message: "Scan the QR code and confirm with a code from your authenticator app",
+}
+var TOTPEnabled = &successResponse{
+	Key: "TOTP_ENABLED",
+	Message: "Two-factor authentication enabled, store your recovery codes safely",
+}
+var TOTPDisabled = &successResponse{
+	Key: "TOTP_DISABLED",
+	Message: "Two-factor authentication disabled",
+}
+var RecoveryCodesRegenerated = &successResponse{
+	Key: "RECOVERY_CODES_REGENERATED",
+	Message: "Recovery codes regenerated, previous codes no longer work",
+}
+var PasskeyCeremonyStarted = &successResponse{
+	Key: "PASSKEY_CEREMONY_STARTED",
+	Message: "Pass the options to your authenticator and send back its response",
+}
+var SSOLoginStarted = &successResponse{
+	Key: "SSO_LOGIN_STARTED",
+	Message: "Redirect the browser to the authorization URL",
+}
+var SessionRevoked = &successResponse{
+	Key: "SESSION_REVOKED",
+	Message: "Session signed out",
+}
+var ImpersonationStarted = &successResponse{
+	Key: "IMPERSONATION_STARTED",
+	Message: "Impersonation started",
+}
+var ImpersonationStopped = &successResponse{
+	Key: "IMPERSONATION_STOPPED",
+	Message: "Impersonation stopped",
+}
+var InvitationSent = &successResponse{
+	Key: "INVITATION_SENT",
+	Message: "Invitation sent",
+}
+var InvitationAccepted = &successResponse{
+	Key: "INVITATION_ACCEPTED",
+	Message: "Invitation accepted, you can now log in",
+}
+var InvitationRevoked = &successResponse{
+	Key: "INVITATION_REVOKED",
+	Message: "Invitation withdrawn",
+}
+var UserUnlocked = &successResponse{
+	Key: "USER_UNLOCKED",
+	Message: "Failed login attempts cleared, the account can log in again",
+}
+var APIKeyCreated = &successResponse{
+	Key: "API_KEY_CREATED",
+	Message: "API key created, store it now as it will not be shown again",
+}
+var APIKeyRevoked = &successResponse{
+	Key: "API_KEY_REVOKED",
+	Message: "API key revoked",
+}
 var RegisterSuccessful = &successResponse{
-	Message: "Registration successful",
+	Message: "Registration successful, please check your email to verify your account",
+}
+var EmailVerified = &successResponse{
+	Key: "EMAIL_VERIFIED",
+	Message: "Email verified successfully",
+}
+var VerificationEmailSent = &successResponse{
+	Key: "VERIFICATION_EMAIL_SENT",
+	Message: "If the account exists and is not verified yet, a verification email has been sent",
 }
 var RetrievedSuccessfully = &successResponse{
 	Message: "Retrieved successfully",
@@ -18,6 +18,8 @@ import (
 
 const (
 	ObjectTypeUser int64 = iota + 1
+	ObjectTypeServiceAccount
+	ObjectTypeAPIKey
 )
 
 type UID struct {
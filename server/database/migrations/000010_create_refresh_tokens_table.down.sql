@@ -0,0 +1,2 @@
+ALTER TABLE refresh_tokens DROP FOREIGN KEY fk_refresh_tokens_user;
+DROP TABLE IF EXISTS refresh_tokens;
\ No newline at end of file
@@ -0,0 +1,16 @@
+CREATE TABLE refresh_tokens (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT 'Unique identifier for the refresh token',
+    user_id BIGINT NOT NULL COMMENT 'Reference to the user',
+    family_id VARCHAR(36) NOT NULL COMMENT 'Token family shared by all rotations of one login',
+    jti VARCHAR(36) NOT NULL UNIQUE COMMENT 'JWT ID of the refresh token',
+    device VARCHAR(255) COMMENT 'Device or user agent the token was issued to',
+    expires_at TIMESTAMP NOT NULL COMMENT 'Refresh token expiry',
+    used_at TIMESTAMP NULL DEFAULT NULL COMMENT 'Timestamp when the token was rotated',
+    revoked_at TIMESTAMP NULL DEFAULT NULL COMMENT 'Timestamp when the token was revoked',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Record creation timestamp',
+
+    INDEX idx_refresh_tokens_family (family_id),
+    CONSTRAINT fk_refresh_tokens_user FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
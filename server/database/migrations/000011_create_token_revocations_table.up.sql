@@ -0,0 +1,10 @@
+CREATE TABLE token_revocations (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT 'Unique identifier for the revocation',
+    jti VARCHAR(36) DEFAULT NULL UNIQUE COMMENT 'JWT ID of a single revoked access token',
+    user_id BIGINT DEFAULT NULL COMMENT 'User whose tokens issued before revoked_before are revoked',
+    revoked_before TIMESTAMP(6) NULL DEFAULT NULL COMMENT 'Tokens of user_id issued before this time are revoked',
+    expires_at TIMESTAMP NOT NULL COMMENT 'Time after which every affected token has expired anyway',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Record creation timestamp',
+
+    INDEX idx_token_revocations_expires_at (expires_at)
+) COMMENT='Revoked access tokens, keyed by jti or by user';
@@ -0,0 +1,2 @@
+ALTER TABLE password_reset_tokens DROP FOREIGN KEY fk_password_reset_tokens_user;
+DROP TABLE IF EXISTS password_reset_tokens;
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE password_reset_tokens (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT 'Unique identifier for the reset token',
+    user_id BIGINT NOT NULL COMMENT 'Reference to the user',
+    token_hash CHAR(64) NOT NULL UNIQUE COMMENT 'SHA-256 of the token sent by email',
+    expires_at TIMESTAMP NOT NULL COMMENT 'Token expiry',
+    used_at TIMESTAMP NULL DEFAULT NULL COMMENT 'Timestamp when the token was consumed or superseded',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Record creation timestamp',
+
+    CONSTRAINT fk_password_reset_tokens_user FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
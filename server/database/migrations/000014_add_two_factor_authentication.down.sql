@@ -0,0 +1,9 @@
+ALTER TABLE user_recovery_codes DROP FOREIGN KEY fk_user_recovery_codes_user;
+DROP TABLE IF EXISTS user_recovery_codes;
+
+ALTER TABLE roles DROP COLUMN require_mfa;
+
+ALTER TABLE users
+    DROP COLUMN totp_last_step,
+    DROP COLUMN totp_enabled_at,
+    DROP COLUMN totp_secret;
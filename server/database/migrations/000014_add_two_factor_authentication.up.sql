@@ -0,0 +1,20 @@
+ALTER TABLE users
+    ADD COLUMN totp_secret VARCHAR(64) NULL DEFAULT NULL COMMENT 'Base32 TOTP shared secret' AFTER hash_password,
+    ADD COLUMN totp_enabled_at TIMESTAMP NULL DEFAULT NULL COMMENT 'Timestamp when TOTP enrollment was confirmed' AFTER totp_secret,
+    ADD COLUMN totp_last_step BIGINT NULL DEFAULT NULL COMMENT 'Last accepted TOTP time step, to reject replayed codes' AFTER totp_enabled_at;
+
+ALTER TABLE roles
+    ADD COLUMN require_mfa BOOLEAN NOT NULL DEFAULT FALSE COMMENT 'Members of this role must use two-factor authentication' AFTER description;
+
+CREATE TABLE user_recovery_codes (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT 'Unique identifier for the recovery code',
+    user_id BIGINT NOT NULL COMMENT 'Reference to the user',
+    code_hash CHAR(64) NOT NULL COMMENT 'SHA-256 of the recovery code',
+    used_at TIMESTAMP NULL DEFAULT NULL COMMENT 'Timestamp when the code was used',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Record creation timestamp',
+
+    CONSTRAINT uq_user_recovery_code UNIQUE (user_id, code_hash),
+    CONSTRAINT fk_user_recovery_codes_user FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
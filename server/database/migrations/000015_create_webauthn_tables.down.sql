@@ -0,0 +1,5 @@
+ALTER TABLE webauthn_sessions DROP FOREIGN KEY fk_webauthn_sessions_user;
+DROP TABLE IF EXISTS webauthn_sessions;
+
+ALTER TABLE webauthn_credentials DROP FOREIGN KEY fk_webauthn_credentials_user;
+DROP TABLE IF EXISTS webauthn_credentials;
@@ -0,0 +1,27 @@
+CREATE TABLE webauthn_credentials (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT 'Unique identifier for the credential',
+    user_id BIGINT NOT NULL COMMENT 'Reference to the user',
+    credential_id VARCHAR(255) NOT NULL UNIQUE COMMENT 'Base64url credential ID assigned by the authenticator',
+    name VARCHAR(100) COMMENT 'Label chosen by the user',
+    credential JSON NOT NULL COMMENT 'Public key, sign count and flags of the credential',
+    last_used_at TIMESTAMP NULL DEFAULT NULL COMMENT 'Timestamp of the last successful login',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Record creation timestamp',
+
+    CONSTRAINT fk_webauthn_credentials_user FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
+
+CREATE TABLE webauthn_sessions (
+    id VARCHAR(36) PRIMARY KEY COMMENT 'Ceremony identifier handed to the client',
+    user_id BIGINT NULL DEFAULT NULL COMMENT 'User the ceremony was started for, empty for discoverable login',
+    purpose VARCHAR(20) NOT NULL COMMENT 'registration or login',
+    data JSON NOT NULL COMMENT 'Challenge and options of the ceremony',
+    expires_at TIMESTAMP NOT NULL COMMENT 'Ceremony expiry',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Record creation timestamp',
+
+    INDEX idx_webauthn_sessions_expires (expires_at),
+    CONSTRAINT fk_webauthn_sessions_user FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
@@ -0,0 +1,8 @@
+CREATE TABLE login_attempts (
+    attempt_key VARCHAR(320) PRIMARY KEY COMMENT 'Throttled subject, account:<email> or ip:<address>',
+    failures INT NOT NULL DEFAULT 0 COMMENT 'Consecutive failed logins within the failure window',
+    last_failed_at TIMESTAMP NOT NULL COMMENT 'Timestamp of the latest failed login',
+    locked_until TIMESTAMP NULL DEFAULT NULL COMMENT 'Logins are refused until this time',
+
+    INDEX idx_login_attempts_last_failed (last_failed_at)
+);
@@ -0,0 +1,9 @@
+ALTER TABLE api_key_permissions DROP FOREIGN KEY fk_api_key_permissions_permission;
+ALTER TABLE api_key_permissions DROP FOREIGN KEY fk_api_key_permissions_key;
+DROP TABLE IF EXISTS api_key_permissions;
+
+ALTER TABLE api_keys DROP FOREIGN KEY fk_api_keys_service_account;
+DROP TABLE IF EXISTS api_keys;
+
+ALTER TABLE service_accounts DROP FOREIGN KEY fk_service_accounts_created_by;
+DROP TABLE IF EXISTS service_accounts;
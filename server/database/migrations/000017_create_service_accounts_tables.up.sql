@@ -0,0 +1,43 @@
+CREATE TABLE service_accounts (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT 'Unique identifier for the service account',
+    name VARCHAR(100) NOT NULL UNIQUE COMMENT 'Name of the machine client',
+    description TEXT COMMENT 'What the service account is used for',
+    created_by BIGINT NULL DEFAULT NULL COMMENT 'User who created the service account',
+    disabled_at TIMESTAMP NULL DEFAULT NULL COMMENT 'Keys of a disabled account are rejected',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Record creation timestamp',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT 'Record last update timestamp',
+
+    CONSTRAINT fk_service_accounts_created_by FOREIGN KEY (created_by) REFERENCES users(id)
+        ON DELETE SET NULL
+        ON UPDATE CASCADE
+);
+
+CREATE TABLE api_keys (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT 'Unique identifier for the API key',
+    service_account_id BIGINT NOT NULL COMMENT 'Reference to the owning service account',
+    name VARCHAR(100) NOT NULL COMMENT 'Label of the key',
+    prefix VARCHAR(16) NOT NULL UNIQUE COMMENT 'Public part of the key used for lookup',
+    key_hash CHAR(64) NOT NULL COMMENT 'SHA-256 of the full key',
+    expires_at TIMESTAMP NULL DEFAULT NULL COMMENT 'Optional key expiry',
+    last_used_at TIMESTAMP NULL DEFAULT NULL COMMENT 'Timestamp of the last authenticated request',
+    revoked_at TIMESTAMP NULL DEFAULT NULL COMMENT 'Timestamp when the key was revoked',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Record creation timestamp',
+
+    INDEX idx_api_keys_service_account (service_account_id),
+    CONSTRAINT fk_api_keys_service_account FOREIGN KEY (service_account_id) REFERENCES service_accounts(id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
+
+CREATE TABLE api_key_permissions (
+    api_key_id BIGINT NOT NULL COMMENT 'Reference to the API key',
+    permission_id INT NOT NULL COMMENT 'Permission granted to the key',
+
+    PRIMARY KEY (api_key_id, permission_id),
+    CONSTRAINT fk_api_key_permissions_key FOREIGN KEY (api_key_id) REFERENCES api_keys(id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE,
+    CONSTRAINT fk_api_key_permissions_permission FOREIGN KEY (permission_id) REFERENCES permissions(id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
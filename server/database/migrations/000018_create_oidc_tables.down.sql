@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS oidc_login_states;
+
+ALTER TABLE user_identities DROP FOREIGN KEY fk_user_identities_user;
+DROP TABLE IF EXISTS user_identities;
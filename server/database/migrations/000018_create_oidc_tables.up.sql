@@ -0,0 +1,25 @@
+CREATE TABLE user_identities (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT 'Unique identifier for the identity',
+    user_id BIGINT NOT NULL COMMENT 'Reference to the user',
+    provider VARCHAR(50) NOT NULL COMMENT 'Name of the configured OIDC provider',
+    subject VARCHAR(255) NOT NULL COMMENT 'Subject (sub) assigned by the identity provider',
+    email VARCHAR(255) COMMENT 'Email reported by the provider at the last login',
+    last_login_at TIMESTAMP NULL DEFAULT NULL COMMENT 'Timestamp of the last login through the provider',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Record creation timestamp',
+
+    UNIQUE KEY uq_user_identities_provider_subject (provider, subject),
+    CONSTRAINT fk_user_identities_user FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
+
+CREATE TABLE oidc_login_states (
+    state_hash CHAR(64) PRIMARY KEY COMMENT 'SHA-256 of the state parameter sent to the provider',
+    provider VARCHAR(50) NOT NULL COMMENT 'Provider the login was started with',
+    code_verifier VARCHAR(128) NOT NULL COMMENT 'PKCE code verifier',
+    nonce VARCHAR(64) NOT NULL COMMENT 'Nonce expected in the ID token',
+    expires_at TIMESTAMP NOT NULL COMMENT 'Login attempt expiry',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Record creation timestamp',
+
+    INDEX idx_oidc_login_states_expires (expires_at)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE token_revocations DROP COLUMN session_id;
+
+ALTER TABLE user_sessions DROP FOREIGN KEY fk_user_sessions_user;
+DROP TABLE IF EXISTS user_sessions;
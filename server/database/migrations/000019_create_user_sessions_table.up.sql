@@ -0,0 +1,18 @@
+CREATE TABLE user_sessions (
+    id VARCHAR(36) PRIMARY KEY COMMENT 'Session identifier, equal to the refresh token family',
+    user_id BIGINT NOT NULL COMMENT 'Reference to the user',
+    device VARCHAR(255) COMMENT 'Device name sent by the client at login',
+    user_agent VARCHAR(512) COMMENT 'User agent of the last request that used the session',
+    ip_address VARCHAR(45) COMMENT 'Client IP of the last request that used the session',
+    last_seen_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT 'Timestamp of the last login or token refresh',
+    revoked_at TIMESTAMP NULL DEFAULT NULL COMMENT 'Timestamp when the session was signed out',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Record creation timestamp',
+
+    INDEX idx_user_sessions_user (user_id, revoked_at),
+    CONSTRAINT fk_user_sessions_user FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
+
+ALTER TABLE token_revocations
+    ADD COLUMN session_id VARCHAR(36) DEFAULT NULL COMMENT 'Session whose access tokens are revoked' AFTER user_id;
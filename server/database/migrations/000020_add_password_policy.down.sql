@@ -0,0 +1,6 @@
+ALTER TABLE password_history DROP FOREIGN KEY fk_password_history_user;
+DROP TABLE IF EXISTS password_history;
+
+ALTER TABLE roles DROP COLUMN password_max_age_days;
+
+ALTER TABLE users DROP COLUMN password_changed_at;
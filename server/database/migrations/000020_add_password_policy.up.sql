@@ -0,0 +1,19 @@
+ALTER TABLE users
+    ADD COLUMN password_changed_at TIMESTAMP NULL DEFAULT NULL COMMENT 'Timestamp when the password was last set' AFTER hash_password;
+
+UPDATE users SET password_changed_at = created_at;
+
+ALTER TABLE roles
+    ADD COLUMN password_max_age_days INT NULL DEFAULT NULL COMMENT 'Members of this role must change their password after this many days' AFTER require_mfa;
+
+CREATE TABLE password_history (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT 'Unique identifier for the history entry',
+    user_id BIGINT NOT NULL COMMENT 'Reference to the user',
+    hash_password VARCHAR(255) NOT NULL COMMENT 'Hash of a previous password',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Timestamp when the password was replaced',
+
+    INDEX idx_password_history_user (user_id, id),
+    CONSTRAINT fk_password_history_user FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
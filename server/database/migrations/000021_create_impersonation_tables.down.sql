@@ -0,0 +1,6 @@
+ALTER TABLE impersonation_requests DROP FOREIGN KEY fk_impersonation_requests_session;
+DROP TABLE IF EXISTS impersonation_requests;
+
+ALTER TABLE impersonation_sessions DROP FOREIGN KEY fk_impersonation_sessions_subject;
+ALTER TABLE impersonation_sessions DROP FOREIGN KEY fk_impersonation_sessions_actor;
+DROP TABLE IF EXISTS impersonation_sessions;
@@ -0,0 +1,35 @@
+CREATE TABLE impersonation_sessions (
+    id VARCHAR(36) PRIMARY KEY COMMENT 'Session identifier, carried as sid in the impersonation token',
+    actor_id BIGINT NOT NULL COMMENT 'Super Admin who impersonates',
+    subject_id BIGINT NOT NULL COMMENT 'User being impersonated',
+    reason VARCHAR(500) NOT NULL COMMENT 'Why the actor needed to impersonate, e.g. a ticket reference',
+    ip_address VARCHAR(45) COMMENT 'Client IP of the actor when starting',
+    user_agent VARCHAR(512) COMMENT 'User agent of the actor when starting',
+    expires_at TIMESTAMP NOT NULL COMMENT 'Expiry of the impersonation token',
+    ended_at TIMESTAMP NULL DEFAULT NULL COMMENT 'Timestamp when the actor stopped impersonating',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Timestamp when impersonation started',
+
+    INDEX idx_impersonation_sessions_actor (actor_id),
+    INDEX idx_impersonation_sessions_subject (subject_id),
+    CONSTRAINT fk_impersonation_sessions_actor FOREIGN KEY (actor_id) REFERENCES users(id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE,
+    CONSTRAINT fk_impersonation_sessions_subject FOREIGN KEY (subject_id) REFERENCES users(id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
+
+CREATE TABLE impersonation_requests (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT COMMENT 'Unique identifier for the request record',
+    session_id VARCHAR(36) NOT NULL COMMENT 'Impersonation session the request was made in',
+    method VARCHAR(10) NOT NULL COMMENT 'HTTP method',
+    path VARCHAR(2048) NOT NULL COMMENT 'Request path with query string',
+    status SMALLINT NOT NULL COMMENT 'HTTP status of the response',
+    ip_address VARCHAR(45) COMMENT 'Client IP of the request',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Timestamp of the request',
+
+    INDEX idx_impersonation_requests_session (session_id, id),
+    CONSTRAINT fk_impersonation_requests_session FOREIGN KEY (session_id) REFERENCES impersonation_sessions(id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
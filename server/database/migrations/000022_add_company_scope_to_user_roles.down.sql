@@ -0,0 +1,14 @@
+-- Company scoped assignments have no equivalent without the scope
+DELETE FROM user_roles WHERE company_id IS NOT NULL;
+
+ALTER TABLE user_roles DROP FOREIGN KEY fk_user_roles_company;
+
+ALTER TABLE user_roles
+    DROP INDEX uq_user_roles_scope,
+    DROP COLUMN id,
+    ADD CONSTRAINT pk_user_roles PRIMARY KEY (user_id, role_id);
+
+ALTER TABLE user_roles
+    DROP COLUMN scope_company_id,
+    DROP COLUMN include_subsidiaries,
+    DROP COLUMN company_id;
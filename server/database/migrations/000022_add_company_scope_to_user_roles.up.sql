@@ -0,0 +1,15 @@
+ALTER TABLE user_roles
+    ADD COLUMN company_id BIGINT NULL DEFAULT NULL COMMENT 'Company the role applies to, NULL for every company' AFTER role_id,
+    ADD COLUMN include_subsidiaries BOOLEAN NOT NULL DEFAULT FALSE COMMENT 'Whether the role also applies to the subsidiaries of the company' AFTER company_id;
+
+-- The same role may now be held in several companies, so the assignment gets
+-- its own key. scope_company_id makes global assignments unique as well, since
+-- a UNIQUE index allows repeated NULLs
+ALTER TABLE user_roles
+    ADD COLUMN scope_company_id BIGINT AS (IFNULL(company_id, 0)) STORED COMMENT 'company_id with 0 for global assignments' AFTER include_subsidiaries,
+    DROP PRIMARY KEY,
+    ADD COLUMN id BIGINT AUTO_INCREMENT PRIMARY KEY COMMENT 'Unique identifier for the assignment' FIRST,
+    ADD CONSTRAINT uq_user_roles_scope UNIQUE (user_id, role_id, scope_company_id),
+    ADD CONSTRAINT fk_user_roles_company FOREIGN KEY (company_id) REFERENCES companies(id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE;
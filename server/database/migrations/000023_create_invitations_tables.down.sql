@@ -0,0 +1,9 @@
+ALTER TABLE invitation_roles DROP FOREIGN KEY fk_invitation_roles_role;
+ALTER TABLE invitation_roles DROP FOREIGN KEY fk_invitation_roles_invitation;
+DROP TABLE IF EXISTS invitation_roles;
+
+ALTER TABLE invitations DROP FOREIGN KEY fk_invitations_user;
+ALTER TABLE invitations DROP FOREIGN KEY fk_invitations_invited_by;
+ALTER TABLE invitations DROP FOREIGN KEY fk_invitations_position;
+ALTER TABLE invitations DROP FOREIGN KEY fk_invitations_company;
+DROP TABLE IF EXISTS invitations;
@@ -0,0 +1,40 @@
+CREATE TABLE invitations (
+    id VARCHAR(36) PRIMARY KEY COMMENT 'Invitation identifier, carried in the signed invitation link',
+    email VARCHAR(100) NOT NULL COMMENT 'Address the invitation was sent to, the email of the new account',
+    full_name VARCHAR(100) COMMENT 'Suggested name of the new user',
+    company_id BIGINT NOT NULL COMMENT 'Company the new user joins; the roles are assigned in it',
+    position_id BIGINT COMMENT 'Position the new user takes in the company',
+    invited_by BIGINT COMMENT 'User who sent the invitation',
+    user_id BIGINT COMMENT 'Account created by accepting the invitation',
+    expires_at TIMESTAMP NOT NULL COMMENT 'Expiry of the invitation link',
+    accepted_at TIMESTAMP NULL DEFAULT NULL COMMENT 'Timestamp when the invitation was accepted',
+    revoked_at TIMESTAMP NULL DEFAULT NULL COMMENT 'Timestamp when the invitation was withdrawn',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Timestamp when the invitation was sent',
+
+    INDEX idx_invitations_email (email),
+    CONSTRAINT fk_invitations_company FOREIGN KEY (company_id) REFERENCES companies(id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE,
+    CONSTRAINT fk_invitations_position FOREIGN KEY (position_id) REFERENCES positions(id)
+        ON DELETE SET NULL
+        ON UPDATE CASCADE,
+    CONSTRAINT fk_invitations_invited_by FOREIGN KEY (invited_by) REFERENCES users(id)
+        ON DELETE SET NULL
+        ON UPDATE CASCADE,
+    CONSTRAINT fk_invitations_user FOREIGN KEY (user_id) REFERENCES users(id)
+        ON DELETE SET NULL
+        ON UPDATE CASCADE
+);
+
+CREATE TABLE invitation_roles (
+    invitation_id VARCHAR(36) NOT NULL COMMENT 'Invitation granting the role',
+    role_id INT NOT NULL COMMENT 'Role the new user gets in the company of the invitation',
+
+    CONSTRAINT pk_invitation_roles PRIMARY KEY (invitation_id, role_id),
+    CONSTRAINT fk_invitation_roles_invitation FOREIGN KEY (invitation_id) REFERENCES invitations(id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE,
+    CONSTRAINT fk_invitation_roles_role FOREIGN KEY (role_id) REFERENCES roles(id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
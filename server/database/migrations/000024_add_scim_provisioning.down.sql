@@ -0,0 +1,3 @@
+ALTER TABLE users DROP INDEX uq_users_external_id;
+ALTER TABLE users DROP COLUMN deactivated_at;
+ALTER TABLE users DROP COLUMN external_id;
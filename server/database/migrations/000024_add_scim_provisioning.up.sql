@@ -0,0 +1,4 @@
+ALTER TABLE users
+    ADD COLUMN external_id VARCHAR(255) NULL DEFAULT NULL COMMENT 'Identifier of the user in the identity provider that provisions it over SCIM' AFTER id,
+    ADD COLUMN deactivated_at TIMESTAMP NULL DEFAULT NULL COMMENT 'Timestamp when the account was deactivated; deactivated users cannot sign in' AFTER avatar,
+    ADD UNIQUE INDEX uq_users_external_id (external_id);
@@ -0,0 +1,3 @@
+ALTER TABLE role_parents DROP FOREIGN KEY fk_role_parents_parent;
+ALTER TABLE role_parents DROP FOREIGN KEY fk_role_parents_role;
+DROP TABLE IF EXISTS role_parents;
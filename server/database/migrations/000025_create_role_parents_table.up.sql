@@ -0,0 +1,30 @@
+CREATE TABLE role_parents (
+    role_id INT NOT NULL COMMENT 'Reference to roles.id, the inheriting role',
+    parent_id INT NOT NULL COMMENT 'Reference to roles.id, the role whose permissions are inherited',
+
+    CONSTRAINT pk_role_parents PRIMARY KEY (role_id, parent_id),
+    CONSTRAINT fk_role_parents_role FOREIGN KEY (role_id) REFERENCES roles(id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE,
+    CONSTRAINT fk_role_parents_parent FOREIGN KEY (parent_id) REFERENCES roles(id)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+) COMMENT='Roles inherit every permission of their parent roles, transitively';
+
+-- Default hierarchy of the built-in roles on databases that were already
+-- seeded, found by name since their ids depend on how they were created.
+-- Their existing grants are kept, so no permission changes
+INSERT INTO role_parents (role_id, parent_id)
+SELECT child.id, parent.id
+FROM (
+    SELECT 'Admin' AS role_name, 'Employee' AS parent_name
+    UNION ALL SELECT 'HR Manager', 'HR Staff'
+    UNION ALL SELECT 'HR Staff', 'Employee'
+    UNION ALL SELECT 'Finance Manager', 'Accountant'
+    UNION ALL SELECT 'Accountant', 'Employee'
+    UNION ALL SELECT 'Sales Manager', 'Sales Staff'
+    UNION ALL SELECT 'Sales Staff', 'Employee'
+    UNION ALL SELECT 'Product Manager', 'Employee'
+) AS defaults
+INNER JOIN roles AS child ON child.name = defaults.role_name
+INNER JOIN roles AS parent ON parent.name = defaults.parent_name;
@@ -0,0 +1,14 @@
+CREATE TABLE policies (
+    id INT AUTO_INCREMENT PRIMARY KEY COMMENT 'Policy identifier',
+    name VARCHAR(100) NOT NULL COMMENT 'Unique name, reported by the decisions the policy makes',
+    description VARCHAR(255) COMMENT 'What the policy is for',
+    effect ENUM('allow', 'deny') NOT NULL COMMENT 'Effect when every condition holds; deny wins over allow',
+    resource VARCHAR(50) NOT NULL COMMENT 'Resource type the policy applies to (user, contract) or * for all',
+    actions JSON NOT NULL COMMENT 'Actions the policy applies to (read, update, delete) or * for all',
+    conditions JSON NOT NULL COMMENT 'Conditions on subject and resource attributes that must all hold',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE COMMENT 'Disabled policies are kept but not evaluated',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP COMMENT 'Timestamp when the policy was created',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT 'Timestamp when the policy was last updated',
+
+    CONSTRAINT uq_policies_name UNIQUE (name)
+) COMMENT='Attribute-based access rules evaluated before the role permissions';
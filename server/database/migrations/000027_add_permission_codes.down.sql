@@ -0,0 +1,2 @@
+ALTER TABLE permissions DROP INDEX uq_permissions_code;
+ALTER TABLE permissions DROP COLUMN code;
@@ -0,0 +1,42 @@
+ALTER TABLE permissions
+    ADD COLUMN code VARCHAR(100) NULL COMMENT 'Stable machine code, e.g. contract.create; NULL for permissions created without one' AFTER id,
+    ADD CONSTRAINT uq_permissions_code UNIQUE (code);
+
+-- Codes of the built-in permissions on databases that were already seeded
+UPDATE permissions
+INNER JOIN (
+    SELECT 1 AS id, 'user.create' AS code
+    UNION ALL SELECT 2, 'user.read'
+    UNION ALL SELECT 3, 'user.update'
+    UNION ALL SELECT 4, 'user.delete'
+    UNION ALL SELECT 5, 'role.manage'
+    UNION ALL SELECT 6, 'report.view_own'
+    UNION ALL SELECT 7, 'report.view_subordinate'
+    UNION ALL SELECT 8, 'report.view_all'
+    UNION ALL SELECT 9, 'report.create'
+    UNION ALL SELECT 10, 'report.delete'
+    UNION ALL SELECT 11, 'finance.manage'
+    UNION ALL SELECT 12, 'inventory.manage'
+    UNION ALL SELECT 13, 'position.create'
+    UNION ALL SELECT 14, 'position.update'
+    UNION ALL SELECT 15, 'position.delete'
+    UNION ALL SELECT 16, 'department.create'
+    UNION ALL SELECT 17, 'department.update'
+    UNION ALL SELECT 18, 'department.delete'
+    UNION ALL SELECT 19, 'company.create'
+    UNION ALL SELECT 20, 'company.update'
+    UNION ALL SELECT 21, 'company.delete'
+    UNION ALL SELECT 22, 'contract.create'
+    UNION ALL SELECT 23, 'contract.update'
+    UNION ALL SELECT 24, 'contract.delete'
+    UNION ALL SELECT 25, 'request.approve'
+    UNION ALL SELECT 26, 'request.create'
+    UNION ALL SELECT 27, 'request.update'
+    UNION ALL SELECT 28, 'request.delete'
+    UNION ALL SELECT 29, 'company.read'
+    UNION ALL SELECT 30, 'position.read'
+    UNION ALL SELECT 31, 'contract.read'
+    UNION ALL SELECT 32, 'service_account.manage'
+    UNION ALL SELECT 33, 'user.provision'
+) AS codes ON codes.id = permissions.id
+SET permissions.code = codes.code;
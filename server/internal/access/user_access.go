@@ -0,0 +1,114 @@
+// Package access holds the rules that decide who may act on which user. The
+// rules work on plain values, so they can be exercised without a request or
+// a database.
+package access
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/vlahanam/company-management/internal/models"
+)
+
+type Action string
+
+const (
+	ActionRead   Action = "read"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+var (
+	ErrUserAccessDenied  = errors.New("you are not allowed to access this user")
+	ErrFieldNotEditable  = errors.New("only HR or an administrator can change this field")
+	ErrDeleteOwnAccount  = errors.New("you cannot delete your own account")
+	ErrUnknownUserAction = errors.New("unknown user action")
+	ErrTargetOutranks    = errors.New("the user holds roles you cannot assign")
+)
+
+// UserAdminPermissions make their holder a user administrator, who acts on
+// every user within its permissions. Read User is not one of them: every
+// employee holds it.
+var UserAdminPermissions = []int64{
+	models.PermissionCreateUser,
+	models.PermissionUpdateUser,
+	models.PermissionDeleteUser,
+}
+
+// SelfEditableUserFields are the fields users may change on their own
+// account without an administrator.
+var SelfEditableUserFields = []string{"full_name", "date_of_birth", "gender", "phone_number", "avatar"}
+
+// actionPermissions is the permission an administrator or API key needs for
+// each action.
+var actionPermissions = map[Action]int64{
+	ActionRead:   models.PermissionReadUser,
+	ActionUpdate: models.PermissionUpdateUser,
+	ActionDelete: models.PermissionDeleteUser,
+}
+
+// Actor is the caller as seen from the target user: the permissions it holds
+// in the companies the target works for, through its roles and the roles they
+// inherit, whether it manages the target, and whether it could assign every
+// role the target holds.
+type Actor struct {
+	UserID               uint64
+	APIKey               bool
+	PermissionIDs        []int64
+	ManagesTarget        bool
+	CanAssignTargetRoles bool
+}
+
+// CheckUserAccess decides whether actor may take the action on the user
+// targetID. fields lists the fields an update changes.
+//
+//   - User administrators act on everyone, within their permissions. They
+//     only change or delete users whose roles they could assign, so that no
+//     one takes over a more privileged account.
+//   - API keys act on everyone, within their scopes and by the same rule.
+//   - Users read their own account and update its self-editable fields.
+//   - Managers read the users working under them.
+func CheckUserAccess(actor Actor, targetID uint64, action Action, fields ...string) error {
+	permission, ok := actionPermissions[action]
+	if !ok {
+		return ErrUnknownUserAction
+	}
+
+	self := !actor.APIKey && actor.UserID == targetID
+	if self && action == ActionDelete {
+		return ErrDeleteOwnAccount
+	}
+
+	if slices.Contains(actor.PermissionIDs, permission) && (actor.APIKey || isUserAdmin(actor.PermissionIDs)) {
+		if action != ActionRead && !self && !actor.CanAssignTargetRoles {
+			return ErrTargetOutranks
+		}
+		return nil
+	}
+
+	switch {
+	case self && action == ActionRead:
+		return nil
+	case self && action == ActionUpdate:
+		for _, f := range fields {
+			if !slices.Contains(SelfEditableUserFields, f) {
+				return ErrFieldNotEditable
+			}
+		}
+		return nil
+	case actor.ManagesTarget && action == ActionRead:
+		return nil
+	}
+
+	return ErrUserAccessDenied
+}
+
+func isUserAdmin(permissionIDs []int64) bool {
+	for _, id := range permissionIDs {
+		if slices.Contains(UserAdminPermissions, id) {
+			return true
+		}
+	}
+
+	return false
+}
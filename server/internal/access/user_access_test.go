@@ -0,0 +1,76 @@
+package access
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/vlahanam/company-management/internal/models"
+)
+
+func TestCheckUserAccess(t *testing.T) {
+	const (
+		self   uint64 = 1
+		report uint64 = 2
+		other  uint64 = 3
+	)
+
+	employee := []int64{models.PermissionReadUser, models.PermissionViewOwnReport}
+	// HR Staff inherits Read User from Employee
+	hrStaff := append([]int64{models.PermissionUpdateUser}, employee...)
+	// A custom role granted Delete User, without inheriting anything
+	offboarding := []int64{models.PermissionDeleteUser}
+
+	tests := []struct {
+		name   string
+		actor  Actor
+		target uint64
+		action Action
+		fields []string
+		want   error
+	}{
+		{"self read", Actor{UserID: self, PermissionIDs: employee}, self, ActionRead, nil, nil},
+		{"self read without permissions", Actor{UserID: self}, self, ActionRead, nil, nil},
+		{"self update editable fields", Actor{UserID: self, PermissionIDs: employee}, self, ActionUpdate, []string{"full_name", "phone_number"}, nil},
+		{"self update email", Actor{UserID: self, PermissionIDs: employee}, self, ActionUpdate, []string{"full_name", "email"}, ErrFieldNotEditable},
+		{"self delete", Actor{UserID: self, PermissionIDs: offboarding}, self, ActionDelete, nil, ErrDeleteOwnAccount},
+
+		{"employee reads colleague", Actor{UserID: self, PermissionIDs: employee}, other, ActionRead, nil, ErrUserAccessDenied},
+		{"employee updates colleague", Actor{UserID: self, PermissionIDs: employee}, other, ActionUpdate, []string{"full_name"}, ErrUserAccessDenied},
+
+		{"manager reads report", Actor{UserID: self, PermissionIDs: employee, ManagesTarget: true}, report, ActionRead, nil, nil},
+		{"manager updates report", Actor{UserID: self, PermissionIDs: employee, ManagesTarget: true}, report, ActionUpdate, []string{"full_name"}, ErrUserAccessDenied},
+		{"manager deletes report", Actor{UserID: self, PermissionIDs: employee, ManagesTarget: true}, report, ActionDelete, nil, ErrUserAccessDenied},
+
+		{"hr reads anyone", Actor{UserID: self, PermissionIDs: hrStaff}, other, ActionRead, nil, nil},
+		{"hr updates any field", Actor{UserID: self, PermissionIDs: hrStaff, CanAssignTargetRoles: true}, other, ActionUpdate, []string{"email", "id_card_number"}, nil},
+		{"hr updates own email", Actor{UserID: self, PermissionIDs: hrStaff}, self, ActionUpdate, []string{"email"}, nil},
+		{"hr without delete permission", Actor{UserID: self, PermissionIDs: hrStaff}, other, ActionDelete, nil, ErrUserAccessDenied},
+		{"custom role deletes", Actor{UserID: self, PermissionIDs: offboarding, CanAssignTargetRoles: true}, other, ActionDelete, nil, nil},
+		{"custom role without read permission", Actor{UserID: self, PermissionIDs: offboarding}, other, ActionRead, nil, ErrUserAccessDenied},
+
+		// The permissions are those held in the target's companies, so an HR
+		// role assigned in another company leaves none
+		{"hr of another company reads", Actor{UserID: self}, other, ActionRead, nil, ErrUserAccessDenied},
+		{"hr of another company updates", Actor{UserID: self}, other, ActionUpdate, []string{"full_name"}, ErrUserAccessDenied},
+
+		{"api key within scope", Actor{APIKey: true, PermissionIDs: []int64{models.PermissionReadUser}}, other, ActionRead, nil, nil},
+		// The target holds a role the actor could not assign, such as Super
+		// Admin
+		{"hr reads privileged user", Actor{UserID: self, PermissionIDs: hrStaff}, other, ActionRead, nil, nil},
+		{"hr updates privileged user", Actor{UserID: self, PermissionIDs: hrStaff}, other, ActionUpdate, []string{"email"}, ErrTargetOutranks},
+		{"custom role deletes privileged user", Actor{UserID: self, PermissionIDs: offboarding}, other, ActionDelete, nil, ErrTargetOutranks},
+		{"api key updates privileged user", Actor{APIKey: true, PermissionIDs: []int64{models.PermissionUpdateUser}}, other, ActionUpdate, []string{"email"}, ErrTargetOutranks},
+
+		{"api key outside scope", Actor{APIKey: true, PermissionIDs: []int64{models.PermissionReadUser}}, other, ActionUpdate, []string{"full_name"}, ErrUserAccessDenied},
+
+		{"unknown action", Actor{UserID: self, PermissionIDs: hrStaff}, other, "approve", nil, ErrUnknownUserAction},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckUserAccess(tt.actor, tt.target, tt.action, tt.fields...); !errors.Is(err, tt.want) {
+				t.Errorf("CheckUserAccess() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
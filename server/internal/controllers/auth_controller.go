@@ -1,6 +1,9 @@
 package controllers
 
 import (
+	"errors"
+	"strconv"
+
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 
@@ -8,9 +11,10 @@ import (
 	"github.com/vlahanam/company-management/internal/repositories"
 	"github.com/vlahanam/company-management/internal/requests"
 	"github.com/vlahanam/company-management/internal/services"
+	"github.com/vlahanam/company-management/utils"
 )
 
-func LoginHandler(db *gorm.DB, accessSecret, refreshSecret string) fiber.Handler {
+func LoginHandler(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var lr requests.LoginRequest
 
@@ -22,20 +26,30 @@ func LoginHandler(db *gorm.DB, accessSecret, refreshSecret string) fiber.Handler
 			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
 		}
 
+		if lr.Device == "" {
+			lr.Device = c.Get(fiber.HeaderUserAgent)
+		}
+		lr.IP = c.IP()
+
 		rp := repositories.NewMySQLStorage(db)
 		es := services.NewUserService(rp)
-		as := services.NewAuthService(es, accessSecret, refreshSecret)
+		as := services.NewAuthService(es, rp, opts)
 
 		auth, err := as.Login(c.UserContext(), &lr)
 		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(err)
+			var throttled *services.LoginThrottledError
+			if errors.As(err, &throttled) {
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(throttled.RetryAfterSeconds()))
+				return c.Status(fiber.StatusTooManyRequests).JSON(err)
+			}
+			return c.Status(fiber.StatusUnauthorized).JSON(err)
 		}
 
 		return c.Status(fiber.StatusOK).JSON(common.LoginSuccessful.WrapData(auth))
 	}
 }
 
-func RefreshHandler(db *gorm.DB, accessSecret, refreshSecret string) fiber.Handler {
+func RefreshHandler(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var rr requests.RefreshRequest
 
@@ -49,7 +63,7 @@ func RefreshHandler(db *gorm.DB, accessSecret, refreshSecret string) fiber.Handl
 
 		rp := repositories.NewMySQLStorage(db)
 		es := services.NewUserService(rp)
-		as := services.NewAuthService(es, accessSecret, refreshSecret)
+		as := services.NewAuthService(es, rp, opts)
 
 		auth, err := as.RefreshAccessToken(c.UserContext(), rr.RefreshToken)
 		if err != nil {
@@ -60,7 +74,120 @@ func RefreshHandler(db *gorm.DB, accessSecret, refreshSecret string) fiber.Handl
 	}
 }
 
-func RegisterHandler(db *gorm.DB) fiber.Handler {
+func LogoutHandler(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rr requests.RefreshRequest
+
+		if err := c.BodyParser(&rr); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rr.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		if err := as.Logout(c.UserContext(), rr.RefreshToken); err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.LogoutSuccessful)
+	}
+}
+
+func LogoutAllHandler(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		if err := as.LogoutAll(c.UserContext(), utils.GetUserUID(c)); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.LogoutSuccessful)
+	}
+}
+
+func ForgotPasswordHandler(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rq requests.ForgotPasswordRequest
+
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		if err := as.ForgotPassword(c.UserContext(), &rq); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.PasswordResetRequested)
+	}
+}
+
+func ResetPasswordHandler(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rq requests.ResetPasswordRequest
+
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		if err := as.ResetPassword(c.UserContext(), &rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.PasswordResetSuccessful)
+	}
+}
+
+// ChangeExpiredPasswordHandler finishes a login that returned
+// password_change_required.
+func ChangeExpiredPasswordHandler(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rq requests.ChangeExpiredPasswordRequest
+
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		auth, err := as.ChangeExpiredPassword(c.UserContext(), &rq)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.LoginSuccessful.WrapData(auth))
+	}
+}
+
+func RegisterHandler(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var rr requests.RegisterRequest
 
@@ -73,12 +200,70 @@ func RegisterHandler(db *gorm.DB) fiber.Handler {
 		}
 
 		rp := repositories.NewMySQLStorage(db)
-		sr := services.NewUserService(rp)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
 
-		if err := sr.CreateUser(c.UserContext(), &rr); err != nil {
+		if err := as.Register(c.UserContext(), &rr); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(err)
 		}
 
 		return c.Status(fiber.StatusOK).JSON(common.RegisterSuccessful)
 	}
 }
+
+func VerifyEmailHandler(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rq requests.VerifyEmailRequest
+
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		if err := as.VerifyEmail(c.UserContext(), &rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.EmailVerified)
+	}
+}
+
+func ResendVerificationHandler(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rq requests.ResendVerificationRequest
+
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		if err := as.ResendVerification(c.UserContext(), &rq); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.VerificationEmailSent)
+	}
+}
+
+// JWKSHandler serves the public access token keys as a plain JWK set, the
+// format JWT libraries expect, instead of the usual response envelope.
+func JWKSHandler(keys *utils.KeySet) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+		return c.Status(fiber.StatusOK).JSON(keys.JWKS())
+	}
+}
@@ -0,0 +1,156 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/repositories"
+	"github.com/vlahanam/company-management/internal/services"
+	"github.com/vlahanam/company-management/utils"
+)
+
+// The resolvers below tell utils.CheckPermissionIn which company a request
+// is about, so that roles assigned in that company apply to it.
+
+var errNoCompany = errors.New("request does not target a company")
+
+// CompanyFromParam resolves the company from a base58 company id route
+// parameter.
+func CompanyFromParam(name string) utils.CompanyResolver {
+	return func(c *fiber.Ctx) ([]uint64, error) {
+		uid, err := common.FromBase58(c.Params(name))
+		if err != nil {
+			return nil, err
+		}
+
+		return []uint64{uint64(uid.GetLocalID())}, nil
+	}
+}
+
+// CompanyFromNumericParam resolves the company from a numeric company id
+// route parameter.
+func CompanyFromNumericParam(name string) utils.CompanyResolver {
+	return func(c *fiber.Ctx) ([]uint64, error) {
+		id, err := strconv.ParseUint(c.Params(name), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		return []uint64{id}, nil
+	}
+}
+
+// CompanyFromQuery resolves the company from a numeric company id query
+// parameter. Without it the request is about every company.
+func CompanyFromQuery(name string) utils.CompanyResolver {
+	return func(c *fiber.Ctx) ([]uint64, error) {
+		if c.Query(name) == "" {
+			return nil, nil
+		}
+
+		id, err := strconv.ParseUint(c.Query(name), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		return []uint64{id}, nil
+	}
+}
+
+// CompanyFromBody resolves the company from a numeric company id field of the
+// JSON body. A missing field means the request is about every company, e.g. a
+// company created without a parent.
+func CompanyFromBody(field string) utils.CompanyResolver {
+	return func(c *fiber.Ctx) ([]uint64, error) {
+		var body map[string]interface{}
+		if err := c.BodyParser(&body); err != nil {
+			return nil, err
+		}
+
+		value, ok := body[field].(float64)
+		if !ok || value <= 0 {
+			return nil, nil
+		}
+
+		return []uint64{uint64(value)}, nil
+	}
+}
+
+// CompanyOfPosition resolves the company of the position in the id route
+// parameter.
+func CompanyOfPosition(db *gorm.DB) utils.CompanyResolver {
+	return func(c *fiber.Ctx) ([]uint64, error) {
+		uid, err := common.FromBase58(c.Params("id"))
+		if err != nil {
+			return nil, err
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewPositionService(rp)
+
+		position, err := svc.FindByID(c.UserContext(), uint64(uid.GetLocalID()))
+		if err != nil {
+			return nil, err
+		}
+
+		return []uint64{position.CompanyID}, nil
+	}
+}
+
+// CompanyOfContract resolves the company of the contract in the id route
+// parameter.
+func CompanyOfContract(db *gorm.DB) utils.CompanyResolver {
+	return func(c *fiber.Ctx) ([]uint64, error) {
+		uid, err := common.FromBase58(c.Params("id"))
+		if err != nil {
+			return nil, err
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewContractService(rp)
+
+		contract, err := svc.FindByID(c.UserContext(), uint64(uid.GetLocalID()))
+		if err != nil {
+			return nil, err
+		}
+
+		return []uint64{contract.CompanyID}, nil
+	}
+}
+
+// CompanyOfInvitation resolves the company of the invitation in the id route
+// parameter.
+func CompanyOfInvitation(db *gorm.DB, opts *services.AuthOptions) utils.CompanyResolver {
+	return func(c *fiber.Ctx) ([]uint64, error) {
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		invitation, err := as.GetInvitation(c.UserContext(), c.Params("id"))
+		if err != nil {
+			return nil, err
+		}
+
+		return []uint64{invitation.CompanyID}, nil
+	}
+}
+
+// CompaniesOfUser resolves the companies the user in the id route parameter
+// works for. A role in any of them applies.
+func CompaniesOfUser(db *gorm.DB) utils.CompanyResolver {
+	return func(c *fiber.Ctx) ([]uint64, error) {
+		userID, ok := objectIDParam(c, "id", common.ObjectTypeUser)
+		if !ok {
+			return nil, errNoCompany
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+
+		return es.GetCompanyIDsByUserID(c.UserContext(), userID)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/vlahanam/company-management/internal/repositories"
 	"github.com/vlahanam/company-management/internal/requests"
 	"github.com/vlahanam/company-management/internal/services"
+	"github.com/vlahanam/company-management/utils"
 )
 
 func CreateContract(db *gorm.DB) fiber.Handler {
@@ -35,11 +36,12 @@ func CreateContract(db *gorm.DB) fiber.Handler {
 	}
 }
 
+// GetListContracts lists the contracts of the company the read permission
+// was checked in, or of every company when it is held in all of them.
 func GetListContracts(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		var rq requests.ListContractRequest
-
-		if err := c.QueryParser(&rq); err != nil {
+		rq, err := listContractRequest(c)
+		if err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorQueryParser)
 		}
 
@@ -59,6 +61,23 @@ func GetListContracts(db *gorm.DB) fiber.Handler {
 	}
 }
 
+// listContractRequest parses the list filters and limits them to the company
+// the caller was authorized in.
+func listContractRequest(c *fiber.Ctx) (requests.ListContractRequest, error) {
+	var rq requests.ListContractRequest
+
+	if err := c.QueryParser(&rq); err != nil {
+		return rq, err
+	}
+
+	rq.CompanyID = nil
+	if companyIDs := utils.GetPermissionCompanies(c); len(companyIDs) == 1 {
+		rq.CompanyID = &companyIDs[0]
+	}
+
+	return rq, nil
+}
+
 func GetContract(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		id := c.Params("id")
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/utils"
+)
+
+// companyPermissions grants Read Contract in the listed companies only, or in
+// every company when global.
+type companyPermissions struct {
+	companies []uint64
+	global    bool
+}
+
+func (p companyPermissions) GetPermissionIDsInCompanies(ctx context.Context, userUID string, companyIDs []uint64) ([]int64, error) {
+	if p.global {
+		return []int64{models.PermissionReadContract}, nil
+	}
+	for _, id := range companyIDs {
+		if slices.Contains(p.companies, id) {
+			return []int64{models.PermissionReadContract}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// The contract list is limited to the company the caller was authorized in,
+// whatever the query asks for.
+func TestListContractRequestScope(t *testing.T) {
+	tests := []struct {
+		name       string
+		perms      companyPermissions
+		query      string
+		wantStatus int
+		wantID     *uint64
+	}{
+		{"own company", companyPermissions{companies: []uint64{1}}, "?company_id=1", fiber.StatusOK, ptr(uint64(1))},
+		{"other company", companyPermissions{companies: []uint64{1}}, "?company_id=2", fiber.StatusForbidden, nil},
+		{"every company", companyPermissions{companies: []uint64{1}}, "", fiber.StatusForbidden, nil},
+		{"global in one company", companyPermissions{global: true}, "?company_id=2", fiber.StatusOK, ptr(uint64(2))},
+		{"global in every company", companyPermissions{global: true}, "?status=active", fiber.StatusOK, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/contracts",
+				func(c *fiber.Ctx) error {
+					c.Locals("userClaims", jwt.MapClaims{"user_id": "caller", "roles": []interface{}{}})
+					return c.Next()
+				},
+				utils.CheckPermissionIn(tt.perms, CompanyFromQuery("company_id"), models.PermissionReadContract),
+				func(c *fiber.Ctx) error {
+					rq, err := listContractRequest(c)
+					if err != nil {
+						return err
+					}
+					return c.JSON(rq.CompanyID)
+				},
+			)
+
+			resp, err := app.Test(httptest.NewRequest("GET", "/contracts"+tt.query, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != fiber.StatusOK {
+				return
+			}
+
+			var companyID *uint64
+			if err := json.NewDecoder(resp.Body).Decode(&companyID); err != nil {
+				t.Fatal(err)
+			}
+			if (companyID == nil) != (tt.wantID == nil) || (companyID != nil && *companyID != *tt.wantID) {
+				t.Errorf("company_id filter = %v, want %v", companyID, tt.wantID)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
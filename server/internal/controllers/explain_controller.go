@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/repositories"
+	"github.com/vlahanam/company-management/internal/requests"
+	"github.com/vlahanam/company-management/internal/services"
+)
+
+// ExplainUserPermission tells whether the user in the id route parameter
+// holds a permission, optionally on a resource, and which roles and scopes
+// granted it or failed to.
+func ExplainUserPermission(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := objectIDParam(c, "id", common.ObjectTypeUser)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
+		}
+
+		var rq requests.ExplainPermissionRequest
+		if err := c.QueryParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorQueryParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		sa := services.NewServiceAccountService(services.NewUserService(rp), rp)
+		svc := services.NewExplainService(rp, services.NewPolicyService(rp, sa), sa)
+
+		explanation, err := svc.ExplainPermission(c.UserContext(), userID, &rq)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.GetSuccessResponse("permission explanation").WrapData(explanation))
+	}
+}
@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/repositories"
+	"github.com/vlahanam/company-management/internal/requests"
+	"github.com/vlahanam/company-management/internal/services"
+	"github.com/vlahanam/company-management/utils"
+)
+
+func ImpersonateUser(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		subjectID, ok := objectIDParam(c, "id", common.ObjectTypeUser)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
+		}
+
+		var rq requests.ImpersonateRequest
+
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		token, err := as.StartImpersonation(c.UserContext(), utils.GetUserUID(c), subjectID, &rq)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(common.ImpersonationStarted.WrapData(token))
+	}
+}
+
+// StopImpersonating ends the impersonation session of the calling token.
+func StopImpersonating(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		if err := as.StopImpersonation(c.UserContext(), utils.GetSessionID(c), utils.GetActorUID(c)); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.ImpersonationStopped)
+	}
+}
+
+func GetListImpersonations(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rq requests.ListImpersonationRequest
+
+		if err := c.QueryParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorQueryParser)
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		sessions, err := as.ListImpersonations(c.UserContext(), &rq)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(err)
+		}
+
+		for _, session := range sessions {
+			session.Mask()
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.GetListSuccessResponse("impersonations").WrapData(sessions))
+	}
+}
+
+func GetImpersonation(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		session, err := as.GetImpersonation(c.UserContext(), c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(err)
+		}
+
+		session.Mask()
+		return c.Status(fiber.StatusOK).JSON(common.GetSuccessResponse("impersonation").WrapData(session))
+	}
+}
+
+// EndImpersonation lets a Super Admin stop any running impersonation.
+func EndImpersonation(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		if err := as.EndImpersonation(c.UserContext(), c.Params("id")); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.ImpersonationStopped)
+	}
+}
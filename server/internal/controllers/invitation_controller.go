@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/repositories"
+	"github.com/vlahanam/company-management/internal/requests"
+	"github.com/vlahanam/company-management/internal/services"
+	"github.com/vlahanam/company-management/utils"
+)
+
+func CreateInvitation(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rq requests.CreateInvitationRequest
+
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		invitation, err := as.CreateInvitation(c.UserContext(), utils.GetUserUID(c), &rq)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		invitation.Mask()
+		return c.Status(fiber.StatusCreated).JSON(common.InvitationSent.WrapData(invitation))
+	}
+}
+
+func GetListInvitations(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rq requests.ListInvitationRequest
+
+		if err := c.QueryParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorQueryParser)
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		invitations, err := as.ListInvitations(c.UserContext(), &rq)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(err)
+		}
+
+		for _, invitation := range invitations {
+			invitation.Mask()
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.GetListSuccessResponse("invitations").WrapData(invitations))
+	}
+}
+
+func GetInvitation(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		invitation, err := as.GetInvitation(c.UserContext(), c.Params("id"))
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(err)
+		}
+
+		invitation.Mask()
+		return c.Status(fiber.StatusOK).JSON(common.GetSuccessResponse("invitation").WrapData(invitation))
+	}
+}
+
+func RevokeInvitation(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		if err := as.RevokeInvitation(c.UserContext(), c.Params("id")); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.InvitationRevoked)
+	}
+}
+
+// AcceptInvitationHandler opens the account of an invited user.
+func AcceptInvitationHandler(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rq requests.AcceptInvitationRequest
+
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		user, err := as.AcceptInvitation(c.UserContext(), &rq)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		user.Mask(common.ObjectTypeUser)
+		return c.Status(fiber.StatusCreated).JSON(common.InvitationAccepted.WrapData(user))
+	}
+}
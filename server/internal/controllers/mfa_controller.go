@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/repositories"
+	"github.com/vlahanam/company-management/internal/requests"
+	"github.com/vlahanam/company-management/internal/services"
+	"github.com/vlahanam/company-management/utils"
+)
+
+func MFALoginHandler(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rq requests.MFALoginRequest
+
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		auth, err := as.CompleteMFALogin(c.UserContext(), &rq)
+		if err != nil {
+			var throttled *services.LoginThrottledError
+			if errors.As(err, &throttled) {
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(throttled.RetryAfterSeconds()))
+				return c.Status(fiber.StatusTooManyRequests).JSON(err)
+			}
+			return c.Status(fiber.StatusUnauthorized).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.LoginSuccessful.WrapData(auth))
+	}
+}
+
+func MFALoginSetupHandler(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rq requests.MFATokenRequest
+
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		setup, err := as.SetupTOTPForLogin(c.UserContext(), &rq)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.TOTPSetupStarted.WrapData(setup))
+	}
+}
+
+func MFALoginConfirmHandler(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rq requests.MFAEnrollRequest
+
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		enrollment, err := as.ConfirmTOTPForLogin(c.UserContext(), &rq)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.LoginSuccessful.WrapData(enrollment))
+	}
+}
+
+func SetupTOTP(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		setup, err := as.SetupTOTP(c.UserContext(), utils.GetUserUID(c))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.TOTPSetupStarted.WrapData(setup))
+	}
+}
+
+func ConfirmTOTP(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rq requests.ConfirmTOTPRequest
+
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		codes, err := as.ConfirmTOTP(c.UserContext(), utils.GetUserUID(c), &rq)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.TOTPEnabled.WrapData(codes))
+	}
+}
+
+func DisableTOTP(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rq requests.SecondFactorRequest
+
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		if err := as.DisableTOTP(c.UserContext(), utils.GetUserUID(c), &rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.TOTPDisabled)
+	}
+}
+
+func RegenerateRecoveryCodes(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rq requests.SecondFactorRequest
+
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		codes, err := as.RegenerateRecoveryCodes(c.UserContext(), utils.GetUserUID(c), &rq)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.RecoveryCodesRegenerated.WrapData(codes))
+	}
+}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/repositories"
+	"github.com/vlahanam/company-management/internal/requests"
+	"github.com/vlahanam/company-management/internal/services"
+)
+
+func GetListOIDCProviders(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		return c.Status(fiber.StatusOK).JSON(common.GetListSuccessResponse("sso providers").WrapData(as.ListOIDCProviders()))
+	}
+}
+
+func OIDCLoginBeginHandler(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		authorization, err := as.BeginOIDCLogin(c.UserContext(), c.Params("provider"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.SSOLoginStarted.WrapData(authorization))
+	}
+}
+
+func OIDCLoginCallbackHandler(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rq requests.OIDCCallbackRequest
+
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		auth, err := as.FinishOIDCLogin(c.UserContext(), c.Params("provider"), &rq)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.LoginSuccessful.WrapData(auth))
+	}
+}
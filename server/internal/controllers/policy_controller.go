@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/policy"
+	"github.com/vlahanam/company-management/internal/repositories"
+	"github.com/vlahanam/company-management/internal/requests"
+	"github.com/vlahanam/company-management/internal/services"
+	"github.com/vlahanam/company-management/utils"
+)
+
+func GetListPolicies(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewPolicyService(rp, services.NewServiceAccountService(services.NewUserService(rp), rp))
+
+		policies, err := svc.ListPolicies(c.UserContext())
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.GetListSuccessResponse("policies").WrapData(fiber.Map{
+			"policies":   policies,
+			"configured": policy.Configured(),
+		}))
+	}
+}
+
+func CreatePolicy(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rq requests.CreatePolicyRequest
+
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewPolicyService(rp, services.NewServiceAccountService(services.NewUserService(rp), rp))
+
+		p, err := svc.CreatePolicy(c.UserContext(), &rq)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(common.CreateSuccessResponse("policy").WrapData(p))
+	}
+}
+
+func GetPolicy(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewPolicyService(rp, services.NewServiceAccountService(services.NewUserService(rp), rp))
+
+		p, err := svc.FindByID(c.UserContext(), id)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.GetSuccessResponse("policy").WrapData(p))
+	}
+}
+
+func UpdatePolicy(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
+		}
+
+		var rq requests.UpdatePolicyRequest
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewPolicyService(rp, services.NewServiceAccountService(services.NewUserService(rp), rp))
+
+		p, err := svc.UpdatePolicy(c.UserContext(), id, &rq)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.UpdateSuccessResponse("policy").WrapData(p))
+	}
+}
+
+func DeletePolicy(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewPolicyService(rp, services.NewServiceAccountService(services.NewUserService(rp), rp))
+
+		if err := svc.DeletePolicy(c.UserContext(), id); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.DeleteSuccessResponse("policy"))
+	}
+}
+
+// EvaluatePolicy reports how the policies would decide an action, without
+// taking it.
+func EvaluatePolicy(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rq requests.EvaluatePolicyRequest
+
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewPolicyService(rp, services.NewServiceAccountService(services.NewUserService(rp), rp))
+
+		evaluation, err := svc.Evaluate(c.UserContext(), utils.GetUserUID(c), &rq)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.GetSuccessResponse("policy evaluation").WrapData(evaluation))
+	}
+}
+
+// CheckPolicy evaluates the policies for the action on the resource in the
+// base58 id route parameter, then runs check, usually a permission check.
+// Policies only narrow access: a deny stops the request, and otherwise check
+// still decides.
+func CheckPolicy(db *gorm.DB, resourceType, action string, check fiber.Handler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		uid, err := common.FromBase58(c.Params("id"))
+		if err != nil {
+			return check(c)
+		}
+
+		if status, err := authorizePolicy(c, db, resourceType, uint64(uid.GetLocalID()), action); err != nil {
+			return c.Status(status).JSON(err)
+		}
+
+		return check(c)
+	}
+}
+
+// authorizePolicy evaluates the policies for the caller. A deny comes back as
+// a 403 error; an allow grants nothing, the permission checks still apply.
+func authorizePolicy(c *fiber.Ctx, db *gorm.DB, resourceType string, resourceID uint64, action string) (int, error) {
+	rp := repositories.NewMySQLStorage(db)
+	svc := services.NewPolicyService(rp, services.NewServiceAccountService(services.NewUserService(rp), rp))
+
+	result, err := svc.Authorize(c.UserContext(), utils.GetUserUID(c), resourceType, resourceID, action)
+	if err != nil {
+		return fiber.StatusBadRequest, err
+	}
+
+	if result.Decision == policy.DecisionDeny {
+		return fiber.StatusForbidden,
+			common.ErrorForbidden.Clone().WrapMessage(models.ErrPolicyDenied.Error()).SetDetail("policy", result.Rule)
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/repositories"
+	"github.com/vlahanam/company-management/internal/requests"
+	"github.com/vlahanam/company-management/internal/services"
+	"github.com/vlahanam/company-management/utils"
+)
+
+func GetMyProfile(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		profile, err := as.GetOwnProfile(c.UserContext(), utils.GetUserUID(c))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		profile.Mask(common.ObjectTypeUser)
+		for _, r := range profile.Roles {
+			if r.Company != nil {
+				r.Company.Mask(1)
+			}
+		}
+		if p := profile.PrimaryPosition; p != nil {
+			p.Mask(1)
+			if p.Position != nil {
+				p.Position.Mask(1)
+				if p.Position.Company != nil {
+					p.Position.Company.Mask(1)
+				}
+			}
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.GetSuccessResponse("profile").WrapData(profile))
+	}
+}
+
+func UpdateMyProfile(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rq requests.UpdateProfileRequest
+
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		if err := as.UpdateOwnProfile(c.UserContext(), utils.GetUserUID(c), &rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.UpdateSuccessResponse("profile"))
+	}
+}
+
+func ChangeMyPassword(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rq requests.ChangePasswordRequest
+
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+		rq.IP = c.IP()
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		if err := as.ChangeOwnPassword(c.UserContext(), utils.GetUserUID(c), utils.GetSessionID(c), &rq); err != nil {
+			var throttled *services.LoginThrottledError
+			if errors.As(err, &throttled) {
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(throttled.RetryAfterSeconds()))
+				return c.Status(fiber.StatusTooManyRequests).JSON(err)
+			}
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.PasswordChanged)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/vlahanam/company-management/internal/repositories"
 	"github.com/vlahanam/company-management/internal/requests"
 	"github.com/vlahanam/company-management/internal/services"
+	"github.com/vlahanam/company-management/utils"
 )
 
 func CreateRole(db *gorm.DB) fiber.Handler {
@@ -25,9 +26,9 @@ func CreateRole(db *gorm.DB) fiber.Handler {
 		}
 
 		rp := repositories.NewMySQLStorage(db)
-		svc := services.NewRoleService(rp)
+		svc := services.NewRoleService(rp, services.NewServiceAccountService(services.NewUserService(rp), rp))
 
-		role, err := svc.CreateRole(c.UserContext(), &rq)
+		role, err := svc.CreateRole(c.UserContext(), utils.GetUserUID(c), &rq)
 		if err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(err)
 		}
@@ -45,7 +46,7 @@ func GetListRoles(db *gorm.DB) fiber.Handler {
 		}
 
 		rp := repositories.NewMySQLStorage(db)
-		svc := services.NewRoleService(rp)
+		svc := services.NewRoleService(rp, services.NewServiceAccountService(services.NewUserService(rp), rp))
 
 		roles, err := svc.GetListRolesWithPagination(c.UserContext(), rq)
 		if err != nil {
@@ -65,11 +66,11 @@ func GetRole(db *gorm.DB) fiber.Handler {
 		}
 
 		rp := repositories.NewMySQLStorage(db)
-		svc := services.NewRoleService(rp)
+		svc := services.NewRoleService(rp, services.NewServiceAccountService(services.NewUserService(rp), rp))
 
-		role, err := svc.FindByID(c.UserContext(), id)
+		role, err := svc.GetRolePermissions(c.UserContext(), id)
 		if err != nil {
-			return c.Status(fiber.StatusNotFound).JSON(common.ErrorNotFound.Clone().WrapMessage("role not found"))
+			return c.Status(fiber.StatusNotFound).JSON(err)
 		}
 
 		return c.Status(fiber.StatusOK).JSON(common.GetSuccessResponse("role").WrapData(role))
@@ -94,9 +95,9 @@ func UpdateRole(db *gorm.DB) fiber.Handler {
 		}
 
 		rp := repositories.NewMySQLStorage(db)
-		svc := services.NewRoleService(rp)
+		svc := services.NewRoleService(rp, services.NewServiceAccountService(services.NewUserService(rp), rp))
 
-		if err := svc.UpdateRole(c.UserContext(), id, &rq); err != nil {
+		if err := svc.UpdateRole(c.UserContext(), utils.GetUserUID(c), id, &rq); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(err)
 		}
 
@@ -113,7 +114,7 @@ func DeleteRole(db *gorm.DB) fiber.Handler {
 		}
 
 		rp := repositories.NewMySQLStorage(db)
-		svc := services.NewRoleService(rp)
+		svc := services.NewRoleService(rp, services.NewServiceAccountService(services.NewUserService(rp), rp))
 
 		if err := svc.DeleteRole(c.UserContext(), id); err != nil {
 			return c.Status(fiber.StatusBadRequest).JSON(err)
@@ -122,3 +123,126 @@ func DeleteRole(db *gorm.DB) fiber.Handler {
 		return c.Status(fiber.StatusOK).JSON(common.DeleteSuccessResponse("role"))
 	}
 }
+
+func GetRolePermissions(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewRoleService(rp, services.NewServiceAccountService(services.NewUserService(rp), rp))
+
+		role, err := svc.GetRolePermissions(c.UserContext(), id)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.GetSuccessResponse("role permissions").WrapData(role))
+	}
+}
+
+// GrantRolePermissions grants the permissions listed in the body.
+func GrantRolePermissions(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
+		}
+
+		var rq requests.RolePermissionsRequest
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		return grantRolePermissions(c, db, id, rq)
+	}
+}
+
+// GrantRolePermission grants the permission named in the path.
+func GrantRolePermission(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, permissionID, ok := rolePermissionParams(c)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
+		}
+
+		return grantRolePermissions(c, db, id, requests.RolePermissionsRequest{PermissionIDs: []int64{permissionID}})
+	}
+}
+
+// RevokeRolePermissions revokes the permissions listed in the body.
+func RevokeRolePermissions(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
+		}
+
+		var rq requests.RolePermissionsRequest
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		return revokeRolePermissions(c, db, id, rq)
+	}
+}
+
+// RevokeRolePermission revokes the permission named in the path.
+func RevokeRolePermission(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, permissionID, ok := rolePermissionParams(c)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
+		}
+
+		return revokeRolePermissions(c, db, id, requests.RolePermissionsRequest{PermissionIDs: []int64{permissionID}})
+	}
+}
+
+func grantRolePermissions(c *fiber.Ctx, db *gorm.DB, id int64, rq requests.RolePermissionsRequest) error {
+	if err := rq.Validation(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+	}
+
+	rp := repositories.NewMySQLStorage(db)
+	svc := services.NewRoleService(rp, services.NewServiceAccountService(services.NewUserService(rp), rp))
+
+	role, err := svc.GrantPermissions(c.UserContext(), utils.GetUserUID(c), id, rq.PermissionIDs)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(common.UpdateSuccessResponse("role permissions").WrapData(role))
+}
+
+func revokeRolePermissions(c *fiber.Ctx, db *gorm.DB, id int64, rq requests.RolePermissionsRequest) error {
+	if err := rq.Validation(); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+	}
+
+	rp := repositories.NewMySQLStorage(db)
+	svc := services.NewRoleService(rp, services.NewServiceAccountService(services.NewUserService(rp), rp))
+
+	role, err := svc.RevokePermissions(c.UserContext(), id, rq.PermissionIDs)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(common.UpdateSuccessResponse("role permissions").WrapData(role))
+}
+
+func rolePermissionParams(c *fiber.Ctx) (int64, int64, bool) {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	permissionID, err := strconv.ParseInt(c.Params("permission_id"), 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return id, permissionID, true
+}
@@ -0,0 +1,282 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/repositories"
+	"github.com/vlahanam/company-management/internal/scim"
+	"github.com/vlahanam/company-management/internal/services"
+)
+
+// scimBase is where the SCIM API is mounted, used to locate resources.
+const scimBase = "/scim/v2"
+
+// scimJSON writes a SCIM response with the SCIM media type.
+func scimJSON(c *fiber.Ctx, status int, data interface{}) error {
+	if err := c.Status(status).JSON(data); err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, scim.ContentType)
+
+	return nil
+}
+
+// scimErrorJSON writes a SCIM error. Errors that are not SCIM errors are
+// internal failures.
+func scimErrorJSON(c *fiber.Ctx, err error) error {
+	var scimErr *scim.Error
+	if !errors.As(err, &scimErr) {
+		scimErr = scim.NewError(fiber.StatusInternalServerError, "", common.ErrorInternal.Message)
+	}
+
+	return scimJSON(c, scimErr.StatusCode(), scimErr)
+}
+
+func scimLocation(c *fiber.Ctx) string {
+	return c.BaseURL() + scimBase
+}
+
+func GetSCIMServiceProviderConfig() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return scimJSON(c, fiber.StatusOK, scim.ServiceProviderConfig())
+	}
+}
+
+func GetListSCIMUsers(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewSCIMService(services.NewAuthService(services.NewUserService(rp), rp, opts), rp)
+
+		var rq scim.ListRequest
+
+		if err := c.QueryParser(&rq); err != nil {
+			return scimErrorJSON(c, scim.BadRequest(scim.ErrInvalidValue, "invalid query"))
+		}
+
+		list, err := svc.ListUsers(c.UserContext(), &rq)
+		if err != nil {
+			return scimErrorJSON(c, err)
+		}
+
+		for _, u := range list.Resources.([]*scim.User) {
+			u.Locate(scimLocation(c))
+		}
+
+		return scimJSON(c, fiber.StatusOK, list)
+	}
+}
+
+func GetSCIMUser(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewSCIMService(services.NewAuthService(services.NewUserService(rp), rp, opts), rp)
+
+		u, err := svc.GetUser(c.UserContext(), c.Params("id"))
+		if err != nil {
+			return scimErrorJSON(c, err)
+		}
+
+		u.Locate(scimLocation(c))
+		return scimJSON(c, fiber.StatusOK, u)
+	}
+}
+
+func CreateSCIMUser(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewSCIMService(services.NewAuthService(services.NewUserService(rp), rp, opts), rp)
+
+		var rq scim.User
+
+		if err := c.BodyParser(&rq); err != nil {
+			return scimErrorJSON(c, scim.BadRequest(scim.ErrInvalidSyntax, "invalid request body"))
+		}
+
+		u, err := svc.CreateUser(c.UserContext(), &rq)
+		if err != nil {
+			return scimErrorJSON(c, err)
+		}
+
+		u.Locate(scimLocation(c))
+		c.Location(u.Meta.Location)
+		return scimJSON(c, fiber.StatusCreated, u)
+	}
+}
+
+func ReplaceSCIMUser(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewSCIMService(services.NewAuthService(services.NewUserService(rp), rp, opts), rp)
+
+		var rq scim.User
+
+		if err := c.BodyParser(&rq); err != nil {
+			return scimErrorJSON(c, scim.BadRequest(scim.ErrInvalidSyntax, "invalid request body"))
+		}
+
+		u, err := svc.ReplaceUser(c.UserContext(), c.Params("id"), &rq)
+		if err != nil {
+			return scimErrorJSON(c, err)
+		}
+
+		u.Locate(scimLocation(c))
+		return scimJSON(c, fiber.StatusOK, u)
+	}
+}
+
+func PatchSCIMUser(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewSCIMService(services.NewAuthService(services.NewUserService(rp), rp, opts), rp)
+
+		var rq scim.PatchRequest
+
+		if err := c.BodyParser(&rq); err != nil {
+			return scimErrorJSON(c, scim.BadRequest(scim.ErrInvalidSyntax, "invalid request body"))
+		}
+
+		u, err := svc.PatchUser(c.UserContext(), c.Params("id"), &rq)
+		if err != nil {
+			return scimErrorJSON(c, err)
+		}
+
+		u.Locate(scimLocation(c))
+		return scimJSON(c, fiber.StatusOK, u)
+	}
+}
+
+// DeleteSCIMUser deprovisions the user by deactivating the account.
+func DeleteSCIMUser(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewSCIMService(services.NewAuthService(services.NewUserService(rp), rp, opts), rp)
+
+		if err := svc.DeactivateUser(c.UserContext(), c.Params("id")); err != nil {
+			return scimErrorJSON(c, err)
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+func GetListSCIMGroups(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewSCIMService(services.NewAuthService(services.NewUserService(rp), rp, opts), rp)
+
+		var rq scim.ListRequest
+
+		if err := c.QueryParser(&rq); err != nil {
+			return scimErrorJSON(c, scim.BadRequest(scim.ErrInvalidValue, "invalid query"))
+		}
+
+		list, err := svc.ListGroups(c.UserContext(), &rq)
+		if err != nil {
+			return scimErrorJSON(c, err)
+		}
+
+		for _, g := range list.Resources.([]*scim.Group) {
+			g.Locate(scimLocation(c))
+		}
+
+		return scimJSON(c, fiber.StatusOK, list)
+	}
+}
+
+func GetSCIMGroup(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewSCIMService(services.NewAuthService(services.NewUserService(rp), rp, opts), rp)
+
+		rq := scim.ListRequest{ExcludedAttributes: c.Query("excludedAttributes")}
+
+		g, err := svc.GetGroup(c.UserContext(), c.Params("id"), !rq.Excludes("members"))
+		if err != nil {
+			return scimErrorJSON(c, err)
+		}
+
+		g.Locate(scimLocation(c))
+		return scimJSON(c, fiber.StatusOK, g)
+	}
+}
+
+func CreateSCIMGroup(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewSCIMService(services.NewAuthService(services.NewUserService(rp), rp, opts), rp)
+
+		var rq scim.Group
+
+		if err := c.BodyParser(&rq); err != nil {
+			return scimErrorJSON(c, scim.BadRequest(scim.ErrInvalidSyntax, "invalid request body"))
+		}
+
+		g, err := svc.CreateGroup(c.UserContext(), &rq)
+		if err != nil {
+			return scimErrorJSON(c, err)
+		}
+
+		g.Locate(scimLocation(c))
+		c.Location(g.Meta.Location)
+		return scimJSON(c, fiber.StatusCreated, g)
+	}
+}
+
+func ReplaceSCIMGroup(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewSCIMService(services.NewAuthService(services.NewUserService(rp), rp, opts), rp)
+
+		var rq scim.Group
+
+		if err := c.BodyParser(&rq); err != nil {
+			return scimErrorJSON(c, scim.BadRequest(scim.ErrInvalidSyntax, "invalid request body"))
+		}
+
+		g, err := svc.ReplaceGroup(c.UserContext(), c.Params("id"), &rq)
+		if err != nil {
+			return scimErrorJSON(c, err)
+		}
+
+		g.Locate(scimLocation(c))
+		return scimJSON(c, fiber.StatusOK, g)
+	}
+}
+
+func PatchSCIMGroup(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewSCIMService(services.NewAuthService(services.NewUserService(rp), rp, opts), rp)
+
+		var rq scim.PatchRequest
+
+		if err := c.BodyParser(&rq); err != nil {
+			return scimErrorJSON(c, scim.BadRequest(scim.ErrInvalidSyntax, "invalid request body"))
+		}
+
+		g, err := svc.PatchGroup(c.UserContext(), c.Params("id"), &rq)
+		if err != nil {
+			return scimErrorJSON(c, err)
+		}
+
+		g.Locate(scimLocation(c))
+		return scimJSON(c, fiber.StatusOK, g)
+	}
+}
+
+func DeleteSCIMGroup(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewSCIMService(services.NewAuthService(services.NewUserService(rp), rp, opts), rp)
+
+		if err := svc.DeleteGroup(c.UserContext(), c.Params("id")); err != nil {
+			return scimErrorJSON(c, err)
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/repositories"
+	"github.com/vlahanam/company-management/internal/requests"
+	"github.com/vlahanam/company-management/internal/services"
+	"github.com/vlahanam/company-management/utils"
+)
+
+func CreateServiceAccount(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rq requests.CreateServiceAccountRequest
+
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewServiceAccountService(services.NewUserService(rp), rp)
+
+		account, err := svc.CreateServiceAccount(c.UserContext(), utils.GetUserUID(c), &rq)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		account.Mask()
+		return c.Status(fiber.StatusCreated).JSON(common.CreateSuccessResponse("service account").WrapData(account))
+	}
+}
+
+func GetListServiceAccounts(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewServiceAccountService(services.NewUserService(rp), rp)
+
+		accounts, err := svc.ListServiceAccounts(c.UserContext())
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(err)
+		}
+
+		for _, account := range accounts {
+			account.Mask()
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.GetListSuccessResponse("service accounts").WrapData(accounts))
+	}
+}
+
+func GetServiceAccount(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, ok := objectIDParam(c, "id", common.ObjectTypeServiceAccount)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewServiceAccountService(services.NewUserService(rp), rp)
+
+		account, err := svc.FindByID(c.UserContext(), id)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(err)
+		}
+
+		account.Mask()
+		return c.Status(fiber.StatusOK).JSON(common.GetSuccessResponse("service account").WrapData(account))
+	}
+}
+
+func UpdateServiceAccount(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, ok := objectIDParam(c, "id", common.ObjectTypeServiceAccount)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
+		}
+
+		var rq requests.UpdateServiceAccountRequest
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewServiceAccountService(services.NewUserService(rp), rp)
+
+		if err := svc.UpdateServiceAccount(c.UserContext(), id, &rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.UpdateSuccessResponse("service account"))
+	}
+}
+
+func DeleteServiceAccount(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, ok := objectIDParam(c, "id", common.ObjectTypeServiceAccount)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewServiceAccountService(services.NewUserService(rp), rp)
+
+		if err := svc.DeleteServiceAccount(c.UserContext(), id); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.DeleteSuccessResponse("service account"))
+	}
+}
+
+func CreateAPIKey(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, ok := objectIDParam(c, "id", common.ObjectTypeServiceAccount)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
+		}
+
+		var rq requests.CreateAPIKeyRequest
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewServiceAccountService(services.NewUserService(rp), rp)
+
+		key, err := svc.CreateAPIKey(c.UserContext(), utils.GetUserUID(c), id, &rq)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(common.APIKeyCreated.WrapData(key))
+	}
+}
+
+func GetListAPIKeys(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, ok := objectIDParam(c, "id", common.ObjectTypeServiceAccount)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewServiceAccountService(services.NewUserService(rp), rp)
+
+		account, err := svc.FindByID(c.UserContext(), id)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(err)
+		}
+
+		account.Mask()
+		return c.Status(fiber.StatusOK).JSON(common.GetListSuccessResponse("api keys").WrapData(account.APIKeys))
+	}
+}
+
+func RotateAPIKey(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, ok := objectIDParam(c, "id", common.ObjectTypeServiceAccount)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
+		}
+
+		keyID, ok := objectIDParam(c, "key_id", common.ObjectTypeAPIKey)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("key_id", "invalid id format"))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewServiceAccountService(services.NewUserService(rp), rp)
+
+		key, err := svc.RotateAPIKey(c.UserContext(), utils.GetUserUID(c), id, keyID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(common.APIKeyCreated.WrapData(key))
+	}
+}
+
+func RevokeAPIKey(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, ok := objectIDParam(c, "id", common.ObjectTypeServiceAccount)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
+		}
+
+		keyID, ok := objectIDParam(c, "key_id", common.ObjectTypeAPIKey)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("key_id", "invalid id format"))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		svc := services.NewServiceAccountService(services.NewUserService(rp), rp)
+
+		if err := svc.RevokeAPIKey(c.UserContext(), id, keyID); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.APIKeyRevoked)
+	}
+}
+
+// objectIDParam decodes a base58 route parameter and checks that it refers to
+// an object of the expected type.
+func objectIDParam(c *fiber.Ctx, name string, objectType int64) (uint64, bool) {
+	uid, err := common.FromBase58(c.Params(name))
+	if err != nil || uid.GetObjectType() != objectType {
+		return 0, false
+	}
+
+	return uint64(uid.GetLocalID()), true
+}
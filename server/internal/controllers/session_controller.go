@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/repositories"
+	"github.com/vlahanam/company-management/internal/services"
+	"github.com/vlahanam/company-management/utils"
+)
+
+func GetListMySessions(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		sessions, err := as.ListOwnSessions(c.UserContext(), utils.GetUserUID(c), utils.GetSessionID(c))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.GetListSuccessResponse("sessions").WrapData(sessions))
+	}
+}
+
+func DeleteMySession(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		if err := as.RevokeOwnSession(c.UserContext(), utils.GetUserUID(c), c.Params("id")); err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.SessionRevoked)
+	}
+}
+
+func GetListUserSessions(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := objectIDParam(c, "id", common.ObjectTypeUser)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		sessions, err := as.ListUserSessions(c.UserContext(), userID)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.GetListSuccessResponse("sessions").WrapData(sessions))
+	}
+}
+
+func DeleteUserSession(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := objectIDParam(c, "id", common.ObjectTypeUser)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		if err := as.RevokeSession(c.UserContext(), userID, c.Params("session_id")); err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.SessionRevoked)
+	}
+}
@@ -5,11 +5,40 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/access"
+	"github.com/vlahanam/company-management/internal/policy"
 	"github.com/vlahanam/company-management/internal/repositories"
 	"github.com/vlahanam/company-management/internal/requests"
 	"github.com/vlahanam/company-management/internal/services"
+	"github.com/vlahanam/company-management/utils"
 )
 
+func CreateUser(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var rq requests.CreateUserRequest
+
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+		as := services.NewAuthService(es, rp, opts)
+
+		user, err := as.CreateUser(c.UserContext(), &rq)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		user.Mask(common.ObjectTypeUser)
+		return c.Status(fiber.StatusCreated).JSON(common.CreateSuccessResponse("user").WrapData(user))
+	}
+}
+
 func GetListUsers(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var rq requests.ListUserRequest
@@ -38,6 +67,10 @@ func GetUser(db *gorm.DB) fiber.Handler {
 			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
 		}
 
+		if status, err := authorizeUserAccess(c, db, uint64(uid.GetLocalID()), access.ActionRead); err != nil {
+			return c.Status(status).JSON(err)
+		}
+
 		rp := repositories.NewMySQLStorage(db)
 		es := services.NewUserService(rp)
 
@@ -68,6 +101,10 @@ func UpdateUser(db *gorm.DB) fiber.Handler {
 			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
 		}
 
+		if status, err := authorizeUserAccess(c, db, uint64(uid.GetLocalID()), access.ActionUpdate, rq.Fields()...); err != nil {
+			return c.Status(status).JSON(err)
+		}
+
 		rp := repositories.NewMySQLStorage(db)
 		es := services.NewUserService(rp)
 
@@ -79,7 +116,7 @@ func UpdateUser(db *gorm.DB) fiber.Handler {
 	}
 }
 
-func DeleteUser(db *gorm.DB) fiber.Handler {
+func DeleteUser(db *gorm.DB, revocations services.TokenRevoker) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		id := c.Params("id")
 		uid, err := common.FromBase58(id)
@@ -87,6 +124,10 @@ func DeleteUser(db *gorm.DB) fiber.Handler {
 			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
 		}
 
+		if status, err := authorizeUserAccess(c, db, uint64(uid.GetLocalID()), access.ActionDelete); err != nil {
+			return c.Status(status).JSON(err)
+		}
+
 		rp := repositories.NewMySQLStorage(db)
 		es := services.NewUserService(rp)
 
@@ -94,6 +135,60 @@ func DeleteUser(db *gorm.DB) fiber.Handler {
 			return c.Status(fiber.StatusBadRequest).JSON(err)
 		}
 
+		// The account is gone, so its live access tokens must stop working now
+		if err := revocations.RevokeUserTokens(c.UserContext(), uint64(uid.GetLocalID())); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(err)
+		}
+
 		return c.Status(fiber.StatusOK).JSON(common.DeleteSuccessResponse("user"))
 	}
 }
+
+// UnlockUser clears the failed login counter of the user's account, lifting
+// any delay or lockout.
+func UnlockUser(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		uid, err := common.FromBase58(id)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		es := services.NewUserService(rp)
+
+		user, err := es.FindByID(c.UserContext(), uint64(uid.GetLocalID()))
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(common.ErrorNotFound.Clone().WrapMessage("user not found"))
+		}
+
+		if err := opts.LoginThrottle.Unlock(c.UserContext(), user.Email); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.UserUnlocked)
+	}
+}
+
+// authorizeUserAccess applies the policies, then the access rules to the
+// caller acting on the user targetID. On refusal it
+// returns the status and error to answer with.
+func authorizeUserAccess(c *fiber.Ctx, db *gorm.DB, targetID uint64, action access.Action, fields ...string) (int, error) {
+	if status, err := authorizePolicy(c, db, policy.ResourceUser, targetID, string(action)); err != nil {
+		return status, err
+	}
+
+	rp := repositories.NewMySQLStorage(db)
+	sa := services.NewServiceAccountService(services.NewUserService(rp), rp)
+
+	actor, err := sa.GetUserAccessActor(c.UserContext(), utils.GetUserUID(c), targetID)
+	if err != nil {
+		return fiber.StatusBadRequest, err
+	}
+
+	if err := access.CheckUserAccess(*actor, targetID, action, fields...); err != nil {
+		return fiber.StatusForbidden, common.ErrorForbidden.Clone().WrapMessage(err.Error())
+	}
+
+	return 0, nil
+}
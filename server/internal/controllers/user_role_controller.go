@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/repositories"
+	"github.com/vlahanam/company-management/internal/requests"
+	"github.com/vlahanam/company-management/internal/services"
+	"github.com/vlahanam/company-management/utils"
+)
+
+func GetListUserRoles(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := objectIDParam(c, "id", common.ObjectTypeUser)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		as := services.NewAuthService(services.NewUserService(rp), rp, opts)
+
+		roles, err := as.ListUserRoles(c.UserContext(), userID)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.GetListSuccessResponse("user roles").WrapData(roles))
+	}
+}
+
+func AssignUserRole(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := objectIDParam(c, "id", common.ObjectTypeUser)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
+		}
+
+		var rq requests.AssignUserRoleRequest
+		if err := c.BodyParser(&rq); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorBodyParser)
+		}
+
+		if err := rq.Validation(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.WrapDetail(err))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		as := services.NewAuthService(services.NewUserService(rp), rp, opts)
+
+		userRole, err := as.AssignUserRole(c.UserContext(), utils.GetUserUID(c), userID, &rq)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(common.CreateSuccessResponse("user role").WrapData(userRole))
+	}
+}
+
+func RemoveUserRole(db *gorm.DB, opts *services.AuthOptions) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := objectIDParam(c, "id", common.ObjectTypeUser)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("id", "invalid id format"))
+		}
+
+		assignmentID, err := strconv.ParseUint(c.Params("assignment_id"), 10, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorValidation.Clone().SetDetail("assignment_id", "invalid id format"))
+		}
+
+		rp := repositories.NewMySQLStorage(db)
+		as := services.NewAuthService(services.NewUserService(rp), rp, opts)
+
+		if err := as.RemoveUserRole(c.UserContext(), utils.GetUserUID(c), userID, assignmentID); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(err)
+		}
+
+		return c.Status(fiber.StatusOK).JSON(common.DeleteSuccessResponse("user role"))
+	}
+}
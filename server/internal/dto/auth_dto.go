@@ -1 +1,62 @@
-package dto
\ No newline at end of file
+package dto
+
+import (
+	"time"
+
+	"github.com/vlahanam/company-management/internal/models"
+)
+
+// TOTPSetup is returned when a user starts TOTP enrollment. The secret is
+// shown once so it can be typed in manually when the QR code cannot be
+// scanned.
+type TOTPSetup struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	QRCode     string `json:"qr_code"`
+}
+
+// RecoveryCodes are one-time codes that replace a TOTP code when the
+// authenticator is lost. They are only ever shown in this response.
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+// MFAEnrollment is returned when enrollment is completed during login.
+// MFAEnrollment completes a login like models.Auth: with the token pair, or
+// with a password change token when the password expired.
+type MFAEnrollment struct {
+	AccessToken            string   `json:"access_token,omitempty"`
+	RefreshToken           string   `json:"refresh_token,omitempty"`
+	PasswordChangeRequired bool     `json:"password_change_required,omitempty"`
+	PasswordChangeToken    string   `json:"password_change_token,omitempty"`
+	RecoveryCodes          []string `json:"recovery_codes"`
+}
+
+// WebAuthnCeremony is returned by the begin step of a passkey ceremony.
+// Options is passed to navigator.credentials.create or .get as is, and
+// SessionID is sent back with the authenticator response.
+type WebAuthnCeremony struct {
+	SessionID string      `json:"session_id"`
+	Options   interface{} `json:"options"`
+}
+
+// OIDCAuthorization is returned when an SSO login starts. The browser is sent
+// to AuthorizationURL and the provider redirects back with code and state.
+type OIDCAuthorization struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+// APIKeySecret is returned when an API key is created or rotated. Key is the
+// only time the full key is shown; afterwards only its prefix is known.
+type APIKeySecret struct {
+	*models.APIKey
+	Key string `json:"key"`
+}
+
+// ImpersonationToken is returned when a Super Admin starts impersonating a
+// user. The access token acts as the user and cannot be refreshed.
+type ImpersonationToken struct {
+	AccessToken string    `json:"access_token"`
+	SessionID   string    `json:"session_id"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
@@ -0,0 +1,30 @@
+package dto
+
+import (
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/policy"
+)
+
+// PermissionExplanation tells whether a user holds a permission, on a
+// resource or in every company, and why.
+type PermissionExplanation struct {
+	Allowed    bool               `json:"allowed"`
+	Reason     string             `json:"reason"`
+	Permission *models.Permission `json:"permission"`
+	// Companies the resource belongs to; roles must apply in one of them
+	CompanyIDs  []uint64                     `json:"company_ids"`
+	Assignments []*RoleAssignmentExplanation `json:"assignments"`
+	// How the access policies decided, for the resources they cover
+	Policy *policy.Result `json:"policy,omitempty"`
+}
+
+// RoleAssignmentExplanation is one role assignment of the user: whether its
+// scope covers the resource, and the chain of roles from the assigned one to
+// the role the permission is granted to, when there is one.
+type RoleAssignmentExplanation struct {
+	*models.UserRole
+	Applies bool           `json:"applies"`
+	Scope   string         `json:"scope"`
+	Grants  bool           `json:"grants"`
+	Via     []*models.Role `json:"via,omitempty"`
+}
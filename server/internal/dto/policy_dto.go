@@ -0,0 +1,10 @@
+package dto
+
+import "github.com/vlahanam/company-management/internal/policy"
+
+// PolicyEvaluation is the outcome of a dry run: the attributes the rules saw
+// and how they decided.
+type PolicyEvaluation struct {
+	Request policy.Request `json:"request"`
+	*policy.Result
+}
@@ -0,0 +1,12 @@
+package dto
+
+import "github.com/vlahanam/company-management/internal/models"
+
+// Profile is the account of the signed-in user together with what it is
+// allowed to do.
+type Profile struct {
+	*models.User
+	Roles           []*models.UserRole   `json:"roles"`
+	Permissions     []*models.Permission `json:"permissions"`
+	PrimaryPosition *models.UserPosition `json:"primary_position"`
+}
@@ -0,0 +1,12 @@
+package dto
+
+import "github.com/vlahanam/company-management/internal/models"
+
+// RolePermissions is a role together with the roles it inherits from, the
+// permissions granted to it and those it inherits.
+type RolePermissions struct {
+	*models.Role
+	Parents              []*models.Role       `json:"parents"`
+	Permissions          []*models.Permission `json:"permissions"`
+	InheritedPermissions []*models.Permission `json:"inherited_permissions"`
+}
@@ -0,0 +1,46 @@
+package initialize
+
+import (
+	"log"
+
+	"github.com/vlahanam/company-management/utils"
+)
+
+// minSecretLength is the size of an HS256 key, 256 bits.
+const minSecretLength = 32
+
+// InitTokenKeys builds the access and refresh token key sets. With a key
+// directory both are signed by its active asymmetric key and told apart by
+// their typ header; otherwise each uses its own HS256 secret. It also checks
+// VERIFY_SECRET_KEY, which signs the MFA, password change, email
+// verification and invitation tokens.
+func InitTokenKeys(cfg *Config) (*utils.KeySet, *utils.KeySet) {
+	requireSecret("VERIFY_SECRET_KEY", cfg.Auth.VerifySecret)
+
+	if cfg.Auth.JWTKeysDir == "" {
+		requireSecret("ACCESS_SECRET_KEY", cfg.Auth.AccessSecret)
+		requireSecret("REFRESH_SECRET_KEY", cfg.Auth.RefreshSecret)
+
+		return utils.NewHMACKeySet(cfg.Auth.AccessSecret), utils.NewHMACKeySet(cfg.Auth.RefreshSecret)
+	}
+
+	access, err := utils.LoadKeySet(cfg.Auth.JWTKeysDir, cfg.Auth.JWTActiveKeyID, utils.TokenTypeAccess)
+	if err != nil {
+		log.Fatal("Failed to load JWT signing keys:", err)
+	}
+
+	refresh, err := utils.LoadKeySet(cfg.Auth.JWTKeysDir, cfg.Auth.JWTActiveKeyID, utils.TokenTypeRefresh)
+	if err != nil {
+		log.Fatal("Failed to load JWT signing keys:", err)
+	}
+
+	return access, refresh
+}
+
+// requireSecret stops the server when an HS256 secret is missing or too short
+// to resist guessing: anyone could then sign tokens it accepts.
+func requireSecret(name, secret string) {
+	if len(secret) < minSecretLength {
+		log.Fatalf("%s must be set to at least %d characters", name, minSecretLength)
+	}
+}
@@ -3,15 +3,23 @@ package initialize
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	DB    DB
-	Fiber Fiber
-	Auth  Auth
-	CORS  CORS
+	DB       DB
+	Fiber    Fiber
+	Auth     Auth
+	CORS     CORS
+	Mail     Mail
+	Client   Client
+	WebAuthn WebAuthn
+	OIDC     []OIDCProvider
+	Policy   Policy
 }
 
 type DB struct {
@@ -24,17 +32,125 @@ type DB struct {
 
 type Fiber struct {
 	Port string
+	// Header carrying the client IP when running behind a proxy, e.g.
+	// X-Forwarded-For. Empty uses the connection address
+	ProxyHeader string
 }
 
 type Auth struct {
 	AccessSecret  string
 	RefreshSecret string
+
+	// Directory of <kid>.pem RSA or Ed25519 keys used to sign access and
+	// refresh tokens. The HS256 secrets above are used when empty
+	JWTKeysDir string
+	// Kid of the key that signs new tokens; the other keys only verify
+	JWTActiveKeyID string
+
+	// How long the in-process token revocation cache is trusted before it is
+	// reloaded from the database
+	RevocationCacheTTL time.Duration
+
+	PasswordResetTTL time.Duration
+
+	VerifySecret         string
+	EmailVerificationTTL time.Duration
+
+	// Whether anyone may sign up through POST /register. Otherwise accounts
+	// are opened by invitation or by an administrator
+	OpenRegistration bool
+	InvitationTTL    time.Duration
+
+	// Issuer label shown in authenticator apps
+	TOTPIssuer string
+
+	LoginThrottle LoginThrottle
+
+	Password Password
+}
+
+type Password struct {
+	// bcrypt (default) or argon2id. Stored hashes are upgraded on login
+	HashAlgorithm     string
+	BcryptCost        int
+	Argon2Memory      int // KiB
+	Argon2Iterations  int
+	Argon2Parallelism int
+
+	MinLength  int
+	MaxLength  int
+	MinClasses int
+	// Number of previous passwords that cannot be reused
+	History int
+	// Extra newline separated list of rejected passwords
+	BlocklistFile string
+}
+
+type LoginThrottle struct {
+	// database (shared by every instance) or memory (single node)
+	Store                   string
+	MaxFailuresPerAccount   int
+	MaxFailuresPerIP        int
+	MaxFailuresPerChallenge int
+	FailureWindow           time.Duration
+	LockoutDuration         time.Duration
+	BaseDelay               time.Duration
+	MaxDelay                time.Duration
+}
+
+// Policy configures the attribute-based rules evaluated before the role
+// permissions.
+type Policy struct {
+	// JSON array of rules, evaluated along with those stored in the database
+	File string
 }
 
 type CORS struct {
 	AllowedOrigins string
 }
 
+type Mail struct {
+	// smtp, file or log (default)
+	Driver   string
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	FilePath string
+}
+
+type WebAuthn struct {
+	// Relying party ID, the domain passkeys are bound to. Passkeys are
+	// disabled when empty
+	RPID          string
+	RPDisplayName string
+	// Comma separated origins allowed to run the ceremonies
+	RPOrigins string
+}
+
+// OIDCProvider is an SSO identity provider, configured through
+// OIDC_<NAME>_* variables for every name listed in OIDC_PROVIDERS.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// Frontend URL the provider redirects back to with code and state
+	RedirectURL string
+	// Space separated, defaults to "openid email profile"
+	Scopes string
+	// Create users on their first SSO login, optionally only for some email
+	// domains (comma separated)
+	AutoProvision  bool
+	AllowedDomains string
+}
+
+type Client struct {
+	// Base URL of the frontend, used to build links sent by email
+	URL string
+}
+
 func LoadConfig() *Config {
 	err := godotenv.Load()
 
@@ -51,16 +167,143 @@ func LoadConfig() *Config {
 			DBName:     os.Getenv("DB_NAME"),
 		},
 		Fiber: Fiber{
-			Port: os.Getenv("PORT"),
+			Port:        os.Getenv("PORT"),
+			ProxyHeader: os.Getenv("PROXY_HEADER"),
 		},
 		Auth: Auth{
 			AccessSecret:  os.Getenv("ACCESS_SECRET_KEY"),
 			RefreshSecret: os.Getenv("REFRESH_SECRET_KEY"),
+
+			JWTKeysDir:     os.Getenv("JWT_KEYS_DIR"),
+			JWTActiveKeyID: os.Getenv("JWT_ACTIVE_KEY_ID"),
+
+			RevocationCacheTTL: getEnvDuration("TOKEN_REVOCATION_CACHE_TTL", 30*time.Second),
+
+			PasswordResetTTL: getEnvDuration("PASSWORD_RESET_TTL", 30*time.Minute),
+
+			VerifySecret:         os.Getenv("VERIFY_SECRET_KEY"),
+			EmailVerificationTTL: getEnvDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+
+			OpenRegistration: os.Getenv("OPEN_REGISTRATION") == "true",
+			InvitationTTL:    getEnvDuration("INVITATION_TTL", 72*time.Hour),
+
+			TOTPIssuer: getEnv("TOTP_ISSUER", "Company Management"),
+
+			LoginThrottle: LoginThrottle{
+				Store:                   getEnv("LOGIN_THROTTLE_STORE", "database"),
+				MaxFailuresPerAccount:   getEnvInt("LOGIN_MAX_FAILURES_PER_ACCOUNT", 5),
+				MaxFailuresPerIP:        getEnvInt("LOGIN_MAX_FAILURES_PER_IP", 20),
+				MaxFailuresPerChallenge: getEnvInt("LOGIN_MAX_MFA_FAILURES", 3),
+				FailureWindow:           getEnvDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+				LockoutDuration:         getEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+				BaseDelay:               getEnvDuration("LOGIN_DELAY_BASE", time.Second),
+				MaxDelay:                getEnvDuration("LOGIN_DELAY_MAX", 30*time.Second),
+			},
+
+			Password: Password{
+				HashAlgorithm:     getEnv("PASSWORD_HASH_ALGORITHM", "bcrypt"),
+				BcryptCost:        getEnvInt("BCRYPT_COST", 10),
+				Argon2Memory:      getEnvInt("ARGON2_MEMORY", 64*1024),
+				Argon2Iterations:  getEnvInt("ARGON2_ITERATIONS", 3),
+				Argon2Parallelism: getEnvInt("ARGON2_PARALLELISM", 2),
+
+				MinLength:     getEnvInt("PASSWORD_MIN_LENGTH", 10),
+				MaxLength:     getEnvInt("PASSWORD_MAX_LENGTH", 72),
+				MinClasses:    getEnvInt("PASSWORD_MIN_CLASSES", 3),
+				History:       getEnvInt("PASSWORD_HISTORY", 5),
+				BlocklistFile: os.Getenv("PASSWORD_BLOCKLIST_FILE"),
+			},
 		},
 		CORS: CORS{
 			AllowedOrigins: os.Getenv("CORS_ALLOWED_ORIGINS"),
 		},
+		Mail: Mail{
+			Driver:   os.Getenv("MAIL_DRIVER"),
+			Host:     os.Getenv("MAIL_HOST"),
+			Port:     os.Getenv("MAIL_PORT"),
+			Username: os.Getenv("MAIL_USERNAME"),
+			Password: os.Getenv("MAIL_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+			FilePath: os.Getenv("MAIL_FILE_PATH"),
+		},
+		Client: Client{
+			URL: os.Getenv("CLIENT_URL"),
+		},
+		WebAuthn: WebAuthn{
+			RPID:          os.Getenv("WEBAUTHN_RP_ID"),
+			RPDisplayName: getEnv("WEBAUTHN_RP_NAME", "Company Management"),
+			RPOrigins:     getEnv("WEBAUTHN_RP_ORIGINS", os.Getenv("CLIENT_URL")),
+		},
+		OIDC: loadOIDCProviders(),
+		Policy: Policy{
+			File: os.Getenv("POLICY_FILE"),
+		},
 	}
 
 	return cfg
 }
+
+func loadOIDCProviders() []OIDCProvider {
+	var providers []OIDCProvider
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		providers = append(providers, OIDCProvider{
+			Name:           name,
+			Issuer:         os.Getenv(prefix + "ISSUER"),
+			ClientID:       os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret:   os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:    getEnv(prefix+"REDIRECT_URL", os.Getenv("CLIENT_URL")+"/auth/sso/"+name+"/callback"),
+			Scopes:         os.Getenv(prefix + "SCOPES"),
+			AutoProvision:  os.Getenv(prefix+"AUTO_PROVISION") == "true",
+			AllowedDomains: os.Getenv(prefix + "ALLOWED_DOMAINS"),
+		})
+	}
+
+	return providers
+}
+
+func getEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+
+	return def
+}
+
+func getEnvInt(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %d", key, v, def)
+		return def
+	}
+
+	return n
+}
+
+// getEnvDuration parses a duration such as "30s" or "15m", falling back to def
+// when the variable is unset or malformed.
+func getEnvDuration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("Invalid %s %q, using %s", key, v, def)
+		return def
+	}
+
+	return d
+}
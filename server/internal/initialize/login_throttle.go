@@ -0,0 +1,29 @@
+package initialize
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/internal/repositories"
+	"github.com/vlahanam/company-management/internal/services"
+)
+
+// InitLoginThrottle builds the failed login throttle. The database store is
+// shared by every instance; the memory store suits single-node deployments.
+func InitLoginThrottle(cfg *Config, db *gorm.DB) services.LoginThrottle {
+	c := cfg.Auth.LoginThrottle
+
+	var store services.LoginAttemptRepo = repositories.NewMySQLStorage(db)
+	if c.Store == "memory" {
+		store = services.NewMemoryLoginAttemptStore()
+	}
+
+	return services.NewLoginThrottle(store, services.LoginThrottlePolicy{
+		MaxFailuresPerAccount:   c.MaxFailuresPerAccount,
+		MaxFailuresPerIP:        c.MaxFailuresPerIP,
+		MaxFailuresPerChallenge: c.MaxFailuresPerChallenge,
+		FailureWindow:           c.FailureWindow,
+		LockoutDuration:         c.LockoutDuration,
+		BaseDelay:               c.BaseDelay,
+		MaxDelay:                c.MaxDelay,
+	})
+}
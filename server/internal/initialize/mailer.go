@@ -0,0 +1,23 @@
+package initialize
+
+import (
+	"log"
+	"os"
+
+	"github.com/vlahanam/company-management/internal/mailer"
+)
+
+func InitMailer(cfg *Config) mailer.Mailer {
+	switch cfg.Mail.Driver {
+	case "smtp":
+		return mailer.NewSMTPMailer(cfg.Mail.Host, cfg.Mail.Port, cfg.Mail.Username, cfg.Mail.Password, cfg.Mail.From)
+	case "file":
+		m, err := mailer.NewFileMailer(cfg.Mail.FilePath)
+		if err != nil {
+			log.Fatal("Failed to open mail file:", err)
+		}
+		return m
+	default:
+		return mailer.NewLogMailer(os.Stdout)
+	}
+}
@@ -0,0 +1,40 @@
+package initialize
+
+import (
+	"log"
+	"strings"
+
+	"github.com/vlahanam/company-management/internal/oidc"
+)
+
+// InitOIDCProviders builds the configured SSO providers by name. Discovery
+// happens on first use, so a provider that is down does not stop the API.
+func InitOIDCProviders(cfg *Config) map[string]*oidc.Provider {
+	providers := make(map[string]*oidc.Provider, len(cfg.OIDC))
+
+	for _, c := range cfg.OIDC {
+		if c.Issuer == "" || c.ClientID == "" {
+			log.Fatalf("OIDC provider %q needs an issuer and a client id", c.Name)
+		}
+
+		var domains []string
+		for _, d := range strings.Split(c.AllowedDomains, ",") {
+			if d = strings.TrimSpace(d); d != "" {
+				domains = append(domains, d)
+			}
+		}
+
+		providers[c.Name] = oidc.NewProvider(oidc.Config{
+			Name:           c.Name,
+			Issuer:         c.Issuer,
+			ClientID:       c.ClientID,
+			ClientSecret:   c.ClientSecret,
+			RedirectURL:    c.RedirectURL,
+			Scopes:         strings.Fields(c.Scopes),
+			AutoProvision:  c.AutoProvision,
+			AllowedDomains: domains,
+		}, nil)
+	}
+
+	return providers
+}
@@ -0,0 +1,41 @@
+package initialize
+
+import (
+	"log"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/vlahanam/company-management/utils"
+)
+
+// InitPasswordPolicy applies the password hashing settings and the rules new
+// passwords must follow.
+func InitPasswordPolicy(cfg *Config) {
+	c := cfg.Auth.Password
+
+	algorithm := c.HashAlgorithm
+	if algorithm != utils.HashAlgorithmBcrypt && algorithm != utils.HashAlgorithmArgon2id {
+		log.Fatalf("Unsupported PASSWORD_HASH_ALGORITHM %q", algorithm)
+	}
+	if c.BcryptCost < bcrypt.MinCost || c.BcryptCost > bcrypt.MaxCost {
+		log.Fatalf("BCRYPT_COST must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	if c.Argon2Memory < 8*1024 || c.Argon2Iterations < 1 || c.Argon2Parallelism < 1 || c.Argon2Parallelism > 255 {
+		log.Fatal("ARGON2_MEMORY must be at least 8192 KiB, ARGON2_ITERATIONS and ARGON2_PARALLELISM (up to 255) at least 1")
+	}
+
+	utils.SetPasswordHashing(utils.PasswordHashing{
+		Algorithm:         algorithm,
+		BcryptCost:        c.BcryptCost,
+		Argon2Memory:      uint32(c.Argon2Memory),
+		Argon2Iterations:  uint32(c.Argon2Iterations),
+		Argon2Parallelism: uint8(c.Argon2Parallelism),
+	})
+
+	policy, err := utils.NewPasswordPolicy(c.MinLength, c.MaxLength, c.MinClasses, c.History, c.BlocklistFile)
+	if err != nil {
+		log.Fatalf("Failed to load PASSWORD_BLOCKLIST_FILE: %v", err)
+	}
+
+	utils.SetPasswordPolicy(policy)
+}
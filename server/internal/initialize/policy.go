@@ -0,0 +1,40 @@
+package initialize
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+
+	"github.com/vlahanam/company-management/internal/policy"
+)
+
+// InitPolicies loads the rules of POLICY_FILE. Without it only the policies
+// stored in the database apply.
+func InitPolicies(cfg *Config) {
+	if cfg.Policy.File == "" {
+		return
+	}
+
+	data, err := os.ReadFile(cfg.Policy.File)
+	if err != nil {
+		log.Fatalf("Failed to read POLICY_FILE: %v", err)
+	}
+
+	var rules []*policy.Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		log.Fatalf("Failed to parse POLICY_FILE: %v", err)
+	}
+
+	names := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			log.Fatalf("Invalid rule in POLICY_FILE: %v", err)
+		}
+		if names[rule.Name] {
+			log.Fatalf("Duplicate rule %q in POLICY_FILE", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+
+	policy.SetConfigured(rules)
+}
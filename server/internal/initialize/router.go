@@ -7,17 +7,22 @@ import (
 
 	"github.com/vlahanam/company-management/internal/controllers"
 	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/policy"
+	"github.com/vlahanam/company-management/internal/repositories"
+	"github.com/vlahanam/company-management/internal/services"
 	"github.com/vlahanam/company-management/utils"
 )
 
 func InitRoute(cfg *Config, db *gorm.DB) {
-	app := fiber.New()
+	app := fiber.New(fiber.Config{
+		ProxyHeader: cfg.Fiber.ProxyHeader,
+	})
 
 	// CORS middleware
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     cfg.CORS.AllowedOrigins,
 		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders:     "Origin,Content-Type,Accept,Authorization",
+		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,X-API-Key",
 		AllowCredentials: true,
 	}))
 
@@ -28,48 +33,189 @@ func InitRoute(cfg *Config, db *gorm.DB) {
 		})
 	})
 
+	accessKeys, refreshKeys := InitTokenKeys(cfg)
+	revocations := services.NewTokenRevocationService(repositories.NewMySQLStorage(db), cfg.Auth.RevocationCacheTTL)
+	authOpts := &services.AuthOptions{
+		AccessKeys:    accessKeys,
+		RefreshKeys:   refreshKeys,
+		Revocations:   revocations,
+		LoginThrottle: InitLoginThrottle(cfg, db),
+
+		Mailer:           InitMailer(cfg),
+		ClientURL:        cfg.Client.URL,
+		PasswordResetTTL: cfg.Auth.PasswordResetTTL,
+
+		VerifySecret:         cfg.Auth.VerifySecret,
+		EmailVerificationTTL: cfg.Auth.EmailVerificationTTL,
+		InvitationTTL:        cfg.Auth.InvitationTTL,
+
+		TOTPIssuer: cfg.Auth.TOTPIssuer,
+
+		WebAuthn: InitWebAuthn(cfg),
+
+		OIDCProviders: InitOIDCProviders(cfg),
+	}
+
+	// Public keys for services that verify access tokens themselves
+	app.Get("/.well-known/jwks.json", controllers.JWKSHandler(accessKeys))
+
 	v1 := app.Group("api/v1")
+	v1.Use(utils.ClientInfoMiddleware())
+
+	v1.Post("/login", controllers.LoginHandler(db, authOpts))
+	v1.Post("/login/mfa", controllers.MFALoginHandler(db, authOpts))
+	v1.Post("/login/mfa/setup", controllers.MFALoginSetupHandler(db, authOpts))
+	v1.Post("/login/mfa/confirm", controllers.MFALoginConfirmHandler(db, authOpts))
+	v1.Post("/login/password/change", controllers.ChangeExpiredPasswordHandler(db, authOpts))
+	v1.Post("/login/passkey/begin", controllers.PasskeyLoginBeginHandler(db, authOpts))
+	v1.Post("/login/passkey/finish", controllers.PasskeyLoginFinishHandler(db, authOpts))
+	v1.Get("/login/oidc", controllers.GetListOIDCProviders(db, authOpts))
+	v1.Post("/login/oidc/:provider", controllers.OIDCLoginBeginHandler(db, authOpts))
+	v1.Post("/login/oidc/:provider/callback", controllers.OIDCLoginCallbackHandler(db, authOpts))
+	v1.Post("/refresh", controllers.RefreshHandler(db, authOpts))
+	v1.Post("/logout", controllers.LogoutHandler(db, authOpts))
+	if cfg.Auth.OpenRegistration {
+		v1.Post("/register", controllers.RegisterHandler(db, authOpts))
+	}
+	v1.Post("/invitations/accept", controllers.AcceptInvitationHandler(db, authOpts))
+	v1.Post("/email/verify", controllers.VerifyEmailHandler(db, authOpts))
+	v1.Post("/email/verify/resend", controllers.ResendVerificationHandler(db, authOpts))
+	v1.Post("/password/forgot", controllers.ForgotPasswordHandler(db, authOpts))
+	v1.Post("/password/reset", controllers.ResetPasswordHandler(db, authOpts))
+
+	rp := repositories.NewMySQLStorage(db)
+	serviceAccounts := services.NewServiceAccountService(services.NewUserService(rp), rp)
+
+	// SCIM provisioning for identity providers, authenticated with an API key
+	// sent as a bearer token
+	scim := app.Group("/scim/v2", utils.ClientInfoMiddleware(), utils.SCIMAuthMiddleware(serviceAccounts, serviceAccounts, models.PermissionProvisionUsers))
+	scim.Get("/ServiceProviderConfig", controllers.GetSCIMServiceProviderConfig())
+	scim.Get("/Users", controllers.GetListSCIMUsers(db, authOpts))
+	scim.Post("/Users", controllers.CreateSCIMUser(db, authOpts))
+	scim.Get("/Users/:id", controllers.GetSCIMUser(db, authOpts))
+	scim.Put("/Users/:id", controllers.ReplaceSCIMUser(db, authOpts))
+	scim.Patch("/Users/:id", controllers.PatchSCIMUser(db, authOpts))
+	scim.Delete("/Users/:id", controllers.DeleteSCIMUser(db, authOpts))
+	scim.Get("/Groups", controllers.GetListSCIMGroups(db, authOpts))
+	scim.Post("/Groups", controllers.CreateSCIMGroup(db, authOpts))
+	scim.Get("/Groups/:id", controllers.GetSCIMGroup(db, authOpts))
+	scim.Put("/Groups/:id", controllers.ReplaceSCIMGroup(db, authOpts))
+	scim.Patch("/Groups/:id", controllers.PatchSCIMGroup(db, authOpts))
+	scim.Delete("/Groups/:id", controllers.DeleteSCIMGroup(db, authOpts))
+
+	v1.Use(utils.AuthMiddleware(accessKeys, revocations, serviceAccounts))
+	v1.Use(utils.ImpersonationAuditMiddleware(services.NewAuthService(services.NewUserService(rp), rp, authOpts)))
+
+	// Sensitive actions that an impersonating Super Admin must not take on the
+	// user's behalf
+	noImpersonation := utils.DenyImpersonation()
+	superAdmin := utils.CheckGlobalRole(services.NewUserService(rp), models.RoleSuperAdmin)
 
-	v1.Post("/login", controllers.LoginHandler(db, cfg.Auth.AccessSecret, cfg.Auth.RefreshSecret))
-	v1.Post("/refresh", controllers.RefreshHandler(db, cfg.Auth.AccessSecret, cfg.Auth.RefreshSecret))
-	v1.Post("/register", controllers.RegisterHandler(db))
+	v1.Post("/logout-all", noImpersonation, controllers.LogoutAllHandler(db, authOpts))
 
-	v1.Use(utils.AuthMiddleware(cfg.Auth.AccessSecret))
+	v1.Post("/impersonation/stop", controllers.StopImpersonating(db, authOpts))
+	v1.Get("/impersonations", noImpersonation, superAdmin, controllers.GetListImpersonations(db, authOpts))
+	v1.Get("/impersonations/:id", noImpersonation, superAdmin, controllers.GetImpersonation(db, authOpts))
+	v1.Delete("/impersonations/:id", noImpersonation, superAdmin, controllers.EndImpersonation(db, authOpts))
 
-	v1.Get("/users", utils.CheckRole([]string{models.RoleNames[models.RoleSuperAdmin]}), controllers.GetListUsers(db))
+	v1.Get("/me", controllers.GetMyProfile(db, authOpts))
+	v1.Put("/me", controllers.UpdateMyProfile(db, authOpts))
+	v1.Post("/me/password", noImpersonation, controllers.ChangeMyPassword(db, authOpts))
+	v1.Get("/me/sessions", controllers.GetListMySessions(db, authOpts))
+	v1.Delete("/me/sessions/:id", noImpersonation, controllers.DeleteMySession(db, authOpts))
+
+	v1.Post("/mfa/totp/setup", noImpersonation, controllers.SetupTOTP(db, authOpts))
+	v1.Post("/mfa/totp/confirm", noImpersonation, controllers.ConfirmTOTP(db, authOpts))
+	v1.Post("/mfa/totp/disable", noImpersonation, controllers.DisableTOTP(db, authOpts))
+	v1.Post("/mfa/recovery-codes", noImpersonation, controllers.RegenerateRecoveryCodes(db, authOpts))
+
+	v1.Post("/webauthn/register/begin", noImpersonation, controllers.BeginPasskeyRegistration(db, authOpts))
+	v1.Post("/webauthn/register/finish", noImpersonation, controllers.FinishPasskeyRegistration(db, authOpts))
+	v1.Get("/webauthn/credentials", controllers.GetListPasskeys(db, authOpts))
+	v1.Delete("/webauthn/credentials/:id", noImpersonation, controllers.DeletePasskey(db, authOpts))
+
+	// Resolves both users and API keys
+	perms := serviceAccounts
+
+	// The company each request is about; roles assigned in that company, or
+	// in a parent company with its subsidiaries, apply there
+	userCompanies := controllers.CompaniesOfUser(db)
+	company := controllers.CompanyFromParam("id")
+	positionsCompany := controllers.CompanyFromNumericParam("company_id")
+	positionCompany := controllers.CompanyOfPosition(db)
+	contractCompany := controllers.CompanyOfContract(db)
+	invitationCompany := controllers.CompanyOfInvitation(db, authOpts)
+
+	v1.Post("/users", utils.CheckPermission(perms, models.PermissionCreateUser), controllers.CreateUser(db, authOpts))
+	v1.Get("/users", superAdmin, controllers.GetListUsers(db))
 	v1.Get("/users/:id", controllers.GetUser(db))
 	v1.Put("/users/:id", controllers.UpdateUser(db))
-	v1.Delete("/users/:id", controllers.DeleteUser(db))
-
-	v1.Post("/companies", controllers.CreateCompany(db))
-	v1.Get("/companies", controllers.GetListCompanies(db))
-	v1.Get("/companies/:id", controllers.GetCompany(db))
-	v1.Put("/companies/:id", controllers.UpdateCompany(db))
-	v1.Delete("/companies/:id", controllers.DeleteCompany(db))
-
-	v1.Post("/positions/:company_id", controllers.CreatePosition(db))
-	v1.Get("/positions/:company_id", controllers.GetListPositions(db))
-	v1.Get("/positions/:id", controllers.GetPosition(db))
-	v1.Put("/positions/:id", controllers.UpdatePosition(db))
-	v1.Delete("/positions/:id", controllers.DeletePosition(db))
-
-	v1.Post("/contracts", controllers.CreateContract(db))
-	v1.Get("/contracts", controllers.GetListContracts(db))
-	v1.Get("/contracts/:id", controllers.GetContract(db))
-	v1.Put("/contracts/:id", controllers.UpdateContract(db))
-	v1.Delete("/contracts/:id", controllers.DeleteContract(db))
-
-	v1.Post("/roles", controllers.CreateRole(db))
-	v1.Get("/roles", controllers.GetListRoles(db))
-	v1.Get("/roles/:id", controllers.GetRole(db))
-	v1.Put("/roles/:id", controllers.UpdateRole(db))
-	v1.Delete("/roles/:id", controllers.DeleteRole(db))
-
-	v1.Post("/permissions", controllers.CreatePermission(db))
-	v1.Get("/permissions", controllers.GetListPermissions(db))
-	v1.Get("/permissions/:id", controllers.GetPermission(db))
-	v1.Put("/permissions/:id", controllers.UpdatePermission(db))
-	v1.Delete("/permissions/:id", controllers.DeletePermission(db))
+	v1.Delete("/users/:id", noImpersonation, controllers.DeleteUser(db, revocations))
+	v1.Post("/users/:id/impersonate", noImpersonation, superAdmin, controllers.ImpersonateUser(db, authOpts))
+	v1.Post("/users/:id/unlock", utils.CheckPermissionIn(perms, userCompanies, models.PermissionUpdateUser), controllers.UnlockUser(db, authOpts))
+	v1.Get("/users/:id/sessions", utils.CheckPermissionIn(perms, userCompanies, models.PermissionReadUser), controllers.GetListUserSessions(db, authOpts))
+	v1.Get("/users/:id/roles", utils.CheckPermissionIn(perms, userCompanies, models.PermissionReadUser), controllers.GetListUserRoles(db, authOpts))
+	v1.Get("/users/:id/permissions/explain", utils.CheckPermission(perms, models.PermissionManageRoles), controllers.ExplainUserPermission(db))
+	v1.Post("/users/:id/roles", noImpersonation, utils.CheckPermissionIn(perms, userCompanies, models.PermissionManageRoles), controllers.AssignUserRole(db, authOpts))
+	v1.Delete("/users/:id/roles/:assignment_id", noImpersonation, utils.CheckPermissionIn(perms, userCompanies, models.PermissionManageRoles), controllers.RemoveUserRole(db, authOpts))
+	v1.Delete("/users/:id/sessions/:session_id", noImpersonation, utils.CheckPermissionIn(perms, userCompanies, models.PermissionUpdateUser), controllers.DeleteUserSession(db, authOpts))
+
+	v1.Post("/invitations", noImpersonation, utils.CheckPermissionIn(perms, controllers.CompanyFromBody("company_id"), models.PermissionCreateUser), controllers.CreateInvitation(db, authOpts))
+	v1.Get("/invitations", utils.CheckPermissionIn(perms, controllers.CompanyFromQuery("company_id"), models.PermissionCreateUser), controllers.GetListInvitations(db, authOpts))
+	v1.Get("/invitations/:id", utils.CheckPermissionIn(perms, invitationCompany, models.PermissionCreateUser), controllers.GetInvitation(db, authOpts))
+	v1.Delete("/invitations/:id", noImpersonation, utils.CheckPermissionIn(perms, invitationCompany, models.PermissionCreateUser), controllers.RevokeInvitation(db, authOpts))
+
+	v1.Post("/service-accounts", noImpersonation, utils.CheckPermission(perms, models.PermissionManageServiceAccounts), controllers.CreateServiceAccount(db))
+	v1.Get("/service-accounts", utils.CheckPermission(perms, models.PermissionManageServiceAccounts), controllers.GetListServiceAccounts(db))
+	v1.Get("/service-accounts/:id", utils.CheckPermission(perms, models.PermissionManageServiceAccounts), controllers.GetServiceAccount(db))
+	v1.Put("/service-accounts/:id", noImpersonation, utils.CheckPermission(perms, models.PermissionManageServiceAccounts), controllers.UpdateServiceAccount(db))
+	v1.Delete("/service-accounts/:id", noImpersonation, utils.CheckPermission(perms, models.PermissionManageServiceAccounts), controllers.DeleteServiceAccount(db))
+	v1.Post("/service-accounts/:id/api-keys", noImpersonation, utils.CheckPermission(perms, models.PermissionManageServiceAccounts), controllers.CreateAPIKey(db))
+	v1.Get("/service-accounts/:id/api-keys", utils.CheckPermission(perms, models.PermissionManageServiceAccounts), controllers.GetListAPIKeys(db))
+	v1.Post("/service-accounts/:id/api-keys/:key_id/rotate", noImpersonation, utils.CheckPermission(perms, models.PermissionManageServiceAccounts), controllers.RotateAPIKey(db))
+	v1.Delete("/service-accounts/:id/api-keys/:key_id", noImpersonation, utils.CheckPermission(perms, models.PermissionManageServiceAccounts), controllers.RevokeAPIKey(db))
+
+	v1.Post("/companies", utils.CheckPermissionIn(perms, controllers.CompanyFromBody("parent_id"), models.PermissionCreateCompany), controllers.CreateCompany(db))
+	v1.Get("/companies", utils.CheckPermission(perms, models.PermissionReadCompany), controllers.GetListCompanies(db))
+	v1.Get("/companies/:id", utils.CheckPermissionIn(perms, company, models.PermissionReadCompany), controllers.GetCompany(db))
+	v1.Put("/companies/:id", utils.CheckPermissionIn(perms, company, models.PermissionUpdateCompany), controllers.UpdateCompany(db))
+	v1.Delete("/companies/:id", utils.CheckPermissionIn(perms, company, models.PermissionDeleteCompany), controllers.DeleteCompany(db))
+
+	v1.Post("/positions/:company_id", utils.CheckPermissionIn(perms, positionsCompany, models.PermissionPosition), controllers.CreatePosition(db))
+	v1.Get("/positions/:company_id", utils.CheckPermissionIn(perms, positionsCompany, models.PermissionReadPosition), controllers.GetListPositions(db))
+	v1.Get("/positions/:id", utils.CheckPermissionIn(perms, positionCompany, models.PermissionReadPosition), controllers.GetPosition(db))
+	v1.Put("/positions/:id", utils.CheckPermissionIn(perms, positionCompany, models.PermissionUpdatePosition), controllers.UpdatePosition(db))
+	v1.Delete("/positions/:id", utils.CheckPermissionIn(perms, positionCompany, models.PermissionDeletePosition), controllers.DeletePosition(db))
+
+	v1.Post("/contracts", utils.CheckPermissionIn(perms, controllers.CompanyFromBody("company_id"), models.PermissionCreateContract), controllers.CreateContract(db))
+	v1.Get("/contracts", utils.CheckPermissionIn(perms, controllers.CompanyFromQuery("company_id"), models.PermissionReadContract), controllers.GetListContracts(db))
+	v1.Get("/contracts/:id", controllers.CheckPolicy(db, policy.ResourceContract, policy.ActionRead, utils.CheckPermissionIn(perms, contractCompany, models.PermissionReadContract)), controllers.GetContract(db))
+	v1.Put("/contracts/:id", controllers.CheckPolicy(db, policy.ResourceContract, policy.ActionUpdate, utils.CheckPermissionIn(perms, contractCompany, models.PermissionUpdateContract)), controllers.UpdateContract(db))
+	v1.Delete("/contracts/:id", controllers.CheckPolicy(db, policy.ResourceContract, policy.ActionDelete, utils.CheckPermissionIn(perms, contractCompany, models.PermissionDeleteContract)), controllers.DeleteContract(db))
+
+	v1.Get("/policies", utils.CheckPermission(perms, models.PermissionManageRoles), controllers.GetListPolicies(db))
+	v1.Post("/policies", noImpersonation, utils.CheckPermission(perms, models.PermissionManageRoles), controllers.CreatePolicy(db))
+	v1.Post("/policies/evaluate", utils.CheckPermission(perms, models.PermissionManageRoles), controllers.EvaluatePolicy(db))
+	v1.Get("/policies/:id", utils.CheckPermission(perms, models.PermissionManageRoles), controllers.GetPolicy(db))
+	v1.Put("/policies/:id", noImpersonation, utils.CheckPermission(perms, models.PermissionManageRoles), controllers.UpdatePolicy(db))
+	v1.Delete("/policies/:id", noImpersonation, utils.CheckPermission(perms, models.PermissionManageRoles), controllers.DeletePolicy(db))
+
+	v1.Post("/roles", noImpersonation, utils.CheckPermission(perms, models.PermissionManageRoles), controllers.CreateRole(db))
+	v1.Get("/roles", utils.CheckPermission(perms, models.PermissionManageRoles), controllers.GetListRoles(db))
+	v1.Get("/roles/:id", utils.CheckPermission(perms, models.PermissionManageRoles), controllers.GetRole(db))
+	v1.Put("/roles/:id", noImpersonation, utils.CheckPermission(perms, models.PermissionManageRoles), controllers.UpdateRole(db))
+	v1.Delete("/roles/:id", noImpersonation, utils.CheckPermission(perms, models.PermissionManageRoles), controllers.DeleteRole(db))
+	v1.Get("/roles/:id/permissions", utils.CheckPermission(perms, models.PermissionManageRoles), controllers.GetRolePermissions(db))
+	v1.Post("/roles/:id/permissions", noImpersonation, utils.CheckPermission(perms, models.PermissionManageRoles), controllers.GrantRolePermissions(db))
+	v1.Delete("/roles/:id/permissions", noImpersonation, utils.CheckPermission(perms, models.PermissionManageRoles), controllers.RevokeRolePermissions(db))
+	v1.Put("/roles/:id/permissions/:permission_id", noImpersonation, utils.CheckPermission(perms, models.PermissionManageRoles), controllers.GrantRolePermission(db))
+	v1.Delete("/roles/:id/permissions/:permission_id", noImpersonation, utils.CheckPermission(perms, models.PermissionManageRoles), controllers.RevokeRolePermission(db))
+
+	v1.Post("/permissions", noImpersonation, utils.CheckPermission(perms, models.PermissionManageRoles), controllers.CreatePermission(db))
+	v1.Get("/permissions", utils.CheckPermission(perms, models.PermissionManageRoles), controllers.GetListPermissions(db))
+	v1.Get("/permissions/:id", utils.CheckPermission(perms, models.PermissionManageRoles), controllers.GetPermission(db))
+	v1.Put("/permissions/:id", noImpersonation, utils.CheckPermission(perms, models.PermissionManageRoles), controllers.UpdatePermission(db))
+	v1.Delete("/permissions/:id", noImpersonation, utils.CheckPermission(perms, models.PermissionManageRoles), controllers.DeletePermission(db))
 
 	port := ":" + cfg.Fiber.Port
 	app.Listen(port)
@@ -3,5 +3,7 @@ package initialize
 func Run() {
 	cfg := LoadConfig()
 	db := InitMysql(cfg)
+	InitPasswordPolicy(cfg)
+	InitPolicies(cfg)
 	InitRoute(cfg, db)
 }
\ No newline at end of file
@@ -0,0 +1,38 @@
+package initialize
+
+import (
+	"log"
+	"strings"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// InitWebAuthn builds the passkey relying party. It returns nil when no RP ID
+// is configured, which disables the passkey endpoints.
+func InitWebAuthn(cfg *Config) *webauthn.WebAuthn {
+	if cfg.WebAuthn.RPID == "" {
+		return nil
+	}
+
+	var origins []string
+	for _, o := range strings.Split(cfg.WebAuthn.RPOrigins, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			origins = append(origins, o)
+		}
+	}
+
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          cfg.WebAuthn.RPID,
+		RPDisplayName: cfg.WebAuthn.RPDisplayName,
+		RPOrigins:     origins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			UserVerification: protocol.VerificationPreferred,
+		},
+	})
+	if err != nil {
+		log.Fatal("Failed to configure WebAuthn:", err)
+	}
+
+	return w
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/vlahanam/company-management/common"
+)
+
+var (
+	ErrImpersonationNotFound = errors.New("impersonation session not found")
+	ErrImpersonateSelf       = errors.New("you cannot impersonate yourself")
+	ErrImpersonateSuperAdmin = errors.New("super admin accounts cannot be impersonated")
+	ErrNotImpersonating      = errors.New("this request is not made while impersonating")
+	ErrImpersonationEnded    = errors.New("impersonation session has already ended")
+)
+
+// ImpersonationSession records a Super Admin acting as another user. Its ID is
+// the sid of the impersonation token.
+type ImpersonationSession struct {
+	ID        string     `json:"id" gorm:"column:id;primaryKey"`
+	ActorID   uint64     `json:"-" gorm:"column:actor_id"`
+	SubjectID uint64     `json:"-" gorm:"column:subject_id"`
+	Reason    string     `json:"reason" gorm:"column:reason"`
+	IPAddress *string    `json:"ip_address,omitempty" gorm:"column:ip_address"`
+	UserAgent *string    `json:"user_agent,omitempty" gorm:"column:user_agent"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"column:expires_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty" gorm:"column:ended_at"`
+	CreatedAt *time.Time `json:"created_at,omitempty" gorm:"column:created_at"`
+
+	ActorUID   *common.UID `json:"actor_id" gorm:"-"`
+	SubjectUID *common.UID `json:"subject_id" gorm:"-"`
+
+	Requests []*ImpersonationRequest `json:"requests,omitempty" gorm:"foreignKey:SessionID"`
+}
+
+func (ImpersonationSession) TableName() string {
+	return "impersonation_sessions"
+}
+
+// Mask exposes the actor and subject as base58 user ids.
+func (s *ImpersonationSession) Mask() {
+	actor := common.NewUID(uint32(s.ActorID), common.ObjectTypeUser, 1)
+	subject := common.NewUID(uint32(s.SubjectID), common.ObjectTypeUser, 1)
+	s.ActorUID = &actor
+	s.SubjectUID = &subject
+}
+
+// ImpersonationRequest is one API request made with an impersonation token.
+type ImpersonationRequest struct {
+	ID        uint64     `json:"-" gorm:"column:id"`
+	SessionID string     `json:"-" gorm:"column:session_id"`
+	Method    string     `json:"method" gorm:"column:method"`
+	Path      string     `json:"path" gorm:"column:path"`
+	Status    int        `json:"status" gorm:"column:status"`
+	IPAddress *string    `json:"ip_address,omitempty" gorm:"column:ip_address"`
+	CreatedAt *time.Time `json:"created_at,omitempty" gorm:"column:created_at"`
+}
+
+func (ImpersonationRequest) TableName() string {
+	return "impersonation_requests"
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/vlahanam/company-management/common"
+)
+
+var (
+	ErrInvitationNotFound      = errors.New("invitation not found")
+	ErrInvalidInvitationToken  = errors.New("invitation link is invalid or expired")
+	ErrInvitationClosed        = errors.New("invitation has already been accepted or withdrawn")
+	ErrPositionNotInCompany    = errors.New("position does not belong to the company")
+	ErrRoleNotFound            = errors.New("role not found")
+	ErrInvitationRoleForbidden = errors.New("you can only grant roles whose permissions you hold in the company")
+)
+
+// Invitation asks someone to join a company. Accepting it creates the user
+// with the position and the roles, assigned in that company.
+type Invitation struct {
+	ID         string     `json:"id" gorm:"column:id;primaryKey"`
+	Email      string     `json:"email" gorm:"column:email"`
+	FullName   *string    `json:"full_name,omitempty" gorm:"column:full_name"`
+	CompanyID  uint64     `json:"company_id" gorm:"column:company_id"`
+	PositionID *uint64    `json:"position_id,omitempty" gorm:"column:position_id"`
+	InvitedBy  *uint64    `json:"-" gorm:"column:invited_by"`
+	UserID     *uint64    `json:"-" gorm:"column:user_id"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"column:expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty" gorm:"column:accepted_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" gorm:"column:revoked_at"`
+	CreatedAt  *time.Time `json:"created_at,omitempty" gorm:"column:created_at"`
+
+	InvitedByUID *common.UID `json:"invited_by,omitempty" gorm:"-"`
+	UserUID      *common.UID `json:"user_id,omitempty" gorm:"-"`
+
+	Roles []*InvitationRole `json:"roles,omitempty" gorm:"foreignKey:InvitationID"`
+}
+
+func (Invitation) TableName() string {
+	return "invitations"
+}
+
+// Mask exposes the inviter and the created user as base58 user ids.
+func (i *Invitation) Mask() {
+	if i.InvitedBy != nil {
+		uid := common.NewUID(uint32(*i.InvitedBy), common.ObjectTypeUser, 1)
+		i.InvitedByUID = &uid
+	}
+	if i.UserID != nil {
+		uid := common.NewUID(uint32(*i.UserID), common.ObjectTypeUser, 1)
+		i.UserUID = &uid
+	}
+}
+
+// Open reports whether the invitation can still be accepted.
+func (i *Invitation) Open(now time.Time) bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && now.Before(i.ExpiresAt)
+}
+
+// RoleIDs lists the roles the invitation grants.
+func (i *Invitation) RoleIDs() []int64 {
+	ids := make([]int64, 0, len(i.Roles))
+	for _, r := range i.Roles {
+		ids = append(ids, r.RoleID)
+	}
+
+	return ids
+}
+
+// InvitationRole is a role granted by an invitation.
+type InvitationRole struct {
+	InvitationID string `json:"-" gorm:"column:invitation_id;primaryKey"`
+	RoleID       int64  `json:"role_id" gorm:"column:role_id;primaryKey"`
+
+	Role *Role `json:"role,omitempty" gorm:"foreignKey:RoleID"`
+}
+
+func (InvitationRole) TableName() string {
+	return "invitation_roles"
+}
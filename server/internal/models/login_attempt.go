@@ -0,0 +1,23 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidCredentials   = errors.New("invalid email or password")
+	ErrTooManyLoginAttempts = errors.New("too many failed login attempts, try again later")
+)
+
+// LoginAttempt counts the recent failed logins of one account or client IP.
+type LoginAttempt struct {
+	Key          string     `json:"key" gorm:"column:attempt_key;primaryKey"`
+	Failures     int        `json:"failures" gorm:"column:failures"`
+	LastFailedAt time.Time  `json:"last_failed_at" gorm:"column:last_failed_at"`
+	LockedUntil  *time.Time `json:"locked_until,omitempty" gorm:"column:locked_until"`
+}
+
+func (LoginAttempt) TableName() string {
+	return "login_attempts"
+}
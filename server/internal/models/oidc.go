@@ -0,0 +1,43 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrOIDCProviderNotFound = errors.New("sso provider not found")
+	ErrInvalidOIDCState     = errors.New("sso login is invalid or expired")
+	ErrOIDCLoginFailed      = errors.New("sso login failed")
+	ErrOIDCNoAccount        = errors.New("no account is linked to this sso identity")
+)
+
+// UserIdentity links a user to the subject of an external identity provider.
+type UserIdentity struct {
+	ID          uint64     `json:"-" gorm:"column:id"`
+	UserID      uint64     `json:"-" gorm:"column:user_id"`
+	Provider    string     `json:"provider" gorm:"column:provider"`
+	Subject     string     `json:"subject" gorm:"column:subject"`
+	Email       *string    `json:"email,omitempty" gorm:"column:email"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty" gorm:"column:last_login_at"`
+	CreatedAt   *time.Time `json:"created_at,omitempty" gorm:"column:created_at"`
+}
+
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
+
+// OIDCLoginState keeps the PKCE verifier and nonce of a login between the
+// redirect to the provider and the callback. It is deleted on the callback.
+type OIDCLoginState struct {
+	StateHash    string     `json:"-" gorm:"column:state_hash;primaryKey"`
+	Provider     string     `json:"provider" gorm:"column:provider"`
+	CodeVerifier string     `json:"-" gorm:"column:code_verifier"`
+	Nonce        string     `json:"-" gorm:"column:nonce"`
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"column:expires_at"`
+	CreatedAt    *time.Time `json:"created_at,omitempty" gorm:"column:created_at"`
+}
+
+func (OIDCLoginState) TableName() string {
+	return "oidc_login_states"
+}
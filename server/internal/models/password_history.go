@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// PasswordHistory keeps the hash of a replaced password so it cannot be
+// chosen again.
+type PasswordHistory struct {
+	ID           uint64     `json:"-" gorm:"column:id"`
+	UserID       uint64     `json:"user_id" gorm:"column:user_id"`
+	HashPassword string     `json:"-" gorm:"column:hash_password"`
+	CreatedAt    *time.Time `json:"created_at,omitempty" gorm:"column:created_at"`
+}
+
+func (PasswordHistory) TableName() string {
+	return "password_history"
+}
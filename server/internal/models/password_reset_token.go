@@ -0,0 +1,23 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrPasswordResetTokenInvalid = errors.New("password reset token is invalid or expired")
+)
+
+type PasswordResetToken struct {
+	ID        uint64     `json:"-" gorm:"column:id"`
+	UserID    uint64     `json:"user_id" gorm:"column:user_id"`
+	TokenHash string     `json:"-" gorm:"column:token_hash"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"column:expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" gorm:"column:used_at"`
+	CreatedAt *time.Time `json:"created_at,omitempty" gorm:"column:created_at"`
+}
+
+func (PasswordResetToken) TableName() string {
+	return "password_reset_tokens"
+}
@@ -1,9 +1,17 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+var ErrPermissionCodeExists = errors.New("permission code already exists")
 
 type Permission struct {
-	ID          int64      `json:"id" gorm:"column:id"`
+	ID int64 `json:"id" gorm:"column:id"`
+	// Stable machine code such as contract.create, empty for permissions
+	// created without one
+	Code        *string    `json:"code,omitempty" gorm:"column:code"`
 	Name        string     `json:"name" gorm:"column:name"`
 	Description string     `json:"description,omitempty" gorm:"column:description"`
 	CreatedAt   *time.Time `json:"created_at,omitempty" gorm:"column:created_at"`
@@ -49,6 +57,11 @@ const (
 	PermissionCreateRequest
 	PermissionUpdateRequest
 	PermissionDeleteRequest
+	PermissionReadCompany
+	PermissionReadPosition
+	PermissionReadContract
+	PermissionManageServiceAccounts
+	PermissionProvisionUsers
 )
 
 var PermissionNames = map[int64]string{
@@ -80,4 +93,85 @@ var PermissionNames = map[int64]string{
 	PermissionCreateRequest:         "Create Request",
 	PermissionUpdateRequest:         "Update Request",
 	PermissionDeleteRequest:         "Delete Request",
+	PermissionReadCompany:           "Read Company",
+	PermissionReadPosition:          "Read Position",
+	PermissionReadContract:          "Read Contract",
+	PermissionManageServiceAccounts: "Manage Service Accounts",
+	PermissionProvisionUsers:        "Provision Users",
+}
+
+// PermissionCodes are the stable machine codes of the built-in permissions.
+// Unlike names and ids they never change, so integrations and configuration
+// can refer to them.
+var PermissionCodes = map[int64]string{
+	PermissionCreateUser:            "user.create",
+	PermissionReadUser:              "user.read",
+	PermissionUpdateUser:            "user.update",
+	PermissionDeleteUser:            "user.delete",
+	PermissionManageRoles:           "role.manage",
+	PermissionViewOwnReport:         "report.view_own",
+	PermissionViewSubordinateReport: "report.view_subordinate",
+	PermissionViewAllReports:        "report.view_all",
+	PermissionCreateReport:          "report.create",
+	PermissionDeleteReport:          "report.delete",
+	PermissionManageFinances:        "finance.manage",
+	PermissionManageInventory:       "inventory.manage",
+	PermissionPosition:              "position.create",
+	PermissionUpdatePosition:        "position.update",
+	PermissionDeletePosition:        "position.delete",
+	PermissionCreateDepartment:      "department.create",
+	PermissionUpdateDepartment:      "department.update",
+	PermissionDeleteDepartment:      "department.delete",
+	PermissionCreateCompany:         "company.create",
+	PermissionUpdateCompany:         "company.update",
+	PermissionDeleteCompany:         "company.delete",
+	PermissionCreateContract:        "contract.create",
+	PermissionUpdateContract:        "contract.update",
+	PermissionDeleteContract:        "contract.delete",
+	PermissionApproveRequests:       "request.approve",
+	PermissionCreateRequest:         "request.create",
+	PermissionUpdateRequest:         "request.update",
+	PermissionDeleteRequest:         "request.delete",
+	PermissionReadCompany:           "company.read",
+	PermissionReadPosition:          "position.read",
+	PermissionReadContract:          "contract.read",
+	PermissionManageServiceAccounts: "service_account.manage",
+	PermissionProvisionUsers:        "user.provision",
+}
+
+// PermissionDescriptions describe the built-in permissions.
+var PermissionDescriptions = map[int64]string{
+	PermissionCreateUser:            "Create new users",
+	PermissionReadUser:              "View user information",
+	PermissionUpdateUser:            "Update user information",
+	PermissionDeleteUser:            "Delete users",
+	PermissionManageRoles:           "Manage user roles",
+	PermissionViewOwnReport:         "View own reports",
+	PermissionViewSubordinateReport: "View subordinate reports",
+	PermissionViewAllReports:        "View all reports",
+	PermissionCreateReport:          "Create new reports",
+	PermissionDeleteReport:          "Delete reports",
+	PermissionManageFinances:        "Manage financial operations",
+	PermissionManageInventory:       "Manage inventory",
+	PermissionPosition:              "Create positions",
+	PermissionUpdatePosition:        "Update positions",
+	PermissionDeletePosition:        "Delete positions",
+	PermissionCreateDepartment:      "Create departments",
+	PermissionUpdateDepartment:      "Update departments",
+	PermissionDeleteDepartment:      "Delete departments",
+	PermissionCreateCompany:         "Create companies",
+	PermissionUpdateCompany:         "Update companies",
+	PermissionDeleteCompany:         "Delete companies",
+	PermissionCreateContract:        "Create contracts",
+	PermissionUpdateContract:        "Update contracts",
+	PermissionDeleteContract:        "Delete contracts",
+	PermissionApproveRequests:       "Approve requests",
+	PermissionCreateRequest:         "Create requests",
+	PermissionUpdateRequest:         "Update requests",
+	PermissionDeleteRequest:         "Delete requests",
+	PermissionReadCompany:           "View company information",
+	PermissionReadPosition:          "View positions",
+	PermissionReadContract:          "View contracts",
+	PermissionManageServiceAccounts: "Manage service accounts and their API keys",
+	PermissionProvisionUsers:        "Provision users and groups over SCIM",
 }
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/vlahanam/company-management/internal/policy"
+)
+
+var (
+	ErrPolicyNotFound   = errors.New("policy not found")
+	ErrPolicyNameExists = errors.New("policy name already exists")
+	ErrPolicyDenied     = errors.New("access denied by policy")
+)
+
+// Policy is an attribute-based rule stored in the database. Enabled policies
+// are evaluated together with the rules of the POLICY_FILE configuration.
+type Policy struct {
+	ID          int64              `json:"id" gorm:"column:id"`
+	Name        string             `json:"name" gorm:"column:name"`
+	Description string             `json:"description,omitempty" gorm:"column:description"`
+	Effect      policy.Effect      `json:"effect" gorm:"column:effect"`
+	Resource    string             `json:"resource" gorm:"column:resource"`
+	Actions     []string           `json:"actions" gorm:"column:actions;serializer:json"`
+	Conditions  []policy.Condition `json:"conditions" gorm:"column:conditions;serializer:json"`
+	Enabled     bool               `json:"enabled" gorm:"column:enabled"`
+	CreatedAt   *time.Time         `json:"created_at,omitempty" gorm:"column:created_at"`
+	UpdatedAt   *time.Time         `json:"updated_at,omitempty" gorm:"column:updated_at"`
+}
+
+func (Policy) TableName() string {
+	return "policies"
+}
+
+// Rule returns the policy in the form the policy package evaluates.
+func (p *Policy) Rule() *policy.Rule {
+	return &policy.Rule{
+		Name:        p.Name,
+		Description: p.Description,
+		Effect:      p.Effect,
+		Resource:    p.Resource,
+		Actions:     p.Actions,
+		Conditions:  p.Conditions,
+	}
+}
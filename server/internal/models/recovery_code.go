@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type RecoveryCode struct {
+	ID        uint64     `json:"-" gorm:"column:id"`
+	UserID    uint64     `json:"user_id" gorm:"column:user_id"`
+	CodeHash  string     `json:"-" gorm:"column:code_hash"`
+	UsedAt    *time.Time `json:"used_at,omitempty" gorm:"column:used_at"`
+	CreatedAt *time.Time `json:"created_at,omitempty" gorm:"column:created_at"`
+}
+
+func (RecoveryCode) TableName() string {
+	return "user_recovery_codes"
+}
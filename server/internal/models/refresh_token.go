@@ -0,0 +1,28 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenRevoked  = errors.New("refresh token has been revoked")
+	ErrRefreshTokenReused   = errors.New("refresh token reuse detected")
+)
+
+type RefreshToken struct {
+	ID        uint64     `json:"-" gorm:"column:id"`
+	UserID    uint64     `json:"user_id" gorm:"column:user_id"`
+	FamilyID  string     `json:"family_id" gorm:"column:family_id"`
+	JTI       string     `json:"jti" gorm:"column:jti"`
+	Device    *string    `json:"device,omitempty" gorm:"column:device"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"column:expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" gorm:"column:used_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty" gorm:"column:revoked_at"`
+	CreatedAt *time.Time `json:"created_at,omitempty" gorm:"column:created_at"`
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
@@ -1,12 +1,19 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+var ErrBuiltInRoleRename = errors.New("built-in roles cannot be renamed")
 
 type Role struct {
-	ID          int64      `json:"id" gorm:"column:id"`
-	Name        string     `json:"name" gorm:"column:name"`
-	Description string     `json:"description,omitempty" gorm:"column:description"`
-	CreatedAt   *time.Time `json:"created_at,omitempty" gorm:"column:created_at"`
+	ID                 int64      `json:"id" gorm:"column:id"`
+	Name               string     `json:"name" gorm:"column:name"`
+	Description        string     `json:"description,omitempty" gorm:"column:description"`
+	RequireMFA         bool       `json:"require_mfa" gorm:"column:require_mfa"`
+	PasswordMaxAgeDays *int       `json:"password_max_age_days" gorm:"column:password_max_age_days"`
+	CreatedAt          *time.Time `json:"created_at,omitempty" gorm:"column:created_at"`
 }
 
 func (Role) TableName() string {
@@ -45,3 +52,17 @@ var RoleNames = map[int64]string{
 	RoleProductMgr:   "Product Manager",
 	RoleEmployee:     "Employee",
 }
+
+// RoleDescriptions describe the built-in roles.
+var RoleDescriptions = map[int64]string{
+	RoleSuperAdmin:   "Full system access with all permissions",
+	RoleAdmin:        "Administrative access to manage users and system",
+	RoleHRManager:    "Manage human resources and employee data",
+	RoleHRStaff:      "HR operations and employee management",
+	RoleFinanceMgr:   "Manage financial operations and reports",
+	RoleAccountant:   "Handle accounting and financial records",
+	RoleSalesManager: "Manage sales team and operations",
+	RoleSalesStaff:   "Sales operations and customer relations",
+	RoleProductMgr:   "Manage products and development",
+	RoleEmployee:     "Basic employee access",
+}
@@ -0,0 +1,28 @@
+package models
+
+import "errors"
+
+var ErrRoleInheritanceCycle = errors.New("a role cannot inherit from itself or from a role that inherits from it")
+
+// RoleParent makes a role inherit every permission of its parent role.
+type RoleParent struct {
+	RoleID   int64 `json:"role_id" gorm:"column:role_id"`
+	ParentID int64 `json:"parent_id" gorm:"column:parent_id"`
+}
+
+func (RoleParent) TableName() string {
+	return "role_parents"
+}
+
+// RoleParents is the default hierarchy of the built-in roles. A role holds
+// the permissions listed for it in RolePermissions and those of its parents.
+var RoleParents = map[int64][]int64{
+	RoleAdmin:        {RoleEmployee},
+	RoleHRManager:    {RoleHRStaff},
+	RoleHRStaff:      {RoleEmployee},
+	RoleFinanceMgr:   {RoleAccountant},
+	RoleAccountant:   {RoleEmployee},
+	RoleSalesManager: {RoleSalesStaff},
+	RoleSalesStaff:   {RoleEmployee},
+	RoleProductMgr:   {RoleEmployee},
+}
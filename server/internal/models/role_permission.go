@@ -1,6 +1,15 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrPermissionNotFound     = errors.New("permission not found")
+	ErrPermissionGrantNotHeld = errors.New("you can only grant permissions you hold")
+	ErrSuperAdminGrantsLocked = errors.New("permissions of the Super Admin role cannot be revoked")
+)
 
 type RolePermission struct {
 	RoleID       int64      `json:"role_id" gorm:"column:role_id"`
@@ -12,6 +21,8 @@ func (RolePermission) TableName() string {
 	return "role_permissions"
 }
 
+// RolePermissions are the permissions granted directly to the built-in
+// roles. Each role also inherits those of its parents in RoleParents.
 var RolePermissions = map[int64][]int64{
 	// Super Admin
 	RoleSuperAdmin: {
@@ -43,16 +54,19 @@ var RolePermissions = map[int64][]int64{
 		PermissionCreateRequest,
 		PermissionUpdateRequest,
 		PermissionDeleteRequest,
+		PermissionReadCompany,
+		PermissionReadPosition,
+		PermissionReadContract,
+		PermissionManageServiceAccounts,
+		PermissionProvisionUsers,
 	},
 
-	// Admin
+	// Admin, inherits Employee
 	RoleAdmin: {
 		PermissionCreateUser,
-		PermissionReadUser,
 		PermissionUpdateUser,
 		PermissionDeleteUser,
 		PermissionManageRoles,
-		PermissionViewOwnReport,
 		PermissionCreateReport,
 		PermissionDeleteReport,
 		PermissionManageFinances,
@@ -66,116 +80,76 @@ var RolePermissions = map[int64][]int64{
 		PermissionCreateContract,
 		PermissionUpdateContract,
 		PermissionApproveRequests,
-		PermissionCreateRequest,
-		PermissionUpdateRequest,
+		PermissionReadContract,
 	},
 
-	// HR Manager
+	// HR Manager, inherits HR Staff
 	RoleHRManager: {
 		PermissionCreateUser,
-		PermissionReadUser,
-		PermissionUpdateUser,
 		PermissionDeleteUser,
 		PermissionManageRoles,
-		PermissionViewOwnReport,
-		PermissionCreateReport,
-		PermissionDeleteReport,
-		PermissionPosition,
 		PermissionUpdatePosition,
-		PermissionCreateDepartment,
-		PermissionUpdateDepartment,
-		PermissionDeleteDepartment,
 		PermissionCreateCompany,
 		PermissionUpdateCompany,
 		PermissionCreateContract,
 		PermissionUpdateContract,
 		PermissionApproveRequests,
-		PermissionCreateRequest,
-		PermissionUpdateRequest,
 	},
 
-	// HR Staff
+	// HR Staff, inherits Employee
 	RoleHRStaff: {
-		PermissionReadUser,
 		PermissionUpdateUser,
-		PermissionViewOwnReport,
 		PermissionCreateReport,
 		PermissionDeleteReport,
 		PermissionPosition,
 		PermissionCreateDepartment,
 		PermissionUpdateDepartment,
 		PermissionDeleteDepartment,
-		PermissionCreateRequest,
-		PermissionUpdateRequest,
+		PermissionReadContract,
 	},
 
-	// Finance Manager
+	// Finance Manager, inherits Accountant
 	RoleFinanceMgr: {
 		PermissionCreateUser,
-		PermissionReadUser,
 		PermissionUpdateUser,
 		PermissionDeleteUser,
-		PermissionViewOwnReport,
-		PermissionViewSubordinateReport,
-		PermissionViewAllReports,
-		PermissionManageFinances,
 		PermissionManageInventory,
-		PermissionCreateReport,
-		PermissionDeleteReport,
 		PermissionApproveRequests,
-		PermissionCreateRequest,
-		PermissionUpdateRequest,
 	},
 
-	// Accountant
+	// Accountant, inherits Employee
 	RoleAccountant: {
-		PermissionReadUser,
-		PermissionViewOwnReport,
 		PermissionViewSubordinateReport,
 		PermissionViewAllReports,
 		PermissionManageFinances,
 		PermissionCreateReport,
 		PermissionDeleteReport,
-		PermissionCreateRequest,
-		PermissionUpdateRequest,
+		PermissionReadContract,
 	},
 
-	// Sales Manager
+	// Sales Manager, inherits Sales Staff
 	RoleSalesManager: {
 		PermissionCreateUser,
-		PermissionReadUser,
 		PermissionUpdateUser,
 		PermissionDeleteUser,
 		PermissionManageRoles,
-		PermissionViewOwnReport,
-		PermissionViewSubordinateReport,
 		PermissionViewAllReports,
-		PermissionCreateReport,
-		PermissionDeleteReport,
 		PermissionApproveRequests,
-		PermissionCreateRequest,
-		PermissionUpdateRequest,
 	},
 
-	// Sales Staff
+	// Sales Staff, inherits Employee
 	RoleSalesStaff: {
-		PermissionReadUser,
-		PermissionViewOwnReport,
 		PermissionViewSubordinateReport,
 		PermissionCreateReport,
 		PermissionDeleteReport,
-		PermissionCreateRequest,
-		PermissionUpdateRequest,
 	},
 
-	// Product Manager
+	// Product Manager, inherits Employee
 	RoleProductMgr: {
 		PermissionCreateUser,
-		PermissionReadUser,
 		PermissionUpdateUser,
 		PermissionDeleteUser,
 		PermissionManageRoles,
-		PermissionViewOwnReport,
 		PermissionViewSubordinateReport,
 		PermissionCreateReport,
 		PermissionDeleteReport,
@@ -183,8 +157,7 @@ var RolePermissions = map[int64][]int64{
 		PermissionUpdateDepartment,
 		PermissionCreateContract,
 		PermissionUpdateContract,
-		PermissionCreateRequest,
-		PermissionUpdateRequest,
+		PermissionReadContract,
 	},
 
 	// Employee
@@ -193,5 +166,7 @@ var RolePermissions = map[int64][]int64{
 		PermissionViewOwnReport,
 		PermissionCreateRequest,
 		PermissionUpdateRequest,
+		PermissionReadCompany,
+		PermissionReadPosition,
 	},
 }
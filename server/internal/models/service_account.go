@@ -0,0 +1,86 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/vlahanam/company-management/common"
+)
+
+var (
+	ErrServiceAccountNotFound   = errors.New("service account not found")
+	ErrServiceAccountNameExists = errors.New("service account name already exists")
+	ErrAPIKeyNotFound           = errors.New("api key not found")
+	ErrInvalidAPIKey            = errors.New("invalid, expired or revoked api key")
+	ErrAPIKeyScopeNotHeld       = errors.New("a key cannot be granted permissions you do not hold")
+	ErrUserAccountRequired      = errors.New("this endpoint requires a user account")
+)
+
+// ServiceAccount is a non-human principal, such as a batch job, that calls the
+// API with API keys instead of a password.
+type ServiceAccount struct {
+	SQLModel
+	Name        string     `json:"name" gorm:"column:name"`
+	Description *string    `json:"description,omitempty" gorm:"column:description"`
+	CreatedBy   *uint64    `json:"-" gorm:"column:created_by"`
+	DisabledAt  *time.Time `json:"disabled_at,omitempty" gorm:"column:disabled_at"`
+
+	APIKeys []*APIKey `json:"api_keys,omitempty" gorm:"foreignKey:ServiceAccountID"`
+}
+
+func (ServiceAccount) TableName() string {
+	return "service_accounts"
+}
+
+func (a *ServiceAccount) Mask() {
+	a.SQLModel.Mask(common.ObjectTypeServiceAccount)
+	for _, k := range a.APIKeys {
+		k.Mask()
+	}
+}
+
+// APIKey authenticates a service account. Only the prefix and a hash of the
+// key are stored; the key itself is shown once when it is created or rotated.
+// A key grants exactly the permissions listed for it.
+type APIKey struct {
+	ID               uint64      `json:"-" gorm:"column:id"`
+	FakeId           *common.UID `json:"id" gorm:"-"`
+	ServiceAccountID uint64      `json:"-" gorm:"column:service_account_id"`
+	Name             string      `json:"name" gorm:"column:name"`
+	Prefix           string      `json:"prefix" gorm:"column:prefix"`
+	KeyHash          string      `json:"-" gorm:"column:key_hash"`
+	ExpiresAt        *time.Time  `json:"expires_at,omitempty" gorm:"column:expires_at"`
+	LastUsedAt       *time.Time  `json:"last_used_at,omitempty" gorm:"column:last_used_at"`
+	RevokedAt        *time.Time  `json:"revoked_at,omitempty" gorm:"column:revoked_at"`
+	CreatedAt        *time.Time  `json:"created_at,omitempty" gorm:"column:created_at"`
+
+	Permissions []*APIKeyPermission `json:"permissions,omitempty" gorm:"foreignKey:APIKeyID"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+func (k *APIKey) Mask() {
+	uid := common.NewUID(uint32(k.ID), common.ObjectTypeAPIKey, 1)
+	k.FakeId = &uid
+}
+
+// PermissionIDs lists the permissions granted to the key.
+func (k *APIKey) PermissionIDs() []int64 {
+	ids := make([]int64, 0, len(k.Permissions))
+	for _, p := range k.Permissions {
+		ids = append(ids, p.PermissionID)
+	}
+
+	return ids
+}
+
+type APIKeyPermission struct {
+	APIKeyID     uint64 `json:"-" gorm:"column:api_key_id"`
+	PermissionID int64  `json:"permission_id" gorm:"column:permission_id"`
+}
+
+func (APIKeyPermission) TableName() string {
+	return "api_key_permissions"
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// TokenRevocation revokes one access token by jti, every access token of a
+// session, or every access token of UserID issued at or before RevokedBefore.
+type TokenRevocation struct {
+	ID            uint64     `json:"-" gorm:"column:id"`
+	JTI           *string    `json:"jti,omitempty" gorm:"column:jti"`
+	UserID        *uint64    `json:"user_id,omitempty" gorm:"column:user_id"`
+	SessionID     *string    `json:"session_id,omitempty" gorm:"column:session_id"`
+	RevokedBefore *time.Time `json:"revoked_before,omitempty" gorm:"column:revoked_before"`
+	ExpiresAt     time.Time  `json:"expires_at" gorm:"column:expires_at"`
+	CreatedAt     *time.Time `json:"created_at,omitempty" gorm:"column:created_at"`
+}
+
+func (TokenRevocation) TableName() string {
+	return "token_revocations"
+}
@@ -6,27 +6,56 @@ import (
 )
 
 var (
-	ErrUserNotFound       = errors.New("user not found")
-	ErrEmailAlreadyExists = errors.New("email already exists")
-	ErrEmailNotFound      = errors.New("email does not exist")
-	ErrInvalidPassword    = errors.New("invalid password")
+	ErrUserNotFound               = errors.New("user not found")
+	ErrEmailAlreadyExists         = errors.New("email already exists")
+	ErrEmailNotFound              = errors.New("email does not exist")
+	ErrInvalidPassword            = errors.New("invalid password")
+	ErrEmailNotVerified           = errors.New("email address has not been verified")
+	ErrInvalidVerifyToken         = errors.New("verification link is invalid or expired")
+	ErrInvalidMFACode             = errors.New("invalid two-factor authentication code")
+	ErrInvalidMFAToken            = errors.New("two-factor authentication session is invalid or expired")
+	ErrMFAAlreadyEnabled          = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled              = errors.New("two-factor authentication is not enabled")
+	ErrMFARequiredByRole          = errors.New("two-factor authentication is required by one of your roles")
+	ErrPasswordReused             = errors.New("password was used recently, choose a different one")
+	ErrPasswordExpired            = errors.New("password has expired and must be changed")
+	ErrInvalidPasswordChangeToken = errors.New("password change session is invalid or expired")
+	ErrAccountDeactivated         = errors.New("account has been deactivated")
 )
 
+// Auth is the result of a login step. Either the token pair is set, or one of
+// the MFA flags together with MFAToken, which must be exchanged for the token
+// pair through the second login step, or PasswordChangeRequired together with
+// PasswordChangeToken, which is exchanged along with a new password.
 type Auth struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+
+	MFARequired           bool   `json:"mfa_required,omitempty"`
+	MFAEnrollmentRequired bool   `json:"mfa_enrollment_required,omitempty"`
+	MFAToken              string `json:"mfa_token,omitempty"`
+
+	PasswordChangeRequired bool   `json:"password_change_required,omitempty"`
+	PasswordChangeToken    string `json:"password_change_token,omitempty"`
 }
 
 type User struct {
 	SQLModel
-	FullName     string     `json:"full_name" gorm:"full_name"`
-	HashPassword string     `json:"hash_password" gorm:"hash_password"`
-	DateOfBirth  *time.Time `json:"date_of_birth,omitempty" gorm:"date_of_birth"`
-	Gender       *string    `json:"gender,omitempty" gorm:"gender"`
-	IdCardNumber *string    `json:"id_card_number" gorm:"id_card_number"`
-	Email        string     `json:"email" gorm:"email"`
-	PhoneNumber  *string    `json:"phone_number" gorm:"phone_number"`
-	Avatar       *string    `json:"avatar,omitempty" gorm:"avatar"`
+	ExternalID        *string    `json:"external_id,omitempty" gorm:"column:external_id"`
+	FullName          string     `json:"full_name" gorm:"full_name"`
+	HashPassword      string     `json:"-" gorm:"hash_password"`
+	PasswordChangedAt *time.Time `json:"password_changed_at,omitempty" gorm:"column:password_changed_at"`
+	TOTPSecret        *string    `json:"-" gorm:"column:totp_secret"`
+	TOTPEnabledAt     *time.Time `json:"totp_enabled_at,omitempty" gorm:"column:totp_enabled_at"`
+	TOTPLastStep      *int64     `json:"-" gorm:"column:totp_last_step"`
+	DateOfBirth       *time.Time `json:"date_of_birth,omitempty" gorm:"date_of_birth"`
+	Gender            *string    `json:"gender,omitempty" gorm:"gender"`
+	IdCardNumber      *string    `json:"id_card_number" gorm:"id_card_number"`
+	Email             string     `json:"email" gorm:"email"`
+	EmailVerifiedAt   *time.Time `json:"email_verified_at,omitempty" gorm:"email_verified_at"`
+	PhoneNumber       *string    `json:"phone_number" gorm:"phone_number"`
+	Avatar            *string    `json:"avatar,omitempty" gorm:"avatar"`
+	DeactivatedAt     *time.Time `json:"deactivated_at,omitempty" gorm:"column:deactivated_at"`
 }
 
 func (User) TableName() string {
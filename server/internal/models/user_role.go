@@ -1,11 +1,32 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"time"
+)
 
+var (
+	ErrUserRoleNotFound  = errors.New("role assignment not found")
+	ErrUserRoleExists    = errors.New("user already holds this role in this scope")
+	ErrUserRoleForbidden = errors.New("you can only assign or remove roles whose permissions you hold in the company")
+	ErrUserRoleScope     = errors.New("you can only assign or remove roles in companies where you manage roles")
+	ErrLastSuperAdmin    = errors.New("the last active Super Admin cannot lose the role or be deleted")
+)
+
+// UserRole assigns a role to a user. Without a company the role applies to
+// every company; otherwise only to that company, and to its subsidiaries when
+// IncludeSubsidiaries is set.
 type UserRole struct {
-	UserID     int64      `json:"user_id" gorm:"column:user_id"`
-	RoleID     int64      `json:"role_id" gorm:"column:role_id"`
-	AssignedAt *time.Time `json:"assigned_at,omitempty" gorm:"column:assigned_at;"`
+	ID                  uint64     `json:"id" gorm:"column:id"`
+	UserID              int64      `json:"user_id" gorm:"column:user_id"`
+	RoleID              int64      `json:"role_id" gorm:"column:role_id"`
+	CompanyID           *uint64    `json:"company_id,omitempty" gorm:"column:company_id"`
+	IncludeSubsidiaries bool       `json:"include_subsidiaries" gorm:"column:include_subsidiaries"`
+	AssignedAt          *time.Time `json:"assigned_at,omitempty" gorm:"column:assigned_at;"`
+
+	// Relationships
+	Role    *Role    `json:"role,omitempty" gorm:"foreignKey:RoleID"`
+	Company *Company `json:"company,omitempty" gorm:"foreignKey:CompanyID"`
 }
 
 func (UserRole) TableName() string {
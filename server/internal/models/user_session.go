@@ -0,0 +1,28 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// UserSession is one login of a user, shared by every refresh token rotated
+// from it. Its ID is the refresh token family.
+type UserSession struct {
+	ID         string     `json:"id" gorm:"column:id;primaryKey"`
+	UserID     uint64     `json:"-" gorm:"column:user_id"`
+	Device     *string    `json:"device,omitempty" gorm:"column:device"`
+	UserAgent  *string    `json:"user_agent,omitempty" gorm:"column:user_agent"`
+	IPAddress  *string    `json:"ip_address,omitempty" gorm:"column:ip_address"`
+	LastSeenAt time.Time  `json:"last_seen_at" gorm:"column:last_seen_at"`
+	RevokedAt  *time.Time `json:"-" gorm:"column:revoked_at"`
+	CreatedAt  *time.Time `json:"created_at,omitempty" gorm:"column:created_at"`
+
+	// Current marks the session of the access token making the request
+	Current bool `json:"current" gorm:"-"`
+}
+
+func (UserSession) TableName() string {
+	return "user_sessions"
+}
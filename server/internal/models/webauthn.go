@@ -0,0 +1,49 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrWebAuthnCredentialNotFound = errors.New("passkey not found")
+	ErrInvalidWebAuthnSession     = errors.New("passkey ceremony is invalid or expired")
+	ErrWebAuthnFailed             = errors.New("passkey verification failed")
+	ErrWebAuthnDisabled           = errors.New("passkey login is not configured")
+)
+
+const (
+	WebAuthnPurposeRegistration = "registration"
+	WebAuthnPurposeLogin        = "login"
+)
+
+// WebAuthnCredential is a passkey registered by a user. Credential holds the
+// library's JSON encoding of the public key, sign count and flags.
+type WebAuthnCredential struct {
+	ID           uint64     `json:"-" gorm:"column:id"`
+	UserID       uint64     `json:"-" gorm:"column:user_id"`
+	CredentialID string     `json:"id" gorm:"column:credential_id"`
+	Name         *string    `json:"name,omitempty" gorm:"column:name"`
+	Credential   string     `json:"-" gorm:"column:credential"`
+	LastUsedAt   *time.Time `json:"last_used_at,omitempty" gorm:"column:last_used_at"`
+	CreatedAt    *time.Time `json:"created_at,omitempty" gorm:"column:created_at"`
+}
+
+func (WebAuthnCredential) TableName() string {
+	return "webauthn_credentials"
+}
+
+// WebAuthnSession keeps the challenge of a ceremony between its begin and
+// finish steps. It is deleted when the ceremony is finished.
+type WebAuthnSession struct {
+	ID        string     `json:"id" gorm:"column:id"`
+	UserID    *uint64    `json:"user_id,omitempty" gorm:"column:user_id"`
+	Purpose   string     `json:"purpose" gorm:"column:purpose"`
+	Data      string     `json:"-" gorm:"column:data"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"column:expires_at"`
+	CreatedAt *time.Time `json:"created_at,omitempty" gorm:"column:created_at"`
+}
+
+func (WebAuthnSession) TableName() string {
+	return "webauthn_sessions"
+}
@@ -0,0 +1,107 @@
+package policy
+
+import (
+	"reflect"
+)
+
+// normalize turns every number into a float64 and named string types into
+// strings, so that attributes built in code compare equal to the values
+// decoded from JSON rules.
+func normalize(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	}
+
+	return v
+}
+
+// list returns the elements of a slice, or nil when v is not one.
+func list(v interface{}) ([]interface{}, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+
+	items := make([]interface{}, rv.Len())
+	for i := range items {
+		items[i] = normalize(rv.Index(i).Interface())
+	}
+
+	return items, true
+}
+
+// scalar reports whether v is null, a boolean, a number or a string, the only
+// values equal compares.
+func scalar(v interface{}) bool {
+	switch normalize(v).(type) {
+	case nil, bool, float64, string:
+		return true
+	}
+
+	return false
+}
+
+// scalars reports whether v is a list of scalar values.
+func scalars(v interface{}) bool {
+	items, ok := list(v)
+	if !ok {
+		return false
+	}
+
+	for _, item := range items {
+		if !scalar(item) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// equal compares two scalar values; lists and objects are never equal to
+// anything.
+func equal(a, b interface{}) bool {
+	if !scalar(a) || !scalar(b) {
+		return false
+	}
+
+	return normalize(a) == normalize(b)
+}
+
+// contains reports whether the list holds the value.
+func contains(l, v interface{}) bool {
+	items, ok := list(l)
+	if !ok {
+		return false
+	}
+
+	for _, item := range items {
+		if equal(item, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func intersects(a, b interface{}) bool {
+	items, ok := list(a)
+	if !ok {
+		return false
+	}
+
+	for _, item := range items {
+		if contains(b, item) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,305 @@
+// Package policy evaluates declarative attribute-based rules on top of the
+// role permissions: "HR Staff may update users only in their own company" or
+// "API keys may not delete contracts". The rules only narrow access; the
+// permission checks still apply to what they allow. Like the access package
+// it works on plain values, so rules can be tried without a request or a
+// database.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type Effect string
+
+const (
+	EffectAllow Effect = "allow"
+	EffectDeny  Effect = "deny"
+)
+
+type Decision string
+
+const (
+	DecisionAllow Decision = "allow"
+	DecisionDeny  Decision = "deny"
+	// No rule applies; like an allow, it leaves the decision to the role
+	// permissions
+	DecisionNotApplicable Decision = "not_applicable"
+)
+
+type Operator string
+
+const (
+	OperatorEq            Operator = "eq"
+	OperatorNe            Operator = "ne"
+	OperatorIn            Operator = "in"
+	OperatorNotIn         Operator = "not_in"
+	OperatorContains      Operator = "contains"
+	OperatorNotContains   Operator = "not_contains"
+	OperatorIntersects    Operator = "intersects"
+	OperatorNotIntersects Operator = "not_intersects"
+)
+
+// Any matches every resource type or action.
+const Any = "*"
+
+// Resource types and actions the controllers evaluate rules for.
+const (
+	ResourceUser     = "user"
+	ResourceContract = "contract"
+
+	ActionRead   = "read"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+var (
+	ErrInvalidRule      = errors.New("invalid policy rule")
+	ErrUnknownAttribute = errors.New("attributes must start with subject. or resource.")
+	ErrScalarValue      = errors.New("value must be null, a boolean, a number or a string")
+	ErrListValue        = errors.New("value must be a list of booleans, numbers or strings")
+)
+
+var operators = []Operator{
+	OperatorEq, OperatorNe, OperatorIn, OperatorNotIn, OperatorContains,
+	OperatorNotContains, OperatorIntersects, OperatorNotIntersects,
+}
+
+// Condition compares the attribute Attribute, such as "subject.company_ids"
+// or "resource.company_id", with the attribute named by ValueFrom or, without
+// it, with the literal Value.
+//
+//   - eq, ne: the values are equal, or not.
+//   - in, not_in: the attribute is one of the values in a list, or not.
+//   - contains, not_contains: the attribute is a list holding the value, or not.
+//   - intersects, not_intersects: the two lists share a value, or not.
+//
+// A condition on an attribute the request does not carry never holds.
+type Condition struct {
+	Attribute string      `json:"attribute"`
+	Operator  Operator    `json:"operator"`
+	Value     interface{} `json:"value,omitempty"`
+	ValueFrom string      `json:"value_from,omitempty"`
+}
+
+// Rule applies its effect to the actions on the resource type when every one
+// of its conditions holds.
+type Rule struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Effect      Effect      `json:"effect"`
+	Resource    string      `json:"resource"`
+	Actions     []string    `json:"actions"`
+	Conditions  []Condition `json:"conditions"`
+}
+
+// Attributes describe the subject or the resource of a request. Values are
+// numbers, strings, booleans or lists of them.
+type Attributes map[string]interface{}
+
+// Request is the subject taking the action on the resource.
+type Request struct {
+	Subject      Attributes `json:"subject"`
+	ResourceType string     `json:"resource_type"`
+	Resource     Attributes `json:"resource"`
+	Action       string     `json:"action"`
+}
+
+// RuleTrace tells how a rule took part in a decision. Failed is the first
+// condition that did not hold.
+type RuleTrace struct {
+	Rule    string     `json:"rule"`
+	Effect  Effect     `json:"effect"`
+	Applies bool       `json:"applies"`
+	Failed  *Condition `json:"failed_condition,omitempty"`
+}
+
+// Result is the decision with the rule that made it and the trace of every
+// rule targeting the resource type and action.
+type Result struct {
+	Decision Decision    `json:"decision"`
+	Rule     string      `json:"rule,omitempty"`
+	Trace    []RuleTrace `json:"trace"`
+}
+
+// Evaluate decides the request. A deny rule whose conditions hold wins over
+// any allow rule; otherwise an allow rule whose conditions hold allows the
+// request, and without either the rules do not apply.
+func Evaluate(rules []*Rule, req Request) Result {
+	result := Result{Decision: DecisionNotApplicable, Trace: []RuleTrace{}}
+
+	for _, rule := range rules {
+		if !rule.targets(req.ResourceType, req.Action) {
+			continue
+		}
+
+		trace := RuleTrace{Rule: rule.Name, Effect: rule.Effect, Applies: true}
+		for i := range rule.Conditions {
+			if !rule.Conditions[i].holds(req) {
+				trace.Applies = false
+				trace.Failed = &rule.Conditions[i]
+				break
+			}
+		}
+		result.Trace = append(result.Trace, trace)
+
+		if !trace.Applies || result.Decision == DecisionDeny {
+			continue
+		}
+
+		switch rule.Effect {
+		case EffectDeny:
+			result.Decision = DecisionDeny
+			result.Rule = rule.Name
+		case EffectAllow:
+			if result.Decision == DecisionNotApplicable {
+				result.Decision = DecisionAllow
+				result.Rule = rule.Name
+			}
+		}
+	}
+
+	return result
+}
+
+// Validate checks that the rule can be evaluated.
+func (r *Rule) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRule)
+	}
+	if r.Effect != EffectAllow && r.Effect != EffectDeny {
+		return fmt.Errorf("%w: effect must be allow or deny", ErrInvalidRule)
+	}
+	if r.Resource == "" {
+		return fmt.Errorf("%w: resource is required", ErrInvalidRule)
+	}
+	if len(r.Actions) == 0 {
+		return fmt.Errorf("%w: at least one action is required", ErrInvalidRule)
+	}
+
+	for _, c := range r.Conditions {
+		if !slices.Contains(operators, c.Operator) {
+			return fmt.Errorf("%w: unknown operator %q", ErrInvalidRule, c.Operator)
+		}
+		if !validAttribute(c.Attribute) {
+			return fmt.Errorf("%w: %q: %w", ErrInvalidRule, c.Attribute, ErrUnknownAttribute)
+		}
+		if c.ValueFrom != "" {
+			if !validAttribute(c.ValueFrom) {
+				return fmt.Errorf("%w: %q: %w", ErrInvalidRule, c.ValueFrom, ErrUnknownAttribute)
+			}
+			continue
+		}
+		if err := c.validValue(); err != nil {
+			return fmt.Errorf("%w: %q: %w", ErrInvalidRule, c.Attribute, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Rule) targets(resourceType, action string) bool {
+	if r.Resource != Any && r.Resource != resourceType {
+		return false
+	}
+
+	return slices.Contains(r.Actions, Any) || slices.Contains(r.Actions, action)
+}
+
+// validValue checks the literal value has the shape the operator compares:
+// a list of scalars for in and the intersections, a scalar otherwise.
+func (c *Condition) validValue() error {
+	switch c.Operator {
+	case OperatorIn, OperatorNotIn, OperatorIntersects, OperatorNotIntersects:
+		if !scalars(c.Value) {
+			return ErrListValue
+		}
+	default:
+		if !scalar(c.Value) {
+			return ErrScalarValue
+		}
+	}
+
+	return nil
+}
+
+func (c *Condition) holds(req Request) bool {
+	left, ok := lookup(req, c.Attribute)
+	if !ok {
+		return false
+	}
+
+	right := c.Value
+	if c.ValueFrom != "" {
+		if right, ok = lookup(req, c.ValueFrom); !ok {
+			return false
+		}
+	}
+
+	switch c.Operator {
+	case OperatorEq:
+		return equal(left, right)
+	case OperatorNe:
+		return !equal(left, right)
+	case OperatorIn:
+		return contains(right, left)
+	case OperatorNotIn:
+		return !contains(right, left)
+	case OperatorContains:
+		return contains(left, right)
+	case OperatorNotContains:
+		return !contains(left, right)
+	case OperatorIntersects:
+		return intersects(left, right)
+	case OperatorNotIntersects:
+		return !intersects(left, right)
+	}
+
+	return false
+}
+
+func validAttribute(name string) bool {
+	return strings.HasPrefix(name, "subject.") || strings.HasPrefix(name, "resource.")
+}
+
+// lookup returns the value of an attribute such as "subject.user_id".
+func lookup(req Request, name string) (interface{}, bool) {
+	var attrs Attributes
+	switch {
+	case strings.HasPrefix(name, "subject."):
+		attrs, name = req.Subject, strings.TrimPrefix(name, "subject.")
+	case strings.HasPrefix(name, "resource."):
+		attrs, name = req.Resource, strings.TrimPrefix(name, "resource.")
+	default:
+		return nil, false
+	}
+
+	v, ok := attrs[name]
+	return v, ok
+}
+
+var (
+	configuredMu sync.RWMutex
+	configured   []*Rule
+)
+
+// SetConfigured installs the rules loaded from configuration at startup.
+// They are evaluated together with the rules stored in the database.
+func SetConfigured(rules []*Rule) {
+	configuredMu.Lock()
+	defer configuredMu.Unlock()
+
+	configured = rules
+}
+
+// Configured returns the rules loaded from configuration.
+func Configured() []*Rule {
+	configuredMu.RLock()
+	defer configuredMu.RUnlock()
+
+	return configured
+}
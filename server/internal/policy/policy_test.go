@@ -0,0 +1,225 @@
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+type contractStatus string
+
+func TestConditionOperators(t *testing.T) {
+	req := Request{
+		Subject: Attributes{
+			"user_id":           uint64(7),
+			"api_key":           false,
+			"company_ids":       []uint64{1, 2},
+			"direct_report_ids": []uint64{9},
+		},
+		ResourceType: ResourceContract,
+		Resource: Attributes{
+			"user_id":     uint64(9),
+			"company_id":  uint64(2),
+			"company_ids": []uint64{3},
+			"status":      contractStatus("active"),
+		},
+		Action: ActionRead,
+	}
+
+	tests := []struct {
+		name string
+		cond Condition
+		want bool
+	}{
+		{"eq number", Condition{Attribute: "subject.user_id", Operator: OperatorEq, Value: float64(7)}, true},
+		{"eq named string", Condition{Attribute: "resource.status", Operator: OperatorEq, Value: "active"}, true},
+		{"eq bool", Condition{Attribute: "subject.api_key", Operator: OperatorEq, Value: false}, true},
+		{"ne", Condition{Attribute: "subject.user_id", Operator: OperatorNe, Value: float64(8)}, true},
+		{"eq list", Condition{Attribute: "subject.company_ids", Operator: OperatorEq, ValueFrom: "subject.company_ids"}, false},
+		{"in value_from", Condition{Attribute: "resource.user_id", Operator: OperatorIn, ValueFrom: "subject.direct_report_ids"}, true},
+		{"in literal", Condition{Attribute: "resource.company_id", Operator: OperatorIn, Value: []interface{}{float64(5)}}, false},
+		{"not_in", Condition{Attribute: "resource.company_id", Operator: OperatorNotIn, Value: []interface{}{float64(5)}}, true},
+		{"contains", Condition{Attribute: "subject.company_ids", Operator: OperatorContains, ValueFrom: "resource.company_id"}, true},
+		{"not_contains", Condition{Attribute: "subject.company_ids", Operator: OperatorNotContains, Value: float64(3)}, true},
+		{"intersects", Condition{Attribute: "resource.company_ids", Operator: OperatorIntersects, ValueFrom: "subject.company_ids"}, false},
+		{"not_intersects", Condition{Attribute: "resource.company_ids", Operator: OperatorNotIntersects, ValueFrom: "subject.company_ids"}, true},
+		{"missing attribute", Condition{Attribute: "resource.position_id", Operator: OperatorNe, Value: float64(1)}, false},
+		{"missing value_from", Condition{Attribute: "resource.user_id", Operator: OperatorIn, ValueFrom: "subject.manager_ids"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cond.holds(req); got != tt.want {
+				t.Errorf("holds() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	allowOwn := &Rule{
+		Name: "allow-own", Effect: EffectAllow, Resource: ResourceUser, Actions: []string{Any},
+		Conditions: []Condition{{Attribute: "resource.id", Operator: OperatorEq, ValueFrom: "subject.user_id"}},
+	}
+	denyAPIKeys := &Rule{
+		Name: "deny-api-keys", Effect: EffectDeny, Resource: Any, Actions: []string{ActionDelete},
+		Conditions: []Condition{{Attribute: "subject.api_key", Operator: OperatorEq, Value: true}},
+	}
+	rules := []*Rule{allowOwn, denyAPIKeys}
+
+	tests := []struct {
+		name     string
+		req      Request
+		decision Decision
+		rule     string
+		traces   int
+	}{
+		{
+			name:     "allow",
+			req:      Request{Subject: Attributes{"user_id": 1, "api_key": false}, ResourceType: ResourceUser, Resource: Attributes{"id": 1}, Action: ActionRead},
+			decision: DecisionAllow,
+			rule:     "allow-own",
+			traces:   1,
+		},
+		{
+			name:     "deny overrides allow",
+			req:      Request{Subject: Attributes{"user_id": 1, "api_key": true}, ResourceType: ResourceUser, Resource: Attributes{"id": 1}, Action: ActionDelete},
+			decision: DecisionDeny,
+			rule:     "deny-api-keys",
+			traces:   2,
+		},
+		{
+			name:     "no rule holds",
+			req:      Request{Subject: Attributes{"user_id": 1, "api_key": false}, ResourceType: ResourceUser, Resource: Attributes{"id": 2}, Action: ActionDelete},
+			decision: DecisionNotApplicable,
+			traces:   2,
+		},
+		{
+			name:     "no rule targets the resource",
+			req:      Request{Subject: Attributes{"user_id": 1}, ResourceType: ResourceContract, Resource: Attributes{"id": 1}, Action: ActionRead},
+			decision: DecisionNotApplicable,
+			traces:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Evaluate(rules, tt.req)
+			if result.Decision != tt.decision || result.Rule != tt.rule {
+				t.Errorf("Evaluate() = %s by %q, want %s by %q", result.Decision, result.Rule, tt.decision, tt.rule)
+			}
+			if len(result.Trace) != tt.traces {
+				t.Errorf("len(Trace) = %d, want %d", len(result.Trace), tt.traces)
+			}
+		})
+	}
+}
+
+func TestEvaluateTraceFailedCondition(t *testing.T) {
+	rule := &Rule{
+		Name: "own-company", Effect: EffectDeny, Resource: ResourceUser, Actions: []string{ActionUpdate},
+		Conditions: []Condition{
+			{Attribute: "subject.api_key", Operator: OperatorEq, Value: false},
+			{Attribute: "resource.company_ids", Operator: OperatorNotIntersects, ValueFrom: "subject.company_ids"},
+		},
+	}
+	req := Request{
+		Subject:      Attributes{"api_key": false, "company_ids": []uint64{1}},
+		ResourceType: ResourceUser,
+		Resource:     Attributes{"company_ids": []uint64{1}},
+		Action:       ActionUpdate,
+	}
+
+	result := Evaluate([]*Rule{rule}, req)
+	if result.Decision != DecisionNotApplicable {
+		t.Fatalf("Decision = %s, want %s", result.Decision, DecisionNotApplicable)
+	}
+	if failed := result.Trace[0].Failed; failed == nil || failed.Attribute != "resource.company_ids" {
+		t.Errorf("Failed = %+v, want the resource.company_ids condition", failed)
+	}
+}
+
+// Objects decoded from JSON are not comparable with ==; they must never
+// match rather than panic.
+func TestEvaluateObjectValues(t *testing.T) {
+	var cond Condition
+	if err := json.Unmarshal([]byte(`{"attribute": "resource.id", "operator": "eq", "value": {"a": 1}}`), &cond); err != nil {
+		t.Fatal(err)
+	}
+	rule := &Rule{Name: "object", Effect: EffectDeny, Resource: Any, Actions: []string{Any}, Conditions: []Condition{
+		cond,
+		{Attribute: "resource.ids", Operator: OperatorContains, ValueFrom: "subject.meta"},
+	}}
+	req := Request{
+		Subject:      Attributes{"meta": map[string]interface{}{"a": float64(1)}},
+		ResourceType: ResourceUser,
+		Resource: Attributes{
+			"id":  map[string]interface{}{"a": float64(1)},
+			"ids": []interface{}{map[string]interface{}{"a": float64(1)}},
+		},
+		Action: ActionRead,
+	}
+
+	if result := Evaluate([]*Rule{rule}, req); result.Decision != DecisionNotApplicable {
+		t.Errorf("Decision = %s, want %s", result.Decision, DecisionNotApplicable)
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	valid := func() *Rule {
+		return &Rule{Name: "rule", Effect: EffectDeny, Resource: ResourceUser, Actions: []string{ActionRead}}
+	}
+
+	tests := []struct {
+		name    string
+		change  func(r *Rule)
+		wantErr error
+	}{
+		{"valid", func(r *Rule) {}, nil},
+		{"no name", func(r *Rule) { r.Name = " " }, ErrInvalidRule},
+		{"bad effect", func(r *Rule) { r.Effect = "maybe" }, ErrInvalidRule},
+		{"no resource", func(r *Rule) { r.Resource = "" }, ErrInvalidRule},
+		{"no actions", func(r *Rule) { r.Actions = nil }, ErrInvalidRule},
+		{"unknown operator", func(r *Rule) {
+			r.Conditions = []Condition{{Attribute: "subject.user_id", Operator: "gt", Value: 1}}
+		}, ErrInvalidRule},
+		{"unknown attribute", func(r *Rule) {
+			r.Conditions = []Condition{{Attribute: "user_id", Operator: OperatorEq, Value: 1}}
+		}, ErrUnknownAttribute},
+		{"unknown value_from", func(r *Rule) {
+			r.Conditions = []Condition{{Attribute: "subject.user_id", Operator: OperatorEq, ValueFrom: "user_id"}}
+		}, ErrUnknownAttribute},
+		{"object for eq", func(r *Rule) {
+			r.Conditions = []Condition{{Attribute: "subject.user_id", Operator: OperatorEq, Value: map[string]interface{}{"a": 1}}}
+		}, ErrScalarValue},
+		{"list for ne", func(r *Rule) {
+			r.Conditions = []Condition{{Attribute: "subject.user_id", Operator: OperatorNe, Value: []interface{}{1}}}
+		}, ErrScalarValue},
+		{"scalar for in", func(r *Rule) {
+			r.Conditions = []Condition{{Attribute: "subject.user_id", Operator: OperatorIn, Value: 1}}
+		}, ErrListValue},
+		{"objects in list", func(r *Rule) {
+			r.Conditions = []Condition{{Attribute: "subject.company_ids", Operator: OperatorIntersects, Value: []interface{}{map[string]interface{}{}}}}
+		}, ErrListValue},
+		{"list for in", func(r *Rule) {
+			r.Conditions = []Condition{{Attribute: "subject.user_id", Operator: OperatorIn, Value: []interface{}{1, "2"}}}
+		}, nil},
+		{"value_from", func(r *Rule) {
+			r.Conditions = []Condition{{Attribute: "resource.user_id", Operator: OperatorIn, ValueFrom: "subject.direct_report_ids"}}
+		}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid()
+			tt.change(r)
+			err := r.Validate()
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("Validate() = %v, want nil", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Validate() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,388 @@
+// Package registry reconciles the roles, permissions, default grants and role
+// hierarchy defined in the models package with the database. Syncing twice
+// changes nothing the second time.
+package registry
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/internal/models"
+)
+
+// Options control what Sync may change.
+type Options struct {
+	// Report the drift without changing anything
+	DryRun bool
+	// Also delete the roles and permissions not defined in code, and the
+	// grants and parents of built-in roles that the code does not define.
+	// Without it they are only reported.
+	Prune bool
+	// Also give back the default grants and parents that were removed from
+	// existing built-in roles. Without it they are only reported, so that a
+	// revocation made through the API survives the sync.
+	Restore bool
+}
+
+// Change is one difference between the code and the database. Applied is
+// false for differences that were only reported.
+type Change struct {
+	Object  string `json:"object"`
+	Action  string `json:"action"`
+	Name    string `json:"name"`
+	Detail  string `json:"detail,omitempty"`
+	Applied bool   `json:"applied"`
+}
+
+func (c Change) String() string {
+	s := fmt.Sprintf("%-6s %-15s %s", c.Action, c.Object, c.Name)
+	if c.Detail != "" {
+		s += " (" + c.Detail + ")"
+	}
+	if !c.Applied {
+		s += " [not applied]"
+	}
+	return s
+}
+
+// Report lists the changes of a sync, in the order they were found.
+type Report struct {
+	Changes []Change `json:"changes"`
+}
+
+// Drift reports whether the database differed from the code.
+func (r *Report) Drift() bool {
+	return len(r.Changes) > 0
+}
+
+// Pending counts the differences left in the database after the sync.
+func (r *Report) Pending() int {
+	n := 0
+	for _, c := range r.Changes {
+		if !c.Applied {
+			n++
+		}
+	}
+	return n
+}
+
+type syncer struct {
+	tx     *gorm.DB
+	opts   Options
+	report *Report
+	now    time.Time
+	// Built-in roles and permissions this sync creates, whose defaults are
+	// new rather than removed
+	newRoles       map[int64]bool
+	newPermissions map[int64]bool
+}
+
+// Sync brings the database in line with the code in a single transaction:
+// built-in roles and permissions are created or corrected, and the default
+// grants and parents of the new ones are added. Defaults missing from
+// existing roles are restored only with Options.Restore, and everything else
+// is reported, and removed only with Options.Prune.
+func Sync(ctx context.Context, db *gorm.DB, opts Options) (*Report, error) {
+	report := &Report{Changes: []Change{}}
+
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		s := &syncer{
+			tx:             tx,
+			opts:           opts,
+			report:         report,
+			now:            time.Now().UTC(),
+			newRoles:       make(map[int64]bool),
+			newPermissions: make(map[int64]bool),
+		}
+
+		if err := s.syncRoles(); err != nil {
+			return fmt.Errorf("roles: %w", err)
+		}
+		if err := s.syncPermissions(); err != nil {
+			return fmt.Errorf("permissions: %w", err)
+		}
+		if err := s.syncGrants(); err != nil {
+			return fmt.Errorf("role permissions: %w", err)
+		}
+		if err := s.syncParents(); err != nil {
+			return fmt.Errorf("role parents: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// record adds the change and reports whether to apply it.
+func (s *syncer) record(object, action, name, detail string, allowed bool) bool {
+	apply := allowed && !s.opts.DryRun
+	s.report.Changes = append(s.report.Changes, Change{
+		Object:  object,
+		Action:  action,
+		Name:    name,
+		Detail:  detail,
+		Applied: apply,
+	})
+	return apply
+}
+
+func (s *syncer) syncRoles() error {
+	var rows []*models.Role
+	if err := s.tx.Find(&rows).Error; err != nil {
+		return err
+	}
+
+	existing := make(map[int64]*models.Role, len(rows))
+	byName := make(map[string]int64, len(rows))
+	for _, r := range rows {
+		existing[r.ID] = r
+		byName[r.Name] = r.ID
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(models.RoleNames)) {
+		name, description := models.RoleNames[id], models.RoleDescriptions[id]
+
+		row, ok := existing[id]
+		if !ok {
+			if other, taken := byName[name]; taken {
+				return fmt.Errorf("role %d is named %q, which built-in role %d needs; rename it first", other, name, id)
+			}
+			s.newRoles[id] = true
+			if s.record("role", "create", name, "", true) {
+				role := &models.Role{ID: id, Name: name, Description: description, CreatedAt: &s.now}
+				if err := s.tx.Create(role).Error; err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		updates := make(map[string]interface{})
+		if row.Name != name {
+			if other, taken := byName[name]; taken && other != id {
+				return fmt.Errorf("role %d is named %q, which built-in role %d needs; rename it first", other, name, id)
+			}
+			updates["name"] = name
+		}
+		if row.Description != description {
+			updates["description"] = description
+		}
+		if len(updates) > 0 && s.record("role", "update", name, fieldList(updates), true) {
+			if err := s.tx.Model(&models.Role{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, r := range rows {
+		if _, ok := models.RoleNames[r.ID]; ok {
+			continue
+		}
+		if s.record("role", "delete", r.Name, "not defined in code", s.opts.Prune) {
+			if err := s.tx.Where("id = ?", r.ID).Delete(&models.Role{}).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *syncer) syncPermissions() error {
+	var rows []*models.Permission
+	if err := s.tx.Find(&rows).Error; err != nil {
+		return err
+	}
+
+	existing := make(map[int64]*models.Permission, len(rows))
+	byName := make(map[string]int64, len(rows))
+	byCode := make(map[string]int64, len(rows))
+	for _, p := range rows {
+		existing[p.ID] = p
+		byName[p.Name] = p.ID
+		if p.Code != nil {
+			byCode[*p.Code] = p.ID
+		}
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(models.PermissionNames)) {
+		code, name, description := models.PermissionCodes[id], models.PermissionNames[id], models.PermissionDescriptions[id]
+
+		if other, taken := byName[name]; taken && other != id {
+			return fmt.Errorf("permission %d is named %q, which built-in permission %d needs; rename it first", other, name, id)
+		}
+		if other, taken := byCode[code]; taken && other != id {
+			return fmt.Errorf("permission %d has code %q, which built-in permission %d needs; change it first", other, code, id)
+		}
+
+		row, ok := existing[id]
+		if !ok {
+			s.newPermissions[id] = true
+			if s.record("permission", "create", code, "", true) {
+				permission := &models.Permission{ID: id, Code: &code, Name: name, Description: description, CreatedAt: &s.now}
+				if err := s.tx.Create(permission).Error; err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		updates := make(map[string]interface{})
+		if row.Code == nil || *row.Code != code {
+			updates["code"] = code
+		}
+		if row.Name != name {
+			updates["name"] = name
+		}
+		if row.Description != description {
+			updates["description"] = description
+		}
+		if len(updates) > 0 && s.record("permission", "update", code, fieldList(updates), true) {
+			if err := s.tx.Model(&models.Permission{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, p := range rows {
+		if _, ok := models.PermissionNames[p.ID]; ok {
+			continue
+		}
+		if s.record("permission", "delete", p.Name, "not defined in code", s.opts.Prune) {
+			if err := s.tx.Where("id = ?", p.ID).Delete(&models.Permission{}).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// syncGrants adds the default grants of new built-in roles and permissions.
+// A default grant missing from an existing role was revoked through the API
+// and is given back only when restoring. Grants made to built-in roles
+// through the API are kept unless pruning; grants of other roles are left
+// alone.
+func (s *syncer) syncGrants() error {
+	var rows []*models.RolePermission
+	if err := s.tx.Where("role_id IN ?", slices.Collect(maps.Keys(models.RoleNames))).Find(&rows).Error; err != nil {
+		return err
+	}
+
+	have := make(map[[2]int64]bool, len(rows))
+	for _, rp := range rows {
+		have[[2]int64{rp.RoleID, rp.PermissionID}] = true
+	}
+
+	want := make(map[[2]int64]bool)
+	for _, roleID := range slices.Sorted(maps.Keys(models.RolePermissions)) {
+		for _, permissionID := range models.RolePermissions[roleID] {
+			key := [2]int64{roleID, permissionID}
+			want[key] = true
+			if have[key] {
+				continue
+			}
+			added := s.newRoles[roleID] || s.newPermissions[permissionID]
+			if s.record("role_permission", "grant", grantName(roleID, permissionID), removedDetail(added), added || s.opts.Restore) {
+				grant := &models.RolePermission{RoleID: roleID, PermissionID: permissionID, GrantedAt: &s.now}
+				if err := s.tx.Create(grant).Error; err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for _, rp := range rows {
+		if want[[2]int64{rp.RoleID, rp.PermissionID}] {
+			continue
+		}
+		if s.record("role_permission", "revoke", grantName(rp.RoleID, rp.PermissionID), "not a default grant", s.opts.Prune) {
+			if err := s.tx.Where("role_id = ? AND permission_id = ?", rp.RoleID, rp.PermissionID).Delete(&models.RolePermission{}).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// syncParents adds the default hierarchy of new built-in roles, like
+// syncGrants does for their permissions.
+func (s *syncer) syncParents() error {
+	var rows []*models.RoleParent
+	if err := s.tx.Where("role_id IN ?", slices.Collect(maps.Keys(models.RoleNames))).Find(&rows).Error; err != nil {
+		return err
+	}
+
+	have := make(map[[2]int64]bool, len(rows))
+	for _, e := range rows {
+		have[[2]int64{e.RoleID, e.ParentID}] = true
+	}
+
+	want := make(map[[2]int64]bool)
+	for _, roleID := range slices.Sorted(maps.Keys(models.RoleParents)) {
+		for _, parentID := range models.RoleParents[roleID] {
+			key := [2]int64{roleID, parentID}
+			want[key] = true
+			if have[key] {
+				continue
+			}
+			added := s.newRoles[roleID] || s.newRoles[parentID]
+			if s.record("role_parent", "add", parentName(roleID, parentID), removedDetail(added), added || s.opts.Restore) {
+				if err := s.tx.Create(&models.RoleParent{RoleID: roleID, ParentID: parentID}).Error; err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for _, e := range rows {
+		if want[[2]int64{e.RoleID, e.ParentID}] {
+			continue
+		}
+		if s.record("role_parent", "remove", parentName(e.RoleID, e.ParentID), "not a default parent", s.opts.Prune) {
+			if err := s.tx.Where("role_id = ? AND parent_id = ?", e.RoleID, e.ParentID).Delete(&models.RoleParent{}).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func removedDetail(added bool) string {
+	if added {
+		return ""
+	}
+	return "removed from an existing role"
+}
+
+func fieldList(updates map[string]interface{}) string {
+	return strings.Join(slices.Sorted(maps.Keys(updates)), ", ")
+}
+
+func grantName(roleID, permissionID int64) string {
+	permission, ok := models.PermissionCodes[permissionID]
+	if !ok {
+		permission = fmt.Sprintf("permission %d", permissionID)
+	}
+	return models.RoleNames[roleID] + " -> " + permission
+}
+
+func parentName(roleID, parentID int64) string {
+	parent, ok := models.RoleNames[parentID]
+	if !ok {
+		parent = fmt.Sprintf("role %d", parentID)
+	}
+	return models.RoleNames[roleID] + " inherits " + parent
+}
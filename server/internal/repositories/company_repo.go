@@ -68,3 +68,60 @@ func (s *mysqlStorage) DeleteCompany(ctx context.Context, id uint64) error {
 
 	return nil
 }
+
+// maxCompanyDepth bounds the walk up the company tree, so a parent cycle in
+// the data cannot loop forever.
+const maxCompanyDepth = 32
+
+// GetCompanyAncestorIDs returns the parent, grandparent and so on of the
+// company, nearest first.
+func (s *mysqlStorage) GetCompanyAncestorIDs(ctx context.Context, id uint64) ([]uint64, error) {
+	var ancestors []uint64
+
+	seen := map[uint64]bool{id: true}
+	for i := 0; i < maxCompanyDepth; i++ {
+		var company models.Company
+		if err := s.db.WithContext(ctx).Select("id", "parent_id").Where("id = ?", id).Take(&company).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				break
+			}
+			return nil, err
+		}
+
+		parentID := company.ParentID
+		if parentID == nil || seen[*parentID] {
+			break
+		}
+
+		seen[*parentID] = true
+		ancestors = append(ancestors, *parentID)
+		id = *parentID
+	}
+
+	return ancestors, nil
+}
+
+// GetUserCompanyIDs returns the companies the user currently works for,
+// through a position or an active contract.
+func (s *mysqlStorage) GetUserCompanyIDs(ctx context.Context, userID uint64) ([]uint64, error) {
+	var fromPositions, fromContracts []uint64
+
+	if err := s.db.WithContext(ctx).
+		Table("user_positions").
+		Joins("INNER JOIN positions ON positions.id = user_positions.position_id").
+		Where("user_positions.user_id = ? AND (user_positions.end_date IS NULL OR user_positions.end_date >= CURDATE())", userID).
+		Distinct().
+		Pluck("positions.company_id", &fromPositions).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.db.WithContext(ctx).
+		Model(&models.Contract{}).
+		Where("user_id = ? AND status = ?", userID, models.ContractStatusActive).
+		Distinct().
+		Pluck("company_id", &fromContracts).Error; err != nil {
+		return nil, err
+	}
+
+	return append(fromPositions, fromContracts...), nil
+}
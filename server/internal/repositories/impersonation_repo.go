@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/internal/models"
+)
+
+func (s *mysqlStorage) CreateImpersonationSession(ctx context.Context, data *models.ImpersonationSession) error {
+	if err := s.db.WithContext(ctx).Create(data).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetImpersonationSession returns the session with the requests made in it,
+// oldest first.
+func (s *mysqlStorage) GetImpersonationSession(ctx context.Context, id string) (*models.ImpersonationSession, error) {
+	var session *models.ImpersonationSession
+
+	if err := s.db.WithContext(ctx).
+		Preload("Requests", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Where("id = ?", id).
+		First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.ErrImpersonationNotFound
+		}
+
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (s *mysqlStorage) GetImpersonationSessions(ctx context.Context, limit, offset int) ([]*models.ImpersonationSession, error) {
+	var sessions []*models.ImpersonationSession
+
+	if err := s.db.WithContext(ctx).
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// EndImpersonationSession marks the session stopped. It reports false when the
+// session had already ended.
+func (s *mysqlStorage) EndImpersonationSession(ctx context.Context, id string) (bool, error) {
+	result := s.db.WithContext(ctx).
+		Model(&models.ImpersonationSession{}).
+		Where("id = ? AND ended_at IS NULL", id).
+		Update("ended_at", time.Now().UTC())
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (s *mysqlStorage) CreateImpersonationRequest(ctx context.Context, data *models.ImpersonationRequest) error {
+	if err := s.db.WithContext(ctx).Create(data).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/internal/models"
+)
+
+func (s *mysqlStorage) CreateInvitation(ctx context.Context, data *models.Invitation) error {
+	if err := s.db.WithContext(ctx).Create(data).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *mysqlStorage) GetInvitation(ctx context.Context, id string) (*models.Invitation, error) {
+	var invitation *models.Invitation
+
+	if err := s.db.WithContext(ctx).
+		Preload("Roles.Role").
+		Where("id = ?", id).
+		First(&invitation).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.ErrInvitationNotFound
+		}
+
+		return nil, err
+	}
+
+	return invitation, nil
+}
+
+// DeleteInvitation removes an invitation and its roles.
+func (s *mysqlStorage) DeleteInvitation(ctx context.Context, id string) error {
+	return s.db.WithContext(ctx).Where("id = ?", id).Delete(&models.Invitation{}).Error
+}
+
+func (s *mysqlStorage) GetInvitations(ctx context.Context, limit, offset int, query map[string]interface{}) ([]*models.Invitation, error) {
+	var invitations []*models.Invitation
+
+	if err := s.db.WithContext(ctx).
+		Preload("Roles").
+		Where(query).
+		Order("created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&invitations).Error; err != nil {
+		return nil, err
+	}
+
+	return invitations, nil
+}
+
+// RevokeInvitation withdraws an invitation. It reports false when the
+// invitation was already accepted or withdrawn.
+func (s *mysqlStorage) RevokeInvitation(ctx context.Context, id string) (bool, error) {
+	result := s.db.WithContext(ctx).
+		Model(&models.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now().UTC())
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// AcceptInvitation closes the invitation and creates the user with its roles,
+// assigned in the company of the invitation, and its position in one
+// transaction. It fails with ErrInvitationClosed when the invitation was
+// accepted, withdrawn or expired in the meantime.
+func (s *mysqlStorage) AcceptInvitation(ctx context.Context, invitation *models.Invitation, user *models.User) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+
+		result := tx.Model(&models.Invitation{}).
+			Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", invitation.ID, now).
+			Update("accepted_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return models.ErrInvitationClosed
+		}
+
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		for _, roleID := range invitation.RoleIDs() {
+			if err := tx.Create(&models.UserRole{
+				UserID:     int64(user.ID),
+				RoleID:     roleID,
+				CompanyID:  &invitation.CompanyID,
+				AssignedAt: &now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		if invitation.PositionID != nil {
+			if err := tx.Create(&models.UserPosition{
+				SQLModel:   models.NewSQLModel(),
+				UserID:     user.ID,
+				PositionID: *invitation.PositionID,
+				StartDate:  now,
+				IsPrimary:  true,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&models.Invitation{}).Where("id = ?", invitation.ID).Update("user_id", user.ID).Error
+	})
+}
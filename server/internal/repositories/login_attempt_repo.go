@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/vlahanam/company-management/internal/models"
+)
+
+func (s *mysqlStorage) GetLoginAttempts(ctx context.Context, keys []string) ([]*models.LoginAttempt, error) {
+	var attempts []*models.LoginAttempt
+
+	if err := s.db.WithContext(ctx).Where("attempt_key IN ?", keys).Find(&attempts).Error; err != nil {
+		return nil, err
+	}
+
+	return attempts, nil
+}
+
+// RecordLoginFailure increments the failure counter of key in a single
+// statement, so concurrent failures on several instances are all counted. The
+// counter starts over once the last failure is older than the window and no
+// lockout is active.
+func (s *mysqlStorage) RecordLoginFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*models.LoginAttempt, error) {
+	staleBefore := now.Add(-window)
+
+	err := s.db.WithContext(ctx).Exec(`
+		INSERT INTO login_attempts (attempt_key, failures, last_failed_at) VALUES (?, 1, ?)
+		ON DUPLICATE KEY UPDATE
+			failures = IF(last_failed_at < ? AND (locked_until IS NULL OR locked_until <= ?), 1, failures + 1),
+			locked_until = IF(locked_until <= ?, NULL, locked_until),
+			last_failed_at = ?`,
+		key, now, staleBefore, now, now, now,
+	).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var attempt *models.LoginAttempt
+	if err := s.db.WithContext(ctx).Where("attempt_key = ?", key).First(&attempt).Error; err != nil {
+		return nil, err
+	}
+
+	return attempt, nil
+}
+
+func (s *mysqlStorage) LockLoginAttempt(ctx context.Context, key string, until time.Time) error {
+	if err := s.db.WithContext(ctx).
+		Model(&models.LoginAttempt{}).
+		Where("attempt_key = ?", key).
+		Update("locked_until", until).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *mysqlStorage) DeleteLoginAttempts(ctx context.Context, keys []string) error {
+	if err := s.db.WithContext(ctx).Where("attempt_key IN ?", keys).Delete(&models.LoginAttempt{}).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteStaleLoginAttempts drops counters whose last failure is older than
+// before and that are not locked.
+func (s *mysqlStorage) DeleteStaleLoginAttempts(ctx context.Context, before time.Time) error {
+	if err := s.db.WithContext(ctx).
+		Where("last_failed_at < ? AND (locked_until IS NULL OR locked_until < ?)", before, before).
+		Delete(&models.LoginAttempt{}).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/internal/models"
+)
+
+// UserRequiresMFA reports whether any role of the user enforces two-factor
+// authentication.
+func (s *mysqlStorage) UserRequiresMFA(ctx context.Context, userID uint64) (bool, error) {
+	var count int64
+
+	err := s.db.WithContext(ctx).
+		Table("roles").
+		Joins("INNER JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ? AND roles.require_mfa = ?", userID, true).
+		Count(&count).Error
+
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// ReplaceRecoveryCodes discards the user's previous recovery codes and stores
+// the new set.
+func (s *mysqlStorage) ReplaceRecoveryCodes(ctx context.Context, userID uint64, codeHashes []string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		codes := make([]models.RecoveryCode, 0, len(codeHashes))
+		for _, h := range codeHashes {
+			codes = append(codes, models.RecoveryCode{
+				UserID:    userID,
+				CodeHash:  h,
+				CreatedAt: &now,
+			})
+		}
+
+		return tx.Create(&codes).Error
+	})
+}
+
+// UseRecoveryCode consumes an unused recovery code; it reports false when the
+// code does not exist or was already used.
+func (s *mysqlStorage) UseRecoveryCode(ctx context.Context, userID uint64, codeHash string) (bool, error) {
+	result := s.db.WithContext(ctx).
+		Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now().UTC())
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// UseTOTPStep records the time step of an accepted TOTP code. It reports
+// false when the step is not newer than the last one recorded, so a code is
+// accepted once even when it is presented twice at the same time.
+func (s *mysqlStorage) UseTOTPStep(ctx context.Context, userID uint64, step int64) (bool, error) {
+	result := s.db.WithContext(ctx).
+		Model(&models.User{}).
+		Where("id = ? AND (totp_last_step IS NULL OR totp_last_step < ?)", userID, step).
+		Update("totp_last_step", step)
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (s *mysqlStorage) DeleteRecoveryCodes(ctx context.Context, userID uint64) error {
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
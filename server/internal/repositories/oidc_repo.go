@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/internal/models"
+)
+
+func (s *mysqlStorage) GetUserIdentity(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	var identity *models.UserIdentity
+
+	if err := s.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.ErrOIDCNoAccount
+		}
+
+		return nil, err
+	}
+
+	return identity, nil
+}
+
+func (s *mysqlStorage) CreateUserIdentity(ctx context.Context, data *models.UserIdentity) error {
+	if err := s.db.WithContext(ctx).Create(data).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *mysqlStorage) UpdateUserIdentity(ctx context.Context, id uint64, data map[string]interface{}) error {
+	if err := s.db.WithContext(ctx).Model(&models.UserIdentity{}).Where("id = ?", id).Updates(data).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CreateUserWithIdentity provisions a user and links the SSO identity in one
+// transaction, so a failed link does not leave an orphan account.
+func (s *mysqlStorage) CreateUserWithIdentity(ctx context.Context, user *models.User, identity *models.UserIdentity) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		identity.UserID = user.ID
+		return tx.Create(identity).Error
+	})
+}
+
+func (s *mysqlStorage) CreateOIDCLoginState(ctx context.Context, data *models.OIDCLoginState) error {
+	if err := s.db.WithContext(ctx).Create(data).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// TakeOIDCLoginState loads and deletes an unexpired login state in one step so
+// a callback can only be redeemed once.
+func (s *mysqlStorage) TakeOIDCLoginState(ctx context.Context, stateHash, provider string) (*models.OIDCLoginState, error) {
+	var state *models.OIDCLoginState
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+
+		if err := tx.Where("state_hash = ? AND provider = ? AND expires_at > ?", stateHash, provider, now).First(&state).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("state_hash = ?", stateHash).Delete(&models.OIDCLoginState{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Where("expires_at <= ?", now).Delete(&models.OIDCLoginState{}).Error
+	})
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.ErrInvalidOIDCState
+		}
+
+		return nil, err
+	}
+
+	return state, nil
+}
@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/internal/models"
+)
+
+// GetPasswordHistory returns the hashes of the user's most recent previous
+// passwords, newest first.
+func (s *mysqlStorage) GetPasswordHistory(ctx context.Context, userID uint64, limit int) ([]string, error) {
+	var hashes []string
+
+	if err := s.db.WithContext(ctx).
+		Model(&models.PasswordHistory{}).
+		Where("user_id = ?", userID).
+		Order("id DESC").
+		Limit(limit).
+		Pluck("hash_password", &hashes).Error; err != nil {
+		return nil, err
+	}
+
+	return hashes, nil
+}
+
+// ChangeUserPassword sets the new password hash and moves the old one into
+// the history, keeping only the newest keep entries.
+func (s *mysqlStorage) ChangeUserPassword(ctx context.Context, userID uint64, data map[string]interface{}, oldHash string, keep int) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return changeUserPassword(tx, userID, data, oldHash, keep)
+	})
+}
+
+func changeUserPassword(tx *gorm.DB, userID uint64, data map[string]interface{}, oldHash string, keep int) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(data).Error; err != nil {
+		return err
+	}
+
+	if keep <= 0 {
+		return tx.Where("user_id = ?", userID).Delete(&models.PasswordHistory{}).Error
+	}
+
+	if err := tx.Create(&models.PasswordHistory{UserID: userID, HashPassword: oldHash}).Error; err != nil {
+		return err
+	}
+
+	var kept []uint64
+	if err := tx.Model(&models.PasswordHistory{}).
+		Where("user_id = ?", userID).
+		Order("id DESC").
+		Limit(keep).
+		Pluck("id", &kept).Error; err != nil {
+		return err
+	}
+
+	return tx.Where("user_id = ? AND id NOT IN ?", userID, kept).Delete(&models.PasswordHistory{}).Error
+}
+
+// GetUserPasswordMaxAge returns the strictest password expiry among the roles
+// of the user in days, or 0 when none of them expires passwords.
+func (s *mysqlStorage) GetUserPasswordMaxAge(ctx context.Context, userID uint64) (int, error) {
+	var days sql.NullInt64
+
+	err := s.db.WithContext(ctx).
+		Table("roles").
+		Joins("INNER JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ? AND roles.password_max_age_days IS NOT NULL", userID).
+		Select("MIN(roles.password_max_age_days)").
+		Scan(&days).Error
+
+	if err != nil {
+		return 0, err
+	}
+
+	return int(days.Int64), nil
+}
@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/internal/models"
+)
+
+func (s *mysqlStorage) CreatePasswordResetToken(ctx context.Context, data *models.PasswordResetToken) error {
+	if err := s.db.WithContext(ctx).Create(data).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *mysqlStorage) GetPasswordResetToken(ctx context.Context, data map[string]interface{}) (*models.PasswordResetToken, error) {
+	var token *models.PasswordResetToken
+	if err := s.db.WithContext(ctx).Where(data).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.ErrPasswordResetTokenInvalid
+		}
+
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// ResetUserPassword consumes the token and changes the password like
+// ChangeUserPassword, in one transaction. It reports false and changes nothing
+// when the token was already used, so a link can only be redeemed once.
+func (s *mysqlStorage) ResetUserPassword(ctx context.Context, tokenID, userID uint64, data map[string]interface{}, oldHash string, keep int) (bool, error) {
+	consumed := false
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", tokenID).
+			Update("used_at", time.Now().UTC())
+		if result.Error != nil || result.RowsAffected != 1 {
+			return result.Error
+		}
+
+		if err := changeUserPassword(tx, userID, data, oldHash, keep); err != nil {
+			return err
+		}
+
+		consumed = true
+		return nil
+	})
+
+	return consumed, err
+}
+
+func (s *mysqlStorage) InvalidateUserPasswordResetTokens(ctx context.Context, userID uint64) error {
+	if err := s.db.WithContext(ctx).
+		Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now().UTC()).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -61,3 +61,12 @@ func (s *mysqlStorage) DeletePermission(ctx context.Context, id int64) error {
 
 	return nil
 }
+
+func (s *mysqlStorage) GetPermissionsByIDs(ctx context.Context, ids []int64) ([]*models.Permission, error) {
+	var permissions []*models.Permission
+	if err := s.db.WithContext(ctx).Where("id IN ?", ids).Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
@@ -0,0 +1,64 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/internal/models"
+)
+
+func (s *mysqlStorage) CreatePolicy(ctx context.Context, data *models.Policy) error {
+	if err := s.db.WithContext(ctx).Create(data).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *mysqlStorage) GetPolicy(ctx context.Context, data map[string]interface{}) (*models.Policy, error) {
+	var p *models.Policy
+	if err := s.db.WithContext(ctx).Where(data).First(&p).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.ErrPolicyNotFound
+		}
+
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// GetPolicies returns the stored policies in the order they were created,
+// only the enabled ones when enabledOnly is set.
+func (s *mysqlStorage) GetPolicies(ctx context.Context, enabledOnly bool) ([]*models.Policy, error) {
+	var policies []*models.Policy
+
+	qr := s.db.WithContext(ctx).Order("id")
+	if enabledOnly {
+		qr = qr.Where("enabled = ?", true)
+	}
+
+	if err := qr.Find(&policies).Error; err != nil {
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+func (s *mysqlStorage) UpdatePolicy(ctx context.Context, data *models.Policy) error {
+	if err := s.db.WithContext(ctx).Save(data).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *mysqlStorage) DeletePolicy(ctx context.Context, id int64) error {
+	if err := s.db.WithContext(ctx).Where("id = ?", id).Delete(&models.Policy{}).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
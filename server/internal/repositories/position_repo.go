@@ -68,3 +68,64 @@ func (s *mysqlStorage) DeletePosition(ctx context.Context, id uint64) error {
 
 	return nil
 }
+
+// GetPrimaryUserPosition returns the current primary position of the user
+// together with its company.
+func (s *mysqlStorage) GetPrimaryUserPosition(ctx context.Context, userID uint64) (*models.UserPosition, error) {
+	var position *models.UserPosition
+
+	if err := s.db.WithContext(ctx).
+		Preload("Position.Company").
+		Where("user_id = ? AND is_primary = ? AND (end_date IS NULL OR end_date >= CURDATE())", userID, true).
+		Order("start_date DESC").
+		First(&position).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.ErrUserPositionNotFound
+		}
+
+		return nil, err
+	}
+
+	return position, nil
+}
+
+// IsUserManagerOf reports whether the manager holds a position in a company
+// where the user holds a position of a lower level.
+func (s *mysqlStorage) IsUserManagerOf(ctx context.Context, managerID, userID uint64) (bool, error) {
+	var count int64
+
+	if err := s.db.WithContext(ctx).
+		Table("user_positions AS mup").
+		Joins("INNER JOIN positions AS mp ON mp.id = mup.position_id").
+		Joins("INNER JOIN positions AS sp ON sp.company_id = mp.company_id AND sp.level < mp.level").
+		Joins("INNER JOIN user_positions AS sup ON sup.position_id = sp.id").
+		Where("mup.user_id = ? AND (mup.end_date IS NULL OR mup.end_date >= CURDATE())", managerID).
+		Where("sup.user_id = ? AND (sup.end_date IS NULL OR sup.end_date >= CURDATE())", userID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// GetManagedUserIDs returns the users the manager manages in the sense of
+// IsUserManagerOf: those holding a lower-level position in a company where
+// the manager holds a position.
+func (s *mysqlStorage) GetManagedUserIDs(ctx context.Context, managerID uint64) ([]uint64, error) {
+	var ids []uint64
+
+	if err := s.db.WithContext(ctx).
+		Table("user_positions AS mup").
+		Joins("INNER JOIN positions AS mp ON mp.id = mup.position_id").
+		Joins("INNER JOIN positions AS sp ON sp.company_id = mp.company_id AND sp.level < mp.level").
+		Joins("INNER JOIN user_positions AS sup ON sup.position_id = sp.id").
+		Where("mup.user_id = ? AND (mup.end_date IS NULL OR mup.end_date >= CURDATE())", managerID).
+		Where("sup.end_date IS NULL OR sup.end_date >= CURDATE()").
+		Where("sup.user_id <> ?", managerID).
+		Distinct().
+		Pluck("sup.user_id", &ids).Error; err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
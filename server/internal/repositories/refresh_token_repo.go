@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/internal/models"
+)
+
+func (s *mysqlStorage) CreateRefreshToken(ctx context.Context, data *models.RefreshToken) error {
+	if err := s.db.WithContext(ctx).Create(data).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *mysqlStorage) GetRefreshToken(ctx context.Context, data map[string]interface{}) (*models.RefreshToken, error) {
+	var token *models.RefreshToken
+	if err := s.db.WithContext(ctx).Where(data).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.ErrRefreshTokenNotFound
+		}
+
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// MarkRefreshTokenUsed flags the token as rotated. It only succeeds for a token
+// that has not been used yet, so two concurrent refreshes cannot both win.
+func (s *mysqlStorage) MarkRefreshTokenUsed(ctx context.Context, jti string) (bool, error) {
+	result := s.db.WithContext(ctx).
+		Model(&models.RefreshToken{}).
+		Where("jti = ? AND used_at IS NULL AND revoked_at IS NULL", jti).
+		Update("used_at", time.Now().UTC())
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (s *mysqlStorage) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	if err := s.db.WithContext(ctx).
+		Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now().UTC()).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *mysqlStorage) RevokeUserRefreshTokens(ctx context.Context, userID uint64) error {
+	if err := s.db.WithContext(ctx).
+		Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now().UTC()).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetUserRefreshTokenFamilies lists the token families of the user that still
+// hold a usable refresh token.
+func (s *mysqlStorage) GetUserRefreshTokenFamilies(ctx context.Context, userID uint64) ([]string, error) {
+	var families []string
+
+	if err := s.db.WithContext(ctx).
+		Model(&models.RefreshToken{}).
+		Distinct("family_id").
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now().UTC()).
+		Pluck("family_id", &families).Error; err != nil {
+		return nil, err
+	}
+
+	return families, nil
+}
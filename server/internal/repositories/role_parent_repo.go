@@ -0,0 +1,212 @@
+package repositories
+
+import (
+	"context"
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/vlahanam/company-management/internal/models"
+)
+
+// GetRoleParentMap returns the parents of every role that inherits from
+// another. The hierarchy is small, so it is loaded whole.
+func (s *mysqlStorage) GetRoleParentMap(ctx context.Context) (map[int64][]int64, error) {
+	var edges []*models.RoleParent
+	if err := s.db.WithContext(ctx).Find(&edges).Error; err != nil {
+		return nil, err
+	}
+
+	parents := make(map[int64][]int64)
+	for _, e := range edges {
+		parents[e.RoleID] = append(parents[e.RoleID], e.ParentID)
+	}
+
+	return parents, nil
+}
+
+// expandRoleIDs returns the roles together with every role they inherit
+// from, directly or through other roles. A cycle in the data stops the walk
+// instead of looping.
+func (s *mysqlStorage) expandRoleIDs(ctx context.Context, roleIDs []int64) ([]int64, error) {
+	if len(roleIDs) == 0 {
+		return roleIDs, nil
+	}
+
+	parents, err := s.GetRoleParentMap(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[int64]bool, len(roleIDs))
+	expanded := make([]int64, 0, len(roleIDs))
+	queue := append([]int64(nil), roleIDs...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+
+		seen[id] = true
+		expanded = append(expanded, id)
+		queue = append(queue, parents[id]...)
+	}
+
+	return expanded, nil
+}
+
+// GetRoleAncestorIDs returns every role the role inherits from, directly or
+// through other roles, nearest first.
+func (s *mysqlStorage) GetRoleAncestorIDs(ctx context.Context, roleID int64) ([]int64, error) {
+	expanded, err := s.expandRoleIDs(ctx, []int64{roleID})
+	if err != nil {
+		return nil, err
+	}
+
+	ancestors := make([]int64, 0, len(expanded))
+	for _, id := range expanded {
+		if id != roleID {
+			ancestors = append(ancestors, id)
+		}
+	}
+
+	return ancestors, nil
+}
+
+// GetRoleParents returns the roles the role inherits from directly.
+func (s *mysqlStorage) GetRoleParents(ctx context.Context, roleID int64) ([]*models.Role, error) {
+	var roles []*models.Role
+
+	if err := s.db.WithContext(ctx).
+		Where("id IN (?)", s.db.Model(&models.RoleParent{}).
+			Select("parent_id").
+			Where("role_id = ?", roleID)).
+		Order("id").
+		Find(&roles).Error; err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// GetInheritedRolePermissions returns the permissions the role gets from the
+// roles it inherits from.
+func (s *mysqlStorage) GetInheritedRolePermissions(ctx context.Context, roleID int64) ([]*models.Permission, error) {
+	ancestors, err := s.GetRoleAncestorIDs(ctx, roleID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getPermissionsOfRoles(ctx, ancestors)
+}
+
+// SetRoleParents replaces the roles the role inherits from directly. The
+// parents may carry only the permissions in held. It fails with
+// ErrRoleInheritanceCycle when a parent is the role or inherits from it, and
+// with ErrPermissionGrantNotHeld when they carry more than held.
+func (s *mysqlStorage) SetRoleParents(ctx context.Context, roleID int64, parentIDs []int64, held []int64) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return setRoleParents(tx, roleID, parentIDs, held)
+	})
+}
+
+// CreateRoleWithParents creates the role and the roles it inherits from
+// directly in one transaction, checking the parents like SetRoleParents.
+func (s *mysqlStorage) CreateRoleWithParents(ctx context.Context, data *models.Role, parentIDs []int64, held []int64) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(data).Error; err != nil {
+			return err
+		}
+
+		return setRoleParents(tx, data.ID, parentIDs, held)
+	})
+}
+
+// setRoleParents checks and writes the parents of the role. The role and
+// every role the parents inherit from are locked first, and the hierarchy is
+// read with locking reads: a concurrent change that could close a cycle with
+// this one locks some of the same roles, so it waits and then sees this one.
+func setRoleParents(tx *gorm.DB, roleID int64, parentIDs []int64, held []int64) error {
+	if err := tx.Model(&models.Role{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", roleID).
+		Pluck("id", new([]int64)).Error; err != nil {
+		return err
+	}
+
+	ancestors, err := lockRoleAncestors(tx, parentIDs)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(ancestors, roleID) {
+		return models.ErrRoleInheritanceCycle
+	}
+
+	var inherited []int64
+	if len(ancestors) > 0 {
+		if err := tx.Model(&models.RolePermission{}).
+			Where("role_id IN ?", ancestors).
+			Distinct().
+			Pluck("permission_id", &inherited).Error; err != nil {
+			return err
+		}
+	}
+	for _, id := range inherited {
+		if !slices.Contains(held, id) {
+			return models.ErrPermissionGrantNotHeld
+		}
+	}
+
+	if err := tx.Where("role_id = ?", roleID).Delete(&models.RoleParent{}).Error; err != nil {
+		return err
+	}
+
+	for _, id := range parentIDs {
+		if err := tx.Create(&models.RoleParent{RoleID: roleID, ParentID: id}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lockRoleAncestors locks the roles and every role they inherit from, and
+// returns them all.
+func lockRoleAncestors(tx *gorm.DB, roleIDs []int64) ([]int64, error) {
+	var locked []int64
+
+	seen := make(map[int64]bool)
+	queue := roleIDs
+	for len(queue) > 0 {
+		var batch []int64
+		for _, id := range queue {
+			if !seen[id] {
+				seen[id] = true
+				batch = append(batch, id)
+			}
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		if err := tx.Model(&models.Role{}).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", batch).
+			Pluck("id", new([]int64)).Error; err != nil {
+			return nil, err
+		}
+		locked = append(locked, batch...)
+
+		queue = nil
+		if err := tx.Model(&models.RoleParent{}).
+			Clauses(clause.Locking{Strength: "SHARE"}).
+			Where("role_id IN ?", batch).
+			Pluck("parent_id", &queue).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return locked, nil
+}
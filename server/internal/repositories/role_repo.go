@@ -2,10 +2,18 @@ package repositories
 
 import (
 	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/vlahanam/company-management/internal/models"
 )
 
+// GetUserRoleNames returns the roles the user holds in every company. They
+// are the roles carried in access tokens.
 func (s *mysqlStorage) GetUserRoleNames(ctx context.Context, userID uint64) ([]string, error) {
 	var roleNames []string
 
@@ -13,7 +21,7 @@ func (s *mysqlStorage) GetUserRoleNames(ctx context.Context, userID uint64) ([]s
 		Table("roles").
 		Select("roles.name").
 		Joins("INNER JOIN user_roles ON user_roles.role_id = roles.id").
-		Where("user_roles.user_id = ?", userID).
+		Where("user_roles.user_id = ? AND user_roles.company_id IS NULL", userID).
 		Pluck("name", &roleNames).Error
 
 	if err != nil {
@@ -23,6 +31,127 @@ func (s *mysqlStorage) GetUserRoleNames(ctx context.Context, userID uint64) ([]s
 	return roleNames, nil
 }
 
+// GetUserGlobalRoleIDs returns the roles the user is assigned in every
+// company, without the roles they inherit from.
+func (s *mysqlStorage) GetUserGlobalRoleIDs(ctx context.Context, userID uint64) ([]int64, error) {
+	var roleIDs []int64
+
+	if err := s.db.WithContext(ctx).
+		Table("user_roles").
+		Where("user_id = ? AND company_id IS NULL", userID).
+		Pluck("role_id", &roleIDs).Error; err != nil {
+		return nil, err
+	}
+
+	return roleIDs, nil
+}
+
+// GetUserPermissionIDs returns the permissions the user holds in every
+// company.
+func (s *mysqlStorage) GetUserPermissionIDs(ctx context.Context, userID uint64) ([]int64, error) {
+	return s.GetUserPermissionIDsInCompanies(ctx, userID, nil)
+}
+
+// GetUserPermissionIDsInCompanies returns the permissions the user holds in
+// at least one of the companies: through global roles, roles assigned in the
+// company itself, or roles assigned with subsidiaries in one of its ancestors,
+// and through the roles those roles inherit from. Without companies only
+// global roles count.
+func (s *mysqlStorage) GetUserPermissionIDsInCompanies(ctx context.Context, userID uint64, companyIDs []uint64) ([]int64, error) {
+	roleIDs, err := s.GetUserRoleIDsInCompanies(ctx, userID, companyIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getDirectRolePermissionIDs(ctx, roleIDs)
+}
+
+// GetUserRoleIDsInCompanies returns the roles the user holds in at least one
+// of the companies, by the same rules as GetUserPermissionIDsInCompanies,
+// together with the roles they inherit from.
+func (s *mysqlStorage) GetUserRoleIDsInCompanies(ctx context.Context, userID uint64, companyIDs []uint64) ([]int64, error) {
+	var roleIDs []int64
+
+	scope, err := s.userRoleScope(ctx, companyIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.WithContext(ctx).
+		Table("user_roles").
+		Distinct("user_roles.role_id").
+		Where("user_roles.user_id = ?", userID).
+		Where(scope).
+		Pluck("user_roles.role_id", &roleIDs).Error; err != nil {
+		return nil, err
+	}
+
+	return s.expandRoleIDs(ctx, roleIDs)
+}
+
+// userRoleScope matches the user_roles rows that apply in at least one of the
+// companies: global ones, ones assigned in the company, and ones assigned
+// with subsidiaries in one of its ancestors.
+func (s *mysqlStorage) userRoleScope(ctx context.Context, companyIDs []uint64) (*gorm.DB, error) {
+	scope := s.db.Where("user_roles.company_id IS NULL")
+	if len(companyIDs) == 0 {
+		return scope, nil
+	}
+
+	var subtree []uint64
+	for _, id := range companyIDs {
+		ancestors, err := s.GetCompanyAncestorIDs(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		subtree = append(subtree, id)
+		subtree = append(subtree, ancestors...)
+	}
+
+	return scope.
+		Or("user_roles.company_id IN ?", companyIDs).
+		Or("user_roles.include_subsidiaries = ? AND user_roles.company_id IN ?", true, subtree), nil
+}
+
+// GetUserRoles returns the role assignments of the user with their role and
+// company.
+func (s *mysqlStorage) GetUserRoles(ctx context.Context, userID uint64) ([]*models.UserRole, error) {
+	var roles []*models.UserRole
+
+	if err := s.db.WithContext(ctx).
+		Preload("Role").
+		Preload("Company").
+		Where("user_id = ?", userID).
+		Order("role_id, company_id").
+		Find(&roles).Error; err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// GetUserPermissions returns the permissions granted to the user by any of
+// their roles, or the roles they inherit from, whatever company the role is
+// assigned in.
+func (s *mysqlStorage) GetUserPermissions(ctx context.Context, userID uint64) ([]*models.Permission, error) {
+	var roleIDs []int64
+
+	if err := s.db.WithContext(ctx).
+		Model(&models.UserRole{}).
+		Distinct("role_id").
+		Where("user_id = ?", userID).
+		Pluck("role_id", &roleIDs).Error; err != nil {
+		return nil, err
+	}
+
+	roleIDs, err := s.expandRoleIDs(ctx, roleIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getPermissionsOfRoles(ctx, roleIDs)
+}
+
 func (s *mysqlStorage) CreateRole(ctx context.Context, data *models.Role) error {
 	if err := s.db.WithContext(ctx).Create(data).Error; err != nil {
 		return err
@@ -40,6 +169,59 @@ func (s *mysqlStorage) GetRole(ctx context.Context, data map[string]interface{})
 	return role, nil
 }
 
+func (s *mysqlStorage) GetRolesByIDs(ctx context.Context, ids []int64) ([]*models.Role, error) {
+	var roles []*models.Role
+	if err := s.db.WithContext(ctx).Where("id IN ?", ids).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+// GetRolePermissionIDs returns the permissions granted by any of the roles,
+// directly or through the roles they inherit from.
+func (s *mysqlStorage) GetRolePermissionIDs(ctx context.Context, roleIDs []int64) ([]int64, error) {
+	roleIDs, err := s.expandRoleIDs(ctx, roleIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getDirectRolePermissionIDs(ctx, roleIDs)
+}
+
+// GetRoleIDsGrantingPermission returns the roles the permission is granted
+// to directly, not counting the roles that inherit it.
+func (s *mysqlStorage) GetRoleIDsGrantingPermission(ctx context.Context, permissionID int64) ([]int64, error) {
+	var roleIDs []int64
+
+	if err := s.db.WithContext(ctx).
+		Model(&models.RolePermission{}).
+		Where("permission_id = ?", permissionID).
+		Pluck("role_id", &roleIDs).Error; err != nil {
+		return nil, err
+	}
+
+	return roleIDs, nil
+}
+
+func (s *mysqlStorage) getDirectRolePermissionIDs(ctx context.Context, roleIDs []int64) ([]int64, error) {
+	var permissionIDs []int64
+
+	if len(roleIDs) == 0 {
+		return permissionIDs, nil
+	}
+
+	if err := s.db.WithContext(ctx).
+		Model(&models.RolePermission{}).
+		Distinct("permission_id").
+		Where("role_id IN ?", roleIDs).
+		Pluck("permission_id", &permissionIDs).Error; err != nil {
+		return nil, err
+	}
+
+	return permissionIDs, nil
+}
+
 func (s *mysqlStorage) GetAllRolesWithPagination(ctx context.Context, limit, offset int) ([]*models.Role, error) {
 	var roles []*models.Role
 
@@ -78,3 +260,147 @@ func (s *mysqlStorage) DeleteRole(ctx context.Context, id int64) error {
 
 	return nil
 }
+
+// GetRolePermissions returns the permissions granted to the role itself.
+func (s *mysqlStorage) GetRolePermissions(ctx context.Context, roleID int64) ([]*models.Permission, error) {
+	return s.getPermissionsOfRoles(ctx, []int64{roleID})
+}
+
+// getPermissionsOfRoles returns the permissions granted directly to any of
+// the roles.
+func (s *mysqlStorage) getPermissionsOfRoles(ctx context.Context, roleIDs []int64) ([]*models.Permission, error) {
+	var permissions []*models.Permission
+
+	if len(roleIDs) == 0 {
+		return permissions, nil
+	}
+
+	if err := s.db.WithContext(ctx).
+		Where("id IN (?)", s.db.Model(&models.RolePermission{}).
+			Select("permission_id").
+			Where("role_id IN ?", roleIDs)).
+		Order("id").
+		Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+
+	return permissions, nil
+}
+
+// GrantRolePermissions grants the permissions to the role, skipping those it
+// already has.
+func (s *mysqlStorage) GrantRolePermissions(ctx context.Context, roleID int64, permissionIDs []int64) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing []int64
+		if err := tx.Model(&models.RolePermission{}).
+			Where("role_id = ? AND permission_id IN ?", roleID, permissionIDs).
+			Pluck("permission_id", &existing).Error; err != nil {
+			return err
+		}
+
+		granted := make(map[int64]bool, len(existing))
+		for _, id := range existing {
+			granted[id] = true
+		}
+
+		now := time.Now().UTC()
+		for _, id := range permissionIDs {
+			if granted[id] {
+				continue
+			}
+			granted[id] = true
+
+			if err := tx.Create(&models.RolePermission{
+				RoleID:       roleID,
+				PermissionID: id,
+				GrantedAt:    &now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// RevokeRolePermissions takes the permissions away from the role.
+func (s *mysqlStorage) RevokeRolePermissions(ctx context.Context, roleID int64, permissionIDs []int64) error {
+	if err := s.db.WithContext(ctx).
+		Where("role_id = ? AND permission_id IN ?", roleID, permissionIDs).
+		Delete(&models.RolePermission{}).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetUserRole returns the first role assignment matching data.
+func (s *mysqlStorage) GetUserRole(ctx context.Context, data map[string]interface{}) (*models.UserRole, error) {
+	var userRole *models.UserRole
+	if err := s.db.WithContext(ctx).Where(data).First(&userRole).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.ErrUserRoleNotFound
+		}
+		return nil, err
+	}
+
+	return userRole, nil
+}
+
+func (s *mysqlStorage) CreateUserRole(ctx context.Context, data *models.UserRole) error {
+	if err := s.db.WithContext(ctx).Omit("Role", "Company").Create(data).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteUserRole removes the role assignment. The global Super Admin role of
+// the last active Super Admin is kept, see keepSuperAdmin.
+func (s *mysqlStorage) DeleteUserRole(ctx context.Context, id uint64) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var userRole models.UserRole
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&userRole).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return models.ErrUserRoleNotFound
+			}
+			return err
+		}
+
+		if userRole.RoleID == models.RoleSuperAdmin && userRole.CompanyID == nil {
+			if err := keepSuperAdmin(tx, uint64(userRole.UserID)); err != nil {
+				return err
+			}
+		}
+
+		return tx.Where("id = ?", id).Delete(&models.UserRole{}).Error
+	})
+}
+
+// keepSuperAdmin returns models.ErrLastSuperAdmin when the user is the only
+// active user holding the Super Admin role in every company. It locks those
+// assignments until the transaction ends, so concurrent removals run one
+// after the other and the second one sees the first.
+func keepSuperAdmin(tx *gorm.DB, userID uint64) error {
+	var holders []uint64
+
+	if err := tx.Model(&models.UserRole{}).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Joins("INNER JOIN users ON users.id = user_roles.user_id").
+		Where("user_roles.role_id = ? AND user_roles.company_id IS NULL", models.RoleSuperAdmin).
+		Where("users.deactivated_at IS NULL").
+		Pluck("user_roles.user_id", &holders).Error; err != nil {
+		return err
+	}
+
+	if !slices.Contains(holders, userID) {
+		return nil
+	}
+	for _, id := range holders {
+		if id != userID {
+			return nil
+		}
+	}
+
+	return models.ErrLastSuperAdmin
+}
@@ -0,0 +1,311 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/scim"
+)
+
+// scimAttribute is the column a SCIM attribute is filtered on. Flag
+// attributes are true while the column is NULL.
+type scimAttribute struct {
+	column string
+	flag   bool
+}
+
+var scimUserAttributes = map[string]scimAttribute{
+	"username":           {column: "users.email"},
+	"emails":             {column: "users.email"},
+	"emails.value":       {column: "users.email"},
+	"externalid":         {column: "users.external_id"},
+	"displayname":        {column: "users.full_name"},
+	"name.formatted":     {column: "users.full_name"},
+	"phonenumbers":       {column: "users.phone_number"},
+	"phonenumbers.value": {column: "users.phone_number"},
+	"active":             {column: "users.deactivated_at", flag: true},
+	"meta.created":       {column: "users.created_at"},
+	"meta.lastmodified":  {column: "users.updated_at"},
+}
+
+var scimGroupAttributes = map[string]scimAttribute{
+	"id":           {column: "roles.id"},
+	"displayname":  {column: "roles.name"},
+	"meta.created": {column: "roles.created_at"},
+}
+
+// GetSCIMUsers returns a page of the users matching the filter and the
+// number of matches.
+func (s *mysqlStorage) GetSCIMUsers(ctx context.Context, filter scim.Filter, limit, offset int) ([]*models.User, int64, error) {
+	qr, err := scimQuery(s.db.WithContext(ctx).Model(&models.User{}), filter, scimUserAttributes)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := qr.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []*models.User
+	if err := qr.Order("users.id").Limit(limit).Offset(offset).Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// GetSCIMGroups returns a page of the roles matching the filter and the
+// number of matches.
+func (s *mysqlStorage) GetSCIMGroups(ctx context.Context, filter scim.Filter, limit, offset int) ([]*models.Role, int64, error) {
+	qr, err := scimQuery(s.db.WithContext(ctx).Model(&models.Role{}), filter, scimGroupAttributes)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := qr.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var roles []*models.Role
+	if err := qr.Order("roles.id").Limit(limit).Offset(offset).Find(&roles).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return roles, total, nil
+}
+
+func (s *mysqlStorage) GetUsersByIDs(ctx context.Context, ids []uint64) ([]*models.User, error) {
+	var users []*models.User
+	if err := s.db.WithContext(ctx).Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// GetRoleMembers returns the users holding each role in every company. Roles
+// assigned in a single company are not group memberships.
+func (s *mysqlStorage) GetRoleMembers(ctx context.Context, roleIDs []int64) (map[int64][]*models.User, error) {
+	var rows []struct {
+		RoleID int64
+		models.User
+	}
+
+	if err := s.db.WithContext(ctx).
+		Table("user_roles").
+		Select("user_roles.role_id, users.*").
+		Joins("JOIN users ON users.id = user_roles.user_id").
+		Where("user_roles.role_id IN ? AND user_roles.company_id IS NULL", roleIDs).
+		Order("users.id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	members := make(map[int64][]*models.User, len(roleIDs))
+	for i := range rows {
+		members[rows[i].RoleID] = append(members[rows[i].RoleID], &rows[i].User)
+	}
+
+	return members, nil
+}
+
+// GetUsersGlobalRoles returns the roles each user holds in every company.
+func (s *mysqlStorage) GetUsersGlobalRoles(ctx context.Context, userIDs []uint64) (map[uint64][]*models.Role, error) {
+	var rows []struct {
+		UserID uint64
+		models.Role
+	}
+
+	if err := s.db.WithContext(ctx).
+		Table("user_roles").
+		Select("user_roles.user_id, roles.*").
+		Joins("JOIN roles ON roles.id = user_roles.role_id").
+		Where("user_roles.user_id IN ? AND user_roles.company_id IS NULL", userIDs).
+		Order("roles.id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	roles := make(map[uint64][]*models.Role, len(userIDs))
+	for i := range rows {
+		roles[rows[i].UserID] = append(roles[rows[i].UserID], &rows[i].Role)
+	}
+
+	return roles, nil
+}
+
+// AddRoleMembers assigns the role to the users in every company, skipping
+// users who already hold it there.
+func (s *mysqlStorage) AddRoleMembers(ctx context.Context, roleID int64, userIDs []uint64) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing []uint64
+		if err := tx.Model(&models.UserRole{}).
+			Where("role_id = ? AND company_id IS NULL AND user_id IN ?", roleID, userIDs).
+			Pluck("user_id", &existing).Error; err != nil {
+			return err
+		}
+
+		held := make(map[uint64]bool, len(existing))
+		for _, id := range existing {
+			held[id] = true
+		}
+
+		now := time.Now().UTC()
+		for _, id := range userIDs {
+			if held[id] {
+				continue
+			}
+			held[id] = true
+
+			if err := tx.Create(&models.UserRole{
+				UserID:     int64(id),
+				RoleID:     roleID,
+				AssignedAt: &now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// RemoveRoleMembers takes the role the users hold in every company away from
+// them. Assignments scoped to a company are kept.
+func (s *mysqlStorage) RemoveRoleMembers(ctx context.Context, roleID int64, userIDs []uint64) error {
+	if err := s.db.WithContext(ctx).
+		Where("role_id = ? AND company_id IS NULL AND user_id IN ?", roleID, userIDs).
+		Delete(&models.UserRole{}).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func scimQuery(qr *gorm.DB, filter scim.Filter, attributes map[string]scimAttribute) (*gorm.DB, error) {
+	if filter == nil {
+		return qr, nil
+	}
+
+	where, args, err := scimWhere(filter, attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	return qr.Where(where, args...), nil
+}
+
+// scimWhere translates a filter into a SQL condition. Only the columns of
+// attributes can appear in it, and values are always bound.
+func scimWhere(filter scim.Filter, attributes map[string]scimAttribute) (string, []interface{}, error) {
+	switch f := filter.(type) {
+	case scim.Logical:
+		left, leftArgs, err := scimWhere(f.Left, attributes)
+		if err != nil {
+			return "", nil, err
+		}
+		right, rightArgs, err := scimWhere(f.Right, attributes)
+		if err != nil {
+			return "", nil, err
+		}
+		return fmt.Sprintf("(%s %s %s)", left, strings.ToUpper(f.Operator), right), append(leftArgs, rightArgs...), nil
+	case scim.Not:
+		inner, args, err := scimWhere(f.Filter, attributes)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT (" + inner + ")", args, nil
+	case scim.Comparison:
+		attr, ok := attributes[f.Attribute]
+		if !ok {
+			return "", nil, scim.BadRequest(scim.ErrInvalidFilter, "filtering on %s is not supported", f.Attribute)
+		}
+		if attr.flag {
+			return scimFlagWhere(attr.column, f)
+		}
+		return scimComparisonWhere(attr.column, f)
+	}
+
+	return "", nil, scim.BadRequest(scim.ErrInvalidFilter, "invalid filter")
+}
+
+func scimFlagWhere(column string, f scim.Comparison) (string, []interface{}, error) {
+	if f.Operator == "pr" {
+		return "1 = 1", nil, nil
+	}
+
+	value, ok := f.Value.(bool)
+	if !ok || (f.Operator != "eq" && f.Operator != "ne") {
+		return "", nil, scim.BadRequest(scim.ErrInvalidFilter, "%s only supports eq and ne with true or false", f.Attribute)
+	}
+
+	if value == (f.Operator == "eq") {
+		return column + " IS NULL", nil, nil
+	}
+
+	return column + " IS NOT NULL", nil, nil
+}
+
+func scimComparisonWhere(column string, f scim.Comparison) (string, []interface{}, error) {
+	if f.Operator == "pr" {
+		return fmt.Sprintf("(%s IS NOT NULL AND %s <> '')", column, column), nil, nil
+	}
+
+	if f.Value == nil {
+		switch f.Operator {
+		case "eq":
+			return column + " IS NULL", nil, nil
+		case "ne":
+			return column + " IS NOT NULL", nil, nil
+		}
+		return "", nil, scim.BadRequest(scim.ErrInvalidFilter, "%s cannot compare with null", f.Operator)
+	}
+
+	value := f.Value
+	if s, ok := value.(string); ok {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			value = t
+		}
+	}
+
+	switch f.Operator {
+	case "eq":
+		return column + " = ?", []interface{}{value}, nil
+	case "ne":
+		return fmt.Sprintf("(%s <> ? OR %s IS NULL)", column, column), []interface{}{value}, nil
+	case "gt":
+		return column + " > ?", []interface{}{value}, nil
+	case "ge":
+		return column + " >= ?", []interface{}{value}, nil
+	case "lt":
+		return column + " < ?", []interface{}{value}, nil
+	case "le":
+		return column + " <= ?", []interface{}{value}, nil
+	}
+
+	s, ok := f.Value.(string)
+	if !ok {
+		return "", nil, scim.BadRequest(scim.ErrInvalidFilter, "%s needs a string", f.Operator)
+	}
+	s = likeEscaper.Replace(s)
+
+	switch f.Operator {
+	case "co":
+		s = "%" + s + "%"
+	case "sw":
+		s = s + "%"
+	case "ew":
+		s = "%" + s
+	}
+
+	return column + " LIKE ?", []interface{}{s}, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/internal/models"
+)
+
+func (s *mysqlStorage) CreateServiceAccount(ctx context.Context, data *models.ServiceAccount) error {
+	if err := s.db.WithContext(ctx).Create(data).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *mysqlStorage) GetServiceAccount(ctx context.Context, data map[string]interface{}) (*models.ServiceAccount, error) {
+	var account *models.ServiceAccount
+	if err := s.db.WithContext(ctx).
+		Preload("APIKeys", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("APIKeys.Permissions").
+		Where(data).
+		First(&account).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.ErrServiceAccountNotFound
+		}
+
+		return nil, err
+	}
+
+	return account, nil
+}
+
+func (s *mysqlStorage) GetAllServiceAccounts(ctx context.Context) ([]*models.ServiceAccount, error) {
+	var accounts []*models.ServiceAccount
+	if err := s.db.WithContext(ctx).Order("id").Find(&accounts).Error; err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
+func (s *mysqlStorage) UpdateServiceAccount(ctx context.Context, id uint64, data map[string]interface{}) error {
+	if err := s.db.WithContext(ctx).Model(&models.ServiceAccount{}).Where("id = ?", id).Updates(data).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *mysqlStorage) DeleteServiceAccount(ctx context.Context, id uint64) error {
+	if err := s.db.WithContext(ctx).Where("id = ?", id).Delete(&models.ServiceAccount{}).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CreateAPIKey stores the key together with its permissions.
+func (s *mysqlStorage) CreateAPIKey(ctx context.Context, data *models.APIKey) error {
+	if err := s.db.WithContext(ctx).Create(data).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *mysqlStorage) GetAPIKey(ctx context.Context, data map[string]interface{}) (*models.APIKey, error) {
+	var key *models.APIKey
+	if err := s.db.WithContext(ctx).Preload("Permissions").Where(data).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.ErrAPIKeyNotFound
+		}
+
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// RotateAPIKey revokes the old key and stores its replacement in one
+// transaction.
+func (s *mysqlStorage) RotateAPIKey(ctx context.Context, oldID uint64, data *models.APIKey) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.APIKey{}).
+			Where("id = ? AND revoked_at IS NULL", oldID).
+			Update("revoked_at", time.Now().UTC())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return models.ErrAPIKeyNotFound
+		}
+
+		return tx.Create(data).Error
+	})
+}
+
+func (s *mysqlStorage) RevokeAPIKey(ctx context.Context, id uint64) (bool, error) {
+	result := s.db.WithContext(ctx).
+		Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now().UTC())
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// TouchAPIKey records that the key was used. The timestamp is only written
+// when it is older than a minute so busy keys do not cause a write per
+// request.
+func (s *mysqlStorage) TouchAPIKey(ctx context.Context, id uint64, now time.Time) error {
+	if err := s.db.WithContext(ctx).
+		Model(&models.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, now.Add(-time.Minute)).
+		Update("last_used_at", now).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
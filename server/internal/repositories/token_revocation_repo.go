@@ -0,0 +1,46 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/vlahanam/company-management/internal/models"
+	"gorm.io/gorm/clause"
+)
+
+func (s *mysqlStorage) CreateTokenRevocation(ctx context.Context, data *models.TokenRevocation) error {
+	if err := s.db.WithContext(ctx).Create(data).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ConsumeTokenRevocation records the revocation unless one with the same jti
+// exists, and reports whether it was recorded.
+func (s *mysqlStorage) ConsumeTokenRevocation(ctx context.Context, data *models.TokenRevocation) (bool, error) {
+	result := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(data)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (s *mysqlStorage) GetActiveTokenRevocations(ctx context.Context, now time.Time) ([]*models.TokenRevocation, error) {
+	var revocations []*models.TokenRevocation
+
+	if err := s.db.WithContext(ctx).Where("expires_at > ?", now).Find(&revocations).Error; err != nil {
+		return nil, err
+	}
+
+	return revocations, nil
+}
+
+func (s *mysqlStorage) DeleteExpiredTokenRevocations(ctx context.Context, now time.Time) error {
+	if err := s.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&models.TokenRevocation{}).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
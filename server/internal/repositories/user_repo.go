@@ -74,10 +74,13 @@ func (s *mysqlStorage) UpdateUser(ctx context.Context, id uint64, data map[strin
 	return nil
 }
 
+// DeleteUser deletes the user, unless it is the last active Super Admin.
 func (s *mysqlStorage) DeleteUser(ctx context.Context, id uint64) error {
-	if err := s.db.WithContext(ctx).Where("id = ?", id).Delete(&models.User{}).Error; err != nil {
-		return err
-	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := keepSuperAdmin(tx, id); err != nil {
+			return err
+		}
 
-	return nil
+		return tx.Where("id = ?", id).Delete(&models.User{}).Error
+	})
 }
@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/internal/models"
+)
+
+func (s *mysqlStorage) CreateUserSession(ctx context.Context, data *models.UserSession) error {
+	if err := s.db.WithContext(ctx).Create(data).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetUserSessions lists the sessions of the user that are not signed out and
+// were used after activeSince, most recently used first.
+func (s *mysqlStorage) GetUserSessions(ctx context.Context, userID uint64, activeSince time.Time) ([]*models.UserSession, error) {
+	var sessions []*models.UserSession
+
+	if err := s.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL AND last_seen_at > ?", userID, activeSince).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (s *mysqlStorage) GetUserSession(ctx context.Context, id string, userID uint64) (*models.UserSession, error) {
+	var session *models.UserSession
+
+	if err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.ErrSessionNotFound
+		}
+
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// TouchUserSession records a use of the session. It reports false when the
+// session is unknown, e.g. a login from before sessions were recorded.
+func (s *mysqlStorage) TouchUserSession(ctx context.Context, id string, data map[string]interface{}) (bool, error) {
+	result := s.db.WithContext(ctx).Model(&models.UserSession{}).Where("id = ?", id).Updates(data)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (s *mysqlStorage) RevokeUserSession(ctx context.Context, id string) error {
+	if err := s.db.WithContext(ctx).
+		Model(&models.UserSession{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now().UTC()).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *mysqlStorage) RevokeUserSessions(ctx context.Context, userID uint64) error {
+	if err := s.db.WithContext(ctx).
+		Model(&models.UserSession{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now().UTC()).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
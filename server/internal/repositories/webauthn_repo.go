@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/vlahanam/company-management/internal/models"
+)
+
+func (s *mysqlStorage) CreateWebAuthnCredential(ctx context.Context, data *models.WebAuthnCredential) error {
+	if err := s.db.WithContext(ctx).Create(data).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *mysqlStorage) GetWebAuthnCredentials(ctx context.Context, userID uint64) ([]*models.WebAuthnCredential, error) {
+	var credentials []*models.WebAuthnCredential
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+
+	return credentials, nil
+}
+
+func (s *mysqlStorage) UpdateWebAuthnCredential(ctx context.Context, id uint64, data map[string]interface{}) error {
+	if err := s.db.WithContext(ctx).Model(&models.WebAuthnCredential{}).Where("id = ?", id).Updates(data).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteWebAuthnCredential removes one of the user's passkeys; it reports false
+// when the user has no passkey with that credential ID.
+func (s *mysqlStorage) DeleteWebAuthnCredential(ctx context.Context, userID uint64, credentialID string) (bool, error) {
+	result := s.db.WithContext(ctx).
+		Where("user_id = ? AND credential_id = ?", userID, credentialID).
+		Delete(&models.WebAuthnCredential{})
+
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+func (s *mysqlStorage) CreateWebAuthnSession(ctx context.Context, data *models.WebAuthnSession) error {
+	if err := s.db.WithContext(ctx).Create(data).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// TakeWebAuthnSession loads and deletes an unexpired ceremony in one step so a
+// challenge can only be answered once.
+func (s *mysqlStorage) TakeWebAuthnSession(ctx context.Context, id, purpose string) (*models.WebAuthnSession, error) {
+	var session *models.WebAuthnSession
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+
+		if err := tx.Where("id = ? AND purpose = ? AND expires_at > ?", id, purpose, now).First(&session).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("id = ?", id).Delete(&models.WebAuthnSession{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Where("expires_at <= ?", now).Delete(&models.WebAuthnSession{}).Error
+	})
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.ErrInvalidWebAuthnSession
+		}
+
+		return nil, err
+	}
+
+	return session, nil
+}
@@ -7,6 +7,9 @@ import (
 type LoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	Device   string `json:"device,omitempty"`
+	// Client IP, set by the handler
+	IP string `json:"-"`
 }
 
 type RegisterRequest struct {
@@ -19,10 +22,35 @@ type RefreshRequest struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// ChangeExpiredPasswordRequest sets a new password with the token returned by
+// a login whose password has expired.
+type ChangeExpiredPasswordRequest struct {
+	PasswordChangeToken string `json:"password_change_token"`
+	Password            string `json:"password"`
+}
+
 func (lr LoginRequest) Validation() error {
 	return validation.ValidateStruct(&lr,
 		validation.Field(&lr.Email, validation.Required, isValidEmail()),
 		validation.Field(&lr.Password, validation.Required),
+		validation.Field(&lr.Device, validation.RuneLength(0, 255)),
 	)
 }
 
@@ -30,7 +58,7 @@ func (rr RegisterRequest) Validation() error {
 	return validation.ValidateStruct(&rr,
 		validation.Field(&rr.FullName, validation.Required, validation.RuneLength(1, 100)),
 		validation.Field(&rr.Email, validation.Required, isValidEmail()),
-		validation.Field(&rr.Password, validation.Required),
+		validation.Field(&rr.Password, validation.Required, isStrongPassword(rr.Email, rr.FullName)),
 	)
 }
 
@@ -39,3 +67,35 @@ func (rr RefreshRequest) Validation() error {
 		validation.Field(&rr.RefreshToken, validation.Required),
 	)
 }
+
+func (r VerifyEmailRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Token, validation.Required),
+	)
+}
+
+func (r ResendVerificationRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Email, validation.Required, isValidEmail()),
+	)
+}
+
+func (r ForgotPasswordRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Email, validation.Required, isValidEmail()),
+	)
+}
+
+func (r ResetPasswordRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Token, validation.Required),
+		validation.Field(&r.Password, validation.Required),
+	)
+}
+
+func (r ChangeExpiredPasswordRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.PasswordChangeToken, validation.Required),
+		validation.Field(&r.Password, validation.Required),
+	)
+}
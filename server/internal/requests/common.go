@@ -4,6 +4,7 @@ import (
 	"net/mail"
 
 	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/vlahanam/company-management/utils"
 )
 
 func isValidEmail() validation.Rule {
@@ -19,6 +20,20 @@ func isValidEmail() validation.Rule {
 	})
 }
 
+// isStrongPassword applies the password policy. userInputs are values the
+// password must not contain, such as the email and name.
+func isStrongPassword(userInputs ...string) validation.Rule {
+	return validation.By(func(value interface{}) error {
+		password, _ := value.(string)
+
+		if err := utils.CheckPasswordPolicy(password, userInputs...); err != nil {
+			return validation.NewError("validation_weak_password", err.Error())
+		}
+
+		return nil
+	})
+}
+
 func FormatValidationError(err error) map[string]any {
 	if errs, ok := err.(validation.Errors); ok {
 		return map[string]interface{}{"detail": errs}
@@ -33,10 +33,10 @@ type UpdateContractRequest struct {
 
 type ListContractRequest struct {
 	common.Paging
-	UserID    *uint64 `json:"user_id,omitempty"`
-	CompanyID *uint64 `json:"company_id,omitempty"`
-	Status    *string `json:"status,omitempty"`
-	Type      *string `json:"type,omitempty"`
+	UserID    *uint64 `json:"user_id,omitempty" query:"user_id"`
+	CompanyID *uint64 `json:"company_id,omitempty" query:"company_id"`
+	Status    *string `json:"status,omitempty" query:"status"`
+	Type      *string `json:"type,omitempty" query:"type"`
 }
 
 func (r CreateContractRequest) Validation() error {
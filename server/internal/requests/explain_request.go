@@ -0,0 +1,24 @@
+package requests
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// ExplainPermissionRequest asks why a user holds a permission or not,
+// optionally on a resource.
+type ExplainPermissionRequest struct {
+	// Permission id or code, e.g. 31 or contract.read
+	Permission string `json:"permission" query:"permission"`
+	// user, contract or company; empty checks the permission in every company
+	ResourceType string `json:"resource_type" query:"resource_type"`
+	// Base58 id of the resource
+	ResourceID string `json:"resource_id" query:"resource_id"`
+}
+
+func (r ExplainPermissionRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Permission, validation.Required),
+		validation.Field(&r.ResourceType, validation.In("user", "contract", "company")),
+		validation.Field(&r.ResourceID, validation.When(r.ResourceType != "", validation.Required)),
+	)
+}
@@ -0,0 +1,21 @@
+package requests
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/vlahanam/company-management/common"
+)
+
+type ImpersonateRequest struct {
+	// Why the user is impersonated, e.g. a support ticket reference
+	Reason string `json:"reason"`
+}
+
+type ListImpersonationRequest struct {
+	common.Paging
+}
+
+func (r ImpersonateRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Reason, validation.Required, validation.RuneLength(3, 500)),
+	)
+}
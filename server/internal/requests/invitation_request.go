@@ -0,0 +1,45 @@
+package requests
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/vlahanam/company-management/common"
+)
+
+type CreateInvitationRequest struct {
+	Email      string  `json:"email"`
+	FullName   *string `json:"full_name,omitempty"`
+	CompanyID  uint64  `json:"company_id"`
+	PositionID *uint64 `json:"position_id,omitempty"`
+	// Roles the new user gets in the company
+	RoleIDs []int64 `json:"role_ids"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token"`
+	// Overrides the name suggested by the invitation
+	FullName *string `json:"full_name,omitempty"`
+	Password string  `json:"password"`
+}
+
+type ListInvitationRequest struct {
+	common.Paging
+	// Lists only the invitations to the company
+	CompanyID *uint64 `json:"company_id,omitempty" query:"company_id"`
+}
+
+func (r CreateInvitationRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Email, validation.Required, isValidEmail()),
+		validation.Field(&r.FullName, validation.When(r.FullName != nil, validation.RuneLength(1, 100))),
+		validation.Field(&r.CompanyID, validation.Required),
+		validation.Field(&r.RoleIDs, validation.Each(validation.Required)),
+	)
+}
+
+func (r AcceptInvitationRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Token, validation.Required),
+		validation.Field(&r.FullName, validation.When(r.FullName != nil, validation.RuneLength(1, 100))),
+		validation.Field(&r.Password, validation.Required),
+	)
+}
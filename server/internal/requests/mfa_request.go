@@ -0,0 +1,62 @@
+package requests
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type MFALoginRequest struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+type MFATokenRequest struct {
+	MFAToken string `json:"mfa_token"`
+}
+
+type MFAEnrollRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+type ConfirmTOTPRequest struct {
+	Code string `json:"code"`
+}
+
+// SecondFactorRequest carries either a TOTP code or a recovery code.
+type SecondFactorRequest struct {
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+func (r MFALoginRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.MFAToken, validation.Required),
+		validation.Field(&r.Code, validation.When(r.RecoveryCode == "", validation.Required, validation.Length(6, 6))),
+	)
+}
+
+func (r MFATokenRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.MFAToken, validation.Required),
+	)
+}
+
+func (r MFAEnrollRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.MFAToken, validation.Required),
+		validation.Field(&r.Code, validation.Required, validation.Length(6, 6)),
+	)
+}
+
+func (r ConfirmTOTPRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Code, validation.Required, validation.Length(6, 6)),
+	)
+}
+
+func (r SecondFactorRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Code, validation.When(r.RecoveryCode == "", validation.Required, validation.Length(6, 6))),
+	)
+}
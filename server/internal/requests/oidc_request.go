@@ -0,0 +1,20 @@
+package requests
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// OIDCCallbackRequest carries the code and state the provider appended to the
+// redirect URL.
+type OIDCCallbackRequest struct {
+	Code   string `json:"code"`
+	State  string `json:"state"`
+	Device string `json:"device,omitempty"`
+}
+
+func (r OIDCCallbackRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Code, validation.Required),
+		validation.Field(&r.State, validation.Required),
+	)
+}
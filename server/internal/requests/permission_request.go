@@ -1,16 +1,24 @@
 package requests
 
 import (
+	"regexp"
+
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/vlahanam/company-management/common"
 )
 
+// permissionCode is a dotted lowercase machine code such as contract.create.
+var permissionCode = regexp.MustCompile(`^[a-z][a-z0-9_]*(\.[a-z][a-z0-9_]*)+$`)
+
 type CreatePermissionRequest struct {
+	// Optional stable machine code
+	Code        string `json:"code"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
 }
 
 type UpdatePermissionRequest struct {
+	Code        *string `json:"code,omitempty"`
 	Name        *string `json:"name,omitempty"`
 	Description *string `json:"description,omitempty"`
 }
@@ -21,12 +29,14 @@ type ListPermissionRequest struct {
 
 func (r CreatePermissionRequest) Validation() error {
 	return validation.ValidateStruct(&r,
+		validation.Field(&r.Code, validation.RuneLength(0, 100), validation.Match(permissionCode)),
 		validation.Field(&r.Name, validation.Required, validation.RuneLength(1, 100)),
 	)
 }
 
 func (r UpdatePermissionRequest) Validation() error {
 	return validation.ValidateStruct(&r,
+		validation.Field(&r.Code, validation.When(r.Code != nil, validation.Required, validation.RuneLength(1, 100), validation.Match(permissionCode))),
 		validation.Field(&r.Name, validation.When(r.Name != nil, validation.RuneLength(1, 100))),
 	)
 }
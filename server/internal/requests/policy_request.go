@@ -0,0 +1,74 @@
+package requests
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+
+	"github.com/vlahanam/company-management/internal/policy"
+)
+
+type CreatePolicyRequest struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Effect      policy.Effect      `json:"effect"`
+	Resource    string             `json:"resource"`
+	Actions     []string           `json:"actions"`
+	Conditions  []policy.Condition `json:"conditions"`
+	// Defaults to true
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+type UpdatePolicyRequest struct {
+	Name        *string             `json:"name,omitempty"`
+	Description *string             `json:"description,omitempty"`
+	Effect      *policy.Effect      `json:"effect,omitempty"`
+	Resource    *string             `json:"resource,omitempty"`
+	Actions     *[]string           `json:"actions,omitempty"`
+	Conditions  *[]policy.Condition `json:"conditions,omitempty"`
+	Enabled     *bool               `json:"enabled,omitempty"`
+}
+
+// EvaluatePolicyRequest asks how the policies decide an action without
+// taking it.
+type EvaluatePolicyRequest struct {
+	// Base58 id of the user or API key acting, the caller when empty
+	SubjectID string `json:"subject_id"`
+	// user or contract
+	ResourceType string `json:"resource_type"`
+	// Base58 id of the user or contract acted on
+	ResourceID string `json:"resource_id"`
+	Action     string `json:"action"`
+	// Attributes replacing or adding to those looked up for the subject and
+	// the resource
+	Subject  policy.Attributes `json:"subject"`
+	Resource policy.Attributes `json:"resource"`
+	// Rules evaluated instead of the active ones, to try a policy before
+	// saving it
+	Rules []*policy.Rule `json:"rules"`
+}
+
+func (r CreatePolicyRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Name, validation.Required, validation.RuneLength(1, 100)),
+		validation.Field(&r.Description, validation.RuneLength(0, 255)),
+		validation.Field(&r.Effect, validation.Required, validation.In(policy.EffectAllow, policy.EffectDeny)),
+		validation.Field(&r.Resource, validation.Required, validation.RuneLength(1, 50)),
+		validation.Field(&r.Actions, validation.Required),
+	)
+}
+
+func (r UpdatePolicyRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Name, validation.When(r.Name != nil, validation.Required, validation.RuneLength(1, 100))),
+		validation.Field(&r.Description, validation.When(r.Description != nil, validation.RuneLength(0, 255))),
+		validation.Field(&r.Effect, validation.When(r.Effect != nil, validation.In(policy.EffectAllow, policy.EffectDeny))),
+		validation.Field(&r.Resource, validation.When(r.Resource != nil, validation.Required, validation.RuneLength(1, 50))),
+		validation.Field(&r.Actions, validation.When(r.Actions != nil, validation.Required)),
+	)
+}
+
+func (r EvaluatePolicyRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.ResourceType, validation.Required, validation.In(policy.ResourceUser, policy.ResourceContract)),
+		validation.Field(&r.Action, validation.Required),
+	)
+}
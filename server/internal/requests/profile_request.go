@@ -0,0 +1,38 @@
+package requests
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// UpdateProfileRequest holds the fields users may change on their own
+// account. Email and ID card number are managed by administrators.
+type UpdateProfileRequest struct {
+	FullName    *string    `json:"full_name,omitempty"`
+	DateOfBirth *time.Time `json:"date_of_birth,omitempty"`
+	Gender      *string    `json:"gender,omitempty"`
+	PhoneNumber *string    `json:"phone_number,omitempty"`
+	Avatar      *string    `json:"avatar,omitempty"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+	// Client IP, set by the handler
+	IP string `json:"-"`
+}
+
+func (r UpdateProfileRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.FullName, validation.When(r.FullName != nil, validation.RuneLength(1, 100))),
+		validation.Field(&r.Gender, validation.When(r.Gender != nil, validation.In("Male", "Female", "Other"))),
+	)
+}
+
+func (r ChangePasswordRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.CurrentPassword, validation.Required),
+		validation.Field(&r.NewPassword, validation.Required),
+	)
+}
@@ -8,25 +8,49 @@ import (
 type CreateRoleRequest struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	RequireMFA  bool   `json:"require_mfa"`
+	// Days before members must change their password, 0 for never
+	PasswordMaxAgeDays int `json:"password_max_age_days"`
+	// Roles whose permissions the role inherits
+	ParentIDs []int64 `json:"parent_ids"`
 }
 
 type UpdateRoleRequest struct {
 	Name        *string `json:"name,omitempty"`
 	Description *string `json:"description,omitempty"`
+	RequireMFA  *bool   `json:"require_mfa,omitempty"`
+	// 0 removes the expiry
+	PasswordMaxAgeDays *int `json:"password_max_age_days,omitempty"`
+	// Replaces the roles the role inherits from, empty for none
+	ParentIDs *[]int64 `json:"parent_ids,omitempty"`
 }
 
 type ListRoleRequest struct {
 	common.Paging
 }
 
+// RolePermissionsRequest names the permissions to grant to or revoke from a
+// role.
+type RolePermissionsRequest struct {
+	PermissionIDs []int64 `json:"permission_ids"`
+}
+
 func (r CreateRoleRequest) Validation() error {
 	return validation.ValidateStruct(&r,
 		validation.Field(&r.Name, validation.Required, validation.RuneLength(1, 100)),
+		validation.Field(&r.PasswordMaxAgeDays, validation.Min(0), validation.Max(3650)),
 	)
 }
 
 func (r UpdateRoleRequest) Validation() error {
 	return validation.ValidateStruct(&r,
 		validation.Field(&r.Name, validation.When(r.Name != nil, validation.RuneLength(1, 100))),
+		validation.Field(&r.PasswordMaxAgeDays, validation.When(r.PasswordMaxAgeDays != nil, validation.Min(0), validation.Max(3650))),
+	)
+}
+
+func (r RolePermissionsRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.PermissionIDs, validation.Required),
 	)
 }
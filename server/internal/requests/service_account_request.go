@@ -0,0 +1,46 @@
+package requests
+
+import (
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type CreateServiceAccountRequest struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description,omitempty"`
+}
+
+type UpdateServiceAccountRequest struct {
+	Name        *string `json:"name,omitempty"`
+	Description *string `json:"description,omitempty"`
+	Disabled    *bool   `json:"disabled,omitempty"`
+}
+
+// CreateAPIKeyRequest issues a key limited to Permissions, which the caller
+// must hold themselves.
+type CreateAPIKeyRequest struct {
+	Name        string     `json:"name"`
+	Permissions []int64    `json:"permissions"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+func (r CreateServiceAccountRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Name, validation.Required, validation.RuneLength(1, 100)),
+	)
+}
+
+func (r UpdateServiceAccountRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Name, validation.When(r.Name != nil, validation.RuneLength(1, 100))),
+	)
+}
+
+func (r CreateAPIKeyRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Name, validation.Required, validation.RuneLength(1, 100)),
+		validation.Field(&r.Permissions, validation.Required),
+		validation.Field(&r.ExpiresAt, validation.When(r.ExpiresAt != nil, validation.Min(time.Now()))),
+	)
+}
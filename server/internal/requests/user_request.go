@@ -14,6 +14,13 @@ type ListUserRequest struct {
 	PositionID *int64  `json:"position_id,omitempty"`
 }
 
+// CreateUserRequest is used by administrators to open an account on behalf
+// of someone else.
+type CreateUserRequest struct {
+	RegisterRequest
+	SkipVerification bool `json:"skip_verification"`
+}
+
 type UpdateUserRequest struct {
 	FullName     *string    `json:"full_name,omitempty"`
 	Email        *string    `json:"email,omitempty"`
@@ -24,6 +31,40 @@ type UpdateUserRequest struct {
 	Avatar       *string    `json:"avatar,omitempty"`
 }
 
+// AssignUserRoleRequest gives a user a role in every company, or only in
+// CompanyID and, with IncludeSubsidiaries, in its subsidiaries.
+type AssignUserRoleRequest struct {
+	RoleID              int64   `json:"role_id"`
+	CompanyID           *uint64 `json:"company_id,omitempty"`
+	IncludeSubsidiaries bool    `json:"include_subsidiaries"`
+}
+
+func (r CreateUserRequest) Validation() error {
+	return r.RegisterRequest.Validation()
+}
+
+// Fields lists the fields the update changes.
+func (r UpdateUserRequest) Fields() []string {
+	var fields []string
+
+	set := map[string]bool{
+		"full_name":      r.FullName != nil,
+		"email":          r.Email != nil,
+		"date_of_birth":  r.DateOfBirth != nil,
+		"gender":         r.Gender != nil,
+		"id_card_number": r.IdCardNumber != nil,
+		"phone_number":   r.PhoneNumber != nil,
+		"avatar":         r.Avatar != nil,
+	}
+	for field, ok := range set {
+		if ok {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
 func (r UpdateUserRequest) Validation() error {
 	return validation.ValidateStruct(&r,
 		validation.Field(&r.Email, validation.When(r.Email != nil, isValidEmail())),
@@ -33,4 +74,9 @@ func (r UpdateUserRequest) Validation() error {
 	)
 }
 
-
+func (r AssignUserRoleRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.RoleID, validation.Required),
+		validation.Field(&r.IncludeSubsidiaries, validation.When(r.CompanyID == nil, validation.Empty.Error("requires company_id"))),
+	)
+}
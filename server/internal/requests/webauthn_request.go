@@ -0,0 +1,53 @@
+package requests
+
+import (
+	"encoding/json"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// WebAuthnRegisterRequest finishes a registration ceremony. Credential is the
+// PublicKeyCredential returned by navigator.credentials.create, serialized as
+// JSON.
+type WebAuthnRegisterRequest struct {
+	SessionID  string          `json:"session_id"`
+	Name       string          `json:"name,omitempty"`
+	Credential json.RawMessage `json:"credential"`
+}
+
+// WebAuthnLoginBeginRequest starts a login ceremony. Without an email the
+// ceremony is discoverable and the authenticator picks the account.
+type WebAuthnLoginBeginRequest struct {
+	Email string `json:"email,omitempty"`
+}
+
+// WebAuthnLoginRequest finishes a login ceremony. Credential is the
+// PublicKeyCredential returned by navigator.credentials.get, serialized as
+// JSON.
+type WebAuthnLoginRequest struct {
+	SessionID  string          `json:"session_id"`
+	Credential json.RawMessage `json:"credential"`
+	Device     string          `json:"device,omitempty"`
+}
+
+func (r WebAuthnRegisterRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.SessionID, validation.Required),
+		validation.Field(&r.Name, validation.RuneLength(0, 100)),
+		validation.Field(&r.Credential, validation.Required),
+	)
+}
+
+func (r WebAuthnLoginBeginRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.Email, validation.When(r.Email != "", isValidEmail())),
+	)
+}
+
+func (r WebAuthnLoginRequest) Validation() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.SessionID, validation.Required),
+		validation.Field(&r.Credential, validation.Required),
+		validation.Field(&r.Device, validation.RuneLength(0, 255)),
+	)
+}
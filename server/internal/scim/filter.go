@@ -0,0 +1,254 @@
+package scim
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Filter is a parsed filter expression (RFC 7644 section 3.4.2.2).
+type Filter interface {
+	isFilter()
+}
+
+// Comparison compares an attribute with a value. Attribute is lower case.
+// Value is a string, bool, float64 or nil; it is unused by the pr operator.
+type Comparison struct {
+	Attribute string
+	Operator  string
+	Value     interface{}
+}
+
+// Logical joins two filters with "and" or "or".
+type Logical struct {
+	Operator    string
+	Left, Right Filter
+}
+
+// Not negates a filter.
+type Not struct {
+	Filter Filter
+}
+
+func (Comparison) isFilter() {}
+func (Logical) isFilter()    {}
+func (Not) isFilter()        {}
+
+var comparisonOperators = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true,
+	"gt": true, "ge": true, "lt": true, "le": true,
+}
+
+// ParseFilter parses a filter such as
+//
+//	userName eq "bjensen" and (active eq true or not (title pr))
+//
+// Complex attribute filters such as emails[type eq "work"] are not
+// supported. An empty filter returns nil.
+func ParseFilter(s string) (Filter, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &filterParser{tokens: tokens}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.tokens) {
+		return nil, BadRequest(ErrInvalidFilter, "unexpected %q in filter", p.tokens[p.pos].text)
+	}
+
+	return f, nil
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, token{text: string(c)})
+			i++
+		case c == '"':
+			end := i + 1
+			for ; end < len(s) && s[end] != '"'; end++ {
+				if s[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(s) {
+				return nil, BadRequest(ErrInvalidFilter, "unterminated string in filter")
+			}
+
+			var value string
+			if err := json.Unmarshal([]byte(s[i:end+1]), &value); err != nil {
+				return nil, BadRequest(ErrInvalidFilter, "invalid string in filter")
+			}
+			tokens = append(tokens, token{text: value, quoted: true})
+			i = end + 1
+		default:
+			end := i
+			for end < len(s) && !unicode.IsSpace(rune(s[end])) && s[end] != '(' && s[end] != ')' && s[end] != '"' {
+				end++
+			}
+			if strings.ContainsAny(s[i:end], "[]") {
+				return nil, BadRequest(ErrInvalidFilter, "complex attribute filters are not supported")
+			}
+			tokens = append(tokens, token{text: s[i:end]})
+			i = end
+		}
+	}
+
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *filterParser) peekKeyword(keyword string) bool {
+	return p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && strings.EqualFold(p.tokens[p.pos].text, keyword)
+}
+
+func (p *filterParser) next() (token, error) {
+	if p.pos >= len(p.tokens) {
+		return token{}, BadRequest(ErrInvalidFilter, "unexpected end of filter")
+	}
+
+	t := p.tokens[p.pos]
+	p.pos++
+	return t, nil
+}
+
+func (p *filterParser) parseOr() (Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peekKeyword("or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = Logical{Operator: "or", Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (Filter, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peekKeyword("and") {
+		p.pos++
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = Logical{Operator: "and", Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseTerm() (Filter, error) {
+	if p.peekKeyword("not") {
+		p.pos++
+		f, err := p.parseGroup()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Filter: f}, nil
+	}
+
+	if p.peekKeyword("(") {
+		return p.parseGroup()
+	}
+
+	attr, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if attr.quoted {
+		return nil, BadRequest(ErrInvalidFilter, "expected an attribute, got %q", attr.text)
+	}
+
+	op, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	operator := strings.ToLower(op.text)
+
+	if operator == "pr" && !op.quoted {
+		return Comparison{Attribute: strings.ToLower(attr.text), Operator: operator}, nil
+	}
+	if op.quoted || !comparisonOperators[operator] {
+		return nil, BadRequest(ErrInvalidFilter, "unknown operator %q", op.text)
+	}
+
+	value, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	return Comparison{Attribute: strings.ToLower(attr.text), Operator: operator, Value: literal(value)}, nil
+}
+
+func (p *filterParser) parseGroup() (Filter, error) {
+	if t, err := p.next(); err != nil || t.text != "(" || t.quoted {
+		return nil, BadRequest(ErrInvalidFilter, "expected ( in filter")
+	}
+
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t, err := p.next(); err != nil || t.text != ")" || t.quoted {
+		return nil, BadRequest(ErrInvalidFilter, "expected ) in filter")
+	}
+
+	return f, nil
+}
+
+// literal converts an unquoted true, false, null or number to its value.
+func literal(t token) interface{} {
+	if t.quoted {
+		return t.text
+	}
+
+	switch strings.ToLower(t.text) {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+
+	if n, err := strconv.ParseFloat(t.text, 64); err == nil {
+		return n
+	}
+
+	return t.text
+}
@@ -0,0 +1,475 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	OpAdd     = "add"
+	OpReplace = "replace"
+	OpRemove  = "remove"
+)
+
+// PatchRequest is the body of a PATCH request (RFC 7644 section 3.5.2).
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Validate checks the schema and the operation names.
+func (r *PatchRequest) Validate() error {
+	if len(r.Schemas) != 1 || r.Schemas[0] != SchemaPatchOp {
+		return BadRequest(ErrInvalidSyntax, "the request must use the %s schema", SchemaPatchOp)
+	}
+	if len(r.Operations) == 0 {
+		return BadRequest(ErrInvalidSyntax, "the request has no operations")
+	}
+
+	for i := range r.Operations {
+		op := strings.ToLower(r.Operations[i].Op)
+		if op != OpAdd && op != OpReplace && op != OpRemove {
+			return BadRequest(ErrInvalidSyntax, "unknown operation %q", r.Operations[i].Op)
+		}
+		r.Operations[i].Op = op
+	}
+
+	return nil
+}
+
+// Path is a parsed attribute path such as name.givenName or
+// members[value eq "2819c223"].display.
+type Path struct {
+	Attribute    string
+	Filter       Filter
+	SubAttribute string
+}
+
+// readOnlyAttributes cannot be changed by a client.
+var readOnlyAttributes = []string{"id", "meta", "schemas", "groups"}
+
+// ParsePath parses the path of a PATCH operation. The core schema URN may
+// prefix the attribute; other schema extensions are not supported.
+func ParsePath(s string) (*Path, error) {
+	for _, schema := range []string{SchemaUser, SchemaGroup} {
+		if len(s) > len(schema) && strings.EqualFold(s[:len(schema)+1], schema+":") {
+			s = s[len(schema)+1:]
+		}
+	}
+	if strings.HasPrefix(strings.ToLower(s), "urn:") {
+		return nil, BadRequest(ErrInvalidPath, "unsupported schema in path %q", s)
+	}
+
+	p := &Path{}
+	if i := strings.IndexByte(s, '['); i >= 0 {
+		j := strings.LastIndexByte(s, ']')
+		if j < i {
+			return nil, BadRequest(ErrInvalidPath, "invalid path %q", s)
+		}
+
+		f, err := ParseFilter(s[i+1 : j])
+		if err != nil || f == nil {
+			return nil, BadRequest(ErrInvalidPath, "invalid filter in path %q", s)
+		}
+
+		rest := s[j+1:]
+		if rest != "" && !strings.HasPrefix(rest, ".") {
+			return nil, BadRequest(ErrInvalidPath, "invalid path %q", s)
+		}
+
+		p.Attribute, p.Filter, p.SubAttribute = s[:i], f, strings.TrimPrefix(rest, ".")
+	} else {
+		p.Attribute, p.SubAttribute, _ = strings.Cut(s, ".")
+	}
+
+	if p.Attribute == "" || strings.ContainsAny(p.Attribute+p.SubAttribute, "[]. \"") {
+		return nil, BadRequest(ErrInvalidPath, "invalid path %q", s)
+	}
+	for _, attr := range readOnlyAttributes {
+		if strings.EqualFold(p.Attribute, attr) {
+			return nil, NewError(http.StatusBadRequest, ErrMutability, fmt.Sprintf("%s is read-only", p.Attribute))
+		}
+	}
+
+	return p, nil
+}
+
+// Apply runs the operations, in order, on a copy of resource and stores the
+// result in resource when they all succeed.
+func Apply[T any](resource *T, operations []PatchOperation) error {
+	raw, err := json.Marshal(resource)
+	if err != nil {
+		return err
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return err
+	}
+
+	for _, op := range operations {
+		if err := applyOperation(doc, op); err != nil {
+			return err
+		}
+	}
+
+	if raw, err = json.Marshal(doc); err != nil {
+		return err
+	}
+
+	var out T
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return BadRequest(ErrInvalidValue, "invalid value: %s", err)
+	}
+	*resource = out
+
+	return nil
+}
+
+func applyOperation(doc map[string]interface{}, op PatchOperation) error {
+	var value interface{}
+	if len(op.Value) > 0 {
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return BadRequest(ErrInvalidValue, "invalid value: %s", err)
+		}
+	}
+
+	if op.Path == "" {
+		if op.Op == OpRemove {
+			return BadRequest(ErrNoTarget, "remove needs a path")
+		}
+
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			return BadRequest(ErrInvalidValue, "the value must be an object when no path is given")
+		}
+
+		for key, v := range values {
+			path, err := ParsePath(key)
+			if err != nil {
+				// Attributes of schema extensions are not stored
+				if strings.HasPrefix(strings.ToLower(key), "urn:") {
+					continue
+				}
+				return err
+			}
+			if err := applyPath(doc, op.Op, path, v); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	path, err := ParsePath(op.Path)
+	if err != nil {
+		return err
+	}
+	if op.Op != OpRemove && value == nil {
+		return BadRequest(ErrInvalidValue, "%s needs a value", op.Op)
+	}
+
+	return applyPath(doc, op.Op, path, value)
+}
+
+func applyPath(doc map[string]interface{}, op string, path *Path, value interface{}) error {
+	key := lookupKey(doc, path.Attribute)
+
+	if path.Filter != nil {
+		return applyFiltered(doc, key, op, path, value)
+	}
+
+	if path.SubAttribute != "" {
+		parent, _ := doc[key].(map[string]interface{})
+		if parent == nil {
+			if op == OpRemove {
+				return nil
+			}
+			parent = map[string]interface{}{}
+			doc[key] = parent
+		}
+
+		return applyPath(parent, op, &Path{Attribute: path.SubAttribute}, value)
+	}
+
+	existing, present := doc[key]
+
+	switch op {
+	case OpRemove:
+		// A value on remove lists the entries of a multi-valued attribute to
+		// drop, as some clients send for group members
+		items, isList := existing.([]interface{})
+		drop, hasDrop := value.([]interface{})
+		if !isList || !hasDrop {
+			delete(doc, key)
+			return nil
+		}
+
+		kept := items[:0]
+		for _, item := range items {
+			if !containsValue(drop, item) {
+				kept = append(kept, item)
+			}
+		}
+		doc[key] = kept
+	case OpAdd:
+		items, isList := existing.([]interface{})
+		added, addList := value.([]interface{})
+		switch {
+		case isList && addList:
+			for _, item := range added {
+				if !containsValue(items, item) {
+					items = append(items, item)
+				}
+			}
+			doc[key] = items
+		case isList:
+			if !containsValue(items, value) {
+				doc[key] = append(items, value)
+			}
+		default:
+			doc[key] = mergeValue(existing, present, value)
+		}
+	case OpReplace:
+		doc[key] = mergeValue(existing, present, value)
+	}
+
+	return nil
+}
+
+// applyFiltered runs an operation on the entries of a multi-valued attribute
+// that match the filter of the path.
+func applyFiltered(doc map[string]interface{}, key, op string, path *Path, value interface{}) error {
+	items, _ := doc[key].([]interface{})
+
+	matched := false
+	kept := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		entry, ok := item.(map[string]interface{})
+		if !ok || !Match(path.Filter, entry) {
+			kept = append(kept, item)
+			continue
+		}
+		matched = true
+
+		switch {
+		case op == OpRemove && path.SubAttribute == "":
+			continue
+		case op == OpRemove:
+			delete(entry, lookupKey(entry, path.SubAttribute))
+		case path.SubAttribute != "":
+			entry[lookupKey(entry, path.SubAttribute)] = value
+		default:
+			fields, ok := value.(map[string]interface{})
+			if !ok {
+				return BadRequest(ErrInvalidValue, "the value of %s must be an object", path.Attribute)
+			}
+			for k, v := range fields {
+				entry[lookupKey(entry, k)] = v
+			}
+		}
+		kept = append(kept, entry)
+	}
+
+	if !matched {
+		if op == OpRemove {
+			return nil
+		}
+
+		// emails[type eq "work"].value on a user without a work email adds it
+		entry, ok := entryFromFilter(path.Filter)
+		if !ok || path.SubAttribute == "" {
+			return BadRequest(ErrNoTarget, "no %s matches the path", path.Attribute)
+		}
+		entry[path.SubAttribute] = value
+		kept = append(kept, entry)
+	}
+
+	doc[key] = kept
+	return nil
+}
+
+// entryFromFilter builds the entry described by a filter made of eq
+// comparisons joined with and.
+func entryFromFilter(f Filter) (map[string]interface{}, bool) {
+	switch f := f.(type) {
+	case Comparison:
+		if f.Operator != "eq" {
+			return nil, false
+		}
+		return map[string]interface{}{f.Attribute: f.Value}, true
+	case Logical:
+		if f.Operator != "and" {
+			return nil, false
+		}
+		left, ok := entryFromFilter(f.Left)
+		if !ok {
+			return nil, false
+		}
+		right, ok := entryFromFilter(f.Right)
+		if !ok {
+			return nil, false
+		}
+		for k, v := range right {
+			left[k] = v
+		}
+		return left, true
+	}
+
+	return nil, false
+}
+
+// mergeValue merges objects into the existing object and accepts "True" and
+// "False" for boolean attributes, as some clients send.
+func mergeValue(existing interface{}, present bool, value interface{}) interface{} {
+	if obj, ok := existing.(map[string]interface{}); ok {
+		if fields, ok := value.(map[string]interface{}); ok {
+			for k, v := range fields {
+				obj[lookupKey(obj, k)] = v
+			}
+			return obj
+		}
+	}
+
+	if _, isBool := existing.(bool); (isBool || !present) && value != nil {
+		if s, ok := value.(string); ok {
+			switch strings.ToLower(s) {
+			case "true":
+				return true
+			case "false":
+				return false
+			}
+		}
+	}
+
+	return value
+}
+
+// containsValue reports whether items holds an entry with the same value as
+// item, comparing the value field of objects.
+func containsValue(items []interface{}, item interface{}) bool {
+	want := entryValue(item)
+	for _, i := range items {
+		if entryValue(i) == want {
+			return true
+		}
+	}
+
+	return false
+}
+
+func entryValue(item interface{}) string {
+	if entry, ok := item.(map[string]interface{}); ok {
+		item = entry[lookupKey(entry, "value")]
+	}
+
+	return fmt.Sprint(item)
+}
+
+// lookupKey returns the key of m that matches name ignoring case, or name.
+func lookupKey(m map[string]interface{}, name string) string {
+	if _, ok := m[name]; ok {
+		return name
+	}
+	for k := range m {
+		if strings.EqualFold(k, name) {
+			return k
+		}
+	}
+
+	return name
+}
+
+// Match evaluates a filter against an entry of a multi-valued attribute.
+// Strings compare ignoring case.
+func Match(f Filter, entry map[string]interface{}) bool {
+	switch f := f.(type) {
+	case Logical:
+		if f.Operator == "and" {
+			return Match(f.Left, entry) && Match(f.Right, entry)
+		}
+		return Match(f.Left, entry) || Match(f.Right, entry)
+	case Not:
+		return !Match(f.Filter, entry)
+	case Comparison:
+		v, ok := entry[lookupKey(entry, f.Attribute)]
+		if f.Operator == "pr" {
+			return ok && v != nil && v != ""
+		}
+		return compare(v, f.Operator, f.Value)
+	}
+
+	return false
+}
+
+func compare(actual interface{}, operator string, want interface{}) bool {
+	if b, ok := want.(bool); ok {
+		got, isBool := actual.(bool)
+		switch operator {
+		case "eq":
+			return isBool && got == b
+		case "ne":
+			return !isBool || got != b
+		}
+		return false
+	}
+
+	if n, ok := want.(float64); ok {
+		got, isNum := actual.(float64)
+		if !isNum {
+			return operator == "ne"
+		}
+		switch operator {
+		case "eq":
+			return got == n
+		case "ne":
+			return got != n
+		case "gt":
+			return got > n
+		case "ge":
+			return got >= n
+		case "lt":
+			return got < n
+		case "le":
+			return got <= n
+		}
+		return false
+	}
+
+	got, isString := actual.(string)
+	if !isString {
+		return operator == "ne" && want != nil
+	}
+	a, b := strings.ToLower(got), strings.ToLower(fmt.Sprint(want))
+
+	switch operator {
+	case "eq":
+		return a == b
+	case "ne":
+		return a != b
+	case "co":
+		return strings.Contains(a, b)
+	case "sw":
+		return strings.HasPrefix(a, b)
+	case "ew":
+		return strings.HasSuffix(a, b)
+	case "gt":
+		return a > b
+	case "ge":
+		return a >= b
+	case "lt":
+		return a < b
+	case "le":
+		return a <= b
+	}
+
+	return false
+}
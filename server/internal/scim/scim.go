@@ -0,0 +1,254 @@
+// Package scim holds the SCIM 2.0 (RFC 7643, RFC 7644) resource shapes, the
+// filter parser and the PATCH operations used by the provisioning API. It
+// knows nothing about storage or HTTP.
+package scim
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+
+	ContentType = "application/scim+json"
+
+	// MaxResults bounds the count of a list request.
+	MaxResults = 200
+)
+
+// scimType values of error responses.
+const (
+	ErrInvalidFilter = "invalidFilter"
+	ErrInvalidSyntax = "invalidSyntax"
+	ErrInvalidPath   = "invalidPath"
+	ErrInvalidValue  = "invalidValue"
+	ErrNoTarget      = "noTarget"
+	ErrUniqueness    = "uniqueness"
+	ErrMutability    = "mutability"
+)
+
+// Error is a SCIM error response.
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+func NewError(status int, scimType, detail string) *Error {
+	return &Error{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	}
+}
+
+func (e *Error) Error() string {
+	return e.Detail
+}
+
+// StatusCode is the HTTP status of the error.
+func (e *Error) StatusCode() int {
+	status, err := strconv.Atoi(e.Status)
+	if err != nil {
+		return http.StatusInternalServerError
+	}
+
+	return status
+}
+
+func BadRequest(scimType, format string, args ...interface{}) *Error {
+	return NewError(http.StatusBadRequest, scimType, fmt.Sprintf(format, args...))
+}
+
+func NotFound(resource, id string) *Error {
+	return NewError(http.StatusNotFound, "", fmt.Sprintf("%s %s not found", resource, id))
+}
+
+type Meta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      *time.Time `json:"created,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+	Location     string     `json:"location,omitempty"`
+}
+
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// Full returns the formatted name, or builds one from its parts.
+func (n *Name) Full() string {
+	if n == nil {
+		return ""
+	}
+	if n.Formatted != "" {
+		return n.Formatted
+	}
+	if n.GivenName != "" && n.FamilyName != "" {
+		return n.GivenName + " " + n.FamilyName
+	}
+
+	return n.GivenName + n.FamilyName
+}
+
+// MultiValued is an entry of a multi-valued attribute such as emails or
+// group members.
+type MultiValued struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+type User struct {
+	Schemas      []string      `json:"schemas"`
+	ID           string        `json:"id,omitempty"`
+	ExternalID   string        `json:"externalId,omitempty"`
+	UserName     string        `json:"userName"`
+	Name         *Name         `json:"name,omitempty"`
+	DisplayName  string        `json:"displayName,omitempty"`
+	Emails       []MultiValued `json:"emails,omitempty"`
+	PhoneNumbers []MultiValued `json:"phoneNumbers,omitempty"`
+	Active       *bool         `json:"active,omitempty"`
+	Password     string        `json:"password,omitempty"`
+	Groups       []MultiValued `json:"groups,omitempty"`
+	Meta         *Meta         `json:"meta,omitempty"`
+}
+
+// Email returns the user name when it is an email address, otherwise the
+// primary email, or the first one.
+func (u *User) Email() string {
+	if _, err := mail.ParseAddress(u.UserName); err == nil {
+		return u.UserName
+	}
+
+	return u.PrimaryEmail()
+}
+
+// PrimaryEmail returns the primary email, or the first one.
+func (u *User) PrimaryEmail() string {
+	for _, e := range u.Emails {
+		if e.Primary {
+			return e.Value
+		}
+	}
+	if len(u.Emails) > 0 {
+		return u.Emails[0].Value
+	}
+
+	return ""
+}
+
+// FullName returns the display name, or the name.
+func (u *User) FullName() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+
+	return u.Name.Full()
+}
+
+// Locate sets the URL of the resource under the SCIM base URL.
+func (u *User) Locate(base string) {
+	if u.Meta != nil {
+		u.Meta.Location = base + "/Users/" + u.ID
+	}
+	for i := range u.Groups {
+		u.Groups[i].Ref = base + "/Groups/" + u.Groups[i].Value
+	}
+}
+
+type Group struct {
+	Schemas     []string      `json:"schemas"`
+	ID          string        `json:"id,omitempty"`
+	DisplayName string        `json:"displayName"`
+	Members     []MultiValued `json:"members,omitempty"`
+	Meta        *Meta         `json:"meta,omitempty"`
+}
+
+// Locate sets the URL of the resource under the SCIM base URL.
+func (g *Group) Locate(base string) {
+	if g.Meta != nil {
+		g.Meta.Location = base + "/Groups/" + g.ID
+	}
+	for i := range g.Members {
+		g.Members[i].Ref = base + "/Users/" + g.Members[i].Value
+	}
+}
+
+type ListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int64       `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// ListRequest holds the query of a list request. StartIndex is 1-based.
+type ListRequest struct {
+	Filter             string `query:"filter"`
+	StartIndex         int    `query:"startIndex"`
+	Count              int    `query:"count"`
+	ExcludedAttributes string `query:"excludedAttributes"`
+}
+
+// Process applies the defaults and bounds of RFC 7644 section 3.4.2.4.
+func (r *ListRequest) Process() {
+	if r.StartIndex < 1 {
+		r.StartIndex = 1
+	}
+	if r.Count <= 0 || r.Count > MaxResults {
+		r.Count = MaxResults
+	}
+}
+
+// Excludes reports whether the client asked to leave the attribute out.
+func (r *ListRequest) Excludes(attribute string) bool {
+	for _, a := range strings.Split(r.ExcludedAttributes, ",") {
+		if strings.EqualFold(strings.TrimSpace(a), attribute) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Offset is the number of resources skipped.
+func (r *ListRequest) Offset() int {
+	return r.StartIndex - 1
+}
+
+// ServiceProviderConfig describes the supported features to clients.
+func ServiceProviderConfig() map[string]interface{} {
+	supported := func(ok bool) map[string]bool { return map[string]bool{"supported": ok} }
+
+	return map[string]interface{}{
+		"schemas":        []string{SchemaServiceProviderConfig},
+		"patch":          supported(true),
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": MaxResults},
+		"changePassword": supported(true),
+		"sort":           supported(false),
+		"etag":           supported(false),
+		"authenticationSchemes": []map[string]interface{}{{
+			"type":        "oauthbearertoken",
+			"name":        "API key",
+			"description": "A service account API key sent as a bearer token",
+			"primary":     true,
+		}},
+	}
+}
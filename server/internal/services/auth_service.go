@@ -2,11 +2,16 @@ package services
 
 import (
 	"context"
+	"sync"
 	"time"
 
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/mailer"
 	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/oidc"
 	"github.com/vlahanam/company-management/internal/requests"
 	"github.com/vlahanam/company-management/utils"
 )
@@ -16,31 +21,165 @@ const (
 	expRefreshToken = 7 * 24 * time.Hour
 )
 
+type RefreshTokenRepo interface {
+	CreateRefreshToken(ctx context.Context, data *models.RefreshToken) error
+	GetRefreshToken(ctx context.Context, data map[string]interface{}) (*models.RefreshToken, error)
+	MarkRefreshTokenUsed(ctx context.Context, jti string) (bool, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID uint64) error
+	GetUserRefreshTokenFamilies(ctx context.Context, userID uint64) ([]string, error)
+}
+
+type AuthRepo interface {
+	RefreshTokenRepo
+	PasswordResetTokenRepo
+	MFARepo
+	WebAuthnRepo
+	OIDCRepo
+	UserSessionRepo
+	ImpersonationRepo
+	InvitationRepo
+	UserRoleRepo
+}
+
+// refreshClaims is the payload extracted from a verified refresh token.
+type refreshClaims struct {
+	UserID   string
+	JTI      string
+	FamilyID string
+}
+
+// AuthOptions holds the settings and shared components of the auth flow. It
+// is built once at startup and handed to every auth handler.
+type AuthOptions struct {
+	AccessKeys    *utils.KeySet
+	RefreshKeys   *utils.KeySet
+	Revocations   TokenRevoker
+	LoginThrottle LoginThrottle
+
+	Mailer           mailer.Mailer
+	ClientURL        string
+	PasswordResetTTL time.Duration
+
+	VerifySecret         string
+	EmailVerificationTTL time.Duration
+	InvitationTTL        time.Duration
+
+	TOTPIssuer string
+
+	// Relying party used for passkey ceremonies, nil when passkeys are not
+	// configured
+	WebAuthn *webauthn.WebAuthn
+
+	// SSO identity providers by name
+	OIDCProviders map[string]*oidc.Provider
+}
+
 type authService struct {
-	es            *userService
-	accessSecret  string
-	refreshSecret string
+	es   *userService
+	rt   AuthRepo
+	opts *AuthOptions
 }
 
-func NewAuthService(es *userService, accessSecret, refreshSecret string) *authService {
+func NewAuthService(es *userService, rt AuthRepo, opts *AuthOptions) *authService {
 	return &authService{
-		es:            es,
-		accessSecret:  accessSecret,
-		refreshSecret: refreshSecret,
+		es:   es,
+		rt:   rt,
+		opts: opts,
 	}
 }
 
+// Login checks the password of the account. Unknown emails and wrong
+// passwords get the same response and take the same time, and both count
+// towards the login throttle, which a right password only clears once any
+// second factor has been checked too.
 func (as *authService) Login(ctx context.Context, data *requests.LoginRequest) (*models.Auth, error) {
+	if err := as.opts.LoginThrottle.Check(ctx, data.Email, data.IP); err != nil {
+		return nil, err
+	}
+
 	u, err := as.es.FindByEmail(ctx, data.Email)
+
+	hash := dummyPasswordHash()
+	if err == nil {
+		hash = u.HashPassword
+	}
+
+	if !utils.CheckPasswordHash(data.Password, hash) || err != nil {
+		if err := as.opts.LoginThrottle.RecordFailure(ctx, data.Email, data.IP); err != nil {
+			return nil, err
+		}
+		return nil, common.ErrorUnauthorized.Clone().WrapKey("INVALID_CREDENTIALS").WrapMessage(models.ErrInvalidCredentials.Error())
+	}
+
+	// Upgrade the stored hash while the plain password is at hand
+	if utils.PasswordNeedsRehash(u.HashPassword) {
+		_ = as.es.RehashPassword(ctx, u.ID, data.Password)
+	}
+
+	if u.EmailVerifiedAt == nil {
+		return nil, common.ErrorUnauthorized.Clone().WrapKey("EMAIL_NOT_VERIFIED").WrapMessage(models.ErrEmailNotVerified.Error())
+	}
+
+	if u.DeactivatedAt != nil {
+		return nil, errAccountDeactivated()
+	}
+
+	// The second factor comes first: an expired password alone must not be
+	// enough to set a new one
+	challenge, err := as.mfaChallenge(ctx, u, data.Device)
 	if err != nil {
-		return nil, common.ErrorValidation.Clone().SetDetail("email", models.ErrEmailNotFound.Error())
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+	if challenge != nil {
+		// The account counter is cleared once the second factor is in
+		return challenge, nil
+	}
+
+	if err := as.opts.LoginThrottle.RecordSuccess(ctx, data.Email); err != nil {
+		return nil, err
 	}
 
-	u.Mask(common.ObjectTypeUser)
+	return as.completeLogin(ctx, u, data.Device)
+}
+
+// dummyPasswordHash is compared against when the email is unknown, so the
+// response time does not reveal whether the account exists.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := utils.HashPassword("not-a-real-password")
+	return hash
+})
+
+// errAccountDeactivated refuses tokens to a user deprovisioned over SCIM.
+func errAccountDeactivated() error {
+	return common.ErrorUnauthorized.Clone().WrapKey("ACCOUNT_DEACTIVATED").WrapMessage(models.ErrAccountDeactivated.Error())
+}
 
-	checkPassword := utils.CheckPasswordHash(data.Password, u.HashPassword)
-	if !checkPassword {
-		return nil, common.ErrorValidation.Clone().SetDetail("password", models.ErrInvalidPassword.Error())
+// completeLogin ends the password login of a user who passed every factor:
+// with the token pair, or with a password change token when the password
+// expired.
+func (as *authService) completeLogin(ctx context.Context, u *models.User, device string) (*models.Auth, error) {
+	expired, err := as.es.PasswordExpired(ctx, u)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+	if !expired {
+		return as.issueLoginTokens(ctx, u, device)
+	}
+
+	token, err := as.signMFAToken(u.ID, purposePasswordChange, device)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return &models.Auth{PasswordChangeRequired: true, PasswordChangeToken: token}, nil
+}
+
+// issueLoginTokens starts a new token family for a user who completed every
+// login step.
+func (as *authService) issueLoginTokens(ctx context.Context, u *models.User, device string) (*models.Auth, error) {
+	if u.DeactivatedAt != nil {
+		return nil, errAccountDeactivated()
 	}
 
 	roles, err := as.es.GetRoleNamesByUserID(ctx, u.ID)
@@ -48,7 +187,12 @@ func (as *authService) Login(ctx context.Context, data *requests.LoginRequest) (
 		roles = []string{}
 	}
 
-	auth, err := as.GenerateTokens(u.FakeId.String(), roles)
+	familyID := uuid.NewString()
+	if err := as.startSession(ctx, u.ID, familyID, device); err != nil {
+		return nil, common.ErrorCreateFailed.Clone().WrapError(err)
+	}
+
+	auth, err := as.GenerateTokens(ctx, u.ID, familyID, device, roles)
 	if err != nil {
 		return nil, common.ErrorCreateFailed.Clone().WrapError(err)
 	}
@@ -56,30 +200,55 @@ func (as *authService) Login(ctx context.Context, data *requests.LoginRequest) (
 	return auth, nil
 }
 
-func (as *authService) GenerateTokens(id string, roles []string) (*models.Auth, error) {
+// GenerateTokens signs a new access/refresh token pair and persists the refresh
+// token as the latest member of the given family. The access token names the
+// family as its session (sid).
+func (as *authService) GenerateTokens(ctx context.Context, userID uint64, familyID, device string, roles []string) (*models.Auth, error) {
+	uid := common.NewUID(uint32(userID), common.ObjectTypeUser, 1)
+	now := time.Now()
+
 	// Access Token (15 minutes)
 	accessClaims := jwt.MapClaims{
-		"user_id": id,
+		"user_id": uid.String(),
 		"roles":   roles,
-		"exp":     time.Now().Add(expAssetToken).Unix(),
+		"jti":     uuid.NewString(),
+		"sid":     familyID,
+		"iat":     utils.IssuedAt(now),
+		"exp":     now.Add(expAssetToken).Unix(),
 	}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-	access, err := accessToken.SignedString([]byte(as.accessSecret))
+	access, err := as.opts.AccessKeys.Sign(accessClaims)
 	if err != nil {
 		return nil, err
 	}
 
 	// Refresh Token (7 days)
+	jti := uuid.NewString()
+	expiresAt := now.Add(expRefreshToken)
 	refreshClaims := jwt.MapClaims{
-		"user_id": id,
-		"exp":     time.Now().Add(expRefreshToken).Unix(),
+		"user_id": uid.String(),
+		"jti":     jti,
+		"fid":     familyID,
+		"exp":     expiresAt.Unix(),
 	}
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	refresh, err := refreshToken.SignedString([]byte(as.refreshSecret))
+	refresh, err := as.opts.RefreshKeys.Sign(refreshClaims)
 	if err != nil {
 		return nil, err
 	}
 
+	record := &models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		JTI:       jti,
+		ExpiresAt: expiresAt.UTC(),
+	}
+	if device != "" {
+		record.Device = &device
+	}
+
+	if err := as.rt.CreateRefreshToken(ctx, record); err != nil {
+		return nil, err
+	}
+
 	auth := &models.Auth{
 		AccessToken:  access,
 		RefreshToken: refresh,
@@ -88,60 +257,136 @@ func (as *authService) GenerateTokens(id string, roles []string) (*models.Auth,
 	return auth, nil
 }
 
-func (as *authService) VerifyRefreshToken(refreshToken string) (string, error) {
-	token, err := jwt.Parse(refreshToken, func(token *jwt.Token) (interface{}, error) {
-		// Verify signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, common.ErrorUnauthorized.Clone().WrapMessage("Invalid signing method")
-		}
-		return []byte(as.refreshSecret), nil
-	})
-
+func (as *authService) VerifyRefreshToken(refreshToken string) (*refreshClaims, error) {
+	token, err := as.opts.RefreshKeys.Parse(refreshToken)
 	if err != nil {
-		return "", common.ErrorUnauthorized.Clone().WrapError(err)
+		return nil, common.ErrorUnauthorized.Clone().WrapError(err)
 	}
 
 	// Extract claims
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok || !token.Valid {
-		return "", common.ErrorUnauthorized.Clone().WrapMessage("Invalid token")
+		return nil, common.ErrorUnauthorized.Clone().WrapMessage("Invalid token")
 	}
 
-	// Get email from claims
-	userID, ok := claims["user_id"].(string)
-	if !ok {
-		return "", common.ErrorUnauthorized.Clone().WrapMessage("Invalid token claims")
+	userID, okUser := claims["user_id"].(string)
+	jti, okJTI := claims["jti"].(string)
+	familyID, okFamily := claims["fid"].(string)
+	if !okUser || !okJTI || !okFamily {
+		return nil, common.ErrorUnauthorized.Clone().WrapMessage("Invalid token claims")
 	}
 
-	return userID, nil
+	return &refreshClaims{
+		UserID:   userID,
+		JTI:      jti,
+		FamilyID: familyID,
+	}, nil
 }
 
 func (as *authService) RefreshAccessToken(ctx context.Context, refreshToken string) (*models.Auth, error) {
 	// Verify refresh token
-	userID, err := as.VerifyRefreshToken(refreshToken)
+	claims, err := as.VerifyRefreshToken(refreshToken)
 	if err != nil {
 		return nil, err
 	}
 
-	uuid, err := common.FromBase58(userID)
-	localID := uint64(uuid.GetLocalID())
+	stored, err := as.rt.GetRefreshToken(ctx, map[string]interface{}{"jti": claims.JTI})
+	if err != nil {
+		return nil, common.ErrorUnauthorized.Clone().WrapMessage(models.ErrRefreshTokenNotFound.Error())
+	}
+
+	if stored.RevokedAt != nil {
+		return nil, common.ErrorUnauthorized.Clone().WrapMessage(models.ErrRefreshTokenRevoked.Error())
+	}
+
+	// A token that was already rotated is being replayed: someone else holds a
+	// copy of it, so the whole family is burned.
+	rotated, err := as.rt.MarkRefreshTokenUsed(ctx, stored.JTI)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+	if !rotated {
+		if err := as.revokeFamily(ctx, stored.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, common.ErrorUnauthorized.Clone().WrapMessage(models.ErrRefreshTokenReused.Error())
+	}
 
 	// Verify user still exists
-	_, err = as.es.FindByID(ctx, localID)
+	u, err := as.es.FindByID(ctx, stored.UserID)
 	if err != nil {
 		return nil, common.ErrorUnauthorized.Clone().WrapMessage("User not found")
 	}
+	if u.DeactivatedAt != nil {
+		return nil, errAccountDeactivated()
+	}
 
-	roles, err := as.es.GetRoleNamesByUserID(ctx, localID)
+	roles, err := as.es.GetRoleNamesByUserID(ctx, stored.UserID)
 	if err != nil {
 		roles = []string{}
 	}
 
+	device := ""
+	if stored.Device != nil {
+		device = *stored.Device
+	}
+
+	if err := as.touchSession(ctx, stored.UserID, stored.FamilyID, device); err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
 	// Generate new tokens
-	auth, err := as.GenerateTokens(userID, roles)
+	auth, err := as.GenerateTokens(ctx, stored.UserID, stored.FamilyID, device, roles)
 	if err != nil {
 		return nil, common.ErrorCreateFailed.Clone().WrapError(err)
 	}
 
 	return auth, nil
 }
+
+// Logout ends the session the given refresh token belongs to.
+func (as *authService) Logout(ctx context.Context, refreshToken string) error {
+	claims, err := as.VerifyRefreshToken(refreshToken)
+	if err != nil {
+		return err
+	}
+
+	return as.revokeFamily(ctx, claims.FamilyID)
+}
+
+// LogoutAll signs the user out of every device.
+func (as *authService) LogoutAll(ctx context.Context, userUID string) error {
+	userID, err := userIDFromUID(userUID)
+	if err != nil {
+		return err
+	}
+
+	return as.RevokeSessions(ctx, userID)
+}
+
+// RevokeSessions revokes every refresh token issued to the user together with
+// the access tokens that are still live.
+func (as *authService) RevokeSessions(ctx context.Context, userID uint64) error {
+	if err := as.rt.RevokeUserRefreshTokens(ctx, userID); err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	if err := as.rt.RevokeUserSessions(ctx, userID); err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return as.opts.Revocations.RevokeUserTokens(ctx, userID)
+}
+
+// userIDFromUID decodes the base58 user id carried in token claims.
+func userIDFromUID(userUID string) (uint64, error) {
+	uid, err := common.FromBase58(userUID)
+	if err != nil {
+		return 0, common.ErrorUnauthorized.Clone().WrapError(err)
+	}
+	if uid.GetObjectType() != common.ObjectTypeUser {
+		return 0, common.ErrorUnauthorized.Clone().WrapMessage(models.ErrUserAccountRequired.Error())
+	}
+
+	return uint64(uid.GetLocalID()), nil
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/mailer"
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/requests"
+)
+
+const purposeEmailVerification = "email_verification"
+
+// Register opens an unverified account and emails the verification link.
+func (as *authService) Register(ctx context.Context, data *requests.RegisterRequest) error {
+	u, err := as.es.CreateUser(ctx, data, false)
+	if err != nil {
+		return err
+	}
+
+	return as.SendVerificationEmail(ctx, u)
+}
+
+// CreateUser opens an account on behalf of an administrator, who may vouch for
+// the email address and skip verification.
+func (as *authService) CreateUser(ctx context.Context, data *requests.CreateUserRequest) (*models.User, error) {
+	u, err := as.es.CreateUser(ctx, &data.RegisterRequest, data.SkipVerification)
+	if err != nil {
+		return nil, err
+	}
+
+	if !data.SkipVerification {
+		if err := as.SendVerificationEmail(ctx, u); err != nil {
+			return nil, err
+		}
+	}
+
+	return u, nil
+}
+
+// SendVerificationEmail mails a signed link proving ownership of u.Email. The
+// token embeds the address, so it stops working if the email changes.
+func (as *authService) SendVerificationEmail(ctx context.Context, u *models.User) error {
+	uid := common.NewUID(uint32(u.ID), common.ObjectTypeUser, 1)
+
+	claims := jwt.MapClaims{
+		"user_id": uid.String(),
+		"email":   u.Email,
+		"purpose": purposeEmailVerification,
+		"exp":     time.Now().Add(as.opts.EmailVerificationTTL).Unix(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(as.opts.VerifySecret))
+	if err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", as.opts.ClientURL, token)
+	if err := as.opts.Mailer.Send(ctx, &mailer.Message{
+		To:      u.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+			u.FullName, link, as.opts.EmailVerificationTTL,
+		),
+	}); err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return nil
+}
+
+func (as *authService) VerifyEmail(ctx context.Context, data *requests.VerifyEmailRequest) error {
+	invalid := common.ErrorValidation.Clone().SetDetail("token", models.ErrInvalidVerifyToken.Error())
+
+	token, err := jwt.Parse(data.Token, func(token *jwt.Token) (interface{}, error) {
+		return []byte(as.opts.VerifySecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid {
+		return invalid
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != purposeEmailVerification {
+		return invalid
+	}
+
+	userID, _ := claims["user_id"].(string)
+	email, _ := claims["email"].(string)
+
+	uid, err := common.FromBase58(userID)
+	if err != nil {
+		return invalid
+	}
+
+	u, err := as.es.FindByID(ctx, uint64(uid.GetLocalID()))
+	if err != nil || u.Email != email {
+		return invalid
+	}
+
+	if u.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	return as.es.MarkEmailVerified(ctx, u.ID)
+}
+
+// ResendVerification sends a fresh link. Unknown or already verified
+// addresses are ignored so the response does not reveal account state.
+func (as *authService) ResendVerification(ctx context.Context, data *requests.ResendVerificationRequest) error {
+	u, err := as.es.FindByEmail(ctx, data.Email)
+	if err != nil || u.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	return as.SendVerificationEmail(ctx, u)
+}
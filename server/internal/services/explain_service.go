@@ -0,0 +1,330 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/access"
+	"github.com/vlahanam/company-management/internal/dto"
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/policy"
+	"github.com/vlahanam/company-management/internal/requests"
+)
+
+type ExplainRepo interface {
+	PolicyRepo
+	GetUser(ctx context.Context, data map[string]interface{}) (*models.User, error)
+	GetUserRoles(ctx context.Context, userID uint64) ([]*models.UserRole, error)
+	GetPermission(ctx context.Context, data map[string]interface{}) (*models.Permission, error)
+	GetCompany(ctx context.Context, data map[string]interface{}) (*models.Company, error)
+	GetCompanyAncestorIDs(ctx context.Context, id uint64) ([]uint64, error)
+	GetRoleParentMap(ctx context.Context) (map[int64][]int64, error)
+	GetRoleIDsGrantingPermission(ctx context.Context, permissionID int64) ([]int64, error)
+	GetRolesByIDs(ctx context.Context, ids []int64) ([]*models.Role, error)
+}
+
+// permissionActions are the policy resource and action each permission is
+// checked for, on the resources the policies cover.
+var permissionActions = map[int64][2]string{
+	models.PermissionReadUser:       {policy.ResourceUser, policy.ActionRead},
+	models.PermissionUpdateUser:     {policy.ResourceUser, policy.ActionUpdate},
+	models.PermissionDeleteUser:     {policy.ResourceUser, policy.ActionDelete},
+	models.PermissionReadContract:   {policy.ResourceContract, policy.ActionRead},
+	models.PermissionUpdateContract: {policy.ResourceContract, policy.ActionUpdate},
+	models.PermissionDeleteContract: {policy.ResourceContract, policy.ActionDelete},
+}
+
+type explainService struct {
+	repo     ExplainRepo
+	policies *policyService
+	sa       *serviceAccountService
+}
+
+func NewExplainService(repo ExplainRepo, policies *policyService, sa *serviceAccountService) *explainService {
+	return &explainService{repo: repo, policies: policies, sa: sa}
+}
+
+// ExplainPermission tells whether the user holds the permission, by the same
+// rules as the permission checks of the routes, and which of the user's role
+// assignments grant it or why they do not. Reading, updating and deleting a
+// user are decided by the access rules of the user routes.
+func (s *explainService) ExplainPermission(ctx context.Context, userID uint64, data *requests.ExplainPermissionRequest) (*dto.PermissionExplanation, error) {
+	if _, err := s.repo.GetUser(ctx, map[string]interface{}{"id": userID}); err != nil {
+		return nil, common.ErrorNotFound.Clone().WrapMessage(models.ErrUserNotFound.Error())
+	}
+
+	permission, err := s.findPermission(ctx, data.Permission)
+	if err != nil {
+		return nil, err
+	}
+
+	var resourceID uint64
+	if data.ResourceType != "" {
+		uid, err := common.FromBase58(data.ResourceID)
+		if err != nil {
+			return nil, common.ErrorValidation.Clone().SetDetail("resource_id", "invalid id format")
+		}
+		resourceID = uint64(uid.GetLocalID())
+	}
+
+	companyIDs, err := s.resourceCompanies(ctx, data.ResourceType, resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	assignments, err := s.explainAssignments(ctx, userID, permission.ID, companyIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	explanation := &dto.PermissionExplanation{
+		Permission:  permission,
+		CompanyIDs:  companyIDs,
+		Assignments: assignments,
+	}
+
+	for _, a := range assignments {
+		if a.Applies && a.Grants {
+			explanation.Allowed = true
+			explanation.Reason = grantReason(a)
+			break
+		}
+	}
+	if !explanation.Allowed {
+		explanation.Reason = "no role assignment that applies grants the permission"
+	}
+
+	if target, ok := permissionActions[permission.ID]; ok && target[0] == data.ResourceType {
+		userUID := common.NewUID(uint32(userID), common.ObjectTypeUser, 1)
+
+		if target[0] == policy.ResourceUser {
+			if err := s.explainUserAccess(ctx, explanation, userUID.String(), resourceID, access.Action(target[1])); err != nil {
+				return nil, err
+			}
+		}
+
+		result, err := s.policies.Authorize(ctx, userUID.String(), target[0], resourceID, target[1])
+		if err != nil {
+			return nil, err
+		}
+		explanation.Policy = result
+
+		// Policies only narrow what the roles grant
+		if result.Decision == policy.DecisionDeny {
+			explanation.Allowed = false
+			explanation.Reason = fmt.Sprintf("denied by the %s policy", result.Rule)
+		}
+	}
+
+	return explanation, nil
+}
+
+// explainUserAccess replaces the decision of the roles with the one of
+// access.CheckUserAccess, from the actor the user routes build.
+func (s *explainService) explainUserAccess(ctx context.Context, e *dto.PermissionExplanation, userUID string, targetID uint64, action access.Action) error {
+	actor, err := s.sa.GetUserAccessActor(ctx, userUID, targetID)
+	if err != nil {
+		return err
+	}
+
+	err = access.CheckUserAccess(*actor, targetID, action)
+	switch {
+	case errors.Is(err, access.ErrDeleteOwnAccount):
+		e.Allowed, e.Reason = false, "users cannot delete their own account"
+	case err != nil && e.Allowed:
+		e.Allowed, e.Reason = false, "the permission only reaches other users for user administrators, who hold the Create, Update or Delete User permission"
+	case err != nil:
+		// No role grants the permission, and the user is neither the target
+		// nor its manager
+	case actor.UserID == targetID && action == access.ActionUpdate:
+		e.Allowed, e.Reason = true, "the user's own account, limited to its self-editable fields"
+	case actor.UserID == targetID:
+		e.Allowed, e.Reason = true, "the user's own account"
+	case actor.ManagesTarget && action == access.ActionRead:
+		e.Allowed, e.Reason = true, "the user manages this user"
+	case !e.Allowed:
+		e.Allowed, e.Reason = true, "allowed by the user access rules"
+	}
+
+	return nil
+}
+
+// findPermission looks the permission up by id or by code.
+func (s *explainService) findPermission(ctx context.Context, key string) (*models.Permission, error) {
+	query := map[string]interface{}{"code": key}
+	if id, err := strconv.ParseInt(key, 10, 64); err == nil {
+		query = map[string]interface{}{"id": id}
+	}
+
+	permission, err := s.repo.GetPermission(ctx, query)
+	if err != nil {
+		return nil, common.ErrorValidation.Clone().SetDetail("permission", models.ErrPermissionNotFound.Error())
+	}
+
+	return permission, nil
+}
+
+// resourceCompanies returns the companies the permission checks look for
+// roles in: those of the resource, none without a resource.
+func (s *explainService) resourceCompanies(ctx context.Context, resourceType string, resourceID uint64) ([]uint64, error) {
+	switch resourceType {
+	case policy.ResourceUser:
+		if _, err := s.repo.GetUser(ctx, map[string]interface{}{"id": resourceID}); err != nil {
+			return nil, common.ErrorNotFound.Clone().WrapMessage(models.ErrUserNotFound.Error())
+		}
+		ids, err := s.repo.GetUserCompanyIDs(ctx, resourceID)
+		if err != nil {
+			return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+		}
+		return ids, nil
+	case policy.ResourceContract:
+		contract, err := s.repo.GetContract(ctx, map[string]interface{}{"id": resourceID})
+		if err != nil {
+			return nil, common.ErrorNotFound.Clone().WrapMessage(models.ErrContractNotFound.Error())
+		}
+		return []uint64{contract.CompanyID}, nil
+	case "company":
+		if _, err := s.repo.GetCompany(ctx, map[string]interface{}{"id": resourceID}); err != nil {
+			return nil, common.ErrorNotFound.Clone().WrapMessage(models.ErrCompanyNotFound.Error())
+		}
+		return []uint64{resourceID}, nil
+	}
+
+	return []uint64{}, nil
+}
+
+// explainAssignments goes through the role assignments of the user: whether
+// each applies in one of the companies and whether its role grants the
+// permission, directly or through the roles it inherits from.
+func (s *explainService) explainAssignments(ctx context.Context, userID uint64, permissionID int64, companyIDs []uint64) ([]*dto.RoleAssignmentExplanation, error) {
+	userRoles, err := s.repo.GetUserRoles(ctx, userID)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	parents, err := s.repo.GetRoleParentMap(ctx)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	granting, err := s.repo.GetRoleIDsGrantingPermission(ctx, permissionID)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	// Companies whose roles with subsidiaries reach the resource's companies
+	var ancestors []uint64
+	for _, id := range companyIDs {
+		ids, err := s.repo.GetCompanyAncestorIDs(ctx, id)
+		if err != nil {
+			return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+		}
+		ancestors = append(ancestors, ids...)
+	}
+
+	explanations := make([]*dto.RoleAssignmentExplanation, 0, len(userRoles))
+	for _, ur := range userRoles {
+		e := &dto.RoleAssignmentExplanation{UserRole: ur}
+		e.Applies, e.Scope = assignmentScope(ur, companyIDs, ancestors)
+
+		if chain := grantChain(ur.RoleID, parents, granting); chain != nil {
+			roles, err := s.repo.GetRolesByIDs(ctx, chain)
+			if err != nil {
+				return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+			}
+			e.Grants = true
+			e.Via = orderRoles(roles, chain)
+		}
+
+		explanations = append(explanations, e)
+	}
+
+	return explanations, nil
+}
+
+// assignmentScope reports whether the assignment applies in one of the
+// companies, and why.
+func assignmentScope(ur *models.UserRole, companyIDs, ancestors []uint64) (bool, string) {
+	if ur.CompanyID == nil {
+		return true, "assigned in every company"
+	}
+
+	company := fmt.Sprintf("company %d", *ur.CompanyID)
+	if ur.Company != nil {
+		company = fmt.Sprintf("%s (%d)", ur.Company.Name, *ur.CompanyID)
+	}
+
+	switch {
+	case len(companyIDs) == 0:
+		return false, fmt.Sprintf("assigned only in %s, and without a resource only roles assigned in every company count", company)
+	case slices.Contains(companyIDs, *ur.CompanyID):
+		return true, fmt.Sprintf("assigned in %s, which the resource belongs to", company)
+	case ur.IncludeSubsidiaries && slices.Contains(ancestors, *ur.CompanyID):
+		return true, fmt.Sprintf("assigned in %s with its subsidiaries, which include the resource's company", company)
+	case ur.IncludeSubsidiaries:
+		return false, fmt.Sprintf("assigned in %s with its subsidiaries, which do not include the resource's company", company)
+	}
+
+	return false, fmt.Sprintf("assigned only in %s, which the resource does not belong to", company)
+}
+
+// grantChain returns the shortest chain of roles from roleID, through the
+// roles it inherits from, to one the permission is granted to. It is nil
+// when the role does not hold the permission.
+func grantChain(roleID int64, parents map[int64][]int64, granting []int64) []int64 {
+	previous := map[int64]int64{roleID: 0}
+	queue := []int64{roleID}
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		if slices.Contains(granting, id) {
+			var chain []int64
+			for ; id != 0; id = previous[id] {
+				chain = append([]int64{id}, chain...)
+			}
+			return chain
+		}
+
+		for _, p := range parents[id] {
+			if _, seen := previous[p]; !seen {
+				previous[p] = id
+				queue = append(queue, p)
+			}
+		}
+	}
+
+	return nil
+}
+
+// orderRoles puts the roles in the order of their ids in chain.
+func orderRoles(roles []*models.Role, chain []int64) []*models.Role {
+	ordered := make([]*models.Role, 0, len(chain))
+	for _, id := range chain {
+		for _, r := range roles {
+			if r.ID == id {
+				ordered = append(ordered, r)
+			}
+		}
+	}
+
+	return ordered
+}
+
+// grantReason names the role the permission is granted to and, when it is
+// inherited, the assigned role that inherits it.
+func grantReason(a *dto.RoleAssignmentExplanation) string {
+	switch len(a.Via) {
+	case 0:
+		return fmt.Sprintf("granted to role %d, %s", a.RoleID, a.Scope)
+	case 1:
+		return fmt.Sprintf("granted to the %s role, %s", a.Via[0].Name, a.Scope)
+	}
+
+	return fmt.Sprintf("granted to the %s role, which the %s role inherits, %s", a.Via[len(a.Via)-1].Name, a.Via[0].Name, a.Scope)
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/vlahanam/company-management/internal/models"
+)
+
+// fakeUserRepo keeps users in memory. Only the methods the tests reach are
+// implemented; the embedded interface panics on the others.
+type fakeUserRepo struct {
+	UserRepo
+	users []*models.User
+}
+
+func (r *fakeUserRepo) GetUser(ctx context.Context, data map[string]interface{}) (*models.User, error) {
+	for _, u := range r.users {
+		if id, ok := data["id"]; ok && id == u.ID {
+			return u, nil
+		}
+		if email, ok := data["email"]; ok && email == u.Email {
+			return u, nil
+		}
+	}
+
+	return nil, models.ErrUserNotFound
+}
+
+func (r *fakeUserRepo) CreateUser(ctx context.Context, data *models.User) error {
+	data.ID = uint64(len(r.users) + 1)
+	r.users = append(r.users, data)
+	return nil
+}
+
+func (r *fakeUserRepo) UpdateUser(ctx context.Context, id uint64, data map[string]interface{}) error {
+	for _, u := range r.users {
+		if u.ID != id {
+			continue
+		}
+		if email, ok := data["email"].(string); ok {
+			u.Email = email
+		}
+		if v, ok := data["email_verified_at"]; ok {
+			if at, ok := v.(time.Time); ok {
+				u.EmailVerifiedAt = &at
+			} else {
+				u.EmailVerifiedAt = nil
+			}
+		}
+		if hash, ok := data["hash_password"].(string); ok {
+			u.HashPassword = hash
+		}
+	}
+
+	return nil
+}
+
+func (r *fakeUserRepo) ChangeUserPassword(ctx context.Context, userID uint64, data map[string]interface{}, oldHash string, keep int) error {
+	return r.UpdateUser(ctx, userID, data)
+}
+
+func (r *fakeUserRepo) GetPasswordHistory(ctx context.Context, userID uint64, limit int) ([]string, error) {
+	return nil, nil
+}
+
+func (r *fakeUserRepo) GetUserRoleNames(ctx context.Context, userID uint64) ([]string, error) {
+	return []string{}, nil
+}
+
+// fakeAuthRepo keeps passkeys, SSO identities, ceremonies, sessions and
+// invitations in memory. Users it creates are added to users.
+type fakeAuthRepo struct {
+	AuthRepo
+	users         *fakeUserRepo
+	credentials   []*models.WebAuthnCredential
+	sessions      map[string]*models.WebAuthnSession
+	identities    []*models.UserIdentity
+	oidcStates    map[string]*models.OIDCLoginState
+	resetTokens   []*models.PasswordResetToken
+	userSessions  []*models.UserSession
+	refreshTokens []*models.RefreshToken
+	revokedUsers  []uint64
+	invitations   []*models.Invitation
+}
+
+func (r *fakeAuthRepo) CreateWebAuthnCredential(ctx context.Context, data *models.WebAuthnCredential) error {
+	data.ID = uint64(len(r.credentials) + 1)
+	r.credentials = append(r.credentials, data)
+	return nil
+}
+
+func (r *fakeAuthRepo) GetWebAuthnCredentials(ctx context.Context, userID uint64) ([]*models.WebAuthnCredential, error) {
+	var out []*models.WebAuthnCredential
+	for _, c := range r.credentials {
+		if c.UserID == userID {
+			out = append(out, c)
+		}
+	}
+
+	return out, nil
+}
+
+func (r *fakeAuthRepo) UpdateWebAuthnCredential(ctx context.Context, id uint64, data map[string]interface{}) error {
+	for _, c := range r.credentials {
+		if c.ID == id {
+			c.Credential = data["credential"].(string)
+		}
+	}
+
+	return nil
+}
+
+func (r *fakeAuthRepo) CreateWebAuthnSession(ctx context.Context, data *models.WebAuthnSession) error {
+	if r.sessions == nil {
+		r.sessions = map[string]*models.WebAuthnSession{}
+	}
+	r.sessions[data.ID] = data
+	return nil
+}
+
+func (r *fakeAuthRepo) TakeWebAuthnSession(ctx context.Context, id, purpose string) (*models.WebAuthnSession, error) {
+	s, ok := r.sessions[id]
+	if !ok || s.Purpose != purpose || s.ExpiresAt.Before(time.Now()) {
+		return nil, models.ErrInvalidWebAuthnSession
+	}
+	delete(r.sessions, id)
+
+	return s, nil
+}
+
+func (r *fakeAuthRepo) CreateUserSession(ctx context.Context, data *models.UserSession) error {
+	r.userSessions = append(r.userSessions, data)
+	return nil
+}
+
+func (r *fakeAuthRepo) CreateRefreshToken(ctx context.Context, data *models.RefreshToken) error {
+	r.refreshTokens = append(r.refreshTokens, data)
+	return nil
+}
+
+func (r *fakeAuthRepo) UserRequiresMFA(ctx context.Context, userID uint64) (bool, error) {
+	return false, nil
+}
+
+func (r *fakeAuthRepo) GetUserIdentity(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	for _, i := range r.identities {
+		if i.Provider == provider && i.Subject == subject {
+			return i, nil
+		}
+	}
+
+	return nil, models.ErrOIDCNoAccount
+}
+
+func (r *fakeAuthRepo) CreateUserIdentity(ctx context.Context, data *models.UserIdentity) error {
+	data.ID = uint64(len(r.identities) + 1)
+	r.identities = append(r.identities, data)
+	return nil
+}
+
+func (r *fakeAuthRepo) UpdateUserIdentity(ctx context.Context, id uint64, data map[string]interface{}) error {
+	return nil
+}
+
+func (r *fakeAuthRepo) CreateUserWithIdentity(ctx context.Context, user *models.User, identity *models.UserIdentity) error {
+	if err := r.users.CreateUser(ctx, user); err != nil {
+		return err
+	}
+	identity.UserID = user.ID
+
+	return r.CreateUserIdentity(ctx, identity)
+}
+
+func (r *fakeAuthRepo) CreateOIDCLoginState(ctx context.Context, data *models.OIDCLoginState) error {
+	if r.oidcStates == nil {
+		r.oidcStates = map[string]*models.OIDCLoginState{}
+	}
+	r.oidcStates[data.StateHash] = data
+	return nil
+}
+
+func (r *fakeAuthRepo) TakeOIDCLoginState(ctx context.Context, stateHash, provider string) (*models.OIDCLoginState, error) {
+	s, ok := r.oidcStates[stateHash]
+	if !ok || s.Provider != provider || s.ExpiresAt.Before(time.Now()) {
+		return nil, models.ErrInvalidOIDCState
+	}
+	delete(r.oidcStates, stateHash)
+
+	return s, nil
+}
+
+func (r *fakeAuthRepo) CreatePasswordResetToken(ctx context.Context, data *models.PasswordResetToken) error {
+	data.ID = uint64(len(r.resetTokens) + 1)
+	r.resetTokens = append(r.resetTokens, data)
+	return nil
+}
+
+func (r *fakeAuthRepo) GetPasswordResetToken(ctx context.Context, data map[string]interface{}) (*models.PasswordResetToken, error) {
+	for _, t := range r.resetTokens {
+		if t.TokenHash == data["token_hash"] {
+			return t, nil
+		}
+	}
+
+	return nil, models.ErrPasswordResetTokenInvalid
+}
+
+func (r *fakeAuthRepo) ResetUserPassword(ctx context.Context, tokenID, userID uint64, data map[string]interface{}, oldHash string, keep int) (bool, error) {
+	for _, t := range r.resetTokens {
+		if t.ID == tokenID && t.UsedAt == nil {
+			now := time.Now().UTC()
+			t.UsedAt = &now
+			return true, r.users.UpdateUser(ctx, userID, data)
+		}
+	}
+
+	return false, nil
+}
+
+func (r *fakeAuthRepo) InvalidateUserPasswordResetTokens(ctx context.Context, userID uint64) error {
+	now := time.Now().UTC()
+	for _, t := range r.resetTokens {
+		if t.UserID == userID && t.UsedAt == nil {
+			t.UsedAt = &now
+		}
+	}
+
+	return nil
+}
+
+func (r *fakeAuthRepo) RevokeUserRefreshTokens(ctx context.Context, userID uint64) error {
+	r.revokedUsers = append(r.revokedUsers, userID)
+	return nil
+}
+
+func (r *fakeAuthRepo) RevokeUserSessions(ctx context.Context, userID uint64) error {
+	return nil
+}
+
+// fakeLoginThrottle never throttles and records the accounts it unlocks.
+type fakeLoginThrottle struct {
+	LoginThrottle
+	unlocked []string
+}
+
+func (t *fakeLoginThrottle) Unlock(ctx context.Context, email string) error {
+	t.unlocked = append(t.unlocked, email)
+	return nil
+}
+
+type fakeTokenRevoker struct {
+	TokenRevoker
+}
+
+func (fakeTokenRevoker) RevokeUserTokens(ctx context.Context, userID uint64) error {
+	return nil
+}
+
+func (r *fakeAuthRepo) GetCompany(ctx context.Context, data map[string]interface{}) (*models.Company, error) {
+	return &models.Company{}, nil
+}
+
+func (r *fakeAuthRepo) CreateInvitation(ctx context.Context, data *models.Invitation) error {
+	r.invitations = append(r.invitations, data)
+	return nil
+}
+
+func (r *fakeAuthRepo) DeleteInvitation(ctx context.Context, id string) error {
+	r.invitations = slices.DeleteFunc(r.invitations, func(i *models.Invitation) bool { return i.ID == id })
+	return nil
+}
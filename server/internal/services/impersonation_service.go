@@ -0,0 +1,156 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/dto"
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/requests"
+	"github.com/vlahanam/company-management/utils"
+)
+
+const expImpersonationToken = 15 * time.Minute
+
+type ImpersonationRepo interface {
+	CreateImpersonationSession(ctx context.Context, data *models.ImpersonationSession) error
+	GetImpersonationSession(ctx context.Context, id string) (*models.ImpersonationSession, error)
+	GetImpersonationSessions(ctx context.Context, limit, offset int) ([]*models.ImpersonationSession, error)
+	EndImpersonationSession(ctx context.Context, id string) (bool, error)
+	CreateImpersonationRequest(ctx context.Context, data *models.ImpersonationRequest) error
+}
+
+// StartImpersonation issues a short-lived access token that acts as the
+// subject and names the actor in its act claim. There is no refresh token;
+// the actor starts a new session once it expires.
+func (as *authService) StartImpersonation(ctx context.Context, actorUID string, subjectID uint64, data *requests.ImpersonateRequest) (*dto.ImpersonationToken, error) {
+	actorID, err := userIDFromUID(actorUID)
+	if err != nil {
+		return nil, err
+	}
+	if actorID == subjectID {
+		return nil, common.ErrorValidation.Clone().SetDetail("id", models.ErrImpersonateSelf.Error())
+	}
+
+	subject, err := as.es.FindByID(ctx, subjectID)
+	if err != nil {
+		return nil, common.ErrorNotFound.Clone().WrapMessage(models.ErrUserNotFound.Error())
+	}
+
+	subjectUID := common.NewUID(uint32(subject.ID), common.ObjectTypeUser, 1)
+	superAdmin, err := as.es.HasGlobalRole(ctx, subjectUID.String(), models.RoleSuperAdmin)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+	if superAdmin {
+		return nil, common.ErrorValidation.Clone().SetDetail("id", models.ErrImpersonateSuperAdmin.Error())
+	}
+
+	roles, err := as.es.GetRoleNamesByUserID(ctx, subject.ID)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	client := utils.ClientInfoFrom(ctx)
+	now := time.Now()
+	session := &models.ImpersonationSession{
+		ID:        uuid.NewString(),
+		ActorID:   actorID,
+		SubjectID: subject.ID,
+		Reason:    data.Reason,
+		IPAddress: optionalString(client.IP),
+		UserAgent: optionalString(truncate(client.UserAgent, 512)),
+		ExpiresAt: now.Add(expImpersonationToken).UTC(),
+	}
+	if err := as.rt.CreateImpersonationSession(ctx, session); err != nil {
+		return nil, common.ErrorCreateFailed.Clone().WrapErrorSafe(err)
+	}
+
+	access, err := as.opts.AccessKeys.Sign(jwt.MapClaims{
+		"user_id": subjectUID.String(),
+		"roles":   roles,
+		"jti":     uuid.NewString(),
+		"sid":     session.ID,
+		"act":     map[string]interface{}{"sub": actorUID},
+		"iat":     utils.IssuedAt(now),
+		"exp":     session.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return &dto.ImpersonationToken{
+		AccessToken: access,
+		SessionID:   session.ID,
+		ExpiresAt:   session.ExpiresAt,
+	}, nil
+}
+
+// StopImpersonation ends the impersonation session of the calling token and
+// revokes it.
+func (as *authService) StopImpersonation(ctx context.Context, sessionID, actorUID string) error {
+	if actorUID == "" || sessionID == "" {
+		return common.ErrorValidation.Clone().WrapMessage(models.ErrNotImpersonating.Error())
+	}
+
+	return as.EndImpersonation(ctx, sessionID)
+}
+
+// EndImpersonation stops an impersonation session and revokes its token.
+func (as *authService) EndImpersonation(ctx context.Context, sessionID string) error {
+	if _, err := as.GetImpersonation(ctx, sessionID); err != nil {
+		return err
+	}
+
+	ended, err := as.rt.EndImpersonationSession(ctx, sessionID)
+	if err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+	if !ended {
+		return common.ErrorValidation.Clone().WrapMessage(models.ErrImpersonationEnded.Error())
+	}
+
+	return as.opts.Revocations.RevokeSessionTokens(ctx, sessionID)
+}
+
+// RecordImpersonatedRequest appends a request to the audit trail of the
+// impersonation session.
+func (as *authService) RecordImpersonatedRequest(ctx context.Context, sessionID, method, path string, status int) error {
+	return as.rt.CreateImpersonationRequest(ctx, &models.ImpersonationRequest{
+		SessionID: sessionID,
+		Method:    method,
+		Path:      truncate(path, 2048),
+		Status:    status,
+		IPAddress: optionalString(utils.ClientInfoFrom(ctx).IP),
+	})
+}
+
+// ListImpersonations lists impersonation sessions, most recent first.
+func (as *authService) ListImpersonations(ctx context.Context, data *requests.ListImpersonationRequest) ([]*models.ImpersonationSession, error) {
+	data.Process()
+
+	sessions, err := as.rt.GetImpersonationSessions(ctx, data.Limit, (data.Page-1)*data.Limit)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return sessions, nil
+}
+
+// GetImpersonation returns an impersonation session with every request made
+// in it.
+func (as *authService) GetImpersonation(ctx context.Context, sessionID string) (*models.ImpersonationSession, error) {
+	session, err := as.rt.GetImpersonationSession(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, models.ErrImpersonationNotFound) {
+			return nil, common.ErrorNotFound.Clone().WrapMessage(err.Error())
+		}
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return session, nil
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/mailer"
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/requests"
+	"github.com/vlahanam/company-management/utils"
+)
+
+const purposeInvitation = "invitation"
+
+type InvitationRepo interface {
+	CreateInvitation(ctx context.Context, data *models.Invitation) error
+	GetInvitation(ctx context.Context, id string) (*models.Invitation, error)
+	GetInvitations(ctx context.Context, limit, offset int, query map[string]interface{}) ([]*models.Invitation, error)
+	DeleteInvitation(ctx context.Context, id string) error
+	RevokeInvitation(ctx context.Context, id string) (bool, error)
+	AcceptInvitation(ctx context.Context, invitation *models.Invitation, user *models.User) error
+	GetCompany(ctx context.Context, data map[string]interface{}) (*models.Company, error)
+	GetPosition(ctx context.Context, data map[string]interface{}) (*models.Position, error)
+	GetRolesByIDs(ctx context.Context, ids []int64) ([]*models.Role, error)
+	GetRolePermissionIDs(ctx context.Context, roleIDs []int64) ([]int64, error)
+}
+
+// CreateInvitation records an invitation to join a company and emails the
+// signed link to accept it. The inviter can only grant roles whose
+// permissions they hold in that company. An invitation whose email could not
+// be sent is not kept.
+func (as *authService) CreateInvitation(ctx context.Context, inviterUID string, data *requests.CreateInvitationRequest) (*models.Invitation, error) {
+	inviterID, err := userIDFromUID(inviterUID)
+	if err != nil {
+		return nil, err
+	}
+
+	if u, _ := as.es.FindByEmail(ctx, data.Email); u != nil {
+		return nil, common.ErrorValidation.Clone().SetDetail("email", models.ErrEmailAlreadyExists.Error())
+	}
+
+	if _, err := as.rt.GetCompany(ctx, map[string]interface{}{"id": data.CompanyID}); err != nil {
+		return nil, common.ErrorValidation.Clone().SetDetail("company_id", models.ErrCompanyNotFound.Error())
+	}
+
+	if data.PositionID != nil {
+		position, err := as.rt.GetPosition(ctx, map[string]interface{}{"id": *data.PositionID})
+		if err != nil {
+			return nil, common.ErrorValidation.Clone().SetDetail("position_id", models.ErrPositionNotFound.Error())
+		}
+		if position.CompanyID != data.CompanyID {
+			return nil, common.ErrorValidation.Clone().SetDetail("position_id", models.ErrPositionNotInCompany.Error())
+		}
+	}
+
+	roleIDs := slices.Compact(slices.Sorted(slices.Values(data.RoleIDs)))
+	if len(roleIDs) > 0 {
+		if err := as.checkGrantableRoles(ctx, inviterUID, data.CompanyID, roleIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	invitation := &models.Invitation{
+		ID:         uuid.NewString(),
+		Email:      data.Email,
+		FullName:   data.FullName,
+		CompanyID:  data.CompanyID,
+		PositionID: data.PositionID,
+		InvitedBy:  &inviterID,
+		ExpiresAt:  time.Now().UTC().Add(as.opts.InvitationTTL),
+	}
+	for _, id := range roleIDs {
+		invitation.Roles = append(invitation.Roles, &models.InvitationRole{InvitationID: invitation.ID, RoleID: id})
+	}
+
+	if err := as.rt.CreateInvitation(ctx, invitation); err != nil {
+		return nil, common.ErrorCreateFailed.Clone().WrapErrorSafe(err)
+	}
+
+	if err := as.sendInvitationEmail(ctx, invitation); err != nil {
+		if err := as.rt.DeleteInvitation(ctx, invitation.ID); err != nil {
+			log.Printf("invitation %s: delete unsent invitation: %v", invitation.ID, err)
+		}
+		return nil, err
+	}
+
+	return invitation, nil
+}
+
+// checkGrantableRoles makes sure the roles exist and grant nothing the inviter
+// does not hold in the company.
+func (as *authService) checkGrantableRoles(ctx context.Context, inviterUID string, companyID uint64, roleIDs []int64) error {
+	roles, err := as.rt.GetRolesByIDs(ctx, roleIDs)
+	if err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+	if len(roles) != len(roleIDs) {
+		return common.ErrorValidation.Clone().SetDetail("role_ids", models.ErrRoleNotFound.Error())
+	}
+
+	granted, err := as.rt.GetRolePermissionIDs(ctx, roleIDs)
+	if err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	held, err := as.es.GetPermissionIDsInCompanies(ctx, inviterUID, []uint64{companyID})
+	if err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	for _, id := range granted {
+		if !slices.Contains(held, id) {
+			return common.ErrorValidation.Clone().SetDetail("role_ids", models.ErrInvitationRoleForbidden.Error())
+		}
+	}
+
+	return nil
+}
+
+// sendInvitationEmail mails the link to accept the invitation. The token is
+// signed and names the invitation, which can only be accepted once.
+func (as *authService) sendInvitationEmail(ctx context.Context, invitation *models.Invitation) error {
+	claims := jwt.MapClaims{
+		"invitation_id": invitation.ID,
+		"email":         invitation.Email,
+		"purpose":       purposeInvitation,
+		"exp":           invitation.ExpiresAt.Unix(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(as.opts.VerifySecret))
+	if err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	name := invitation.Email
+	if invitation.FullName != nil {
+		name = *invitation.FullName
+	}
+
+	link := fmt.Sprintf("%s/accept-invitation?token=%s", as.opts.ClientURL, token)
+	if err := as.opts.Mailer.Send(ctx, &mailer.Message{
+		To:      invitation.Email,
+		Subject: "You have been invited to Company Management",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nYou have been invited to join. Open the link below to choose your password and activate your account:\n\n%s\n\nThe link expires in %s and can only be used once.\n",
+			name, link, as.opts.InvitationTTL,
+		),
+	}); err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return nil
+}
+
+// AcceptInvitation redeems an invitation link: it creates the verified user
+// with the password, the roles and the position of the invitation.
+func (as *authService) AcceptInvitation(ctx context.Context, data *requests.AcceptInvitationRequest) (*models.User, error) {
+	invalid := common.ErrorValidation.Clone().SetDetail("token", models.ErrInvalidInvitationToken.Error())
+
+	token, err := jwt.Parse(data.Token, func(token *jwt.Token) (interface{}, error) {
+		return []byte(as.opts.VerifySecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid {
+		return nil, invalid
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != purposeInvitation {
+		return nil, invalid
+	}
+
+	invitationID, _ := claims["invitation_id"].(string)
+	email, _ := claims["email"].(string)
+
+	invitation, err := as.rt.GetInvitation(ctx, invitationID)
+	if err != nil || invitation.Email != email {
+		return nil, invalid
+	}
+	if !invitation.Open(time.Now().UTC()) {
+		return nil, common.ErrorValidation.Clone().SetDetail("token", models.ErrInvitationClosed.Error())
+	}
+
+	fullName := data.FullName
+	if fullName == nil {
+		fullName = invitation.FullName
+	}
+	if fullName == nil {
+		return nil, common.ErrorValidation.Clone().SetDetail("full_name", "cannot be blank")
+	}
+
+	if err := utils.CheckPasswordPolicy(data.Password, invitation.Email, *fullName); err != nil {
+		return nil, common.ErrorValidation.Clone().SetDetail("password", err.Error())
+	}
+
+	hash, err := utils.HashPassword(data.Password)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	// Opening the emailed link proves the address
+	now := time.Now().UTC()
+	u := &models.User{
+		FullName:          *fullName,
+		Email:             invitation.Email,
+		HashPassword:      hash,
+		PasswordChangedAt: &now,
+		EmailVerifiedAt:   &now,
+	}
+
+	if err := as.rt.AcceptInvitation(ctx, invitation, u); err != nil {
+		if errors.Is(err, models.ErrInvitationClosed) {
+			return nil, common.ErrorValidation.Clone().SetDetail("token", err.Error())
+		}
+		return nil, common.ErrorCreateFailed.Clone().WrapErrorSafe(err)
+	}
+
+	return u, nil
+}
+
+// ListInvitations lists invitations, most recent first, optionally only those
+// to one company.
+func (as *authService) ListInvitations(ctx context.Context, data *requests.ListInvitationRequest) ([]*models.Invitation, error) {
+	data.Process()
+
+	query := make(map[string]interface{})
+	if data.CompanyID != nil {
+		query["company_id"] = *data.CompanyID
+	}
+
+	invitations, err := as.rt.GetInvitations(ctx, data.Limit, (data.Page-1)*data.Limit, query)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return invitations, nil
+}
+
+func (as *authService) GetInvitation(ctx context.Context, id string) (*models.Invitation, error) {
+	invitation, err := as.rt.GetInvitation(ctx, id)
+	if err != nil {
+		if errors.Is(err, models.ErrInvitationNotFound) {
+			return nil, common.ErrorNotFound.Clone().WrapMessage(err.Error())
+		}
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return invitation, nil
+}
+
+// RevokeInvitation withdraws an invitation that has not been accepted yet, so
+// its link stops working.
+func (as *authService) RevokeInvitation(ctx context.Context, id string) error {
+	if _, err := as.GetInvitation(ctx, id); err != nil {
+		return err
+	}
+
+	revoked, err := as.rt.RevokeInvitation(ctx, id)
+	if err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+	if !revoked {
+		return common.ErrorValidation.Clone().WrapMessage(models.ErrInvitationClosed.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/mailer"
+	"github.com/vlahanam/company-management/internal/requests"
+)
+
+// An invitation is kept only once its link has been mailed.
+func TestCreateInvitationMailFailure(t *testing.T) {
+	ctx := context.Background()
+	inviter := common.NewUID(1, common.ObjectTypeUser, 1)
+	inviterUID := inviter.String()
+
+	var mail bytes.Buffer
+	tests := []struct {
+		name string
+		mail mailer.Mailer
+		kept bool
+	}{
+		{"sent", mailer.NewLogMailer(&mail), true},
+		{"mail failure", mailer.NewLogMailer(brokenWriter{}), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &fakeUserRepo{}
+			repo := &fakeAuthRepo{users: users}
+			as := NewAuthService(NewUserService(users), repo, &AuthOptions{
+				Mailer:        tt.mail,
+				VerifySecret:  "verify-secret",
+				ClientURL:     "http://localhost:3000",
+				InvitationTTL: time.Hour,
+			})
+
+			_, err := as.CreateInvitation(ctx, inviterUID, &requests.CreateInvitationRequest{Email: "john@example.com", CompanyID: 1})
+			if (err == nil) != tt.kept {
+				t.Fatalf("CreateInvitation() = %v", err)
+			}
+			if kept := len(repo.invitations) == 1; kept != tt.kept {
+				t.Errorf("invitation kept = %v, want %v", kept, tt.kept)
+			}
+		})
+	}
+
+	if !strings.Contains(mail.String(), "/accept-invitation?token=") {
+		t.Errorf("no invitation link in the mail:\n%s", mail.String())
+	}
+}
@@ -0,0 +1,310 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/utils"
+)
+
+type LoginAttemptRepo interface {
+	GetLoginAttempts(ctx context.Context, keys []string) ([]*models.LoginAttempt, error)
+	RecordLoginFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*models.LoginAttempt, error)
+	LockLoginAttempt(ctx context.Context, key string, until time.Time) error
+	DeleteLoginAttempts(ctx context.Context, keys []string) error
+	DeleteStaleLoginAttempts(ctx context.Context, before time.Time) error
+}
+
+// LoginThrottle slows down and eventually locks out repeated failed logins
+// for an account and for a client IP.
+type LoginThrottle interface {
+	// Check returns a *LoginThrottledError when the account or IP has to wait
+	// before the next attempt.
+	Check(ctx context.Context, email, ip string) error
+	RecordFailure(ctx context.Context, email, ip string) error
+	// CheckMFA and RecordMFAFailure do the same for second factors, counted
+	// for the account and for the login challenge they answer.
+	CheckMFA(ctx context.Context, email, challenge string) error
+	RecordMFAFailure(ctx context.Context, email, challenge string) error
+	RecordSuccess(ctx context.Context, email string) error
+	Unlock(ctx context.Context, email string) error
+}
+
+// LoginThrottlePolicy configures the throttle. From the second failure on, the
+// next attempt has to wait BaseDelay, doubling with every further failure up
+// to MaxDelay; reaching the failure limit locks the account or IP for
+// LockoutDuration. IP and challenge delays are scaled so they reach their
+// limits at the same pace an account reaches MaxFailuresPerAccount.
+type LoginThrottlePolicy struct {
+	MaxFailuresPerAccount   int
+	MaxFailuresPerIP        int
+	MaxFailuresPerChallenge int
+	FailureWindow           time.Duration
+	LockoutDuration         time.Duration
+	BaseDelay               time.Duration
+	MaxDelay                time.Duration
+}
+
+// LoginThrottledError is wrapped in the error returned for a throttled login.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *LoginThrottledError) Error() string {
+	return models.ErrTooManyLoginAttempts.Error()
+}
+
+// RetryAfterSeconds rounds RetryAfter up to whole seconds, as used by the
+// Retry-After header.
+func (e *LoginThrottledError) RetryAfterSeconds() int {
+	return int((e.RetryAfter + time.Second - 1) / time.Second)
+}
+
+type loginThrottle struct {
+	repo   LoginAttemptRepo
+	policy LoginThrottlePolicy
+}
+
+func NewLoginThrottle(repo LoginAttemptRepo, policy LoginThrottlePolicy) *loginThrottle {
+	return &loginThrottle{
+		repo:   repo,
+		policy: policy,
+	}
+}
+
+func accountAttemptKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+// challengeAttemptKey identifies a pending MFA login by the hash of its
+// token.
+func challengeAttemptKey(challenge string) string {
+	return "mfa:" + utils.HashToken(challenge)
+}
+
+func (t *loginThrottle) Check(ctx context.Context, email, ip string) error {
+	return t.check(ctx, t.keys(email, ip))
+}
+
+func (t *loginThrottle) CheckMFA(ctx context.Context, email, challenge string) error {
+	return t.check(ctx, []string{accountAttemptKey(email), challengeAttemptKey(challenge)})
+}
+
+func (t *loginThrottle) check(ctx context.Context, keys []string) error {
+	attempts, err := t.repo.GetLoginAttempts(ctx, keys)
+	if err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	now := time.Now().UTC()
+	var throttled *LoginThrottledError
+
+	for _, a := range attempts {
+		wait, locked := t.wait(a, now)
+		if wait <= 0 {
+			continue
+		}
+		if throttled == nil || wait > throttled.RetryAfter {
+			throttled = &LoginThrottledError{RetryAfter: wait, Locked: locked}
+		}
+	}
+
+	if throttled != nil {
+		return common.ErrorTooManyRequests.Clone().
+			WrapMessage(models.ErrTooManyLoginAttempts.Error()).
+			SetDetail("retry_after", strconv.Itoa(throttled.RetryAfterSeconds())).
+			WrapError(throttled)
+	}
+
+	return nil
+}
+
+func (t *loginThrottle) RecordFailure(ctx context.Context, email, ip string) error {
+	return t.recordFailure(ctx, t.keys(email, ip))
+}
+
+func (t *loginThrottle) RecordMFAFailure(ctx context.Context, email, challenge string) error {
+	return t.recordFailure(ctx, []string{accountAttemptKey(email), challengeAttemptKey(challenge)})
+}
+
+func (t *loginThrottle) recordFailure(ctx context.Context, keys []string) error {
+	now := time.Now().UTC()
+
+	for _, key := range keys {
+		a, err := t.repo.RecordLoginFailure(ctx, key, now, t.policy.FailureWindow)
+		if err != nil {
+			return common.ErrorInternal.Clone().WrapErrorSafe(err)
+		}
+
+		if a.Failures >= t.limit(key) && a.LockedUntil == nil {
+			if err := t.repo.LockLoginAttempt(ctx, key, now.Add(t.policy.LockoutDuration)); err != nil {
+				return common.ErrorInternal.Clone().WrapErrorSafe(err)
+			}
+		}
+	}
+
+	_ = t.repo.DeleteStaleLoginAttempts(ctx, now.Add(-t.policy.FailureWindow))
+
+	return nil
+}
+
+// RecordSuccess clears the account counter. The IP counter is left alone so a
+// client cannot reset it by logging into an account it controls.
+func (t *loginThrottle) RecordSuccess(ctx context.Context, email string) error {
+	return t.Unlock(ctx, email)
+}
+
+func (t *loginThrottle) Unlock(ctx context.Context, email string) error {
+	if err := t.repo.DeleteLoginAttempts(ctx, []string{accountAttemptKey(email)}); err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return nil
+}
+
+func (t *loginThrottle) keys(email, ip string) []string {
+	keys := []string{accountAttemptKey(email)}
+	if ip != "" {
+		keys = append(keys, ipAttemptKey(ip))
+	}
+
+	return keys
+}
+
+func (t *loginThrottle) limit(key string) int {
+	switch {
+	case strings.HasPrefix(key, "ip:"):
+		return t.policy.MaxFailuresPerIP
+	case strings.HasPrefix(key, "mfa:"):
+		return t.policy.MaxFailuresPerChallenge
+	}
+
+	return t.policy.MaxFailuresPerAccount
+}
+
+// wait returns how long the subject of a must wait before trying again, and
+// whether that is because of a lockout.
+func (t *loginThrottle) wait(a *models.LoginAttempt, now time.Time) (time.Duration, bool) {
+	if a.LockedUntil != nil && a.LockedUntil.After(now) {
+		return a.LockedUntil.Sub(now), true
+	}
+
+	if now.Sub(a.LastFailedAt) >= t.policy.FailureWindow {
+		return 0, false
+	}
+
+	// Normalize to the account scale so IPs get the same delay curve
+	level := a.Failures
+	if limit := t.limit(a.Key); limit > 0 && t.policy.MaxFailuresPerAccount > 0 {
+		level = a.Failures * t.policy.MaxFailuresPerAccount / limit
+	}
+	if level < 2 {
+		return 0, false
+	}
+
+	delay := t.policy.BaseDelay << (level - 2)
+	if delay <= 0 || delay > t.policy.MaxDelay {
+		delay = t.policy.MaxDelay
+	}
+
+	return a.LastFailedAt.Add(delay).Sub(now), false
+}
+
+// memoryLoginAttemptStore keeps login attempt counters in process memory. It
+// is meant for single-node deployments; counters are lost on restart and not
+// shared between instances.
+type memoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]*models.LoginAttempt
+}
+
+func NewMemoryLoginAttemptStore() *memoryLoginAttemptStore {
+	return &memoryLoginAttemptStore{
+		attempts: make(map[string]*models.LoginAttempt),
+	}
+}
+
+func (s *memoryLoginAttemptStore) GetLoginAttempts(ctx context.Context, keys []string) ([]*models.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attempts := make([]*models.LoginAttempt, 0, len(keys))
+	for _, key := range keys {
+		if a, ok := s.attempts[key]; ok {
+			copied := *a
+			attempts = append(attempts, &copied)
+		}
+	}
+
+	return attempts, nil
+}
+
+func (s *memoryLoginAttemptStore) RecordLoginFailure(ctx context.Context, key string, now time.Time, window time.Duration) (*models.LoginAttempt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.attempts[key]
+	if !ok {
+		a = &models.LoginAttempt{Key: key}
+		s.attempts[key] = a
+	}
+
+	lockExpired := a.LockedUntil == nil || !a.LockedUntil.After(now)
+	if a.LastFailedAt.Before(now.Add(-window)) && lockExpired {
+		a.Failures = 0
+	}
+	if a.LockedUntil != nil && lockExpired {
+		a.LockedUntil = nil
+	}
+
+	a.Failures++
+	a.LastFailedAt = now
+
+	copied := *a
+	return &copied, nil
+}
+
+func (s *memoryLoginAttemptStore) LockLoginAttempt(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if a, ok := s.attempts[key]; ok {
+		a.LockedUntil = &until
+	}
+
+	return nil
+}
+
+func (s *memoryLoginAttemptStore) DeleteLoginAttempts(ctx context.Context, keys []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		delete(s.attempts, key)
+	}
+
+	return nil
+}
+
+func (s *memoryLoginAttemptStore) DeleteStaleLoginAttempts(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, a := range s.attempts {
+		if a.LastFailedAt.Before(before) && (a.LockedUntil == nil || a.LockedUntil.Before(before)) {
+			delete(s.attempts, key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,432 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/skip2/go-qrcode"
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/dto"
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/requests"
+	"github.com/vlahanam/company-management/utils"
+)
+
+const (
+	purposeMFALogin       = "mfa_login"
+	purposeMFAEnroll      = "mfa_enroll"
+	purposePasswordChange = "password_change"
+
+	expMFAToken       = 5 * time.Minute
+	recoveryCodeCount = 10
+)
+
+type MFARepo interface {
+	UserRequiresMFA(ctx context.Context, userID uint64) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID uint64, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID uint64, codeHash string) (bool, error)
+	UseTOTPStep(ctx context.Context, userID uint64, step int64) (bool, error)
+	DeleteRecoveryCodes(ctx context.Context, userID uint64) error
+}
+
+// mfaChallenge decides whether a user who passed the password check still has
+// to present a second factor. It returns nil when full tokens can be issued.
+func (as *authService) mfaChallenge(ctx context.Context, u *models.User, device string) (*models.Auth, error) {
+	if u.TOTPEnabledAt != nil {
+		token, err := as.signMFAToken(u.ID, purposeMFALogin, device)
+		if err != nil {
+			return nil, err
+		}
+		return &models.Auth{MFARequired: true, MFAToken: token}, nil
+	}
+
+	required, err := as.rt.UserRequiresMFA(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !required {
+		return nil, nil
+	}
+
+	token, err := as.signMFAToken(u.ID, purposeMFAEnroll, device)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Auth{MFAEnrollmentRequired: true, MFAToken: token}, nil
+}
+
+// CompleteMFALogin exchanges the pending MFA token and a TOTP or recovery code
+// for the regular token pair. Wrong codes count towards the login throttle of
+// the account and of the pending login.
+func (as *authService) CompleteMFALogin(ctx context.Context, data *requests.MFALoginRequest) (*models.Auth, error) {
+	userID, device, err := as.parseMFAToken(data.MFAToken, purposeMFALogin)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := as.es.FindByID(ctx, userID)
+	if err != nil {
+		return nil, common.ErrorUnauthorized.Clone().WrapMessage(models.ErrInvalidMFAToken.Error())
+	}
+
+	if err := as.opts.LoginThrottle.CheckMFA(ctx, u.Email, data.MFAToken); err != nil {
+		return nil, err
+	}
+
+	ok, err := as.checkSecondFactor(ctx, u, data.Code, data.RecoveryCode)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err := as.opts.LoginThrottle.RecordMFAFailure(ctx, u.Email, data.MFAToken); err != nil {
+			return nil, err
+		}
+		return nil, errInvalidMFACode()
+	}
+
+	if err := as.opts.LoginThrottle.RecordSuccess(ctx, u.Email); err != nil {
+		return nil, err
+	}
+
+	return as.completeLogin(ctx, u, device)
+}
+
+// SetupTOTP generates a new secret for the user. It only becomes active once
+// a code generated from it is confirmed.
+func (as *authService) SetupTOTP(ctx context.Context, userUID string) (*dto.TOTPSetup, error) {
+	userID, err := userIDFromUID(userUID)
+	if err != nil {
+		return nil, err
+	}
+
+	return as.setupTOTP(ctx, userID)
+}
+
+// ConfirmTOTP activates the pending secret and returns fresh recovery codes.
+func (as *authService) ConfirmTOTP(ctx context.Context, userUID string, data *requests.ConfirmTOTPRequest) (*dto.RecoveryCodes, error) {
+	userID, err := userIDFromUID(userUID)
+	if err != nil {
+		return nil, err
+	}
+
+	return as.confirmTOTP(ctx, userID, data.Code)
+}
+
+// SetupTOTPForLogin starts enrollment for a user whose role enforces MFA but
+// who has not enrolled yet, using the token handed out by Login.
+func (as *authService) SetupTOTPForLogin(ctx context.Context, data *requests.MFATokenRequest) (*dto.TOTPSetup, error) {
+	userID, _, err := as.parseMFAToken(data.MFAToken, purposeMFAEnroll)
+	if err != nil {
+		return nil, err
+	}
+
+	return as.setupTOTP(ctx, userID)
+}
+
+// ConfirmTOTPForLogin completes enrollment started at login and signs the user
+// in.
+func (as *authService) ConfirmTOTPForLogin(ctx context.Context, data *requests.MFAEnrollRequest) (*dto.MFAEnrollment, error) {
+	userID, device, err := as.parseMFAToken(data.MFAToken, purposeMFAEnroll)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := as.confirmTOTP(ctx, userID, data.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := as.es.FindByID(ctx, userID)
+	if err != nil {
+		return nil, common.ErrorUnauthorized.Clone().WrapMessage(models.ErrInvalidMFAToken.Error())
+	}
+
+	if err := as.opts.LoginThrottle.RecordSuccess(ctx, u.Email); err != nil {
+		return nil, err
+	}
+
+	auth, err := as.completeLogin(ctx, u, device)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.MFAEnrollment{
+		AccessToken:            auth.AccessToken,
+		RefreshToken:           auth.RefreshToken,
+		PasswordChangeRequired: auth.PasswordChangeRequired,
+		PasswordChangeToken:    auth.PasswordChangeToken,
+		RecoveryCodes:          codes.Codes,
+	}, nil
+}
+
+// DisableTOTP turns two-factor authentication off after checking a second
+// factor. Users whose role enforces MFA cannot disable it.
+func (as *authService) DisableTOTP(ctx context.Context, userUID string, data *requests.SecondFactorRequest) error {
+	u, err := as.findByUID(ctx, userUID)
+	if err != nil {
+		return err
+	}
+
+	if u.TOTPEnabledAt == nil {
+		return common.ErrorValidation.Clone().WrapMessage(models.ErrMFANotEnabled.Error())
+	}
+
+	required, err := as.rt.UserRequiresMFA(ctx, u.ID)
+	if err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+	if required {
+		return common.ErrorValidation.Clone().WrapMessage(models.ErrMFARequiredByRole.Error())
+	}
+
+	if err := as.verifySecondFactor(ctx, u, data.Code, data.RecoveryCode); err != nil {
+		return err
+	}
+
+	if err := as.es.er.UpdateUser(ctx, u.ID, map[string]interface{}{
+		"totp_secret":     nil,
+		"totp_enabled_at": nil,
+		"totp_last_step":  nil,
+	}); err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	if err := as.rt.DeleteRecoveryCodes(ctx, u.ID); err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces every recovery code after checking a second
+// factor.
+func (as *authService) RegenerateRecoveryCodes(ctx context.Context, userUID string, data *requests.SecondFactorRequest) (*dto.RecoveryCodes, error) {
+	u, err := as.findByUID(ctx, userUID)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.TOTPEnabledAt == nil {
+		return nil, common.ErrorValidation.Clone().WrapMessage(models.ErrMFANotEnabled.Error())
+	}
+
+	if err := as.verifySecondFactor(ctx, u, data.Code, data.RecoveryCode); err != nil {
+		return nil, err
+	}
+
+	return as.newRecoveryCodes(ctx, u.ID)
+}
+
+func (as *authService) setupTOTP(ctx context.Context, userID uint64) (*dto.TOTPSetup, error) {
+	u, err := as.es.FindByID(ctx, userID)
+	if err != nil {
+		return nil, common.ErrorNotFound.Clone().WrapMessage("user not found")
+	}
+
+	if u.TOTPEnabledAt != nil {
+		return nil, common.ErrorValidation.Clone().WrapMessage(models.ErrMFAAlreadyEnabled.Error())
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	if err := as.es.er.UpdateUser(ctx, u.ID, map[string]interface{}{"totp_secret": secret}); err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	uri := utils.TOTPURI(as.opts.TOTPIssuer, u.Email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return &dto.TOTPSetup{
+		Secret:     secret,
+		OTPAuthURI: uri,
+		QRCode:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	}, nil
+}
+
+func (as *authService) confirmTOTP(ctx context.Context, userID uint64, code string) (*dto.RecoveryCodes, error) {
+	u, err := as.es.FindByID(ctx, userID)
+	if err != nil {
+		return nil, common.ErrorNotFound.Clone().WrapMessage("user not found")
+	}
+
+	if u.TOTPEnabledAt != nil {
+		return nil, common.ErrorValidation.Clone().WrapMessage(models.ErrMFAAlreadyEnabled.Error())
+	}
+	if u.TOTPSecret == nil {
+		return nil, common.ErrorValidation.Clone().WrapMessage(models.ErrMFANotEnabled.Error())
+	}
+
+	step, ok := utils.ValidateTOTP(*u.TOTPSecret, code, time.Now())
+	if !ok {
+		return nil, common.ErrorValidation.Clone().SetDetail("code", models.ErrInvalidMFACode.Error())
+	}
+
+	if err := as.es.er.UpdateUser(ctx, u.ID, map[string]interface{}{
+		"totp_enabled_at": time.Now().UTC(),
+		"totp_last_step":  step,
+	}); err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return as.newRecoveryCodes(ctx, u.ID)
+}
+
+// verifySecondFactor accepts either a TOTP code, which must belong to a newer
+// time step than the last accepted one, or an unused recovery code.
+func (as *authService) verifySecondFactor(ctx context.Context, u *models.User, code, recoveryCode string) error {
+	ok, err := as.checkSecondFactor(ctx, u, code, recoveryCode)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errInvalidMFACode()
+	}
+
+	return nil
+}
+
+// checkSecondFactor is verifySecondFactor reporting a wrong code as false
+// rather than as an error.
+func (as *authService) checkSecondFactor(ctx context.Context, u *models.User, code, recoveryCode string) (bool, error) {
+	if u.TOTPEnabledAt == nil || u.TOTPSecret == nil {
+		return false, common.ErrorValidation.Clone().WrapMessage(models.ErrMFANotEnabled.Error())
+	}
+
+	// Both codes are consumed with a conditional update, so concurrent
+	// requests cannot use the same one twice
+	if recoveryCode != "" {
+		used, err := as.rt.UseRecoveryCode(ctx, u.ID, utils.HashToken(normalizeRecoveryCode(recoveryCode)))
+		if err != nil {
+			return false, common.ErrorInternal.Clone().WrapErrorSafe(err)
+		}
+		return used, nil
+	}
+
+	step, ok := utils.ValidateTOTP(*u.TOTPSecret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	used, err := as.rt.UseTOTPStep(ctx, u.ID, step)
+	if err != nil {
+		return false, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return used, nil
+}
+
+func errInvalidMFACode() error {
+	return common.ErrorValidation.Clone().SetDetail("code", models.ErrInvalidMFACode.Error())
+}
+
+func (as *authService) newRecoveryCodes(ctx context.Context, userID uint64) (*dto.RecoveryCodes, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		secret, err := utils.GenerateTOTPSecret()
+		if err != nil {
+			return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+		}
+
+		// 10 base32 characters, shown as xxxxx-xxxxx
+		code := strings.ToLower(secret[:5] + "-" + secret[5:10])
+		codes = append(codes, code)
+		hashes = append(hashes, utils.HashToken(normalizeRecoveryCode(code)))
+	}
+
+	if err := as.rt.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return &dto.RecoveryCodes{Codes: codes}, nil
+}
+
+func (as *authService) signMFAToken(userID uint64, purpose, device string) (string, error) {
+	uid := common.NewUID(uint32(userID), common.ObjectTypeUser, 1)
+
+	claims := jwt.MapClaims{
+		"user_id": uid.String(),
+		"purpose": purpose,
+		"device":  device,
+		"jti":     uuid.NewString(),
+		"exp":     time.Now().Add(expMFAToken).Unix(),
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(as.opts.VerifySecret))
+}
+
+func (as *authService) parseMFAToken(tokenStr, purpose string) (uint64, string, error) {
+	userID, claims, err := as.parseMFAClaims(tokenStr, purpose)
+	if err != nil {
+		return 0, "", err
+	}
+
+	device, _ := claims["device"].(string)
+	return userID, device, nil
+}
+
+// useMFAToken records the token with the given claims as used, so it cannot
+// be presented again. It reports false when it was already used.
+func (as *authService) useMFAToken(ctx context.Context, claims jwt.MapClaims) (bool, error) {
+	jti, _ := claims["jti"].(string)
+	exp, err := claims.GetExpirationTime()
+	if jti == "" || err != nil || exp == nil {
+		return false, nil
+	}
+
+	return as.opts.Revocations.ConsumeToken(ctx, jti, exp.Time)
+}
+
+func (as *authService) parseMFAClaims(tokenStr, purpose string) (uint64, jwt.MapClaims, error) {
+	invalid := common.ErrorUnauthorized.Clone().WrapMessage(models.ErrInvalidMFAToken.Error())
+
+	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		return []byte(as.opts.VerifySecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid {
+		return 0, nil, invalid
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != purpose {
+		return 0, nil, invalid
+	}
+
+	userUID, _ := claims["user_id"].(string)
+
+	userID, err := userIDFromUID(userUID)
+	if err != nil {
+		return 0, nil, invalid
+	}
+
+	return userID, claims, nil
+}
+
+func (as *authService) findByUID(ctx context.Context, userUID string) (*models.User, error) {
+	userID, err := userIDFromUID(userUID)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := as.es.FindByID(ctx, userID)
+	if err != nil {
+		return nil, common.ErrorNotFound.Clone().WrapMessage("user not found")
+	}
+
+	return u, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/dto"
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/oidc"
+	"github.com/vlahanam/company-management/internal/requests"
+	"github.com/vlahanam/company-management/utils"
+)
+
+const expOIDCLoginState = 10 * time.Minute
+
+type OIDCRepo interface {
+	GetUserIdentity(ctx context.Context, provider, subject string) (*models.UserIdentity, error)
+	CreateUserIdentity(ctx context.Context, data *models.UserIdentity) error
+	UpdateUserIdentity(ctx context.Context, id uint64, data map[string]interface{}) error
+	CreateUserWithIdentity(ctx context.Context, user *models.User, identity *models.UserIdentity) error
+	CreateOIDCLoginState(ctx context.Context, data *models.OIDCLoginState) error
+	TakeOIDCLoginState(ctx context.Context, stateHash, provider string) (*models.OIDCLoginState, error)
+}
+
+// ListOIDCProviders returns the names of the configured SSO providers.
+func (as *authService) ListOIDCProviders() []string {
+	names := make([]string, 0, len(as.opts.OIDCProviders))
+	for name := range as.opts.OIDCProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// BeginOIDCLogin stores a single-use state with the PKCE verifier and nonce
+// and returns the provider URL to send the browser to.
+func (as *authService) BeginOIDCLogin(ctx context.Context, provider string) (*dto.OIDCAuthorization, error) {
+	p, ok := as.opts.OIDCProviders[provider]
+	if !ok {
+		return nil, common.ErrorNotFound.Clone().WrapMessage(models.ErrOIDCProviderNotFound.Error())
+	}
+
+	state, errState := utils.GenerateRandomToken(32)
+	verifier, errVerifier := utils.GenerateRandomToken(32)
+	nonce, errNonce := utils.GenerateRandomToken(16)
+	if err := errors.Join(errState, errVerifier, errNonce); err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	if err := as.rt.CreateOIDCLoginState(ctx, &models.OIDCLoginState{
+		StateHash:    utils.HashToken(state),
+		Provider:     provider,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().UTC().Add(expOIDCLoginState),
+	}); err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return &dto.OIDCAuthorization{AuthorizationURL: authURL}, nil
+}
+
+// FinishOIDCLogin redeems the code returned to the redirect URL, maps the
+// verified identity to a user and issues the token pair. Users with 2FA
+// still have to pass it.
+func (as *authService) FinishOIDCLogin(ctx context.Context, provider string, data *requests.OIDCCallbackRequest) (*models.Auth, error) {
+	p, ok := as.opts.OIDCProviders[provider]
+	if !ok {
+		return nil, common.ErrorNotFound.Clone().WrapMessage(models.ErrOIDCProviderNotFound.Error())
+	}
+
+	state, err := as.rt.TakeOIDCLoginState(ctx, utils.HashToken(data.State), provider)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidOIDCState) {
+			return nil, common.ErrorUnauthorized.Clone().WrapMessage(err.Error())
+		}
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	identity, err := p.Exchange(ctx, data.Code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		return nil, common.ErrorUnauthorized.Clone().WrapMessage(models.ErrOIDCLoginFailed.Error()).WrapErrorSafe(err)
+	}
+
+	u, err := as.resolveOIDCUser(ctx, p.Config(), identity)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := as.mfaChallenge(ctx, u, data.Device)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+	if challenge != nil {
+		return challenge, nil
+	}
+
+	return as.issueLoginTokens(ctx, u, data.Device)
+}
+
+// resolveOIDCUser finds the user linked to the identity. An unlinked identity
+// with a verified email in an allowed domain is linked to the user with the
+// same email, or provisions a new user when the provider allows it.
+func (as *authService) resolveOIDCUser(ctx context.Context, cfg oidc.Config, identity *oidc.Identity) (*models.User, error) {
+	now := time.Now().UTC()
+	var email *string
+	if identity.Email != "" {
+		email = &identity.Email
+	}
+
+	linked, err := as.rt.GetUserIdentity(ctx, cfg.Name, identity.Subject)
+	if err == nil {
+		_ = as.rt.UpdateUserIdentity(ctx, linked.ID, map[string]interface{}{"email": email, "last_login_at": now})
+
+		u, err := as.es.FindByID(ctx, linked.UserID)
+		if err != nil {
+			return nil, common.ErrorUnauthorized.Clone().WrapMessage(models.ErrOIDCNoAccount.Error())
+		}
+		return u, nil
+	}
+	if !errors.Is(err, models.ErrOIDCNoAccount) {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	noAccount := common.ErrorUnauthorized.Clone().WrapKey("SSO_ACCOUNT_NOT_FOUND").WrapMessage(models.ErrOIDCNoAccount.Error())
+	if identity.Email == "" || !identity.EmailVerified || !emailDomainAllowed(identity.Email, cfg.AllowedDomains) {
+		return nil, noAccount
+	}
+
+	link := &models.UserIdentity{
+		Provider:    cfg.Name,
+		Subject:     identity.Subject,
+		Email:       email,
+		LastLoginAt: &now,
+	}
+
+	if u, err := as.es.FindByEmail(ctx, identity.Email); err == nil {
+		link.UserID = u.ID
+		if err := as.rt.CreateUserIdentity(ctx, link); err != nil {
+			return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+		}
+
+		// The provider vouched for the address
+		if u.EmailVerifiedAt == nil {
+			if err := as.es.MarkEmailVerified(ctx, u.ID); err != nil {
+				return nil, err
+			}
+			u.EmailVerifiedAt = &now
+		}
+		return u, nil
+	}
+
+	if !cfg.AutoProvision {
+		return nil, noAccount
+	}
+
+	// SSO users have no usable password until they reset it
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+	hash, err := utils.HashPassword(secret)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	fullName := identity.Name
+	if fullName == "" {
+		fullName, _, _ = strings.Cut(identity.Email, "@")
+	}
+
+	u := &models.User{
+		FullName:          fullName,
+		Email:             identity.Email,
+		HashPassword:      hash,
+		PasswordChangedAt: &now,
+		EmailVerifiedAt:   &now,
+	}
+	if err := as.rt.CreateUserWithIdentity(ctx, u, link); err != nil {
+		return nil, common.ErrorCreateFailed.Clone().WrapErrorSafe(err)
+	}
+
+	return u, nil
+}
+
+func emailDomainAllowed(email string, domains []string) bool {
+	if len(domains) == 0 {
+		return true
+	}
+
+	_, domain, _ := strings.Cut(strings.ToLower(email), "@")
+	for _, d := range domains {
+		if strings.EqualFold(strings.TrimSpace(d), domain) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/oidc"
+	"github.com/vlahanam/company-management/internal/requests"
+	"github.com/vlahanam/company-management/utils"
+)
+
+const testClientID = "company-management"
+
+// mockIdP is an OpenID provider that signs in whoever the test authorizes.
+type mockIdP struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	codes  map[string]jwt.MapClaims
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &mockIdP{t: t, key: key, codes: map[string]jwt.MapClaims{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		idp.json(w, map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.json(w, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := idp.codes[r.FormValue("code")]
+		if !ok || claims["code_challenge"] != oidc.CodeChallenge(r.FormValue("code_verifier")) {
+			w.WriteHeader(http.StatusBadRequest)
+			idp.json(w, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		delete(idp.codes, r.FormValue("code"))
+		delete(claims, "code_challenge")
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "test"
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Error(err)
+		}
+		idp.json(w, map[string]string{"id_token": signed})
+	})
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+func (idp *mockIdP) json(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		idp.t.Error(err)
+	}
+}
+
+// authorize plays the browser at the IdP: the subject signs in with the given
+// claims and the IdP redirects back with a code. It returns the callback.
+func (idp *mockIdP) authorize(authorizationURL, subject string, claims jwt.MapClaims) *requests.OIDCCallbackRequest {
+	idp.t.Helper()
+
+	u, err := url.Parse(authorizationURL)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	q := u.Query()
+
+	now := time.Now()
+	idToken := jwt.MapClaims{
+		"iss":            idp.server.URL,
+		"aud":            testClientID,
+		"sub":            subject,
+		"nonce":          q.Get("nonce"),
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Minute).Unix(),
+		"code_challenge": q.Get("code_challenge"),
+	}
+	for k, v := range claims {
+		idToken[k] = v
+	}
+
+	code, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		idp.t.Fatal(err)
+	}
+	idp.codes[code] = idToken
+
+	return &requests.OIDCCallbackRequest{Code: code, State: q.Get("state")}
+}
+
+func TestFinishOIDCLogin(t *testing.T) {
+	idp := newMockIdP(t)
+
+	tests := []struct {
+		name          string
+		autoProvision bool
+		subject       string
+		claims        jwt.MapClaims
+		wantUser      uint64 // 0 when no account is found
+		wantLinked    bool
+	}{
+		{"linked subject", false, "linked", jwt.MapClaims{"email": "other@partner.com"}, 1, false},
+		{"links verified email", false, "new", jwt.MapClaims{"email": "jane@example.com", "email_verified": true}, 1, true},
+		{"unverified email", false, "new", jwt.MapClaims{"email": "jane@example.com", "email_verified": false}, 0, false},
+		{"email outside allowed domains", false, "new", jwt.MapClaims{"email": "mallory@partner.com", "email_verified": true}, 0, false},
+		{"provisions verified email", true, "new", jwt.MapClaims{"email": "john@example.com", "email_verified": true, "name": "John"}, 3, true},
+		{"no provisioning outside allowed domains", true, "new", jwt.MapClaims{"email": "john@partner.com", "email_verified": true}, 0, false},
+		{"no provisioning when disabled", false, "new", jwt.MapClaims{"email": "john@example.com", "email_verified": true}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now().UTC()
+
+			users := &fakeUserRepo{users: []*models.User{
+				{SQLModel: models.SQLModel{ID: 1}, FullName: "Jane Doe", Email: "jane@example.com", EmailVerifiedAt: &now},
+				{SQLModel: models.SQLModel{ID: 2}, FullName: "Mallory", Email: "mallory@partner.com", EmailVerifiedAt: &now},
+			}}
+			repo := &fakeAuthRepo{users: users, identities: []*models.UserIdentity{
+				{ID: 1, UserID: 1, Provider: "corp", Subject: "linked"},
+			}}
+			as := NewAuthService(NewUserService(users), repo, &AuthOptions{
+				AccessKeys:  utils.NewHMACKeySet("access-secret"),
+				RefreshKeys: utils.NewHMACKeySet("refresh-secret"),
+				OIDCProviders: map[string]*oidc.Provider{"corp": oidc.NewProvider(oidc.Config{
+					Name:           "corp",
+					Issuer:         idp.server.URL,
+					ClientID:       testClientID,
+					ClientSecret:   "secret",
+					RedirectURL:    "http://localhost:3030/auth/sso/corp/callback",
+					AutoProvision:  tt.autoProvision,
+					AllowedDomains: []string{"example.com"},
+				}, idp.server.Client())},
+			})
+
+			authorization, err := as.BeginOIDCLogin(ctx, "corp")
+			if err != nil {
+				t.Fatalf("BeginOIDCLogin() = %v", err)
+			}
+
+			auth, err := as.FinishOIDCLogin(ctx, "corp", idp.authorize(authorization.AuthorizationURL, tt.subject, tt.claims))
+			identities := len(repo.identities)
+
+			if tt.wantUser == 0 {
+				if err == nil || err.Error() != models.ErrOIDCNoAccount.Error() {
+					t.Fatalf("FinishOIDCLogin() = %v, want %v", err, models.ErrOIDCNoAccount)
+				}
+				if identities != 1 || len(users.users) != 2 {
+					t.Errorf("a failed login left %d identities and %d users", identities, len(users.users))
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("FinishOIDCLogin() = %v", err)
+			}
+			if auth.AccessToken == "" {
+				t.Fatalf("FinishOIDCLogin() = %+v, want a token pair", auth)
+			}
+			if got := repo.refreshTokens[0].UserID; got != tt.wantUser {
+				t.Errorf("logged in user %d, want %d", got, tt.wantUser)
+			}
+			if linked := identities == 2; linked != tt.wantLinked {
+				t.Errorf("identity linked = %v, want %v", linked, tt.wantLinked)
+			}
+		})
+	}
+}
+
+func TestFinishOIDCLoginRejectsReusedState(t *testing.T) {
+	ctx := context.Background()
+	idp := newMockIdP(t)
+
+	users := &fakeUserRepo{}
+	repo := &fakeAuthRepo{users: users}
+	as := NewAuthService(NewUserService(users), repo, &AuthOptions{
+		AccessKeys:  utils.NewHMACKeySet("access-secret"),
+		RefreshKeys: utils.NewHMACKeySet("refresh-secret"),
+		OIDCProviders: map[string]*oidc.Provider{"corp": oidc.NewProvider(oidc.Config{
+			Name:          "corp",
+			Issuer:        idp.server.URL,
+			ClientID:      testClientID,
+			ClientSecret:  "secret",
+			AutoProvision: true,
+		}, idp.server.Client())},
+	})
+
+	authorization, err := as.BeginOIDCLogin(ctx, "corp")
+	if err != nil {
+		t.Fatalf("BeginOIDCLogin() = %v", err)
+	}
+
+	claims := jwt.MapClaims{"email": "john@example.com", "email_verified": true}
+	if _, err := as.FinishOIDCLogin(ctx, "corp", idp.authorize(authorization.AuthorizationURL, "john", claims)); err != nil {
+		t.Fatalf("FinishOIDCLogin() = %v", err)
+	}
+	if _, err := as.FinishOIDCLogin(ctx, "corp", idp.authorize(authorization.AuthorizationURL, "john", claims)); err == nil {
+		t.Error("FinishOIDCLogin() accepted the same state twice")
+	}
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/mailer"
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/requests"
+	"github.com/vlahanam/company-management/utils"
+)
+
+type PasswordResetTokenRepo interface {
+	CreatePasswordResetToken(ctx context.Context, data *models.PasswordResetToken) error
+	GetPasswordResetToken(ctx context.Context, data map[string]interface{}) (*models.PasswordResetToken, error)
+	ResetUserPassword(ctx context.Context, tokenID, userID uint64, data map[string]interface{}, oldHash string, keep int) (bool, error)
+	InvalidateUserPasswordResetTokens(ctx context.Context, userID uint64) error
+}
+
+// ForgotPassword emails a single-use reset link. Unknown emails are ignored so
+// the response does not reveal which addresses have an account; for the same
+// reason a link that cannot be sent is only logged.
+func (as *authService) ForgotPassword(ctx context.Context, data *requests.ForgotPasswordRequest) error {
+	u, err := as.es.FindByEmail(ctx, data.Email)
+	if err != nil {
+		return nil
+	}
+
+	if err := as.sendPasswordReset(ctx, u); err != nil {
+		log.Printf("Failed to send the password reset link to user %d: %v", u.ID, err)
+	}
+
+	return nil
+}
+
+func (as *authService) sendPasswordReset(ctx context.Context, u *models.User) error {
+	// Only the most recent link stays valid
+	if err := as.rt.InvalidateUserPasswordResetTokens(ctx, u.ID); err != nil {
+		return err
+	}
+
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	if err := as.rt.CreatePasswordResetToken(ctx, &models.PasswordResetToken{
+		UserID:    u.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().UTC().Add(as.opts.PasswordResetTTL),
+	}); err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", as.opts.ClientURL, token)
+	return as.opts.Mailer.Send(ctx, &mailer.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nWe received a request to reset your password. Open the link below to choose a new one:\n\n%s\n\nThe link expires in %s and can only be used once. If you did not request this, you can ignore this email.\n",
+			u.FullName, link, as.opts.PasswordResetTTL,
+		),
+	})
+}
+
+// ResetPassword redeems a reset token, sets the new password and signs the user
+// out everywhere. The token is only used up together with the password
+// change.
+func (as *authService) ResetPassword(ctx context.Context, data *requests.ResetPasswordRequest) error {
+	invalid := common.ErrorValidation.Clone().SetDetail("token", models.ErrPasswordResetTokenInvalid.Error())
+
+	record, err := as.rt.GetPasswordResetToken(ctx, map[string]interface{}{"token_hash": utils.HashToken(data.Token)})
+	if err != nil {
+		return invalid
+	}
+
+	if record.UsedAt != nil || time.Now().UTC().After(record.ExpiresAt) {
+		return invalid
+	}
+
+	u, err := as.es.FindByID(ctx, record.UserID)
+	if err != nil {
+		return invalid
+	}
+	if err := as.es.ValidateNewPassword(ctx, u, data.Password); err != nil {
+		return err
+	}
+
+	updates, err := newPasswordUpdates(data.Password)
+	if err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	consumed, err := as.rt.ResetUserPassword(ctx, record.ID, u.ID, updates, u.HashPassword, utils.CurrentPasswordPolicy().HistorySize)
+	if err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+	if !consumed {
+		return invalid
+	}
+
+	// Proving access to the mailbox lifts a lockout
+	if err := as.opts.LoginThrottle.Unlock(ctx, u.Email); err != nil {
+		return err
+	}
+
+	return as.RevokeSessions(ctx, record.UserID)
+}
+
+// ChangeExpiredPassword completes a login that stopped because the password
+// expired: it sets the new password, signs out the other sessions and issues
+// the token pair. The token is only handed out once the second factor, if
+// any, was checked, and is used up with the password change.
+func (as *authService) ChangeExpiredPassword(ctx context.Context, data *requests.ChangeExpiredPasswordRequest) (*models.Auth, error) {
+	invalid := common.ErrorUnauthorized.Clone().WrapMessage(models.ErrInvalidPasswordChangeToken.Error())
+
+	userID, claims, err := as.parseMFAClaims(data.PasswordChangeToken, purposePasswordChange)
+	if err != nil {
+		return nil, invalid
+	}
+
+	u, err := as.es.FindByID(ctx, userID)
+	if err != nil {
+		return nil, invalid
+	}
+
+	// A rejected password leaves the token usable for another attempt
+	if err := as.es.ValidateNewPassword(ctx, u, data.Password); err != nil {
+		return nil, err
+	}
+
+	used, err := as.useMFAToken(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, invalid
+	}
+
+	if err := as.es.UpdatePassword(ctx, userID, data.Password); err != nil {
+		return nil, err
+	}
+
+	if err := as.RevokeSessions(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	device, _ := claims["device"].(string)
+	return as.issueLoginTokens(ctx, u, device)
+}
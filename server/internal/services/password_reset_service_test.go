@@ -0,0 +1,180 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/vlahanam/company-management/internal/mailer"
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/requests"
+	"github.com/vlahanam/company-management/utils"
+)
+
+var resetLink = regexp.MustCompile(`/reset-password\?token=(\S+)`)
+
+// brokenWriter makes the log mailer fail like an unreachable SMTP relay.
+type brokenWriter struct{}
+
+func (brokenWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("connection refused")
+}
+
+func newPasswordResetTestService(t *testing.T, mail mailer.Mailer) (*authService, *fakeAuthRepo, *fakeLoginThrottle, *models.User) {
+	t.Helper()
+
+	hash, err := utils.HashPassword("Old-password-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := &models.User{SQLModel: models.SQLModel{ID: 1}, FullName: "Jane Doe", Email: "jane@example.com", HashPassword: hash}
+	users := &fakeUserRepo{users: []*models.User{u}}
+	repo := &fakeAuthRepo{users: users}
+	throttle := &fakeLoginThrottle{}
+	as := NewAuthService(NewUserService(users), repo, &AuthOptions{
+		Revocations:      fakeTokenRevoker{},
+		LoginThrottle:    throttle,
+		Mailer:           mail,
+		ClientURL:        "http://localhost:3000",
+		PasswordResetTTL: time.Hour,
+	})
+
+	return as, repo, throttle, u
+}
+
+// requestReset asks for a reset link and returns the token it carries.
+func requestReset(t *testing.T, as *authService, email string, mail *bytes.Buffer) string {
+	t.Helper()
+
+	if err := as.ForgotPassword(context.Background(), &requests.ForgotPasswordRequest{Email: email}); err != nil {
+		t.Fatalf("ForgotPassword() = %v", err)
+	}
+
+	match := resetLink.FindStringSubmatch(mail.String())
+	if match == nil {
+		t.Fatalf("no reset link in the mail:\n%s", mail)
+	}
+	mail.Reset()
+
+	return match[1]
+}
+
+func TestResetPassword(t *testing.T) {
+	ctx := context.Background()
+	var mail bytes.Buffer
+	as, repo, throttle, u := newPasswordResetTestService(t, mailer.NewLogMailer(&mail))
+
+	token := requestReset(t, as, u.Email, &mail)
+
+	if err := as.ResetPassword(ctx, &requests.ResetPasswordRequest{Token: token, Password: "New-password-2"}); err != nil {
+		t.Fatalf("ResetPassword() = %v", err)
+	}
+	if !utils.CheckPasswordHash("New-password-2", u.HashPassword) {
+		t.Error("the password was not changed")
+	}
+	if len(repo.revokedUsers) != 1 || len(throttle.unlocked) != 1 {
+		t.Errorf("revoked %v and unlocked %v, want the user signed out and unlocked", repo.revokedUsers, throttle.unlocked)
+	}
+
+	if err := as.ResetPassword(ctx, &requests.ResetPasswordRequest{Token: token, Password: "Other-password-3"}); err == nil {
+		t.Error("ResetPassword() accepted a used token")
+	}
+}
+
+// Only the newest link works, and only until it expires.
+func TestResetPasswordInvalidTokens(t *testing.T) {
+	ctx := context.Background()
+	var mail bytes.Buffer
+	as, repo, _, u := newPasswordResetTestService(t, mailer.NewLogMailer(&mail))
+
+	first := requestReset(t, as, u.Email, &mail)
+	second := requestReset(t, as, u.Email, &mail)
+
+	if err := as.ResetPassword(ctx, &requests.ResetPasswordRequest{Token: first, Password: "New-password-2"}); err == nil {
+		t.Error("ResetPassword() accepted a replaced token")
+	}
+
+	repo.resetTokens[1].ExpiresAt = time.Now().UTC().Add(-time.Minute)
+	if err := as.ResetPassword(ctx, &requests.ResetPasswordRequest{Token: second, Password: "New-password-2"}); err == nil {
+		t.Error("ResetPassword() accepted an expired token")
+	}
+
+	if err := as.ResetPassword(ctx, &requests.ResetPasswordRequest{Token: "unknown", Password: "New-password-2"}); err == nil {
+		t.Error("ResetPassword() accepted an unknown token")
+	}
+}
+
+// A password the policy rejects does not use up the link.
+func TestResetPasswordRejectedPasswordKeepsToken(t *testing.T) {
+	ctx := context.Background()
+	var mail bytes.Buffer
+	as, _, _, u := newPasswordResetTestService(t, mailer.NewLogMailer(&mail))
+
+	token := requestReset(t, as, u.Email, &mail)
+
+	for _, password := range []string{"short", "Old-password-1"} {
+		if err := as.ResetPassword(ctx, &requests.ResetPasswordRequest{Token: token, Password: password}); err == nil {
+			t.Errorf("ResetPassword(%q) was accepted", password)
+		}
+	}
+
+	if err := as.ResetPassword(ctx, &requests.ResetPasswordRequest{Token: token, Password: "New-password-2"}); err != nil {
+		t.Fatalf("ResetPassword() = %v", err)
+	}
+}
+
+// The password change token of an expired login sets the password once; a
+// replay can neither set it again nor sign in.
+func TestChangeExpiredPasswordTokenIsSingleUse(t *testing.T) {
+	ctx := context.Background()
+	as, _, _, u := newPasswordResetTestService(t, mailer.NewLogMailer(&bytes.Buffer{}))
+	as.opts.Revocations = NewTokenRevocationService(&fakeTokenRevocationRepo{}, time.Hour)
+	as.opts.AccessKeys = utils.NewHMACKeySet("access-secret")
+	as.opts.RefreshKeys = utils.NewHMACKeySet("refresh-secret")
+	as.opts.VerifySecret = "verify-secret"
+
+	token, err := as.signMFAToken(u.ID, purposePasswordChange, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A password the policy rejects does not use up the token
+	if _, err := as.ChangeExpiredPassword(ctx, &requests.ChangeExpiredPasswordRequest{PasswordChangeToken: token, Password: "Old-password-1"}); err == nil {
+		t.Fatal("ChangeExpiredPassword() accepted the current password")
+	}
+
+	if _, err := as.ChangeExpiredPassword(ctx, &requests.ChangeExpiredPasswordRequest{PasswordChangeToken: token, Password: "New-password-2"}); err != nil {
+		t.Fatalf("ChangeExpiredPassword() = %v", err)
+	}
+
+	auth, err := as.ChangeExpiredPassword(ctx, &requests.ChangeExpiredPasswordRequest{PasswordChangeToken: token, Password: "Other-password-3"})
+	if err == nil || auth != nil {
+		t.Fatal("ChangeExpiredPassword() accepted a used token")
+	}
+	if !utils.CheckPasswordHash("New-password-2", u.HashPassword) {
+		t.Error("the replayed token changed the password")
+	}
+}
+
+// Unknown emails and mail failures get the same answer as a sent link.
+func TestForgotPasswordDoesNotRevealAccounts(t *testing.T) {
+	ctx := context.Background()
+
+	var mail bytes.Buffer
+	as, repo, _, _ := newPasswordResetTestService(t, mailer.NewLogMailer(&mail))
+	if err := as.ForgotPassword(ctx, &requests.ForgotPasswordRequest{Email: "nobody@example.com"}); err != nil {
+		t.Errorf("ForgotPassword(unknown) = %v", err)
+	}
+	if mail.Len() != 0 || len(repo.resetTokens) != 0 {
+		t.Error("an unknown email got a reset link")
+	}
+
+	as, _, _, u := newPasswordResetTestService(t, mailer.NewLogMailer(brokenWriter{}))
+	if err := as.ForgotPassword(ctx, &requests.ForgotPasswordRequest{Email: u.Email}); err != nil {
+		t.Errorf("ForgotPassword() with a failing mailer = %v", err)
+	}
+}
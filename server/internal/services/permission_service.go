@@ -33,6 +33,12 @@ func (s *permissionService) CreatePermission(ctx context.Context, data *requests
 		Description: data.Description,
 		CreatedAt:   &now,
 	}
+	if data.Code != "" {
+		if err := s.checkCodeFree(ctx, data.Code, 0); err != nil {
+			return nil, err
+		}
+		permission.Code = &data.Code
+	}
 
 	if err := s.repo.CreatePermission(ctx, permission); err != nil {
 		return nil, common.ErrorCreateFailed.Clone().WrapErrorSafe(err)
@@ -70,6 +76,12 @@ func (s *permissionService) UpdatePermission(ctx context.Context, id int64, data
 
 	// Build update map with only non-nil fields
 	updates := make(map[string]interface{})
+	if data.Code != nil {
+		if err := s.checkCodeFree(ctx, *data.Code, id); err != nil {
+			return err
+		}
+		updates["code"] = *data.Code
+	}
 	if data.Name != nil {
 		updates["name"] = *data.Name
 	}
@@ -101,3 +113,12 @@ func (s *permissionService) DeletePermission(ctx context.Context, id int64) erro
 
 	return nil
 }
+
+// checkCodeFree makes sure no permission other than id has the code.
+func (s *permissionService) checkCodeFree(ctx context.Context, code string, id int64) error {
+	if existing, _ := s.repo.GetPermission(ctx, map[string]interface{}{"code": code}); existing != nil && existing.ID != id {
+		return common.ErrorValidation.Clone().SetDetail("code", models.ErrPermissionCodeExists.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,332 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"time"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/dto"
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/policy"
+	"github.com/vlahanam/company-management/internal/requests"
+)
+
+type PolicyRepo interface {
+	CreatePolicy(ctx context.Context, data *models.Policy) error
+	GetPolicy(ctx context.Context, data map[string]interface{}) (*models.Policy, error)
+	GetPolicies(ctx context.Context, enabledOnly bool) ([]*models.Policy, error)
+	UpdatePolicy(ctx context.Context, data *models.Policy) error
+	DeletePolicy(ctx context.Context, id int64) error
+	GetContract(ctx context.Context, data map[string]interface{}) (*models.Contract, error)
+	GetUserCompanyIDs(ctx context.Context, userID uint64) ([]uint64, error)
+	GetUserRoleIDsInCompanies(ctx context.Context, userID uint64, companyIDs []uint64) ([]int64, error)
+	GetManagedUserIDs(ctx context.Context, managerID uint64) ([]uint64, error)
+}
+
+type policyService struct {
+	repo PolicyRepo
+	sa   *serviceAccountService
+}
+
+// NewPolicyService uses sa to find the permissions of users and API keys,
+// which the policies see as subject attributes.
+func NewPolicyService(repo PolicyRepo, sa *serviceAccountService) *policyService {
+	return &policyService{repo: repo, sa: sa}
+}
+
+func (s *policyService) ListPolicies(ctx context.Context) ([]*models.Policy, error) {
+	policies, err := s.repo.GetPolicies(ctx, false)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return policies, nil
+}
+
+func (s *policyService) FindByID(ctx context.Context, id int64) (*models.Policy, error) {
+	p, err := s.repo.GetPolicy(ctx, map[string]interface{}{"id": id})
+	if err != nil {
+		if errors.Is(err, models.ErrPolicyNotFound) {
+			return nil, common.ErrorNotFound.Clone().WrapMessage(err.Error())
+		}
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return p, nil
+}
+
+func (s *policyService) CreatePolicy(ctx context.Context, data *requests.CreatePolicyRequest) (*models.Policy, error) {
+	now := time.Now().UTC()
+	p := &models.Policy{
+		Name:        data.Name,
+		Description: data.Description,
+		Effect:      data.Effect,
+		Resource:    data.Resource,
+		Actions:     data.Actions,
+		Conditions:  data.Conditions,
+		Enabled:     data.Enabled == nil || *data.Enabled,
+		CreatedAt:   &now,
+		UpdatedAt:   &now,
+	}
+	if err := s.checkPolicy(ctx, p); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreatePolicy(ctx, p); err != nil {
+		return nil, common.ErrorCreateFailed.Clone().WrapErrorSafe(err)
+	}
+
+	return p, nil
+}
+
+func (s *policyService) UpdatePolicy(ctx context.Context, id int64, data *requests.UpdatePolicyRequest) (*models.Policy, error) {
+	p, err := s.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if data.Name != nil {
+		p.Name = *data.Name
+	}
+	if data.Description != nil {
+		p.Description = *data.Description
+	}
+	if data.Effect != nil {
+		p.Effect = *data.Effect
+	}
+	if data.Resource != nil {
+		p.Resource = *data.Resource
+	}
+	if data.Actions != nil {
+		p.Actions = *data.Actions
+	}
+	if data.Conditions != nil {
+		p.Conditions = *data.Conditions
+	}
+	if data.Enabled != nil {
+		p.Enabled = *data.Enabled
+	}
+
+	if err := s.checkPolicy(ctx, p); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	p.UpdatedAt = &now
+	if err := s.repo.UpdatePolicy(ctx, p); err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return p, nil
+}
+
+func (s *policyService) DeletePolicy(ctx context.Context, id int64) error {
+	if _, err := s.FindByID(ctx, id); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeletePolicy(ctx, id); err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return nil
+}
+
+// Authorize evaluates the active rules for the principal taking the action
+// on the resource. Only a deny decides; anything else leaves the decision to
+// the role permissions.
+func (s *policyService) Authorize(ctx context.Context, principalUID, resourceType string, resourceID uint64, action string) (*policy.Result, error) {
+	rules, err := s.rules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(rules) == 0 {
+		return &policy.Result{Decision: policy.DecisionNotApplicable, Trace: []policy.RuleTrace{}}, nil
+	}
+
+	req, err := s.buildRequest(ctx, principalUID, resourceType, resourceID, action)
+	if err != nil {
+		return nil, err
+	}
+
+	result := policy.Evaluate(rules, *req)
+	return &result, nil
+}
+
+// Evaluate is a dry run of Authorize: nothing is acted on, attributes can be
+// overridden and candidate rules tried in place of the active ones.
+func (s *policyService) Evaluate(ctx context.Context, actorUID string, data *requests.EvaluatePolicyRequest) (*dto.PolicyEvaluation, error) {
+	for _, rule := range data.Rules {
+		if err := rule.Validate(); err != nil {
+			return nil, common.ErrorValidation.Clone().SetDetail("rules", err.Error())
+		}
+	}
+
+	subjectUID := data.SubjectID
+	if subjectUID == "" {
+		subjectUID = actorUID
+	}
+	if _, err := common.FromBase58(subjectUID); err != nil {
+		return nil, common.ErrorValidation.Clone().SetDetail("subject_id", "invalid id format")
+	}
+
+	var resourceID uint64
+	if data.ResourceID != "" {
+		uid, err := common.FromBase58(data.ResourceID)
+		if err != nil {
+			return nil, common.ErrorValidation.Clone().SetDetail("resource_id", "invalid id format")
+		}
+		resourceID = uint64(uid.GetLocalID())
+
+		if data.ResourceType == policy.ResourceContract {
+			if _, err := s.repo.GetContract(ctx, map[string]interface{}{"id": resourceID}); err != nil {
+				return nil, common.ErrorNotFound.Clone().WrapMessage(models.ErrContractNotFound.Error())
+			}
+		}
+	}
+
+	req, err := s.buildRequest(ctx, subjectUID, data.ResourceType, resourceID, data.Action)
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(req.Subject, data.Subject)
+	maps.Copy(req.Resource, data.Resource)
+
+	rules := data.Rules
+	if rules == nil {
+		if rules, err = s.rules(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	result := policy.Evaluate(rules, *req)
+	return &dto.PolicyEvaluation{Request: *req, Result: &result}, nil
+}
+
+// rules returns the rules of the configuration followed by the enabled
+// policies of the database.
+func (s *policyService) rules(ctx context.Context) ([]*policy.Rule, error) {
+	stored, err := s.repo.GetPolicies(ctx, true)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	rules := append([]*policy.Rule{}, policy.Configured()...)
+	for _, p := range stored {
+		rules = append(rules, p.Rule())
+	}
+
+	return rules, nil
+}
+
+func (s *policyService) checkPolicy(ctx context.Context, p *models.Policy) error {
+	if err := p.Rule().Validate(); err != nil {
+		return common.ErrorValidation.Clone().SetDetail("conditions", err.Error())
+	}
+
+	if existing, _ := s.repo.GetPolicy(ctx, map[string]interface{}{"name": p.Name}); existing != nil && existing.ID != p.ID {
+		return common.ErrorValidation.Clone().SetDetail("name", models.ErrPolicyNameExists.Error())
+	}
+
+	return nil
+}
+
+// buildRequest looks up the attributes of the resource, then those of the
+// subject in the companies the resource belongs to. A resourceID of 0 leaves
+// the resource attributes empty, and a missing resource has only its id.
+func (s *policyService) buildRequest(ctx context.Context, principalUID, resourceType string, resourceID uint64, action string) (*policy.Request, error) {
+	resource := policy.Attributes{}
+	var companyIDs []uint64
+
+	if resourceID != 0 {
+		switch resourceType {
+		case policy.ResourceUser:
+			ids, err := s.repo.GetUserCompanyIDs(ctx, resourceID)
+			if err != nil {
+				return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+			}
+			resource["id"] = resourceID
+			resource["company_ids"] = ids
+			companyIDs = ids
+		case policy.ResourceContract:
+			resource["id"] = resourceID
+			contract, err := s.repo.GetContract(ctx, map[string]interface{}{"id": resourceID})
+			if errors.Is(err, models.ErrContractNotFound) {
+				// Nothing to decide on; the handler reports the contract missing
+				break
+			}
+			if err != nil {
+				return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+			}
+			resource["user_id"] = contract.UserID
+			resource["company_id"] = contract.CompanyID
+			resource["status"] = contract.Status
+			resource["contract_type"] = contract.ContractType
+			if contract.PositionID != nil {
+				resource["position_id"] = *contract.PositionID
+			}
+			companyIDs = []uint64{contract.CompanyID}
+		}
+	}
+
+	subject, err := s.subjectAttributes(ctx, principalUID, companyIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &policy.Request{
+		Subject:      subject,
+		ResourceType: resourceType,
+		Resource:     resource,
+		Action:       action,
+	}, nil
+}
+
+// subjectAttributes describes the user or API key. Roles and permissions are
+// those that apply in the companies, everywhere when there are none.
+func (s *policyService) subjectAttributes(ctx context.Context, principalUID string, companyIDs []uint64) (policy.Attributes, error) {
+	uid, err := common.FromBase58(principalUID)
+	if err != nil {
+		return nil, common.ErrorUnauthorized.Clone().WrapError(err)
+	}
+
+	permissionIDs, err := s.sa.GetPermissionIDsInCompanies(ctx, principalUID, companyIDs)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	if uid.GetObjectType() == common.ObjectTypeAPIKey {
+		return policy.Attributes{
+			"api_key":        true,
+			"permission_ids": permissionIDs,
+		}, nil
+	}
+
+	userID := uint64(uid.GetLocalID())
+
+	roleIDs, err := s.repo.GetUserRoleIDsInCompanies(ctx, userID, companyIDs)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	ownCompanyIDs, err := s.repo.GetUserCompanyIDs(ctx, userID)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	reportIDs, err := s.repo.GetManagedUserIDs(ctx, userID)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return policy.Attributes{
+		"user_id":           userID,
+		"api_key":           false,
+		"role_ids":          roleIDs,
+		"permission_ids":    permissionIDs,
+		"company_ids":       ownCompanyIDs,
+		"direct_report_ids": reportIDs,
+	}, nil
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/dto"
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/requests"
+	"github.com/vlahanam/company-management/utils"
+)
+
+// GetOwnProfile returns the caller's account with their roles, effective
+// permissions and current primary position.
+func (as *authService) GetOwnProfile(ctx context.Context, userUID string) (*dto.Profile, error) {
+	u, err := as.findByUID(ctx, userUID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := as.es.er.GetUserRoles(ctx, u.ID)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	permissions, err := as.es.er.GetUserPermissions(ctx, u.ID)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	position, err := as.es.er.GetPrimaryUserPosition(ctx, u.ID)
+	if err != nil && !errors.Is(err, models.ErrUserPositionNotFound) {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return &dto.Profile{
+		User:            u,
+		Roles:           roles,
+		Permissions:     permissions,
+		PrimaryPosition: position,
+	}, nil
+}
+
+// UpdateOwnProfile changes the personal details of the caller.
+func (as *authService) UpdateOwnProfile(ctx context.Context, userUID string, data *requests.UpdateProfileRequest) error {
+	userID, err := userIDFromUID(userUID)
+	if err != nil {
+		return err
+	}
+
+	return as.es.UpdateUser(ctx, userID, &requests.UpdateUserRequest{
+		FullName:    data.FullName,
+		DateOfBirth: data.DateOfBirth,
+		Gender:      data.Gender,
+		PhoneNumber: data.PhoneNumber,
+		Avatar:      data.Avatar,
+	})
+}
+
+// ChangeOwnPassword sets a new password for the caller once the current one
+// is confirmed, and signs out every other session. Wrong current passwords
+// count towards the login throttle so a stolen access token cannot be used
+// to guess it.
+func (as *authService) ChangeOwnPassword(ctx context.Context, userUID, sessionID string, data *requests.ChangePasswordRequest) error {
+	u, err := as.findByUID(ctx, userUID)
+	if err != nil {
+		return err
+	}
+
+	if err := as.opts.LoginThrottle.Check(ctx, u.Email, data.IP); err != nil {
+		return err
+	}
+
+	if !utils.CheckPasswordHash(data.CurrentPassword, u.HashPassword) {
+		if err := as.opts.LoginThrottle.RecordFailure(ctx, u.Email, data.IP); err != nil {
+			return err
+		}
+		return common.ErrorValidation.Clone().SetDetail("current_password", models.ErrInvalidPassword.Error())
+	}
+
+	if err := as.opts.LoginThrottle.RecordSuccess(ctx, u.Email); err != nil {
+		return err
+	}
+
+	if err := as.es.UpdatePassword(ctx, u.ID, data.NewPassword); err != nil {
+		return err
+	}
+
+	return as.RevokeOtherSessions(ctx, u.ID, sessionID)
+}
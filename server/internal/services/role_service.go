@@ -2,39 +2,69 @@ package services
 
 import (
 	"context"
+	"errors"
+	"slices"
 	"time"
 
 	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/dto"
 	"github.com/vlahanam/company-management/internal/models"
 	"github.com/vlahanam/company-management/internal/requests"
+	"github.com/vlahanam/company-management/utils"
 )
 
 type RoleRepo interface {
-	CreateRole(ctx context.Context, data *models.Role) error
+	CreateRoleWithParents(ctx context.Context, data *models.Role, parentIDs []int64, held []int64) error
 	GetRole(ctx context.Context, data map[string]interface{}) (*models.Role, error)
 	GetAllRolesWithPagination(ctx context.Context, limit, offset int) ([]*models.Role, error)
 	CountRoles(ctx context.Context) (int64, error)
 	UpdateRole(ctx context.Context, id int64, data map[string]interface{}) error
 	DeleteRole(ctx context.Context, id int64) error
+	GetRolePermissions(ctx context.Context, roleID int64) ([]*models.Permission, error)
+	GrantRolePermissions(ctx context.Context, roleID int64, permissionIDs []int64) error
+	RevokeRolePermissions(ctx context.Context, roleID int64, permissionIDs []int64) error
+	GetPermissionsByIDs(ctx context.Context, ids []int64) ([]*models.Permission, error)
+	GetRolesByIDs(ctx context.Context, ids []int64) ([]*models.Role, error)
+	GetRoleParents(ctx context.Context, roleID int64) ([]*models.Role, error)
+	GetInheritedRolePermissions(ctx context.Context, roleID int64) ([]*models.Permission, error)
+	SetRoleParents(ctx context.Context, roleID int64, parentIDs []int64, held []int64) error
 }
 
 type roleService struct {
-	repo RoleRepo
+	repo  RoleRepo
+	perms utils.PermissionResolver
 }
 
-func NewRoleService(repo RoleRepo) *roleService {
-	return &roleService{repo: repo}
+// NewRoleService uses perms to find what the caller holds, since a role can
+// only be granted permissions, directly or by inheritance, its grantor holds.
+func NewRoleService(repo RoleRepo, perms utils.PermissionResolver) *roleService {
+	return &roleService{repo: repo, perms: perms}
 }
 
-func (s *roleService) CreateRole(ctx context.Context, data *requests.CreateRoleRequest) (*models.Role, error) {
+// CreateRole creates the role and makes it inherit from the parents, which
+// the caller must be able to grant.
+func (s *roleService) CreateRole(ctx context.Context, actorUID string, data *requests.CreateRoleRequest) (*models.Role, error) {
+	parentIDs := slices.Compact(slices.Sorted(slices.Values(data.ParentIDs)))
+	held, err := s.checkParents(ctx, actorUID, parentIDs)
+	if err != nil {
+		return nil, err
+	}
+
 	now := time.Now().UTC()
 	role := &models.Role{
 		Name:        data.Name,
 		Description: data.Description,
+		RequireMFA:  data.RequireMFA,
 		CreatedAt:   &now,
 	}
+	if data.PasswordMaxAgeDays > 0 {
+		role.PasswordMaxAgeDays = &data.PasswordMaxAgeDays
+	}
 
-	if err := s.repo.CreateRole(ctx, role); err != nil {
+	if err := s.repo.CreateRoleWithParents(ctx, role, parentIDs, held); err != nil {
+		if errors.Is(err, models.ErrPermissionGrantNotHeld) {
+			return nil, common.ErrorValidation.Clone().SetDetail("parent_ids", err.Error())
+		}
 		return nil, common.ErrorCreateFailed.Clone().WrapErrorSafe(err)
 	}
 
@@ -61,28 +91,64 @@ func (s *roleService) GetListRolesWithPagination(ctx context.Context, data reque
 	return roles, nil
 }
 
-func (s *roleService) UpdateRole(ctx context.Context, id int64, data *requests.UpdateRoleRequest) error {
+// UpdateRole changes the role. Built-in roles keep their names. New parents
+// must not make the role inherit from itself, and the caller must be able to
+// grant them.
+func (s *roleService) UpdateRole(ctx context.Context, actorUID string, id int64, data *requests.UpdateRoleRequest) error {
 	// Check if role exists
-	_, err := s.FindByID(ctx, id)
+	role, err := s.FindByID(ctx, id)
 	if err != nil {
 		return common.ErrorNotFound.Clone().WrapMessage("role not found")
 	}
 
 	// Build update map with only non-nil fields
 	updates := make(map[string]interface{})
-	if data.Name != nil {
+	if data.Name != nil && *data.Name != role.Name {
+		// Access tokens and the registry sync know them by name
+		if _, builtIn := models.RoleNames[id]; builtIn {
+			return common.ErrorValidation.Clone().SetDetail("name", models.ErrBuiltInRoleRename.Error())
+		}
 		updates["name"] = *data.Name
 	}
 	if data.Description != nil {
 		updates["description"] = *data.Description
 	}
+	if data.RequireMFA != nil {
+		updates["require_mfa"] = *data.RequireMFA
+	}
+	if data.PasswordMaxAgeDays != nil {
+		if *data.PasswordMaxAgeDays == 0 {
+			updates["password_max_age_days"] = nil
+		} else {
+			updates["password_max_age_days"] = *data.PasswordMaxAgeDays
+		}
+	}
 
-	if len(updates) == 0 {
+	if len(updates) == 0 && data.ParentIDs == nil {
 		return common.ErrorValidation.Clone().WrapMessage("no fields to update")
 	}
 
-	if err := s.repo.UpdateRole(ctx, id, updates); err != nil {
-		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	var parentIDs, held []int64
+	if data.ParentIDs != nil {
+		parentIDs = slices.Compact(slices.Sorted(slices.Values(*data.ParentIDs)))
+		if held, err = s.checkParents(ctx, actorUID, parentIDs); err != nil {
+			return err
+		}
+	}
+
+	if len(updates) > 0 {
+		if err := s.repo.UpdateRole(ctx, id, updates); err != nil {
+			return common.ErrorInternal.Clone().WrapErrorSafe(err)
+		}
+	}
+
+	if data.ParentIDs != nil {
+		if err := s.repo.SetRoleParents(ctx, id, parentIDs, held); err != nil {
+			if errors.Is(err, models.ErrRoleInheritanceCycle) || errors.Is(err, models.ErrPermissionGrantNotHeld) {
+				return common.ErrorValidation.Clone().SetDetail("parent_ids", err.Error())
+			}
+			return common.ErrorInternal.Clone().WrapErrorSafe(err)
+		}
 	}
 
 	return nil
@@ -101,3 +167,122 @@ func (s *roleService) DeleteRole(ctx context.Context, id int64) error {
 
 	return nil
 }
+
+// GetRolePermissions returns the role with its parents, the permissions
+// granted to it and those it inherits.
+func (s *roleService) GetRolePermissions(ctx context.Context, id int64) (*dto.RolePermissions, error) {
+	role, err := s.FindByID(ctx, id)
+	if err != nil {
+		return nil, common.ErrorNotFound.Clone().WrapMessage(models.ErrRoleNotFound.Error())
+	}
+
+	parents, err := s.repo.GetRoleParents(ctx, id)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	permissions, err := s.repo.GetRolePermissions(ctx, id)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	inherited, err := s.repo.GetInheritedRolePermissions(ctx, id)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return &dto.RolePermissions{
+		Role:                 role,
+		Parents:              parents,
+		Permissions:          permissions,
+		InheritedPermissions: inherited,
+	}, nil
+}
+
+// GrantPermissions grants the permissions to the role. The caller must hold
+// every one of them, so managing roles is not a way to gain permissions.
+func (s *roleService) GrantPermissions(ctx context.Context, actorUID string, id int64, permissionIDs []int64) (*dto.RolePermissions, error) {
+	if _, err := s.FindByID(ctx, id); err != nil {
+		return nil, common.ErrorNotFound.Clone().WrapMessage(models.ErrRoleNotFound.Error())
+	}
+
+	permissionIDs = slices.Compact(slices.Sorted(slices.Values(permissionIDs)))
+	if err := s.checkPermissionsExist(ctx, permissionIDs); err != nil {
+		return nil, err
+	}
+
+	held, err := s.perms.GetPermissionIDsByUserUID(ctx, actorUID)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+	for _, p := range permissionIDs {
+		if !slices.Contains(held, p) {
+			return nil, common.ErrorValidation.Clone().SetDetail("permission_ids", models.ErrPermissionGrantNotHeld.Error())
+		}
+	}
+
+	if err := s.repo.GrantRolePermissions(ctx, id, permissionIDs); err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return s.GetRolePermissions(ctx, id)
+}
+
+// RevokePermissions takes the permissions away from the role. Super Admin
+// keeps every permission, so that someone can always manage roles.
+func (s *roleService) RevokePermissions(ctx context.Context, id int64, permissionIDs []int64) (*dto.RolePermissions, error) {
+	if _, err := s.FindByID(ctx, id); err != nil {
+		return nil, common.ErrorNotFound.Clone().WrapMessage(models.ErrRoleNotFound.Error())
+	}
+
+	if id == models.RoleSuperAdmin {
+		return nil, common.ErrorValidation.Clone().SetDetail("id", models.ErrSuperAdminGrantsLocked.Error())
+	}
+
+	permissionIDs = slices.Compact(slices.Sorted(slices.Values(permissionIDs)))
+	if err := s.checkPermissionsExist(ctx, permissionIDs); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.RevokeRolePermissions(ctx, id, permissionIDs); err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return s.GetRolePermissions(ctx, id)
+}
+
+// checkParents makes sure the parents exist and returns the permissions the
+// caller holds. The parents may not carry others; the repository checks that,
+// and that they do not inherit from the role, where it sets them.
+func (s *roleService) checkParents(ctx context.Context, actorUID string, parentIDs []int64) ([]int64, error) {
+	if len(parentIDs) == 0 {
+		return nil, nil
+	}
+
+	parents, err := s.repo.GetRolesByIDs(ctx, parentIDs)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+	if len(parents) != len(parentIDs) {
+		return nil, common.ErrorValidation.Clone().SetDetail("parent_ids", models.ErrRoleNotFound.Error())
+	}
+
+	held, err := s.perms.GetPermissionIDsByUserUID(ctx, actorUID)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return held, nil
+}
+
+func (s *roleService) checkPermissionsExist(ctx context.Context, permissionIDs []int64) error {
+	permissions, err := s.repo.GetPermissionsByIDs(ctx, permissionIDs)
+	if err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+	if len(permissions) != len(permissionIDs) {
+		return common.ErrorValidation.Clone().SetDetail("permission_ids", models.ErrPermissionNotFound.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/requests"
+)
+
+// fakeRoleRepo keeps roles in memory.
+type fakeRoleRepo struct {
+	RoleRepo
+	roles map[int64]*models.Role
+}
+
+func (r *fakeRoleRepo) GetRole(ctx context.Context, data map[string]interface{}) (*models.Role, error) {
+	role, ok := r.roles[data["id"].(int64)]
+	if !ok {
+		return nil, models.ErrRoleNotFound
+	}
+
+	return role, nil
+}
+
+func (r *fakeRoleRepo) UpdateRole(ctx context.Context, id int64, data map[string]interface{}) error {
+	if name, ok := data["name"].(string); ok {
+		r.roles[id].Name = name
+	}
+
+	return nil
+}
+
+// A role manager cannot pass a role off as Super Admin by renaming it.
+func TestUpdateRoleName(t *testing.T) {
+	const custom int64 = 42
+
+	tests := []struct {
+		name    string
+		id      int64
+		newName string
+		wantErr bool
+	}{
+		{"built-in role", models.RoleHRManager, models.RoleNames[models.RoleSuperAdmin], true},
+		{"Super Admin", models.RoleSuperAdmin, "Root", true},
+		{"built-in role, same name", models.RoleHRManager, models.RoleNames[models.RoleHRManager], false},
+		{"custom role", custom, "Payroll", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRoleRepo{roles: map[int64]*models.Role{
+				models.RoleSuperAdmin: {ID: models.RoleSuperAdmin, Name: models.RoleNames[models.RoleSuperAdmin]},
+				models.RoleHRManager:  {ID: models.RoleHRManager, Name: models.RoleNames[models.RoleHRManager]},
+				custom:                {ID: custom, Name: "Offboarding"},
+			}}
+			s := NewRoleService(repo, nil)
+
+			description := "updated"
+			err := s.UpdateRole(context.Background(), "", tt.id, &requests.UpdateRoleRequest{Name: &tt.newName, Description: &description})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UpdateRole() = %v, want error %v", err, tt.wantErr)
+			}
+			if renamed := repo.roles[tt.id].Name == tt.newName; renamed == tt.wantErr {
+				t.Errorf("role %d is named %q", tt.id, repo.roles[tt.id].Name)
+			}
+		})
+	}
+}
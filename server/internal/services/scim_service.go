@@ -0,0 +1,764 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/mail"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/scim"
+	"github.com/vlahanam/company-management/utils"
+)
+
+type SCIMRepo interface {
+	CreateUser(ctx context.Context, data *models.User) error
+	GetUser(ctx context.Context, data map[string]interface{}) (*models.User, error)
+	UpdateUser(ctx context.Context, id uint64, data map[string]interface{}) error
+	ChangeUserPassword(ctx context.Context, userID uint64, data map[string]interface{}, oldHash string, keep int) error
+	GetUserRoles(ctx context.Context, userID uint64) ([]*models.UserRole, error)
+	GetUsersByIDs(ctx context.Context, ids []uint64) ([]*models.User, error)
+	GetSCIMUsers(ctx context.Context, filter scim.Filter, limit, offset int) ([]*models.User, int64, error)
+	GetUsersGlobalRoles(ctx context.Context, userIDs []uint64) (map[uint64][]*models.Role, error)
+	CreateRole(ctx context.Context, data *models.Role) error
+	GetRole(ctx context.Context, data map[string]interface{}) (*models.Role, error)
+	UpdateRole(ctx context.Context, id int64, data map[string]interface{}) error
+	DeleteRole(ctx context.Context, id int64) error
+	GetSCIMGroups(ctx context.Context, filter scim.Filter, limit, offset int) ([]*models.Role, int64, error)
+	GetRoleMembers(ctx context.Context, roleIDs []int64) (map[int64][]*models.User, error)
+	AddRoleMembers(ctx context.Context, roleID int64, userIDs []uint64) error
+	RemoveRoleMembers(ctx context.Context, roleID int64, userIDs []uint64) error
+}
+
+// scimService provisions users and groups for an identity provider. Users are
+// accounts, groups are roles, and group members hold the role in every
+// company. Deprovisioning deactivates an account instead of deleting it.
+type scimService struct {
+	as   *authService
+	repo SCIMRepo
+}
+
+func NewSCIMService(as *authService, repo SCIMRepo) *scimService {
+	return &scimService{
+		as:   as,
+		repo: repo,
+	}
+}
+
+func (s *scimService) ListUsers(ctx context.Context, data *scim.ListRequest) (*scim.ListResponse, error) {
+	data.Process()
+
+	filter, err := scim.ParseFilter(data.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	users, total, err := s.repo.GetSCIMUsers(ctx, filter, data.Count, data.Offset())
+	if err != nil {
+		return nil, scimRepoError(err)
+	}
+
+	resources, err := s.toSCIMUsers(ctx, users)
+	if err != nil {
+		return nil, err
+	}
+
+	return &scim.ListResponse{
+		Schemas:      []string{scim.SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   data.StartIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}, nil
+}
+
+func (s *scimService) GetUser(ctx context.Context, id string) (*scim.User, error) {
+	u, err := s.findUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.toSCIMUser(ctx, u)
+}
+
+// CreateUser provisions a verified account. Without a password the account
+// gets a random one, so the user signs in through single sign-on or resets
+// it.
+func (s *scimService) CreateUser(ctx context.Context, data *scim.User) (*scim.User, error) {
+	email, fullName, err := validateSCIMUser(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkUniqueUser(ctx, 0, email, data.ExternalID); err != nil {
+		return nil, err
+	}
+
+	password := data.Password
+	if password == "" {
+		if password, err = utils.GenerateRandomToken(32); err != nil {
+			return nil, scimInternal(err)
+		}
+	} else if err := utils.CheckPasswordPolicy(password, email, fullName); err != nil {
+		return nil, scim.BadRequest(scim.ErrInvalidValue, "password: %s", err)
+	}
+
+	hash, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, scimInternal(err)
+	}
+
+	// The identity provider vouches for the address
+	now := time.Now().UTC()
+	u := &models.User{
+		SQLModel:          models.NewSQLModel(),
+		ExternalID:        optionalString(data.ExternalID),
+		FullName:          fullName,
+		Email:             email,
+		HashPassword:      hash,
+		PasswordChangedAt: &now,
+		EmailVerifiedAt:   &now,
+		PhoneNumber:       scimPhoneNumber(data),
+	}
+	if data.Active != nil && !*data.Active {
+		u.DeactivatedAt = &now
+	}
+
+	if err := s.repo.CreateUser(ctx, u); err != nil {
+		return nil, scimInternal(err)
+	}
+
+	return s.toSCIMUser(ctx, u)
+}
+
+// ReplaceUser overwrites the attributes of a user. An omitted active
+// attribute leaves the account as it is.
+func (s *scimService) ReplaceUser(ctx context.Context, id string, data *scim.User) (*scim.User, error) {
+	u, err := s.findProvisionedUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.replaceUser(ctx, u, data); err != nil {
+		return nil, err
+	}
+
+	return s.GetUser(ctx, id)
+}
+
+// PatchUser applies the operations to the user as it is now, then stores the
+// result like ReplaceUser.
+func (s *scimService) PatchUser(ctx context.Context, id string, data *scim.PatchRequest) (*scim.User, error) {
+	if err := data.Validate(); err != nil {
+		return nil, err
+	}
+
+	u, err := s.findProvisionedUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.toSCIMUser(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := scim.Apply(current, data.Operations); err != nil {
+		return nil, err
+	}
+
+	// An email patched on its own replaces the stored address, like userName
+	if email := current.PrimaryEmail(); email != "" && email != u.Email && current.UserName == u.Email {
+		current.UserName = email
+	}
+
+	// Name parts patched on their own replace the stored name
+	if n := current.Name; n != nil && (n.GivenName != "" || n.FamilyName != "") && n.Formatted == u.FullName {
+		n.Formatted = ""
+	}
+	if name := current.Name.Full(); name != "" && current.DisplayName == u.FullName {
+		current.DisplayName = name
+	}
+
+	if err := s.replaceUser(ctx, u, current); err != nil {
+		return nil, err
+	}
+
+	return s.GetUser(ctx, id)
+}
+
+// DeactivateUser deprovisions a user: the account is kept, with its history,
+// but can no longer sign in and its sessions end.
+func (s *scimService) DeactivateUser(ctx context.Context, id string) error {
+	u, err := s.findProvisionedUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return s.setActive(ctx, u, false)
+}
+
+func (s *scimService) replaceUser(ctx context.Context, u *models.User, data *scim.User) error {
+	email, fullName, err := validateSCIMUser(data)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkUniqueUser(ctx, u.ID, email, data.ExternalID); err != nil {
+		return err
+	}
+
+	if data.Password != "" {
+		if err := utils.CheckPasswordPolicy(data.Password, email, fullName); err != nil {
+			return scim.BadRequest(scim.ErrInvalidValue, "password: %s", err)
+		}
+	}
+
+	now := time.Now().UTC()
+	updates := map[string]interface{}{
+		"email":        email,
+		"full_name":    fullName,
+		"external_id":  optionalString(data.ExternalID),
+		"phone_number": scimPhoneNumber(data),
+		"updated_at":   now,
+	}
+
+	if data.Password == "" {
+		if err := s.repo.UpdateUser(ctx, u.ID, updates); err != nil {
+			return scimInternal(err)
+		}
+	} else {
+		reused, err := s.as.es.PasswordReused(ctx, u, data.Password)
+		if err != nil {
+			return scimInternal(err)
+		}
+		if reused {
+			return scim.BadRequest(scim.ErrInvalidValue, "password: %s", models.ErrPasswordReused)
+		}
+
+		hash, err := utils.HashPassword(data.Password)
+		if err != nil {
+			return scimInternal(err)
+		}
+		updates["hash_password"] = hash
+		updates["password_changed_at"] = now
+
+		// The attributes and the password change together or not at all
+		if err := s.repo.ChangeUserPassword(ctx, u.ID, updates, u.HashPassword, utils.CurrentPasswordPolicy().HistorySize); err != nil {
+			return scimInternal(err)
+		}
+	}
+
+	if data.Active != nil {
+		return s.setActive(ctx, u, *data.Active)
+	}
+
+	return nil
+}
+
+// setActive deactivates or reactivates the account. Deactivating ends every
+// session of the user.
+func (s *scimService) setActive(ctx context.Context, u *models.User, active bool) error {
+	if active == (u.DeactivatedAt == nil) {
+		return nil
+	}
+
+	var deactivatedAt *time.Time
+	if !active {
+		now := time.Now().UTC()
+		deactivatedAt = &now
+	}
+
+	if err := s.repo.UpdateUser(ctx, u.ID, map[string]interface{}{"deactivated_at": deactivatedAt}); err != nil {
+		return scimInternal(err)
+	}
+	u.DeactivatedAt = deactivatedAt
+
+	if !active {
+		if err := s.as.RevokeSessions(ctx, u.ID); err != nil {
+			return scimInternal(err)
+		}
+	}
+
+	return nil
+}
+
+func (s *scimService) checkUniqueUser(ctx context.Context, id uint64, email, externalID string) error {
+	if u, _ := s.repo.GetUser(ctx, map[string]interface{}{"email": email}); u != nil && u.ID != id {
+		return scim.NewError(http.StatusConflict, scim.ErrUniqueness, models.ErrEmailAlreadyExists.Error())
+	}
+
+	if externalID != "" {
+		if u, _ := s.repo.GetUser(ctx, map[string]interface{}{"external_id": externalID}); u != nil && u.ID != id {
+			return scim.NewError(http.StatusConflict, scim.ErrUniqueness, "externalId already exists")
+		}
+	}
+
+	return nil
+}
+
+// findProvisionedUser finds a user the provisioning key may change. Super
+// Admins are managed only through the API: a provisioning key must not be a
+// way to take over or lock out their accounts.
+func (s *scimService) findProvisionedUser(ctx context.Context, id string) (*models.User, error) {
+	u, err := s.findUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	userRoles, err := s.repo.GetUserRoles(ctx, u.ID)
+	if err != nil {
+		return nil, scimInternal(err)
+	}
+	for _, ur := range userRoles {
+		if ur.RoleID == models.RoleSuperAdmin {
+			return nil, scim.BadRequest(scim.ErrMutability, "%s accounts cannot be changed over SCIM", models.RoleNames[models.RoleSuperAdmin])
+		}
+	}
+
+	return u, nil
+}
+
+func (s *scimService) findUser(ctx context.Context, id string) (*models.User, error) {
+	uid, err := common.FromBase58(id)
+	if err != nil || uid.GetObjectType() != common.ObjectTypeUser {
+		return nil, scim.NotFound("User", id)
+	}
+
+	u, err := s.repo.GetUser(ctx, map[string]interface{}{"id": uint64(uid.GetLocalID())})
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			return nil, scim.NotFound("User", id)
+		}
+		return nil, scimInternal(err)
+	}
+
+	return u, nil
+}
+
+func (s *scimService) toSCIMUser(ctx context.Context, u *models.User) (*scim.User, error) {
+	users, err := s.toSCIMUsers(ctx, []*models.User{u})
+	if err != nil {
+		return nil, err
+	}
+
+	return users[0], nil
+}
+
+// toSCIMUsers maps accounts to SCIM users, with the groups they belong to.
+func (s *scimService) toSCIMUsers(ctx context.Context, users []*models.User) ([]*scim.User, error) {
+	ids := make([]uint64, 0, len(users))
+	for _, u := range users {
+		ids = append(ids, u.ID)
+	}
+
+	roles := map[uint64][]*models.Role{}
+	if len(ids) > 0 {
+		var err error
+		if roles, err = s.repo.GetUsersGlobalRoles(ctx, ids); err != nil {
+			return nil, scimInternal(err)
+		}
+	}
+
+	resources := make([]*scim.User, 0, len(users))
+	for _, u := range users {
+		active := u.DeactivatedAt == nil
+		su := &scim.User{
+			Schemas:     []string{scim.SchemaUser},
+			ID:          scimUserID(u.ID),
+			UserName:    u.Email,
+			Name:        &scim.Name{Formatted: u.FullName},
+			DisplayName: u.FullName,
+			Emails:      []scim.MultiValued{{Value: u.Email, Type: "work", Primary: true}},
+			Active:      &active,
+			Meta: &scim.Meta{
+				ResourceType: "User",
+				Created:      u.CreatedAt,
+				LastModified: u.UpdatedAt,
+			},
+		}
+		if u.ExternalID != nil {
+			su.ExternalID = *u.ExternalID
+		}
+		if u.PhoneNumber != nil {
+			su.PhoneNumbers = []scim.MultiValued{{Value: *u.PhoneNumber, Type: "work"}}
+		}
+		for _, r := range roles[u.ID] {
+			su.Groups = append(su.Groups, scim.MultiValued{Value: strconv.FormatInt(r.ID, 10), Display: r.Name})
+		}
+
+		resources = append(resources, su)
+	}
+
+	return resources, nil
+}
+
+func (s *scimService) ListGroups(ctx context.Context, data *scim.ListRequest) (*scim.ListResponse, error) {
+	data.Process()
+
+	filter, err := scim.ParseFilter(data.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, total, err := s.repo.GetSCIMGroups(ctx, filter, data.Count, data.Offset())
+	if err != nil {
+		return nil, scimRepoError(err)
+	}
+
+	resources, err := s.toSCIMGroups(ctx, roles, !data.Excludes("members"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &scim.ListResponse{
+		Schemas:      []string{scim.SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   data.StartIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}, nil
+}
+
+func (s *scimService) GetGroup(ctx context.Context, id string, members bool) (*scim.Group, error) {
+	role, err := s.findRole(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := s.toSCIMGroups(ctx, []*models.Role{role}, members)
+	if err != nil {
+		return nil, err
+	}
+
+	return groups[0], nil
+}
+
+// CreateGroup creates a role without permissions; they are granted through
+// the roles API.
+func (s *scimService) CreateGroup(ctx context.Context, data *scim.Group) (*scim.Group, error) {
+	if err := validateSCIMGroup(data); err != nil {
+		return nil, err
+	}
+
+	if r, _ := s.repo.GetRole(ctx, map[string]interface{}{"name": data.DisplayName}); r != nil {
+		return nil, scim.NewError(http.StatusConflict, scim.ErrUniqueness, "displayName already exists")
+	}
+
+	memberIDs, err := s.memberIDs(ctx, data.Members)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	role := &models.Role{
+		Name:      data.DisplayName,
+		CreatedAt: &now,
+	}
+	if err := s.repo.CreateRole(ctx, role); err != nil {
+		return nil, scimInternal(err)
+	}
+
+	if len(memberIDs) > 0 {
+		if err := s.repo.AddRoleMembers(ctx, role.ID, memberIDs); err != nil {
+			return nil, scimInternal(err)
+		}
+	}
+
+	return s.GetGroup(ctx, strconv.FormatInt(role.ID, 10), true)
+}
+
+// ReplaceGroup renames the role and makes the members hold it in every
+// company. Members that are removed lose their access tokens.
+func (s *scimService) ReplaceGroup(ctx context.Context, id string, data *scim.Group) (*scim.Group, error) {
+	role, err := s.findRole(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.replaceGroup(ctx, role, data); err != nil {
+		return nil, err
+	}
+
+	return s.GetGroup(ctx, id, true)
+}
+
+// PatchGroup applies the operations to the group as it is now, then stores
+// the result like ReplaceGroup.
+func (s *scimService) PatchGroup(ctx context.Context, id string, data *scim.PatchRequest) (*scim.Group, error) {
+	if err := data.Validate(); err != nil {
+		return nil, err
+	}
+
+	current, err := s.GetGroup(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := scim.Apply(current, data.Operations); err != nil {
+		return nil, err
+	}
+
+	role, err := s.findRole(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.replaceGroup(ctx, role, current); err != nil {
+		return nil, err
+	}
+
+	return s.GetGroup(ctx, id, true)
+}
+
+// DeleteGroup deletes a role created for a group. The built-in roles cannot
+// be deleted.
+func (s *scimService) DeleteGroup(ctx context.Context, id string) error {
+	role, err := s.findRole(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if _, builtIn := models.RoleNames[role.ID]; builtIn {
+		return scim.BadRequest(scim.ErrMutability, "built-in role %s cannot be deleted", role.Name)
+	}
+
+	members, err := s.repo.GetRoleMembers(ctx, []int64{role.ID})
+	if err != nil {
+		return scimInternal(err)
+	}
+
+	if err := s.repo.DeleteRole(ctx, role.ID); err != nil {
+		return scimInternal(err)
+	}
+
+	for _, u := range members[role.ID] {
+		if err := s.as.opts.Revocations.RevokeUserTokens(ctx, u.ID); err != nil {
+			return scimInternal(err)
+		}
+	}
+
+	return nil
+}
+
+func (s *scimService) replaceGroup(ctx context.Context, role *models.Role, data *scim.Group) error {
+	if err := validateSCIMGroup(data); err != nil {
+		return err
+	}
+
+	_, builtIn := models.RoleNames[role.ID]
+
+	if data.DisplayName != role.Name {
+		if builtIn {
+			return scim.BadRequest(scim.ErrMutability, "built-in role %s cannot be renamed", role.Name)
+		}
+		if r, _ := s.repo.GetRole(ctx, map[string]interface{}{"name": data.DisplayName}); r != nil && r.ID != role.ID {
+			return scim.NewError(http.StatusConflict, scim.ErrUniqueness, "displayName already exists")
+		}
+		if err := s.repo.UpdateRole(ctx, role.ID, map[string]interface{}{"name": data.DisplayName}); err != nil {
+			return scimInternal(err)
+		}
+	}
+
+	wanted, err := s.memberIDs(ctx, data.Members)
+	if err != nil {
+		return err
+	}
+
+	members, err := s.repo.GetRoleMembers(ctx, []int64{role.ID})
+	if err != nil {
+		return scimInternal(err)
+	}
+
+	var current, added, removed []uint64
+	for _, u := range members[role.ID] {
+		current = append(current, u.ID)
+		if !slices.Contains(wanted, u.ID) {
+			removed = append(removed, u.ID)
+		}
+	}
+	for _, id := range wanted {
+		if !slices.Contains(current, id) {
+			added = append(added, id)
+		}
+	}
+
+	if len(added) == 0 && len(removed) == 0 {
+		return nil
+	}
+
+	// A provisioning key must not be a way to become Super Admin
+	if role.ID == models.RoleSuperAdmin {
+		return scim.BadRequest(scim.ErrMutability, "members of %s cannot be changed over SCIM", role.Name)
+	}
+
+	if len(added) > 0 {
+		if err := s.repo.AddRoleMembers(ctx, role.ID, added); err != nil {
+			return scimInternal(err)
+		}
+	}
+
+	if len(removed) > 0 {
+		if err := s.repo.RemoveRoleMembers(ctx, role.ID, removed); err != nil {
+			return scimInternal(err)
+		}
+		for _, id := range removed {
+			if err := s.as.opts.Revocations.RevokeUserTokens(ctx, id); err != nil {
+				return scimInternal(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// memberIDs resolves the members of a group to user ids. Every member must
+// be an existing user.
+func (s *scimService) memberIDs(ctx context.Context, members []scim.MultiValued) ([]uint64, error) {
+	ids := make([]uint64, 0, len(members))
+	for _, m := range members {
+		uid, err := common.FromBase58(m.Value)
+		if err != nil || uid.GetObjectType() != common.ObjectTypeUser {
+			return nil, scim.BadRequest(scim.ErrInvalidValue, "member %s is not a user", m.Value)
+		}
+
+		id := uint64(uid.GetLocalID())
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) == 0 {
+		return ids, nil
+	}
+
+	users, err := s.repo.GetUsersByIDs(ctx, ids)
+	if err != nil {
+		return nil, scimInternal(err)
+	}
+	if len(users) != len(ids) {
+		return nil, scim.BadRequest(scim.ErrInvalidValue, "some members are not users")
+	}
+
+	return ids, nil
+}
+
+func (s *scimService) findRole(ctx context.Context, id string) (*models.Role, error) {
+	roleID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, scim.NotFound("Group", id)
+	}
+
+	role, err := s.repo.GetRole(ctx, map[string]interface{}{"id": roleID})
+	if err != nil || role == nil {
+		return nil, scim.NotFound("Group", id)
+	}
+
+	return role, nil
+}
+
+// toSCIMGroups maps roles to SCIM groups, listing their members unless
+// members is unset.
+func (s *scimService) toSCIMGroups(ctx context.Context, roles []*models.Role, members bool) ([]*scim.Group, error) {
+	users := map[int64][]*models.User{}
+	if members && len(roles) > 0 {
+		ids := make([]int64, 0, len(roles))
+		for _, r := range roles {
+			ids = append(ids, r.ID)
+		}
+
+		var err error
+		if users, err = s.repo.GetRoleMembers(ctx, ids); err != nil {
+			return nil, scimInternal(err)
+		}
+	}
+
+	groups := make([]*scim.Group, 0, len(roles))
+	for _, r := range roles {
+		g := &scim.Group{
+			Schemas:     []string{scim.SchemaGroup},
+			ID:          strconv.FormatInt(r.ID, 10),
+			DisplayName: r.Name,
+			Meta: &scim.Meta{
+				ResourceType: "Group",
+				Created:      r.CreatedAt,
+			},
+		}
+		for _, u := range users[r.ID] {
+			g.Members = append(g.Members, scim.MultiValued{Value: scimUserID(u.ID), Display: u.FullName})
+		}
+
+		groups = append(groups, g)
+	}
+
+	return groups, nil
+}
+
+func validateSCIMUser(data *scim.User) (string, string, error) {
+	email := data.Email()
+	if _, err := mail.ParseAddress(email); err != nil {
+		return "", "", scim.BadRequest(scim.ErrInvalidValue, "userName must be an email address")
+	}
+
+	// users.full_name cannot be empty
+	fullName := data.FullName()
+	if fullName == "" {
+		fullName = email
+	}
+
+	return email, fullName, nil
+}
+
+func validateSCIMGroup(data *scim.Group) error {
+	if data.DisplayName == "" {
+		return scim.BadRequest(scim.ErrInvalidValue, "displayName is required")
+	}
+	if len([]rune(data.DisplayName)) > 50 {
+		return scim.BadRequest(scim.ErrInvalidValue, "displayName must be at most 50 characters")
+	}
+
+	return nil
+}
+
+func scimPhoneNumber(data *scim.User) *string {
+	for _, p := range data.PhoneNumbers {
+		if p.Primary {
+			return optionalString(p.Value)
+		}
+	}
+	if len(data.PhoneNumbers) > 0 {
+		return optionalString(data.PhoneNumbers[0].Value)
+	}
+
+	return nil
+}
+
+func scimUserID(id uint64) string {
+	uid := common.NewUID(uint32(id), common.ObjectTypeUser, 1)
+	return uid.String()
+}
+
+// scimRepoError passes on filter errors raised while building a query.
+func scimRepoError(err error) error {
+	var scimErr *scim.Error
+	if errors.As(err, &scimErr) {
+		return scimErr
+	}
+
+	return scimInternal(err)
+}
+
+// scimInternal hides the cause of a failure from the client outside of
+// development.
+func scimInternal(err error) error {
+	detail := common.ErrorInternal.Message
+	if utils.IsDevelopment() {
+		detail = err.Error()
+	}
+
+	return scim.NewError(http.StatusInternalServerError, "", detail)
+}
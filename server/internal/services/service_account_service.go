@@ -0,0 +1,392 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/access"
+	"github.com/vlahanam/company-management/internal/dto"
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/requests"
+	"github.com/vlahanam/company-management/utils"
+)
+
+// apiKeyPrefix marks the keys issued by this service, so they are easy to spot
+// in logs and secret scanners.
+const apiKeyPrefix = "cmk_"
+
+type ServiceAccountRepo interface {
+	CreateServiceAccount(ctx context.Context, data *models.ServiceAccount) error
+	GetServiceAccount(ctx context.Context, data map[string]interface{}) (*models.ServiceAccount, error)
+	GetAllServiceAccounts(ctx context.Context) ([]*models.ServiceAccount, error)
+	UpdateServiceAccount(ctx context.Context, id uint64, data map[string]interface{}) error
+	DeleteServiceAccount(ctx context.Context, id uint64) error
+	CreateAPIKey(ctx context.Context, data *models.APIKey) error
+	GetAPIKey(ctx context.Context, data map[string]interface{}) (*models.APIKey, error)
+	RotateAPIKey(ctx context.Context, oldID uint64, data *models.APIKey) error
+	RevokeAPIKey(ctx context.Context, id uint64) (bool, error)
+	TouchAPIKey(ctx context.Context, id uint64, now time.Time) error
+}
+
+type serviceAccountService struct {
+	es   *userService
+	repo ServiceAccountRepo
+}
+
+func NewServiceAccountService(es *userService, repo ServiceAccountRepo) *serviceAccountService {
+	return &serviceAccountService{
+		es:   es,
+		repo: repo,
+	}
+}
+
+func (s *serviceAccountService) CreateServiceAccount(ctx context.Context, actorUID string, data *requests.CreateServiceAccountRequest) (*models.ServiceAccount, error) {
+	if existing, _ := s.repo.GetServiceAccount(ctx, map[string]interface{}{"name": data.Name}); existing != nil {
+		return nil, common.ErrorValidation.Clone().SetDetail("name", models.ErrServiceAccountNameExists.Error())
+	}
+
+	account := &models.ServiceAccount{
+		SQLModel:    models.NewSQLModel(),
+		Name:        data.Name,
+		Description: data.Description,
+	}
+	if actorID, err := userIDFromUID(actorUID); err == nil {
+		account.CreatedBy = &actorID
+	}
+
+	if err := s.repo.CreateServiceAccount(ctx, account); err != nil {
+		return nil, common.ErrorCreateFailed.Clone().WrapErrorSafe(err)
+	}
+
+	return account, nil
+}
+
+func (s *serviceAccountService) ListServiceAccounts(ctx context.Context) ([]*models.ServiceAccount, error) {
+	accounts, err := s.repo.GetAllServiceAccounts(ctx)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return accounts, nil
+}
+
+// FindByID returns the service account with all of its keys, including
+// revoked ones.
+func (s *serviceAccountService) FindByID(ctx context.Context, id uint64) (*models.ServiceAccount, error) {
+	account, err := s.repo.GetServiceAccount(ctx, map[string]interface{}{"id": id})
+	if err != nil {
+		if errors.Is(err, models.ErrServiceAccountNotFound) {
+			return nil, common.ErrorNotFound.Clone().WrapMessage(err.Error())
+		}
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return account, nil
+}
+
+func (s *serviceAccountService) UpdateServiceAccount(ctx context.Context, id uint64, data *requests.UpdateServiceAccountRequest) error {
+	if _, err := s.FindByID(ctx, id); err != nil {
+		return err
+	}
+
+	updates := make(map[string]interface{})
+	if data.Name != nil {
+		if existing, _ := s.repo.GetServiceAccount(ctx, map[string]interface{}{"name": *data.Name}); existing != nil && existing.ID != id {
+			return common.ErrorValidation.Clone().SetDetail("name", models.ErrServiceAccountNameExists.Error())
+		}
+		updates["name"] = *data.Name
+	}
+	if data.Description != nil {
+		updates["description"] = *data.Description
+	}
+	if data.Disabled != nil {
+		if *data.Disabled {
+			updates["disabled_at"] = time.Now().UTC()
+		} else {
+			updates["disabled_at"] = nil
+		}
+	}
+
+	if len(updates) == 0 {
+		return common.ErrorValidation.Clone().WrapMessage("no fields to update")
+	}
+
+	if err := s.repo.UpdateServiceAccount(ctx, id, updates); err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return nil
+}
+
+func (s *serviceAccountService) DeleteServiceAccount(ctx context.Context, id uint64) error {
+	if _, err := s.FindByID(ctx, id); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteServiceAccount(ctx, id); err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return nil
+}
+
+// CreateAPIKey issues a new key for the service account. The caller can only
+// grant permissions they hold, so managing service accounts does not allow
+// privilege escalation.
+func (s *serviceAccountService) CreateAPIKey(ctx context.Context, actorUID string, accountID uint64, data *requests.CreateAPIKeyRequest) (*dto.APIKeySecret, error) {
+	if _, err := s.FindByID(ctx, accountID); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkActorHolds(ctx, actorUID, data.Permissions); err != nil {
+		return nil, err
+	}
+
+	key, secret, err := newAPIKey(accountID, data.Name, data.Permissions, data.ExpiresAt)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	if err := s.repo.CreateAPIKey(ctx, key); err != nil {
+		return nil, common.ErrorCreateFailed.Clone().WrapErrorSafe(err)
+	}
+
+	key.Mask()
+	return &dto.APIKeySecret{APIKey: key, Key: secret}, nil
+}
+
+// RotateAPIKey replaces a key with a new secret that keeps its name, scope and
+// expiry. The old key stops working immediately.
+func (s *serviceAccountService) RotateAPIKey(ctx context.Context, actorUID string, accountID, keyID uint64) (*dto.APIKeySecret, error) {
+	old, err := s.findKey(ctx, accountID, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if old.RevokedAt != nil {
+		return nil, common.ErrorValidation.Clone().WrapMessage(models.ErrInvalidAPIKey.Error())
+	}
+
+	if err := s.checkActorHolds(ctx, actorUID, old.PermissionIDs()); err != nil {
+		return nil, err
+	}
+
+	key, secret, err := newAPIKey(accountID, old.Name, old.PermissionIDs(), old.ExpiresAt)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	if err := s.repo.RotateAPIKey(ctx, old.ID, key); err != nil {
+		if errors.Is(err, models.ErrAPIKeyNotFound) {
+			return nil, common.ErrorNotFound.Clone().WrapMessage(err.Error())
+		}
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	key.Mask()
+	return &dto.APIKeySecret{APIKey: key, Key: secret}, nil
+}
+
+func (s *serviceAccountService) RevokeAPIKey(ctx context.Context, accountID, keyID uint64) error {
+	if _, err := s.findKey(ctx, accountID, keyID); err != nil {
+		return err
+	}
+
+	revoked, err := s.repo.RevokeAPIKey(ctx, keyID)
+	if err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+	if !revoked {
+		return common.ErrorValidation.Clone().WrapMessage("api key is already revoked")
+	}
+
+	return nil
+}
+
+// AuthenticateAPIKey resolves a presented key to the base58 ids of the key
+// and its service account. Unknown, revoked and expired keys as well as keys
+// of disabled accounts are rejected.
+func (s *serviceAccountService) AuthenticateAPIKey(ctx context.Context, presented string) (string, string, error) {
+	invalid := common.ErrorUnauthorized.Clone().WrapMessage(models.ErrInvalidAPIKey.Error())
+
+	prefix, ok := parseAPIKey(presented)
+	if !ok {
+		return "", "", invalid
+	}
+
+	key, err := s.repo.GetAPIKey(ctx, map[string]interface{}{"prefix": prefix})
+	if err != nil {
+		return "", "", invalid
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(utils.HashToken(presented))) != 1 {
+		return "", "", invalid
+	}
+
+	now := time.Now().UTC()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && !now.Before(*key.ExpiresAt)) {
+		return "", "", invalid
+	}
+
+	account, err := s.repo.GetServiceAccount(ctx, map[string]interface{}{"id": key.ServiceAccountID})
+	if err != nil || account.DisabledAt != nil {
+		return "", "", invalid
+	}
+
+	_ = s.repo.TouchAPIKey(ctx, key.ID, now)
+
+	keyUID := common.NewUID(uint32(key.ID), common.ObjectTypeAPIKey, 1)
+	accountUID := common.NewUID(uint32(account.ID), common.ObjectTypeServiceAccount, 1)
+
+	return keyUID.String(), accountUID.String(), nil
+}
+
+// GetPermissionIDsByUserUID resolves the permissions of the authenticated
+// principal: the role permissions of a user, or the scope of an API key.
+func (s *serviceAccountService) GetPermissionIDsByUserUID(ctx context.Context, principalUID string) ([]int64, error) {
+	uid, err := common.FromBase58(principalUID)
+	if err != nil {
+		return nil, err
+	}
+
+	if uid.GetObjectType() != common.ObjectTypeAPIKey {
+		return s.es.GetPermissionIDsByUserUID(ctx, principalUID)
+	}
+
+	key, err := s.repo.GetAPIKey(ctx, map[string]interface{}{"id": uint64(uid.GetLocalID())})
+	if err != nil {
+		return nil, err
+	}
+
+	return key.PermissionIDs(), nil
+}
+
+// GetPermissionIDsInCompanies is GetPermissionIDsByUserUID limited to the
+// roles that apply in at least one of the companies. API keys are not tied
+// to a company, so their scope applies everywhere.
+func (s *serviceAccountService) GetPermissionIDsInCompanies(ctx context.Context, principalUID string, companyIDs []uint64) ([]int64, error) {
+	uid, err := common.FromBase58(principalUID)
+	if err != nil {
+		return nil, err
+	}
+
+	if uid.GetObjectType() != common.ObjectTypeAPIKey {
+		return s.es.GetPermissionIDsInCompanies(ctx, principalUID, companyIDs)
+	}
+
+	return s.GetPermissionIDsByUserUID(ctx, principalUID)
+}
+
+// GetUserAccessActor is the userService one that also accepts API keys, which
+// act within their scopes.
+func (s *serviceAccountService) GetUserAccessActor(ctx context.Context, principalUID string, targetID uint64) (*access.Actor, error) {
+	uid, err := common.FromBase58(principalUID)
+	if err != nil {
+		return nil, common.ErrorUnauthorized.Clone().WrapError(err)
+	}
+
+	if uid.GetObjectType() != common.ObjectTypeAPIKey {
+		return s.es.GetUserAccessActor(ctx, principalUID, targetID)
+	}
+
+	permissionIDs, err := s.GetPermissionIDsByUserUID(ctx, principalUID)
+	if err != nil {
+		return nil, common.ErrorUnauthorized.Clone().WrapError(err)
+	}
+
+	canAssign, err := s.es.CanAssignRolesOf(ctx, targetID, func([]uint64) ([]int64, error) {
+		return permissionIDs, nil
+	})
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return &access.Actor{APIKey: true, PermissionIDs: permissionIDs, CanAssignTargetRoles: canAssign}, nil
+}
+
+func (s *serviceAccountService) findKey(ctx context.Context, accountID, keyID uint64) (*models.APIKey, error) {
+	key, err := s.repo.GetAPIKey(ctx, map[string]interface{}{"id": keyID, "service_account_id": accountID})
+	if err != nil {
+		if errors.Is(err, models.ErrAPIKeyNotFound) {
+			return nil, common.ErrorNotFound.Clone().WrapMessage(err.Error())
+		}
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return key, nil
+}
+
+func (s *serviceAccountService) checkActorHolds(ctx context.Context, actorUID string, permissions []int64) error {
+	held, err := s.GetPermissionIDsByUserUID(ctx, actorUID)
+	if err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	set := make(map[int64]struct{}, len(held))
+	for _, p := range held {
+		set[p] = struct{}{}
+	}
+
+	for _, p := range permissions {
+		if _, ok := set[p]; !ok {
+			return common.ErrorValidation.Clone().SetDetail("permissions", models.ErrAPIKeyScopeNotHeld.Error())
+		}
+	}
+
+	return nil
+}
+
+// newAPIKey generates a key of the form cmk_<prefix>.<secret>. The prefix is
+// stored in clear for lookup, the whole key only as a hash.
+func newAPIKey(accountID uint64, name string, permissions []int64, expiresAt *time.Time) (*models.APIKey, string, error) {
+	prefix, err := utils.GenerateRandomToken(9)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	full := apiKeyPrefix + prefix + "." + secret
+	now := time.Now().UTC()
+
+	seen := make(map[int64]struct{}, len(permissions))
+	scopes := make([]*models.APIKeyPermission, 0, len(permissions))
+	for _, p := range permissions {
+		if _, ok := seen[p]; ok {
+			continue
+		}
+		seen[p] = struct{}{}
+		scopes = append(scopes, &models.APIKeyPermission{PermissionID: p})
+	}
+
+	key := &models.APIKey{
+		ServiceAccountID: accountID,
+		Name:             name,
+		Prefix:           prefix,
+		KeyHash:          utils.HashToken(full),
+		ExpiresAt:        expiresAt,
+		CreatedAt:        &now,
+		Permissions:      scopes,
+	}
+
+	return key, full, nil
+}
+
+func parseAPIKey(key string) (string, bool) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return "", false
+	}
+
+	prefix, secret, ok := strings.Cut(strings.TrimPrefix(key, apiKeyPrefix), ".")
+	if !ok || prefix == "" || secret == "" {
+		return "", false
+	}
+
+	return prefix, true
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/utils"
+)
+
+type UserSessionRepo interface {
+	CreateUserSession(ctx context.Context, data *models.UserSession) error
+	GetUserSessions(ctx context.Context, userID uint64, activeSince time.Time) ([]*models.UserSession, error)
+	GetUserSession(ctx context.Context, id string, userID uint64) (*models.UserSession, error)
+	TouchUserSession(ctx context.Context, id string, data map[string]interface{}) (bool, error)
+	RevokeUserSession(ctx context.Context, id string) error
+	RevokeUserSessions(ctx context.Context, userID uint64) error
+}
+
+// startSession records a new login. The session shares its id with the
+// refresh token family, so rotating tokens keeps the session.
+func (as *authService) startSession(ctx context.Context, userID uint64, familyID, device string) error {
+	client := utils.ClientInfoFrom(ctx)
+
+	session := &models.UserSession{
+		ID:         familyID,
+		UserID:     userID,
+		Device:     optionalString(device),
+		UserAgent:  optionalString(truncate(client.UserAgent, 512)),
+		IPAddress:  optionalString(client.IP),
+		LastSeenAt: time.Now().UTC(),
+	}
+
+	return as.rt.CreateUserSession(ctx, session)
+}
+
+// touchSession records a token refresh. Logins from before sessions were
+// recorded get their session on their first refresh.
+func (as *authService) touchSession(ctx context.Context, userID uint64, familyID, device string) error {
+	client := utils.ClientInfoFrom(ctx)
+
+	updates := map[string]interface{}{"last_seen_at": time.Now().UTC()}
+	if client.IP != "" {
+		updates["ip_address"] = client.IP
+	}
+	if client.UserAgent != "" {
+		updates["user_agent"] = truncate(client.UserAgent, 512)
+	}
+
+	touched, err := as.rt.TouchUserSession(ctx, familyID, updates)
+	if err != nil || touched {
+		return err
+	}
+
+	// Nothing changed within the same second also reports no row; the insert
+	// then fails on the existing id and is ignored
+	_ = as.startSession(ctx, userID, familyID, device)
+	return nil
+}
+
+// ListSessions returns the signed-in sessions of the user. currentID marks the
+// session of the caller.
+func (as *authService) ListSessions(ctx context.Context, userID uint64, currentID string) ([]*models.UserSession, error) {
+	sessions, err := as.rt.GetUserSessions(ctx, userID, time.Now().UTC().Add(-expRefreshToken))
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	for _, s := range sessions {
+		s.Current = s.ID == currentID
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession signs the user out of one session: its refresh tokens stop
+// working and its access tokens are revoked at once.
+func (as *authService) RevokeSession(ctx context.Context, userID uint64, sessionID string) error {
+	session, err := as.rt.GetUserSession(ctx, sessionID, userID)
+	if err != nil {
+		if errors.Is(err, models.ErrSessionNotFound) {
+			return common.ErrorNotFound.Clone().WrapMessage(err.Error())
+		}
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return as.revokeFamily(ctx, session.ID)
+}
+
+// revokeFamily ends the session of a refresh token family.
+func (as *authService) revokeFamily(ctx context.Context, familyID string) error {
+	if err := as.rt.RevokeRefreshTokenFamily(ctx, familyID); err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	if err := as.rt.RevokeUserSession(ctx, familyID); err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return as.opts.Revocations.RevokeSessionTokens(ctx, familyID)
+}
+
+// RevokeOtherSessions signs the user out of every session except keepID.
+func (as *authService) RevokeOtherSessions(ctx context.Context, userID uint64, keepID string) error {
+	families, err := as.rt.GetUserRefreshTokenFamilies(ctx, userID)
+	if err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	sessions, err := as.rt.GetUserSessions(ctx, userID, time.Now().UTC().Add(-expRefreshToken))
+	if err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+	for _, s := range sessions {
+		families = append(families, s.ID)
+	}
+
+	seen := map[string]bool{keepID: true}
+	for _, familyID := range families {
+		if seen[familyID] {
+			continue
+		}
+		seen[familyID] = true
+
+		if err := as.revokeFamily(ctx, familyID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return &s
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	// Drop a rune cut in half
+	return strings.ToValidUTF8(s[:n], "")
+}
+
+// ListOwnSessions lists the sessions of the caller.
+func (as *authService) ListOwnSessions(ctx context.Context, userUID, currentID string) ([]*models.UserSession, error) {
+	userID, err := userIDFromUID(userUID)
+	if err != nil {
+		return nil, err
+	}
+
+	return as.ListSessions(ctx, userID, currentID)
+}
+
+// RevokeOwnSession signs the caller out of one of their sessions, which may be
+// the current one.
+func (as *authService) RevokeOwnSession(ctx context.Context, userUID, sessionID string) error {
+	userID, err := userIDFromUID(userUID)
+	if err != nil {
+		return err
+	}
+
+	return as.RevokeSession(ctx, userID, sessionID)
+}
+
+// ListUserSessions lists the sessions of another user for an administrator.
+func (as *authService) ListUserSessions(ctx context.Context, userID uint64) ([]*models.UserSession, error) {
+	if _, err := as.es.FindByID(ctx, userID); err != nil {
+		return nil, common.ErrorNotFound.Clone().WrapMessage(models.ErrUserNotFound.Error())
+	}
+
+	return as.ListSessions(ctx, userID, "")
+}
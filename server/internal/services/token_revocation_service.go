@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/models"
+)
+
+type TokenRevocationRepo interface {
+	CreateTokenRevocation(ctx context.Context, data *models.TokenRevocation) error
+	ConsumeTokenRevocation(ctx context.Context, data *models.TokenRevocation) (bool, error)
+	GetActiveTokenRevocations(ctx context.Context, now time.Time) ([]*models.TokenRevocation, error)
+	DeleteExpiredTokenRevocations(ctx context.Context, now time.Time) error
+}
+
+// TokenRevoker invalidates access tokens before they expire.
+type TokenRevoker interface {
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	ConsumeToken(ctx context.Context, jti string, expiresAt time.Time) (bool, error)
+	RevokeUserTokens(ctx context.Context, userID uint64) error
+	RevokeSessionTokens(ctx context.Context, sessionID string) error
+}
+
+// tokenRevocationService is the access token revocation list. Revocations are
+// stored in the database so every instance sees them, and mirrored in memory
+// so AuthMiddleware does not hit the database on each request. The cache is
+// reloaded at most once per cacheTTL; revocations made by this process are
+// applied to the cache immediately.
+type tokenRevocationService struct {
+	repo     TokenRevocationRepo
+	cacheTTL time.Duration
+
+	mu       sync.RWMutex
+	loadedAt time.Time
+	jtis     map[string]time.Time
+	users    map[uint64]time.Time
+	sessions map[string]time.Time
+}
+
+func NewTokenRevocationService(repo TokenRevocationRepo, cacheTTL time.Duration) *tokenRevocationService {
+	return &tokenRevocationService{
+		repo:     repo,
+		cacheTTL: cacheTTL,
+		jtis:     make(map[string]time.Time),
+		users:    make(map[uint64]time.Time),
+		sessions: make(map[string]time.Time),
+	}
+}
+
+// IsTokenRevoked reports whether the access token with the given jti, issued
+// to userUID at issuedAt for sessionID, has been revoked.
+func (s *tokenRevocationService) IsTokenRevoked(ctx context.Context, jti, sessionID, userUID string, issuedAt time.Time) (bool, error) {
+	if err := s.reload(ctx); err != nil {
+		return false, err
+	}
+
+	uid, err := common.FromBase58(userUID)
+	if err != nil {
+		return true, nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.jtis[jti]; ok {
+		return true, nil
+	}
+
+	if _, ok := s.sessions[sessionID]; ok && sessionID != "" {
+		return true, nil
+	}
+
+	if before, ok := s.users[uint64(uid.GetLocalID())]; ok && issuedAt.Before(before) {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (s *tokenRevocationService) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	if err := s.repo.CreateTokenRevocation(ctx, &models.TokenRevocation{
+		JTI:       &jti,
+		ExpiresAt: expiresAt.UTC(),
+	}); err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	s.mu.Lock()
+	s.jtis[jti] = expiresAt
+	s.mu.Unlock()
+
+	return nil
+}
+
+// ConsumeToken revokes a single-use token and reports whether it was still
+// unused. The unique jti makes a concurrent second use fail.
+func (s *tokenRevocationService) ConsumeToken(ctx context.Context, jti string, expiresAt time.Time) (bool, error) {
+	consumed, err := s.repo.ConsumeTokenRevocation(ctx, &models.TokenRevocation{
+		JTI:       &jti,
+		ExpiresAt: expiresAt.UTC(),
+	})
+	if err != nil {
+		return false, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	s.mu.Lock()
+	s.jtis[jti] = expiresAt
+	s.mu.Unlock()
+
+	return consumed, nil
+}
+
+// RevokeUserTokens revokes every access token issued to the user so far. The
+// entry only needs to outlive the longest access token lifetime. The cutoff
+// is kept to the millisecond like the iat of access tokens, so a token issued
+// right after it, even within the same millisecond, stays valid.
+func (s *tokenRevocationService) RevokeUserTokens(ctx context.Context, userID uint64) error {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	if err := s.repo.CreateTokenRevocation(ctx, &models.TokenRevocation{
+		UserID:        &userID,
+		RevokedBefore: &now,
+		ExpiresAt:     now.Add(expAssetToken),
+	}); err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	s.mu.Lock()
+	if current, ok := s.users[userID]; !ok || now.After(current) {
+		s.users[userID] = now
+	}
+	s.mu.Unlock()
+
+	return nil
+}
+
+// RevokeSessionTokens revokes every access token issued for the session.
+func (s *tokenRevocationService) RevokeSessionTokens(ctx context.Context, sessionID string) error {
+	expiresAt := time.Now().UTC().Add(expAssetToken)
+
+	if err := s.repo.CreateTokenRevocation(ctx, &models.TokenRevocation{
+		SessionID: &sessionID,
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	s.mu.Lock()
+	s.sessions[sessionID] = expiresAt
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *tokenRevocationService) reload(ctx context.Context) error {
+	s.mu.RLock()
+	fresh := !s.loadedAt.IsZero() && time.Since(s.loadedAt) < s.cacheTTL
+	s.mu.RUnlock()
+
+	if fresh {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	revocations, err := s.repo.GetActiveTokenRevocations(ctx, now)
+	if err != nil {
+		s.mu.RLock()
+		loaded := !s.loadedAt.IsZero()
+		s.mu.RUnlock()
+
+		// Keep serving the last known list rather than locking everyone out
+		if loaded {
+			return nil
+		}
+		return err
+	}
+
+	jtis := make(map[string]time.Time)
+	users := make(map[uint64]time.Time)
+	sessions := make(map[string]time.Time)
+	for _, r := range revocations {
+		if r.JTI != nil {
+			jtis[*r.JTI] = r.ExpiresAt
+		}
+		if r.SessionID != nil {
+			sessions[*r.SessionID] = r.ExpiresAt
+		}
+		if r.UserID != nil && r.RevokedBefore != nil {
+			if current, ok := users[*r.UserID]; !ok || r.RevokedBefore.After(current) {
+				users[*r.UserID] = *r.RevokedBefore
+			}
+		}
+	}
+
+	s.mu.Lock()
+	s.jtis = jtis
+	s.users = users
+	s.sessions = sessions
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	_ = s.repo.DeleteExpiredTokenRevocations(ctx, now)
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/utils"
+)
+
+type fakeTokenRevocationRepo struct {
+	revocations []*models.TokenRevocation
+}
+
+func (r *fakeTokenRevocationRepo) CreateTokenRevocation(ctx context.Context, data *models.TokenRevocation) error {
+	r.revocations = append(r.revocations, data)
+	return nil
+}
+
+func (r *fakeTokenRevocationRepo) ConsumeTokenRevocation(ctx context.Context, data *models.TokenRevocation) (bool, error) {
+	for _, revocation := range r.revocations {
+		if revocation.JTI != nil && *revocation.JTI == *data.JTI {
+			return false, nil
+		}
+	}
+
+	return true, r.CreateTokenRevocation(ctx, data)
+}
+
+func (r *fakeTokenRevocationRepo) GetActiveTokenRevocations(ctx context.Context, now time.Time) ([]*models.TokenRevocation, error) {
+	return r.revocations, nil
+}
+
+func (r *fakeTokenRevocationRepo) DeleteExpiredTokenRevocations(ctx context.Context, now time.Time) error {
+	return nil
+}
+
+// Tokens issued in the same second as the revocation are told apart by their
+// milliseconds, whether the list comes from the cache or the database.
+func TestRevokeUserTokensCutoff(t *testing.T) {
+	ctx := context.Background()
+	uid := common.NewUID(7, common.ObjectTypeUser, 1)
+	userUID := uid.String()
+
+	repo := &fakeTokenRevocationRepo{}
+	revoker := NewTokenRevocationService(repo, time.Hour)
+	if err := revoker.RevokeUserTokens(ctx, 7); err != nil {
+		t.Fatal(err)
+	}
+	cutoff := *repo.revocations[0].RevokedBefore
+
+	reloaded := NewTokenRevocationService(repo, time.Hour)
+
+	tests := []struct {
+		name     string
+		issuedAt time.Time
+		want     bool
+	}{
+		{"before", cutoff.Add(-time.Millisecond), true},
+		{"microsecond before", cutoff.Add(-time.Microsecond), true},
+		{"same millisecond", cutoff, false},
+		{"right after", cutoff.Add(time.Millisecond), false},
+		{"later", cutoff.Add(time.Second), false},
+	}
+
+	for _, s := range []*tokenRevocationService{revoker, reloaded} {
+		for _, tt := range tests {
+			revoked, err := s.IsTokenRevoked(ctx, "jti", "", userUID, tt.issuedAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if revoked != tt.want {
+				t.Errorf("%s: IsTokenRevoked() = %v, want %v", tt.name, revoked, tt.want)
+			}
+		}
+	}
+}
+
+// A token issued right after the user's tokens were revoked, as when a
+// password change signs the other sessions out and logs the user in, is
+// accepted.
+func TestRevokeThenIssue(t *testing.T) {
+	uid := common.NewUID(7, common.ObjectTypeUser, 1)
+	keys := utils.NewHMACKeySet("access-secret")
+	revoker := NewTokenRevocationService(&fakeTokenRevocationRepo{}, time.Hour)
+
+	app := fiber.New()
+	app.Get("/me", utils.AuthMiddleware(keys, revoker, nil), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	for i := 0; i < 100; i++ {
+		if err := revoker.RevokeUserTokens(context.Background(), 7); err != nil {
+			t.Fatal(err)
+		}
+
+		now := time.Now()
+		token, err := keys.Sign(jwt.MapClaims{
+			"user_id": uid.String(),
+			"roles":   []string{},
+			"jti":     "jti",
+			"iat":     utils.IssuedAt(now),
+			"exp":     now.Add(time.Minute).Unix(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest("GET", "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusNoContent {
+			t.Fatalf("token issued after the revocation was refused with status %d", resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/requests"
+)
+
+type UserRoleRepo interface {
+	GetRole(ctx context.Context, data map[string]interface{}) (*models.Role, error)
+	GetCompany(ctx context.Context, data map[string]interface{}) (*models.Company, error)
+	GetRolePermissionIDs(ctx context.Context, roleIDs []int64) ([]int64, error)
+	GetUserRole(ctx context.Context, data map[string]interface{}) (*models.UserRole, error)
+	CreateUserRole(ctx context.Context, data *models.UserRole) error
+	DeleteUserRole(ctx context.Context, id uint64) error
+}
+
+// ListUserRoles returns the role assignments of the user.
+func (as *authService) ListUserRoles(ctx context.Context, userID uint64) ([]*models.UserRole, error) {
+	if _, err := as.es.FindByID(ctx, userID); err != nil {
+		return nil, common.ErrorNotFound.Clone().WrapMessage(models.ErrUserNotFound.Error())
+	}
+
+	roles, err := as.es.er.GetUserRoles(ctx, userID)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return roles, nil
+}
+
+// AssignUserRole gives the user a role, in every company or only in one. The
+// caller must hold the role's permissions where it is assigned, and the
+// user's live access tokens are revoked so the role applies at once.
+func (as *authService) AssignUserRole(ctx context.Context, actorUID string, userID uint64, data *requests.AssignUserRoleRequest) (*models.UserRole, error) {
+	if _, err := as.es.FindByID(ctx, userID); err != nil {
+		return nil, common.ErrorNotFound.Clone().WrapMessage(models.ErrUserNotFound.Error())
+	}
+
+	role, err := as.rt.GetRole(ctx, map[string]interface{}{"id": data.RoleID})
+	if err != nil {
+		return nil, common.ErrorValidation.Clone().SetDetail("role_id", models.ErrRoleNotFound.Error())
+	}
+
+	var company *models.Company
+	if data.CompanyID != nil {
+		company, err = as.rt.GetCompany(ctx, map[string]interface{}{"id": *data.CompanyID})
+		if err != nil {
+			return nil, common.ErrorValidation.Clone().SetDetail("company_id", models.ErrCompanyNotFound.Error())
+		}
+	}
+
+	if err := as.checkAssignableRole(ctx, actorUID, role.ID, data.CompanyID); err != nil {
+		return nil, err
+	}
+
+	if _, err := as.rt.GetUserRole(ctx, map[string]interface{}{
+		"user_id":    userID,
+		"role_id":    role.ID,
+		"company_id": data.CompanyID,
+	}); err == nil {
+		return nil, common.ErrorValidation.Clone().SetDetail("role_id", models.ErrUserRoleExists.Error())
+	}
+
+	now := time.Now().UTC()
+	userRole := &models.UserRole{
+		UserID:              int64(userID),
+		RoleID:              role.ID,
+		CompanyID:           data.CompanyID,
+		IncludeSubsidiaries: data.CompanyID != nil && data.IncludeSubsidiaries,
+		AssignedAt:          &now,
+	}
+	if err := as.rt.CreateUserRole(ctx, userRole); err != nil {
+		return nil, common.ErrorCreateFailed.Clone().WrapErrorSafe(err)
+	}
+
+	if err := as.opts.Revocations.RevokeUserTokens(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	userRole.Role = role
+	userRole.Company = company
+	return userRole, nil
+}
+
+// RemoveUserRole takes a role assignment away from the user. The last active
+// Super Admin keeps the role, so that someone can always administer the
+// system.
+func (as *authService) RemoveUserRole(ctx context.Context, actorUID string, userID, assignmentID uint64) error {
+	userRole, err := as.rt.GetUserRole(ctx, map[string]interface{}{"id": assignmentID, "user_id": userID})
+	if err != nil {
+		if errors.Is(err, models.ErrUserRoleNotFound) {
+			return common.ErrorNotFound.Clone().WrapMessage(err.Error())
+		}
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	if err := as.checkAssignableRole(ctx, actorUID, userRole.RoleID, userRole.CompanyID); err != nil {
+		return err
+	}
+
+	if err := as.rt.DeleteUserRole(ctx, userRole.ID); err != nil {
+		switch {
+		case errors.Is(err, models.ErrLastSuperAdmin):
+			return common.ErrorValidation.Clone().SetDetail("id", err.Error())
+		case errors.Is(err, models.ErrUserRoleNotFound):
+			return common.ErrorNotFound.Clone().WrapMessage(err.Error())
+		}
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return as.opts.Revocations.RevokeUserTokens(ctx, userID)
+}
+
+// checkAssignableRole makes sure the caller manages roles where the role is
+// assigned, in the company or everywhere for a global assignment, and that
+// the role grants nothing the caller does not hold there.
+func (as *authService) checkAssignableRole(ctx context.Context, actorUID string, roleID int64, companyID *uint64) error {
+	granted, err := as.rt.GetRolePermissionIDs(ctx, []int64{roleID})
+	if err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	var companyIDs []uint64
+	if companyID != nil {
+		companyIDs = []uint64{*companyID}
+	}
+
+	held, err := as.es.GetPermissionIDsInCompanies(ctx, actorUID, companyIDs)
+	if err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	// The route only checks Manage Roles in the user's companies
+	if !slices.Contains(held, models.PermissionManageRoles) {
+		return common.ErrorValidation.Clone().SetDetail("company_id", models.ErrUserRoleScope.Error())
+	}
+
+	for _, id := range granted {
+		if !slices.Contains(held, id) {
+			return common.ErrorValidation.Clone().SetDetail("role_id", models.ErrUserRoleForbidden.Error())
+		}
+	}
+
+	return nil
+}
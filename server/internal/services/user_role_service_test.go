@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/requests"
+)
+
+// fakeUserRoleRepo assigns roles into the in-memory assignments.
+type fakeUserRoleRepo struct {
+	*fakeAuthRepo
+	assignments *fakeRoleAssignments
+}
+
+func (r *fakeUserRoleRepo) GetRole(ctx context.Context, data map[string]interface{}) (*models.Role, error) {
+	id := data["id"].(int64)
+	return &models.Role{ID: id, Name: models.RoleNames[id]}, nil
+}
+
+func (r *fakeUserRoleRepo) GetRolePermissionIDs(ctx context.Context, roleIDs []int64) ([]int64, error) {
+	return r.assignments.GetRolePermissionIDs(ctx, roleIDs)
+}
+
+func (r *fakeUserRoleRepo) GetUserRole(ctx context.Context, data map[string]interface{}) (*models.UserRole, error) {
+	return nil, models.ErrUserRoleNotFound
+}
+
+func (r *fakeUserRoleRepo) CreateUserRole(ctx context.Context, data *models.UserRole) error {
+	userID := uint64(data.UserID)
+	r.assignments.userRoles[userID] = append(r.assignments.userRoles[userID], data)
+	return nil
+}
+
+// Managing roles in one company does not let the caller assign roles in
+// another company, even to a user working in both.
+func TestAssignUserRoleScope(t *testing.T) {
+	const (
+		manager uint64 = 1
+		user    uint64 = 2
+	)
+	companyA, companyB := uint64(1), uint64(2)
+
+	employeePermissions := []int64{models.PermissionReadUser, models.PermissionViewOwnReport}
+	adminPermissions := append([]int64{models.PermissionManageRoles, models.PermissionUpdateUser}, employeePermissions...)
+
+	tests := []struct {
+		name      string
+		companyID *uint64
+		wantErr   bool
+	}{
+		{"company where the caller manages roles", &companyA, false},
+		{"other company", &companyB, true},
+		{"every company", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assignments := &fakeRoleAssignments{
+				fakeUserRepo: &fakeUserRepo{users: []*models.User{
+					{SQLModel: models.SQLModel{ID: manager}, Email: "manager@example.com"},
+					{SQLModel: models.SQLModel{ID: user}, Email: "user@example.com"},
+				}},
+				userRoles: map[uint64][]*models.UserRole{
+					manager: {{RoleID: models.RoleAdmin, CompanyID: &companyA}, {RoleID: models.RoleEmployee, CompanyID: &companyB}},
+				},
+				rolePermissions: map[int64][]int64{
+					models.RoleAdmin:    adminPermissions,
+					models.RoleEmployee: employeePermissions,
+				},
+			}
+			repo := &fakeUserRoleRepo{fakeAuthRepo: &fakeAuthRepo{users: assignments.fakeUserRepo}, assignments: assignments}
+			as := NewAuthService(NewUserService(assignments), repo, &AuthOptions{Revocations: fakeTokenRevoker{}})
+
+			actor := common.NewUID(uint32(manager), common.ObjectTypeUser, 1)
+			_, err := as.AssignUserRole(context.Background(), actor.String(), user, &requests.AssignUserRoleRequest{
+				RoleID:    models.RoleEmployee,
+				CompanyID: tt.companyID,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AssignUserRole() = %v, want error %v", err, tt.wantErr)
+			}
+			if assigned := len(assignments.userRoles[user]) == 1; assigned == tt.wantErr {
+				t.Errorf("role assigned = %v", assigned)
+			}
+		})
+	}
+}
@@ -2,8 +2,12 @@ package services
 
 import (
 	"context"
+	"errors"
+	"slices"
+	"time"
 
 	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/access"
 	"github.com/vlahanam/company-management/internal/models"
 	"github.com/vlahanam/company-management/internal/requests"
 	"github.com/vlahanam/company-management/utils"
@@ -13,10 +17,22 @@ type UserRepo interface {
 	CreateUser(ctx context.Context, data *models.User) error
 	GetUser(ctx context.Context, data map[string]interface{}) (*models.User, error)
 	GetUserRoleNames(ctx context.Context, userID uint64) ([]string, error)
+	GetUserGlobalRoleIDs(ctx context.Context, userID uint64) ([]int64, error)
+	GetUserPermissionIDs(ctx context.Context, userID uint64) ([]int64, error)
+	GetUserPermissionIDsInCompanies(ctx context.Context, userID uint64, companyIDs []uint64) ([]int64, error)
+	GetRolePermissionIDs(ctx context.Context, roleIDs []int64) ([]int64, error)
+	GetUserCompanyIDs(ctx context.Context, userID uint64) ([]uint64, error)
+	IsUserManagerOf(ctx context.Context, managerID, userID uint64) (bool, error)
 	CountDataByQuery(ctx context.Context, data map[string]interface{}) (int64, error)
 	GetAllUserWithPagination(ctx context.Context, limit, offset int, data map[string]interface{}) ([]*models.User, error)
 	UpdateUser(ctx context.Context, id uint64, data map[string]interface{}) error
 	DeleteUser(ctx context.Context, id uint64) error
+	GetPasswordHistory(ctx context.Context, userID uint64, limit int) ([]string, error)
+	ChangeUserPassword(ctx context.Context, userID uint64, data map[string]interface{}, oldHash string, keep int) error
+	GetUserPasswordMaxAge(ctx context.Context, userID uint64) (int, error)
+	GetUserRoles(ctx context.Context, userID uint64) ([]*models.UserRole, error)
+	GetUserPermissions(ctx context.Context, userID uint64) ([]*models.Permission, error)
+	GetPrimaryUserPosition(ctx context.Context, userID uint64) (*models.UserPosition, error)
 }
 
 type userService struct {
@@ -27,28 +43,36 @@ func NewUserService(er UserRepo) *userService {
 	return &userService{er: er}
 }
 
-func (es *userService) CreateUser(ctx context.Context, data *requests.RegisterRequest) error {
+// CreateUser registers a new account. Unless verified is set, the account
+// cannot log in until its email address has been confirmed.
+func (es *userService) CreateUser(ctx context.Context, data *requests.RegisterRequest, verified bool) (*models.User, error) {
 	emp, _ := es.FindByEmail(ctx, data.Email)
 	if emp != nil {
-		return common.ErrorValidation.Clone().SetDetail("email", models.ErrEmailAlreadyExists.Error())
+		return nil, common.ErrorValidation.Clone().SetDetail("email", models.ErrEmailAlreadyExists.Error())
 	}
 
 	hashPassword, err := utils.HashPassword(data.Password)
 	if err != nil {
-		return common.ErrorCreateFailed.Clone().WrapError(err)
+		return nil, common.ErrorCreateFailed.Clone().WrapError(err)
 	}
 
+	now := time.Now().UTC()
 	emp = &models.User{
-		Email:        data.Email,
-		FullName:     data.FullName,
-		HashPassword: hashPassword,
+		Email:             data.Email,
+		FullName:          data.FullName,
+		HashPassword:      hashPassword,
+		PasswordChangedAt: &now,
+	}
+
+	if verified {
+		emp.EmailVerifiedAt = &now
 	}
 
 	if err := es.er.CreateUser(ctx, emp); err != nil {
-		return common.ErrorCreateFailed.Clone().WrapErrorSafe(err)
+		return nil, common.ErrorCreateFailed.Clone().WrapErrorSafe(err)
 	}
 
-	return nil
+	return emp, nil
 }
 
 func (es *userService) FindByEmail(ctx context.Context, email string) (*models.User, error) {
@@ -82,6 +106,135 @@ func (es *userService) GetRoleNamesByUserID(ctx context.Context, userID uint64)
 	return es.er.GetUserRoleNames(ctx, userID)
 }
 
+// HasGlobalRole reports whether the user identified by the base58 id is
+// assigned the role in every company. Roles are compared by id, since their
+// names can be changed; API keys hold no roles.
+func (es *userService) HasGlobalRole(ctx context.Context, userUID string, roleID int64) (bool, error) {
+	uid, err := common.FromBase58(userUID)
+	if err != nil {
+		return false, err
+	}
+	if uid.GetObjectType() != common.ObjectTypeUser {
+		return false, nil
+	}
+
+	roleIDs, err := es.er.GetUserGlobalRoleIDs(ctx, uint64(uid.GetLocalID()))
+	if err != nil {
+		return false, err
+	}
+
+	return slices.Contains(roleIDs, roleID), nil
+}
+
+// GetPermissionIDsByUserUID resolves the effective permissions of the user
+// identified by the base58 id carried in the access token claims.
+func (es *userService) GetPermissionIDsByUserUID(ctx context.Context, userUID string) ([]int64, error) {
+	uid, err := common.FromBase58(userUID)
+	if err != nil {
+		return nil, err
+	}
+	if uid.GetObjectType() != common.ObjectTypeUser {
+		return nil, models.ErrUserAccountRequired
+	}
+
+	return es.er.GetUserPermissionIDs(ctx, uint64(uid.GetLocalID()))
+}
+
+// GetPermissionIDsInCompanies resolves the permissions the user identified by
+// the base58 id holds in at least one of the companies. Without companies
+// only roles assigned in every company count.
+func (es *userService) GetPermissionIDsInCompanies(ctx context.Context, userUID string, companyIDs []uint64) ([]int64, error) {
+	uid, err := common.FromBase58(userUID)
+	if err != nil {
+		return nil, err
+	}
+	if uid.GetObjectType() != common.ObjectTypeUser {
+		return nil, models.ErrUserAccountRequired
+	}
+
+	return es.er.GetUserPermissionIDsInCompanies(ctx, uint64(uid.GetLocalID()), companyIDs)
+}
+
+// GetCompanyIDsByUserID returns the companies the user currently works for.
+func (es *userService) GetCompanyIDsByUserID(ctx context.Context, userID uint64) ([]uint64, error) {
+	return es.er.GetUserCompanyIDs(ctx, userID)
+}
+
+// GetUserAccessActor describes the user identified by the base58 id as the
+// caller of an action on the user targetID: its permissions in the companies
+// the target works for, and whether it manages the target.
+func (es *userService) GetUserAccessActor(ctx context.Context, userUID string, targetID uint64) (*access.Actor, error) {
+	userID, err := userIDFromUID(userUID)
+	if err != nil {
+		return nil, err
+	}
+
+	companyIDs, err := es.er.GetUserCompanyIDs(ctx, targetID)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	permissionIDs, err := es.er.GetUserPermissionIDsInCompanies(ctx, userID, companyIDs)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	manages, err := es.er.IsUserManagerOf(ctx, userID, targetID)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	canAssign, err := es.CanAssignRolesOf(ctx, targetID, func(companyIDs []uint64) ([]int64, error) {
+		return es.er.GetUserPermissionIDsInCompanies(ctx, userID, companyIDs)
+	})
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return &access.Actor{
+		UserID:               userID,
+		PermissionIDs:        permissionIDs,
+		ManagesTarget:        manages,
+		CanAssignTargetRoles: canAssign,
+	}, nil
+}
+
+// CanAssignRolesOf reports whether a caller could assign every role the user
+// targetID holds: held returns the permissions the caller holds in the
+// companies, and must cover those of each role where it is assigned, as
+// when assigning it.
+func (es *userService) CanAssignRolesOf(ctx context.Context, targetID uint64, held func(companyIDs []uint64) ([]int64, error)) (bool, error) {
+	userRoles, err := es.er.GetUserRoles(ctx, targetID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, ur := range userRoles {
+		granted, err := es.er.GetRolePermissionIDs(ctx, []int64{ur.RoleID})
+		if err != nil {
+			return false, err
+		}
+
+		var companyIDs []uint64
+		if ur.CompanyID != nil {
+			companyIDs = []uint64{*ur.CompanyID}
+		}
+
+		permissionIDs, err := held(companyIDs)
+		if err != nil {
+			return false, err
+		}
+
+		for _, id := range granted {
+			if !slices.Contains(permissionIDs, id) {
+				return false, nil
+			}
+		}
+	}
+
+	return true, nil
+}
+
 func (es *userService) GetListUsersWithPagination(ctx context.Context, data requests.ListUserRequest) ([]*models.User, error) {
 	offset := (data.Page - 1) * data.Limit
 
@@ -116,8 +269,10 @@ func (es *userService) UpdateUser(ctx context.Context, id uint64, data *requests
 	if data.FullName != nil {
 		updates["full_name"] = *data.FullName
 	}
-	if data.Email != nil {
+	if data.Email != nil && *data.Email != user.Email {
+		// The new address has not been proven yet
 		updates["email"] = *data.Email
+		updates["email_verified_at"] = nil
 	}
 	if data.DateOfBirth != nil {
 		updates["date_of_birth"] = *data.DateOfBirth
@@ -146,6 +301,121 @@ func (es *userService) UpdateUser(ctx context.Context, id uint64, data *requests
 	return nil
 }
 
+func (es *userService) MarkEmailVerified(ctx context.Context, id uint64) error {
+	if err := es.er.UpdateUser(ctx, id, map[string]interface{}{"email_verified_at": time.Now().UTC()}); err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return nil
+}
+
+// ValidateNewPassword checks a password the user wants to switch to against
+// the policy, the current password and the remembered previous ones.
+func (es *userService) ValidateNewPassword(ctx context.Context, u *models.User, password string) error {
+	if err := utils.CheckPasswordPolicy(password, u.Email, u.FullName); err != nil {
+		return common.ErrorValidation.Clone().SetDetail("password", err.Error())
+	}
+
+	reused, err := es.PasswordReused(ctx, u, password)
+	if err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+	if reused {
+		return common.ErrorValidation.Clone().SetDetail("password", models.ErrPasswordReused.Error())
+	}
+
+	return nil
+}
+
+// PasswordReused reports whether the password is the current one or one of
+// the remembered previous ones.
+func (es *userService) PasswordReused(ctx context.Context, u *models.User, password string) (bool, error) {
+	if utils.CheckPasswordHash(password, u.HashPassword) {
+		return true, nil
+	}
+
+	history, err := es.er.GetPasswordHistory(ctx, u.ID, utils.CurrentPasswordPolicy().HistorySize)
+	if err != nil {
+		return false, err
+	}
+
+	for _, hash := range history {
+		if utils.CheckPasswordHash(password, hash) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// UpdatePassword sets a new password that passes ValidateNewPassword and
+// remembers the replaced one.
+func (es *userService) UpdatePassword(ctx context.Context, id uint64, password string) error {
+	u, err := es.FindByID(ctx, id)
+	if err != nil {
+		return common.ErrorNotFound.Clone().WrapMessage(models.ErrUserNotFound.Error())
+	}
+
+	if err := es.ValidateNewPassword(ctx, u, password); err != nil {
+		return err
+	}
+
+	updates, err := newPasswordUpdates(password)
+	if err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	if err := es.er.ChangeUserPassword(ctx, id, updates, u.HashPassword, utils.CurrentPasswordPolicy().HistorySize); err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return nil
+}
+
+// newPasswordUpdates hashes the password into the columns a password change
+// sets.
+func newPasswordUpdates(password string) (map[string]interface{}, error) {
+	hashPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"hash_password":       hashPassword,
+		"password_changed_at": time.Now().UTC(),
+	}, nil
+}
+
+// RehashPassword stores a fresh hash of the unchanged password, used when the
+// hashing settings changed since it was set.
+func (es *userService) RehashPassword(ctx context.Context, id uint64, password string) error {
+	hashPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	return es.er.UpdateUser(ctx, id, map[string]interface{}{"hash_password": hashPassword})
+}
+
+// PasswordExpired reports whether the password is older than the strictest
+// expiry among the user's roles.
+func (es *userService) PasswordExpired(ctx context.Context, u *models.User) (bool, error) {
+	days, err := es.er.GetUserPasswordMaxAge(ctx, u.ID)
+	if err != nil || days == 0 {
+		return false, err
+	}
+
+	changedAt := u.PasswordChangedAt
+	if changedAt == nil {
+		changedAt = u.CreatedAt
+	}
+	if changedAt == nil {
+		return false, nil
+	}
+
+	return time.Now().UTC().After(changedAt.Add(time.Duration(days) * 24 * time.Hour)), nil
+}
+
 func (es *userService) DeleteUser(ctx context.Context, id uint64) error {
 	// Check if user exists
 	_, err := es.FindByID(ctx, id)
@@ -154,6 +424,9 @@ func (es *userService) DeleteUser(ctx context.Context, id uint64) error {
 	}
 
 	if err := es.er.DeleteUser(ctx, id); err != nil {
+		if errors.Is(err, models.ErrLastSuperAdmin) {
+			return common.ErrorValidation.Clone().SetDetail("id", err.Error())
+		}
 		return common.ErrorInternal.Clone().WrapErrorSafe(err)
 	}
 
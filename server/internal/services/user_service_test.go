@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/requests"
+)
+
+// fakeRoleAssignments resolves permissions from role assignments kept in
+// memory.
+type fakeRoleAssignments struct {
+	*fakeUserRepo
+	userRoles       map[uint64][]*models.UserRole
+	rolePermissions map[int64][]int64
+}
+
+func (r *fakeRoleAssignments) GetUserRoles(ctx context.Context, userID uint64) ([]*models.UserRole, error) {
+	return r.userRoles[userID], nil
+}
+
+func (r *fakeRoleAssignments) GetRolePermissionIDs(ctx context.Context, roleIDs []int64) ([]int64, error) {
+	var out []int64
+	for _, id := range roleIDs {
+		out = append(out, r.rolePermissions[id]...)
+	}
+
+	return out, nil
+}
+
+func (r *fakeRoleAssignments) GetUserPermissionIDsInCompanies(ctx context.Context, userID uint64, companyIDs []uint64) ([]int64, error) {
+	var out []int64
+	for _, ur := range r.userRoles[userID] {
+		if ur.CompanyID == nil || slices.Contains(companyIDs, *ur.CompanyID) {
+			out = append(out, r.rolePermissions[ur.RoleID]...)
+		}
+	}
+
+	return out, nil
+}
+
+func (r *fakeRoleAssignments) GetUserCompanyIDs(ctx context.Context, userID uint64) ([]uint64, error) {
+	return []uint64{1}, nil
+}
+
+func (r *fakeRoleAssignments) IsUserManagerOf(ctx context.Context, managerID, userID uint64) (bool, error) {
+	return false, nil
+}
+
+// A user administrator of a company cannot take over the accounts of users
+// holding roles it could not assign.
+func TestGetUserAccessActorCanAssignTargetRoles(t *testing.T) {
+	const (
+		superAdmin uint64 = iota + 1
+		admin
+		hrManager
+		employee
+	)
+	company := uint64(1)
+
+	employeePermissions := []int64{models.PermissionReadUser, models.PermissionViewOwnReport}
+	hrPermissions := append([]int64{models.PermissionCreateUser, models.PermissionUpdateUser}, employeePermissions...)
+	adminPermissions := append([]int64{models.PermissionDeleteUser, models.PermissionManageRoles}, hrPermissions...)
+
+	repo := &fakeRoleAssignments{
+		fakeUserRepo: &fakeUserRepo{},
+		userRoles: map[uint64][]*models.UserRole{
+			superAdmin: {{RoleID: models.RoleSuperAdmin}},
+			admin:      {{RoleID: models.RoleAdmin, CompanyID: &company}},
+			hrManager:  {{RoleID: models.RoleHRManager, CompanyID: &company}},
+			employee:   {{RoleID: models.RoleEmployee, CompanyID: &company}},
+		},
+		rolePermissions: map[int64][]int64{
+			models.RoleSuperAdmin: append([]int64{models.PermissionManageServiceAccounts}, adminPermissions...),
+			models.RoleAdmin:      adminPermissions,
+			models.RoleHRManager:  hrPermissions,
+			models.RoleEmployee:   employeePermissions,
+		},
+	}
+	es := NewUserService(repo)
+
+	tests := []struct {
+		name   string
+		actor  uint64
+		target uint64
+		want   bool
+	}{
+		{"hr manager on employee", hrManager, employee, true},
+		{"hr manager on admin", hrManager, admin, false},
+		{"hr manager on super admin", hrManager, superAdmin, false},
+		{"admin on hr manager", admin, hrManager, true},
+		{"admin on super admin", admin, superAdmin, false},
+		{"super admin on admin", superAdmin, admin, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uid := common.NewUID(uint32(tt.actor), common.ObjectTypeUser, 1)
+
+			actor, err := es.GetUserAccessActor(context.Background(), uid.String(), tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actor.CanAssignTargetRoles != tt.want {
+				t.Errorf("CanAssignTargetRoles = %v, want %v", actor.CanAssignTargetRoles, tt.want)
+			}
+		})
+	}
+}
+
+// A new address must be verified again.
+func TestUpdateUserEmailNeedsVerification(t *testing.T) {
+	now := time.Now().UTC()
+	users := &fakeUserRepo{users: []*models.User{
+		{SQLModel: models.SQLModel{ID: 1}, FullName: "Jane Doe", Email: "jane@example.com", EmailVerifiedAt: &now},
+	}}
+	es := NewUserService(users)
+
+	name := "Jane Roe"
+	if err := es.UpdateUser(context.Background(), 1, &requests.UpdateUserRequest{FullName: &name}); err != nil {
+		t.Fatal(err)
+	}
+	if users.users[0].EmailVerifiedAt == nil {
+		t.Fatal("changing the name cleared the email verification")
+	}
+
+	email := "jane.roe@example.com"
+	if err := es.UpdateUser(context.Background(), 1, &requests.UpdateUserRequest{Email: &email}); err != nil {
+		t.Fatal(err)
+	}
+	if u := users.users[0]; u.Email != email || u.EmailVerifiedAt != nil {
+		t.Errorf("after the email change the user is %s, verified at %v", u.Email, u.EmailVerifiedAt)
+	}
+}
@@ -0,0 +1,440 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/dto"
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/requests"
+)
+
+const expWebAuthnSession = 5 * time.Minute
+
+type WebAuthnRepo interface {
+	CreateWebAuthnCredential(ctx context.Context, data *models.WebAuthnCredential) error
+	GetWebAuthnCredentials(ctx context.Context, userID uint64) ([]*models.WebAuthnCredential, error)
+	UpdateWebAuthnCredential(ctx context.Context, id uint64, data map[string]interface{}) error
+	DeleteWebAuthnCredential(ctx context.Context, userID uint64, credentialID string) (bool, error)
+	CreateWebAuthnSession(ctx context.Context, data *models.WebAuthnSession) error
+	TakeWebAuthnSession(ctx context.Context, id, purpose string) (*models.WebAuthnSession, error)
+}
+
+// webAuthnUser adapts a user and their stored passkeys to webauthn.User. The
+// user handle is the public user UID, so discoverable logins can be mapped
+// back to the account.
+type webAuthnUser struct {
+	user    *models.User
+	records []*models.WebAuthnCredential
+	creds   []webauthn.Credential
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	uid := common.NewUID(uint32(u.user.ID), common.ObjectTypeUser, 1)
+	return []byte(uid.String())
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return u.user.FullName
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.creds
+}
+
+// record returns the stored row of the credential with the given raw ID.
+func (u *webAuthnUser) record(id []byte) *models.WebAuthnCredential {
+	encoded := base64.RawURLEncoding.EncodeToString(id)
+	for _, r := range u.records {
+		if r.CredentialID == encoded {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// BeginPasskeyRegistration starts registering a new passkey for the signed in
+// user. Passkeys the user already has are excluded so the same authenticator
+// is not registered twice.
+func (as *authService) BeginPasskeyRegistration(ctx context.Context, userUID string) (*dto.WebAuthnCeremony, error) {
+	if as.opts.WebAuthn == nil {
+		return nil, common.ErrorValidation.Clone().WrapMessage(models.ErrWebAuthnDisabled.Error())
+	}
+
+	u, err := as.findByUID(ctx, userUID)
+	if err != nil {
+		return nil, err
+	}
+
+	wu, err := as.loadWebAuthnUser(ctx, u)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	creation, session, err := as.opts.WebAuthn.BeginRegistration(wu,
+		webauthn.WithExclusions(webauthn.Credentials(wu.creds).CredentialDescriptors()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	sessionID, err := as.saveWebAuthnSession(ctx, &u.ID, models.WebAuthnPurposeRegistration, session)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return &dto.WebAuthnCeremony{SessionID: sessionID, Options: creation}, nil
+}
+
+// FinishPasskeyRegistration verifies the attestation and stores the passkey.
+func (as *authService) FinishPasskeyRegistration(ctx context.Context, userUID string, data *requests.WebAuthnRegisterRequest) (*models.WebAuthnCredential, error) {
+	if as.opts.WebAuthn == nil {
+		return nil, common.ErrorValidation.Clone().WrapMessage(models.ErrWebAuthnDisabled.Error())
+	}
+
+	u, err := as.findByUID(ctx, userUID)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := as.takeWebAuthnSession(ctx, data.SessionID, models.WebAuthnPurposeRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if session.userID == nil || *session.userID != u.ID {
+		return nil, common.ErrorValidation.Clone().WrapMessage(models.ErrInvalidWebAuthnSession.Error())
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(data.Credential)
+	if err != nil {
+		return nil, webAuthnError(err)
+	}
+
+	wu, err := as.loadWebAuthnUser(ctx, u)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	credential, err := as.opts.WebAuthn.CreateCredential(wu, session.data, parsed)
+	if err != nil {
+		return nil, webAuthnError(err)
+	}
+
+	encoded, err := json.Marshal(credential)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	now := time.Now().UTC()
+	record := &models.WebAuthnCredential{
+		UserID:       u.ID,
+		CredentialID: base64.RawURLEncoding.EncodeToString(credential.ID),
+		Credential:   string(encoded),
+		CreatedAt:    &now,
+	}
+	if data.Name != "" {
+		record.Name = &data.Name
+	}
+
+	if err := as.rt.CreateWebAuthnCredential(ctx, record); err != nil {
+		return nil, common.ErrorCreateFailed.Clone().WrapErrorSafe(err)
+	}
+
+	return record, nil
+}
+
+// ListPasskeys returns the passkeys registered by the user.
+func (as *authService) ListPasskeys(ctx context.Context, userUID string) ([]*models.WebAuthnCredential, error) {
+	userID, err := userIDFromUID(userUID)
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := as.rt.GetWebAuthnCredentials(ctx, userID)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return credentials, nil
+}
+
+func (as *authService) DeletePasskey(ctx context.Context, userUID, credentialID string) error {
+	userID, err := userIDFromUID(userUID)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := as.rt.DeleteWebAuthnCredential(ctx, userID, credentialID)
+	if err != nil {
+		return common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+	if !deleted {
+		return common.ErrorNotFound.Clone().WrapMessage(models.ErrWebAuthnCredentialNotFound.Error())
+	}
+
+	return nil
+}
+
+// BeginPasskeyLogin starts a login ceremony. Without an email the
+// authenticator picks the account (a discoverable login). With one the
+// ceremony is limited to the passkeys of the account; an email without an
+// account or passkeys gets decoy passkeys, so the response does not reveal
+// whether the account exists.
+func (as *authService) BeginPasskeyLogin(ctx context.Context, data *requests.WebAuthnLoginBeginRequest) (*dto.WebAuthnCeremony, error) {
+	if as.opts.WebAuthn == nil {
+		return nil, common.ErrorValidation.Clone().WrapMessage(models.ErrWebAuthnDisabled.Error())
+	}
+
+	var (
+		assertion *protocol.CredentialAssertion
+		session   *webauthn.SessionData
+		userID    *uint64
+		err       error
+	)
+
+	if data.Email == "" {
+		assertion, session, err = as.opts.WebAuthn.BeginDiscoverableLogin()
+	} else {
+		var wu *webAuthnUser
+		if u, findErr := as.es.FindByEmail(ctx, data.Email); findErr == nil {
+			if wu, err = as.loadWebAuthnUser(ctx, u); err != nil {
+				return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+			}
+		}
+
+		if wu != nil && len(wu.creds) > 0 {
+			userID = &wu.user.ID
+		} else {
+			// No session user: the finish step can never match a decoy
+			wu = as.decoyWebAuthnUser(data.Email)
+		}
+
+		assertion, session, err = as.opts.WebAuthn.BeginLogin(wu,
+			webauthn.WithAllowedCredentials(allowedCredentials(wu.creds)),
+		)
+	}
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	sessionID, err := as.saveWebAuthnSession(ctx, userID, models.WebAuthnPurposeLogin, session)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	return &dto.WebAuthnCeremony{SessionID: sessionID, Options: assertion}, nil
+}
+
+// FinishPasskeyLogin verifies the assertion and issues the token pair. A
+// passkey that verified the user (PIN or biometrics) already counts as two
+// factors; otherwise the usual MFA step follows.
+func (as *authService) FinishPasskeyLogin(ctx context.Context, data *requests.WebAuthnLoginRequest) (*models.Auth, error) {
+	if as.opts.WebAuthn == nil {
+		return nil, common.ErrorValidation.Clone().WrapMessage(models.ErrWebAuthnDisabled.Error())
+	}
+
+	session, err := as.takeWebAuthnSession(ctx, data.SessionID, models.WebAuthnPurposeLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(data.Credential)
+	if err != nil {
+		return nil, webAuthnError(err)
+	}
+
+	var (
+		wu         *webAuthnUser
+		credential *webauthn.Credential
+	)
+
+	if session.userID != nil {
+		u, findErr := as.es.FindByID(ctx, *session.userID)
+		if findErr != nil {
+			return nil, common.ErrorUnauthorized.Clone().WrapMessage(models.ErrWebAuthnFailed.Error())
+		}
+		if wu, err = as.loadWebAuthnUser(ctx, u); err != nil {
+			return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+		}
+		if credential, err = as.opts.WebAuthn.ValidateLogin(wu, session.data, parsed); err != nil {
+			return nil, webAuthnError(err)
+		}
+	} else {
+		handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+			userID, err := userIDFromUID(string(userHandle))
+			if err != nil {
+				return nil, err
+			}
+
+			u, err := as.es.FindByID(ctx, userID)
+			if err != nil {
+				return nil, err
+			}
+
+			return as.loadWebAuthnUser(ctx, u)
+		}
+
+		user, validated, err := as.opts.WebAuthn.ValidatePasskeyLogin(handler, session.data, parsed)
+		if err != nil {
+			return nil, webAuthnError(err)
+		}
+		wu, credential = user.(*webAuthnUser), validated
+	}
+
+	// A sign count that went backwards means the key has been cloned
+	if credential.Authenticator.CloneWarning {
+		return nil, common.ErrorUnauthorized.Clone().WrapMessage(models.ErrWebAuthnFailed.Error())
+	}
+
+	record := wu.record(credential.ID)
+	if record == nil {
+		return nil, common.ErrorUnauthorized.Clone().WrapMessage(models.ErrWebAuthnFailed.Error())
+	}
+
+	encoded, err := json.Marshal(credential)
+	if err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	if err := as.rt.UpdateWebAuthnCredential(ctx, record.ID, map[string]interface{}{
+		"credential":   string(encoded),
+		"last_used_at": time.Now().UTC(),
+	}); err != nil {
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	u := wu.user
+	if u.EmailVerifiedAt == nil {
+		return nil, common.ErrorUnauthorized.Clone().WrapKey("EMAIL_NOT_VERIFIED").WrapMessage(models.ErrEmailNotVerified.Error())
+	}
+
+	if !credential.Flags.UserVerified {
+		challenge, err := as.mfaChallenge(ctx, u, data.Device)
+		if err != nil {
+			return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+		}
+		if challenge != nil {
+			return challenge, nil
+		}
+	}
+
+	return as.issueLoginTokens(ctx, u, data.Device)
+}
+
+// decoyWebAuthnUser stands in for an email without passkeys. Its one or two
+// credential IDs are derived from the email, so asking twice gives the same
+// answer, like for a real account.
+func (as *authService) decoyWebAuthnUser(email string) *webAuthnUser {
+	mac := hmac.New(sha256.New, []byte(as.opts.VerifySecret))
+	mac.Write([]byte("passkey-decoy:" + strings.ToLower(strings.TrimSpace(email))))
+	sum := mac.Sum(nil)
+
+	creds := []webauthn.Credential{{ID: sum[:16]}}
+	if sum[31]%2 == 1 {
+		creds = append(creds, webauthn.Credential{ID: sum[16:]})
+	}
+
+	return &webAuthnUser{user: &models.User{Email: email}, creds: creds}
+}
+
+// allowedCredentials lists the credentials by ID alone: transports would
+// tell real passkeys from decoys.
+func allowedCredentials(creds []webauthn.Credential) []protocol.CredentialDescriptor {
+	descriptors := make([]protocol.CredentialDescriptor, 0, len(creds))
+	for _, c := range creds {
+		descriptors = append(descriptors, protocol.CredentialDescriptor{
+			Type:         protocol.PublicKeyCredentialType,
+			CredentialID: c.ID,
+		})
+	}
+
+	return descriptors
+}
+
+func (as *authService) loadWebAuthnUser(ctx context.Context, u *models.User) (*webAuthnUser, error) {
+	records, err := as.rt.GetWebAuthnCredentials(ctx, u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	creds := make([]webauthn.Credential, 0, len(records))
+	for _, r := range records {
+		var c webauthn.Credential
+		if err := json.Unmarshal([]byte(r.Credential), &c); err != nil {
+			return nil, err
+		}
+		creds = append(creds, c)
+	}
+
+	return &webAuthnUser{user: u, records: records, creds: creds}, nil
+}
+
+// webAuthnSession is a stored ceremony with its decoded session data.
+type webAuthnSession struct {
+	userID *uint64
+	data   webauthn.SessionData
+}
+
+func (as *authService) saveWebAuthnSession(ctx context.Context, userID *uint64, purpose string, session *webauthn.SessionData) (string, error) {
+	encoded, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	record := &models.WebAuthnSession{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Purpose:   purpose,
+		Data:      string(encoded),
+		ExpiresAt: now.Add(expWebAuthnSession),
+		CreatedAt: &now,
+	}
+
+	if err := as.rt.CreateWebAuthnSession(ctx, record); err != nil {
+		return "", err
+	}
+
+	return record.ID, nil
+}
+
+func (as *authService) takeWebAuthnSession(ctx context.Context, id, purpose string) (*webAuthnSession, error) {
+	invalid := common.ErrorValidation.Clone().WrapMessage(models.ErrInvalidWebAuthnSession.Error())
+
+	record, err := as.rt.TakeWebAuthnSession(ctx, id, purpose)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidWebAuthnSession) {
+			return nil, invalid
+		}
+		return nil, common.ErrorInternal.Clone().WrapErrorSafe(err)
+	}
+
+	var data webauthn.SessionData
+	if err := json.Unmarshal([]byte(record.Data), &data); err != nil {
+		return nil, invalid
+	}
+
+	return &webAuthnSession{userID: record.UserID, data: data}, nil
+}
+
+// webAuthnError hides the protocol details from the client; they are only
+// exposed in development.
+func webAuthnError(err error) error {
+	return common.ErrorUnauthorized.Clone().WrapMessage(models.ErrWebAuthnFailed.Error()).WrapErrorSafe(err)
+}
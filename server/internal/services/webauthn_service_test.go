@@ -0,0 +1,359 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/vlahanam/company-management/common"
+	"github.com/vlahanam/company-management/internal/models"
+	"github.com/vlahanam/company-management/internal/requests"
+	"github.com/vlahanam/company-management/utils"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "https://localhost"
+)
+
+// softAuthenticator is an ES256 passkey held in memory. It answers the
+// options of a ceremony like navigator.credentials would, with a "none"
+// attestation.
+type softAuthenticator struct {
+	t         *testing.T
+	key       *ecdsa.PrivateKey
+	id        []byte
+	userID    []byte
+	signCount uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		t.Fatal(err)
+	}
+
+	return &softAuthenticator{t: t, key: key, id: id}
+}
+
+func (a *softAuthenticator) authData(flags protocol.AuthenticatorFlags, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(testRPID))
+
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, byte(flags))
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+
+	return append(data, attested...)
+}
+
+func (a *softAuthenticator) clientData(ceremony protocol.CeremonyType, challenge []byte) []byte {
+	data, err := json.Marshal(map[string]string{
+		"type":      string(ceremony),
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    testOrigin,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return data
+}
+
+// create answers navigator.credentials.create.
+func (a *softAuthenticator) create(options interface{}) json.RawMessage {
+	a.t.Helper()
+
+	creation := options.(*protocol.CredentialCreation)
+	a.userID = creation.Response.User.ID.(protocol.URLEncodedBase64)
+
+	coseKey, err := cbor.Marshal(map[int]interface{}{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		-3: a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	attested := make([]byte, 16) // AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.id)))
+	attested = append(attested, a.id...)
+	attested = append(attested, coseKey...)
+
+	flags := protocol.FlagUserPresent | protocol.FlagUserVerified | protocol.FlagAttestedCredentialData
+	attestation, err := cbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": a.authData(flags, attested),
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return a.marshal(map[string]string{
+		"clientDataJSON":    base64.RawURLEncoding.EncodeToString(a.clientData(protocol.CreateCeremony, creation.Response.Challenge)),
+		"attestationObject": base64.RawURLEncoding.EncodeToString(attestation),
+	})
+}
+
+// get answers navigator.credentials.get. verified tells whether the user
+// unlocked the passkey with a PIN or biometrics.
+func (a *softAuthenticator) get(options interface{}, verified bool) json.RawMessage {
+	a.t.Helper()
+
+	assertion := options.(*protocol.CredentialAssertion)
+	a.signCount++
+
+	flags := protocol.FlagUserPresent
+	if verified {
+		flags |= protocol.FlagUserVerified
+	}
+	authData := a.authData(flags, nil)
+	clientData := a.clientData(protocol.AssertCeremony, assertion.Response.Challenge)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return a.marshal(map[string]string{
+		"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+		"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+		"signature":         base64.RawURLEncoding.EncodeToString(signature),
+		"userHandle":        base64.RawURLEncoding.EncodeToString(a.userID),
+	})
+}
+
+func (a *softAuthenticator) marshal(response map[string]string) json.RawMessage {
+	id := base64.RawURLEncoding.EncodeToString(a.id)
+	data, err := json.Marshal(map[string]interface{}{
+		"id":       id,
+		"rawId":    id,
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+
+	return data
+}
+
+func newWebAuthnTestService(t *testing.T) (*authService, *fakeAuthRepo, *models.User) {
+	t.Helper()
+
+	rp, err := webauthn.New(&webauthn.Config{
+		RPID:          testRPID,
+		RPDisplayName: "Company Management",
+		RPOrigins:     []string{testOrigin},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	u := &models.User{SQLModel: models.SQLModel{ID: 1}, FullName: "Jane Doe", Email: "jane@example.com", EmailVerifiedAt: &now}
+	users := &fakeUserRepo{users: []*models.User{u}}
+	repo := &fakeAuthRepo{users: users}
+	as := NewAuthService(NewUserService(users), repo, &AuthOptions{
+		AccessKeys:   utils.NewHMACKeySet("access-secret"),
+		RefreshKeys:  utils.NewHMACKeySet("refresh-secret"),
+		VerifySecret: "verify-secret",
+		WebAuthn:     rp,
+	})
+
+	return as, repo, u
+}
+
+// registerPasskey runs a registration ceremony for the user.
+func registerPasskey(t *testing.T, as *authService, u *models.User) *softAuthenticator {
+	t.Helper()
+
+	ctx := context.Background()
+	uid := common.NewUID(uint32(u.ID), common.ObjectTypeUser, 1)
+	userUID := uid.String()
+	authenticator := newSoftAuthenticator(t)
+
+	ceremony, err := as.BeginPasskeyRegistration(ctx, userUID)
+	if err != nil {
+		t.Fatalf("BeginPasskeyRegistration() = %v", err)
+	}
+
+	record, err := as.FinishPasskeyRegistration(ctx, userUID, &requests.WebAuthnRegisterRequest{
+		SessionID:  ceremony.SessionID,
+		Credential: authenticator.create(ceremony.Options),
+	})
+	if err != nil {
+		t.Fatalf("FinishPasskeyRegistration() = %v", err)
+	}
+	if record.CredentialID != base64.RawURLEncoding.EncodeToString(authenticator.id) {
+		t.Fatalf("CredentialID = %s, want the authenticator's", record.CredentialID)
+	}
+
+	return authenticator
+}
+
+func TestPasskeyLogin(t *testing.T) {
+	tests := []struct {
+		name     string
+		email    string
+		verified bool
+		tokens   bool
+	}{
+		{"with email", "jane@example.com", true, true},
+		{"discoverable", "", true, true},
+		{"without user verification", "jane@example.com", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			as, repo, u := newWebAuthnTestService(t)
+			authenticator := registerPasskey(t, as, u)
+
+			ceremony, err := as.BeginPasskeyLogin(ctx, &requests.WebAuthnLoginBeginRequest{Email: tt.email})
+			if err != nil {
+				t.Fatalf("BeginPasskeyLogin() = %v", err)
+			}
+
+			allowed := ceremony.Options.(*protocol.CredentialAssertion).Response.AllowedCredentials
+			if tt.email == "" && len(allowed) != 0 {
+				t.Errorf("discoverable login allows %d credentials, want none", len(allowed))
+			}
+			if tt.email != "" && (len(allowed) != 1 || !bytes.Equal(allowed[0].CredentialID, authenticator.id)) {
+				t.Errorf("allowCredentials = %v, want the registered passkey", allowed)
+			}
+
+			auth, err := as.FinishPasskeyLogin(ctx, &requests.WebAuthnLoginRequest{
+				SessionID:  ceremony.SessionID,
+				Credential: authenticator.get(ceremony.Options, tt.verified),
+			})
+			if err != nil {
+				t.Fatalf("FinishPasskeyLogin() = %v", err)
+			}
+			if auth.AccessToken == "" || auth.RefreshToken == "" {
+				t.Errorf("FinishPasskeyLogin() = %+v, want a token pair", auth)
+			}
+
+			var stored webauthn.Credential
+			if err := json.Unmarshal([]byte(repo.credentials[0].Credential), &stored); err != nil {
+				t.Fatal(err)
+			}
+			if stored.Authenticator.SignCount != authenticator.signCount {
+				t.Errorf("SignCount = %d, want %d", stored.Authenticator.SignCount, authenticator.signCount)
+			}
+		})
+	}
+}
+
+func TestPasskeyLoginRequiresSecondFactor(t *testing.T) {
+	ctx := context.Background()
+	as, _, u := newWebAuthnTestService(t)
+	authenticator := registerPasskey(t, as, u)
+
+	now := time.Now().UTC()
+	u.TOTPEnabledAt = &now
+
+	for _, verified := range []bool{true, false} {
+		ceremony, err := as.BeginPasskeyLogin(ctx, &requests.WebAuthnLoginBeginRequest{Email: u.Email})
+		if err != nil {
+			t.Fatalf("BeginPasskeyLogin() = %v", err)
+		}
+
+		auth, err := as.FinishPasskeyLogin(ctx, &requests.WebAuthnLoginRequest{
+			SessionID:  ceremony.SessionID,
+			Credential: authenticator.get(ceremony.Options, verified),
+		})
+		if err != nil {
+			t.Fatalf("FinishPasskeyLogin() = %v", err)
+		}
+		if auth.MFARequired == verified {
+			t.Errorf("verified=%v: MFARequired = %v", verified, auth.MFARequired)
+		}
+	}
+}
+
+func TestPasskeyLoginRejectsReplay(t *testing.T) {
+	ctx := context.Background()
+	as, _, u := newWebAuthnTestService(t)
+	authenticator := registerPasskey(t, as, u)
+
+	ceremony, err := as.BeginPasskeyLogin(ctx, &requests.WebAuthnLoginBeginRequest{Email: u.Email})
+	if err != nil {
+		t.Fatalf("BeginPasskeyLogin() = %v", err)
+	}
+
+	req := &requests.WebAuthnLoginRequest{SessionID: ceremony.SessionID, Credential: authenticator.get(ceremony.Options, true)}
+	if _, err := as.FinishPasskeyLogin(ctx, req); err != nil {
+		t.Fatalf("FinishPasskeyLogin() = %v", err)
+	}
+	if _, err := as.FinishPasskeyLogin(ctx, req); err == nil {
+		t.Error("FinishPasskeyLogin() accepted the same ceremony twice")
+	}
+}
+
+// An email without passkeys must get an answer shaped like one with them.
+func TestBeginPasskeyLoginDecoys(t *testing.T) {
+	ctx := context.Background()
+	as, _, u := newWebAuthnTestService(t)
+
+	allowed := func(email string) []protocol.CredentialDescriptor {
+		ceremony, err := as.BeginPasskeyLogin(ctx, &requests.WebAuthnLoginBeginRequest{Email: email})
+		if err != nil {
+			t.Fatalf("BeginPasskeyLogin(%s) = %v", email, err)
+		}
+		return ceremony.Options.(*protocol.CredentialAssertion).Response.AllowedCredentials
+	}
+
+	for _, email := range []string{"nobody@example.com", u.Email} {
+		first, second := allowed(email), allowed(email)
+
+		if len(first) == 0 || len(first) > 2 {
+			t.Fatalf("%s: %d decoy credentials, want 1 or 2", email, len(first))
+		}
+		for i := range first {
+			if !bytes.Equal(first[i].CredentialID, second[i].CredentialID) {
+				t.Errorf("%s: decoy credentials change between requests", email)
+			}
+			if len(first[i].Transport) != 0 {
+				t.Errorf("%s: decoy credential lists transports", email)
+			}
+		}
+	}
+
+	// A real passkey cannot finish a ceremony started for a decoy
+	authenticator := registerPasskey(t, as, u)
+
+	ceremony, err := as.BeginPasskeyLogin(ctx, &requests.WebAuthnLoginBeginRequest{Email: "nobody@example.com"})
+	if err != nil {
+		t.Fatalf("BeginPasskeyLogin() = %v", err)
+	}
+	if _, err := as.FinishPasskeyLogin(ctx, &requests.WebAuthnLoginRequest{
+		SessionID:  ceremony.SessionID,
+		Credential: authenticator.get(ceremony.Options, true),
+	}); err == nil {
+		t.Error("FinishPasskeyLogin() accepted a decoy ceremony")
+	}
+}
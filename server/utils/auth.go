@@ -1,8 +1,11 @@
 package utils
 
 import (
+	"context"
 	"errors"
+	"math"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
@@ -25,9 +28,47 @@ var (
 	ErrPermissionDenied   = errors.New("you do not have permission to access this resource")
 )
 
-func AuthMiddleware(accessSecret string) fiber.Handler {
+// TokenRevocationChecker reports whether an access token was revoked before
+// its expiry.
+type TokenRevocationChecker interface {
+	IsTokenRevoked(ctx context.Context, jti, sessionID, userUID string, issuedAt time.Time) (bool, error)
+}
+
+// APIKeyAuthenticator resolves an API key to the base58 ids of the key and of
+// the service account it belongs to.
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (string, string, error)
+}
+
+// AuthMiddleware accepts a bearer access token, or an API key sent in the
+// X-API-Key header or as "Authorization: ApiKey <key>". API key callers get
+// claims whose user_id is the key id and that carry no roles.
+func AuthMiddleware(accessKeys *KeySet, revocations TokenRevocationChecker, apiKeys APIKeyAuthenticator) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
+
+		apiKey := c.Get("X-API-Key")
+		if apiKey == "" && strings.HasPrefix(authHeader, "ApiKey ") {
+			apiKey = strings.TrimPrefix(authHeader, "ApiKey ")
+		}
+		if apiKey != "" {
+			keyUID, accountUID, err := apiKeys.AuthenticateAPIKey(c.UserContext(), apiKey)
+			if err != nil {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"key":   ErrTokenExpiredKey,
+					"error": ErrTokenExpired,
+				})
+			}
+
+			c.Locals("userClaims", jwt.MapClaims{
+				"user_id":            keyUID,
+				"roles":              []interface{}{},
+				"service_account_id": accountUID,
+			})
+
+			return c.Next()
+		}
+
 		if authHeader == "" {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 				"key":   ErrTokenMissingKey,
@@ -44,9 +85,7 @@ func AuthMiddleware(accessSecret string) fiber.Handler {
 
 		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
 
-		token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-			return []byte(accessSecret), nil
-		})
+		token, err := accessKeys.Parse(tokenStr)
 
 		if err != nil || !token.Valid {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
@@ -55,14 +94,52 @@ func AuthMiddleware(accessSecret string) fiber.Handler {
 			})
 		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			c.Locals("userClaims", claims)
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok || isRevoked(c, revocations, claims) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"key":   ErrTokenExpiredKey,
+				"error": ErrTokenExpired,
+			})
 		}
 
+		c.Locals("userClaims", claims)
+
 		return c.Next()
 	}
 }
 
+// isRevoked treats tokens without jti/iat, or a failing revocation lookup, as
+// revoked.
+func isRevoked(c *fiber.Ctx, revocations TokenRevocationChecker, claims jwt.MapClaims) bool {
+	jti, _ := claims["jti"].(string)
+	sessionID, _ := claims["sid"].(string)
+	userID, _ := claims["user_id"].(string)
+	issuedAt, ok := issuedAtClaim(claims)
+	if jti == "" || userID == "" || !ok {
+		return true
+	}
+
+	revoked, err := revocations.IsTokenRevoked(c.UserContext(), jti, sessionID, userID, issuedAt)
+	return err != nil || revoked
+}
+
+// IssuedAt is the iat claim of a new access token. It keeps the
+// milliseconds, so a token issued right after the user's tokens were revoked
+// is not taken for one of them.
+func IssuedAt(t time.Time) float64 {
+	return float64(t.UnixMilli()) / 1e3
+}
+
+// issuedAtClaim reads iat to the millisecond; GetIssuedAt drops the fraction.
+func issuedAtClaim(claims jwt.MapClaims) (time.Time, bool) {
+	iat, ok := claims["iat"].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return time.UnixMilli(int64(math.Round(iat * 1e3))), true
+}
+
 func CheckRole(allowedRoles []string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		userClaims := protectedHandler(c)
@@ -98,6 +175,125 @@ func CheckRole(allowedRoles []string) fiber.Handler {
 	}
 }
 
+// PermissionResolver returns the effective permission ids of the user whose
+// base58 id is stored in the access token claims.
+type PermissionResolver interface {
+	GetPermissionIDsByUserUID(ctx context.Context, userUID string) ([]int64, error)
+}
+
+// CheckPermission allows the request only when the caller holds every one of
+// the given permissions through roles assigned in every company.
+func CheckPermission(resolver PermissionResolver, permissions ...int64) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userClaims := protectedHandler(c)
+
+		granted, err := resolver.GetPermissionIDsByUserUID(c.UserContext(), userClaims.userID)
+		if err != nil || !hasPermissions(granted, permissions) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"key":   ErrPermissionDeniedKey,
+				"error": ErrPermissionDenied.Error(),
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// RoleChecker reports whether the caller is assigned the role in every
+// company.
+type RoleChecker interface {
+	HasGlobalRole(ctx context.Context, userUID string, roleID int64) (bool, error)
+}
+
+// CheckGlobalRole allows the request only when the caller is assigned the
+// role, by id, in every company. Unlike CheckRole it does not trust the role
+// names carried in the access token.
+func CheckGlobalRole(checker RoleChecker, roleID int64) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userClaims := protectedHandler(c)
+
+		ok, err := checker.HasGlobalRole(c.UserContext(), userClaims.userID, roleID)
+		if err != nil || !ok {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"key":   ErrPermissionDeniedKey,
+				"error": ErrPermissionDenied.Error(),
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// ScopedPermissionResolver returns the permission ids the caller holds in at
+// least one of the given companies.
+type ScopedPermissionResolver interface {
+	GetPermissionIDsInCompanies(ctx context.Context, userUID string, companyIDs []uint64) ([]int64, error)
+}
+
+// CompanyResolver returns the companies a request targets. No companies means
+// the request is not about a particular company.
+type CompanyResolver func(c *fiber.Ctx) ([]uint64, error)
+
+// CheckPermissionIn allows the request only when the caller holds every one
+// of the given permissions in the company the request targets. Requests that
+// target no company, or whose company cannot be resolved, need the
+// permissions in every company.
+func CheckPermissionIn(resolver ScopedPermissionResolver, target CompanyResolver, permissions ...int64) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userClaims := protectedHandler(c)
+
+		companyIDs, err := target(c)
+		if err != nil {
+			companyIDs = nil
+		}
+
+		granted, err := resolver.GetPermissionIDsInCompanies(c.UserContext(), userClaims.userID, companyIDs)
+		if err != nil || !hasPermissions(granted, permissions) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"key":   ErrPermissionDeniedKey,
+				"error": ErrPermissionDenied.Error(),
+			})
+		}
+
+		c.Locals("permissionCompanies", companyIDs)
+		return c.Next()
+	}
+}
+
+// GetPermissionCompanies returns the companies CheckPermissionIn allowed the
+// request in, none when the permissions were checked in every company.
+func GetPermissionCompanies(c *fiber.Ctx) []uint64 {
+	companyIDs, _ := c.Locals("permissionCompanies").([]uint64)
+	return companyIDs
+}
+
+func hasPermissions(granted, required []int64) bool {
+	set := make(map[int64]struct{}, len(granted))
+	for _, p := range granted {
+		set[p] = struct{}{}
+	}
+
+	for _, p := range required {
+		if _, ok := set[p]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// GetSessionID returns the session of the caller's access token, empty for
+// API keys and tokens issued before sessions were recorded.
+func GetSessionID(c *fiber.Ctx) string {
+	sessionID, _ := c.Locals("userClaims").(jwt.MapClaims)["sid"].(string)
+	return sessionID
+}
+
+// GetUserUID returns the base58 user id of the authenticated caller.
+func GetUserUID(c *fiber.Ctx) string {
+	return protectedHandler(c).userID
+}
+
 func protectedHandler(c *fiber.Ctx) *UserClaims {
 	claims := c.Locals("userClaims").(jwt.MapClaims)
 
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ClientInfo describes the client that sent a request.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+type clientInfoKey struct{}
+
+// ClientInfoMiddleware stores the client IP and user agent in the request
+// context, so services can record them without every handler passing them on.
+func ClientInfoMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		info := ClientInfo{
+			IP:        c.IP(),
+			UserAgent: c.Get(fiber.HeaderUserAgent),
+		}
+		c.SetUserContext(context.WithValue(c.UserContext(), clientInfoKey{}, info))
+
+		return c.Next()
+	}
+}
+
+// ClientInfoFrom returns the client stored by ClientInfoMiddleware, or an empty
+// ClientInfo outside of a request.
+func ClientInfoFrom(ctx context.Context) ClientInfo {
+	info, _ := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return info
+}
@@ -1,15 +1,124 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	HashAlgorithmBcrypt   = "bcrypt"
+	HashAlgorithmArgon2id = "argon2id"
+)
+
+// PasswordHashing selects how new password hashes are made. Existing hashes
+// of any supported algorithm keep verifying; PasswordNeedsRehash reports those
+// that no longer match these settings.
+type PasswordHashing struct {
+	Algorithm  string
+	BcryptCost int
+	// Argon2id parameters: memory in KiB, passes and lanes
+	Argon2Memory      uint32
+	Argon2Iterations  uint32
+	Argon2Parallelism uint8
+}
+
+var passwordHashing = PasswordHashing{
+	Algorithm:         HashAlgorithmBcrypt,
+	BcryptCost:        bcrypt.DefaultCost,
+	Argon2Memory:      64 * 1024,
+	Argon2Iterations:  3,
+	Argon2Parallelism: 2,
+}
+
+// SetPasswordHashing replaces the hashing settings. It is meant to be called
+// once at startup.
+func SetPasswordHashing(h PasswordHashing) {
+	passwordHashing = h
+}
+
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	h := passwordHashing
+
+	if h.Algorithm == HashAlgorithmArgon2id {
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+
+		key := argon2.IDKey([]byte(password), salt, h.Argon2Iterations, h.Argon2Memory, h.Argon2Parallelism, 32)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, h.Argon2Memory, h.Argon2Iterations, h.Argon2Parallelism,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+		), nil
+	}
+
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.BcryptCost)
 	return string(bytes), err
 }
 
 func CheckPasswordHash(password, hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		p, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false
+		}
+
+		other := argon2.IDKey([]byte(password), salt, p.Argon2Iterations, p.Argon2Memory, p.Argon2Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1
+	}
+
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
-}
\ No newline at end of file
+}
+
+// PasswordNeedsRehash reports whether hash was made with another algorithm or
+// with other parameters than the current settings.
+func PasswordNeedsRehash(hash string) bool {
+	h := passwordHashing
+
+	if strings.HasPrefix(hash, "$argon2id$") {
+		p, _, _, err := decodeArgon2id(hash)
+		return err != nil || h.Algorithm != HashAlgorithmArgon2id ||
+			p.Argon2Memory != h.Argon2Memory || p.Argon2Iterations != h.Argon2Iterations || p.Argon2Parallelism != h.Argon2Parallelism
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || h.Algorithm != HashAlgorithmBcrypt || cost != h.BcryptCost
+}
+
+// decodeArgon2id parses the PHC string format $argon2id$v=19$m=..,t=..,p=..$salt$key.
+func decodeArgon2id(hash string) (PasswordHashing, []byte, []byte, error) {
+	var p PasswordHashing
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, fmt.Errorf("malformed argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("unsupported argon2id version")
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Argon2Memory, &p.Argon2Iterations, &p.Argon2Parallelism); err != nil {
+		return p, nil, nil, err
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, err
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, fmt.Errorf("malformed argon2id key")
+	}
+
+	return p, salt, key, nil
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrImpersonationForbiddenKey = "IMPERSONATION_FORBIDDEN"
+	ErrImpersonationForbidden    = errors.New("this action is not allowed while impersonating a user")
+)
+
+// ImpersonationRecorder stores the audit trail of requests made with an
+// impersonation token.
+type ImpersonationRecorder interface {
+	RecordImpersonatedRequest(ctx context.Context, sessionID, method, path string, status int) error
+}
+
+// ImpersonationAuditMiddleware records every request made with an
+// impersonation token together with its response status. It must run after
+// AuthMiddleware.
+func ImpersonationAuditMiddleware(recorder ImpersonationRecorder) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if GetActorUID(c) == "" {
+			return c.Next()
+		}
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			status = fiberErr.Code
+		} else if err != nil {
+			status = fiber.StatusInternalServerError
+		}
+
+		if rerr := recorder.RecordImpersonatedRequest(c.UserContext(), GetSessionID(c), c.Method(), c.OriginalURL(), status); rerr != nil {
+			log.Printf("Failed to record impersonated request %s %s: %v", c.Method(), c.Path(), rerr)
+		}
+
+		return err
+	}
+}
+
+// DenyImpersonation rejects the request when it is made with an
+// impersonation token.
+func DenyImpersonation() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if GetActorUID(c) != "" {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"key":   ErrImpersonationForbiddenKey,
+				"error": ErrImpersonationForbidden.Error(),
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+// GetActorUID returns the base58 id of the Super Admin behind an
+// impersonation token (the act.sub claim), empty for any other caller.
+func GetActorUID(c *fiber.Ctx) string {
+	claims, _ := c.Locals("userClaims").(jwt.MapClaims)
+
+	act, _ := claims["act"].(map[string]interface{})
+	actor, _ := act["sub"].(string)
+
+	return actor
+}
@@ -0,0 +1,244 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnknownKeyID        = errors.New("unknown signing key id")
+	ErrUnexpectedAlgorithm = errors.New("unexpected signing algorithm")
+	ErrUnexpectedTokenType = errors.New("unexpected token type")
+	ErrNoSigningKey        = errors.New("no signing key configured")
+)
+
+// Token types set in the "typ" header of tokens signed with asymmetric keys.
+// Access and refresh tokens share those keys, so the header keeps one from
+// being accepted as the other.
+const (
+	TokenTypeAccess  = "at+jwt"
+	TokenTypeRefresh = "rt+jwt"
+)
+
+// SigningKey is one key of a KeySet. Verification-only keys, such as retired
+// keys kept until their tokens expire, have no private part.
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+// KeySet signs tokens with its active key and verifies them with whichever of
+// its keys the "kid" header names, requiring the algorithm of that key.
+type KeySet struct {
+	tokenType string
+	active    *SigningKey
+	keys      map[string]*SigningKey
+}
+
+// NewHMACKeySet returns a key set holding a single HS256 secret. Tokens carry
+// no kid, matching the tokens issued before asymmetric keys were supported.
+func NewHMACKeySet(secret string) *KeySet {
+	key := &SigningKey{
+		Method:  jwt.SigningMethodHS256,
+		private: []byte(secret),
+		public:  []byte(secret),
+	}
+
+	return &KeySet{
+		active: key,
+		keys:   map[string]*SigningKey{"": key},
+	}
+}
+
+// LoadKeySet reads every <kid>.pem file in dir. Private keys (PKCS#8, or
+// PKCS#1 for RSA) can sign; public keys (PKIX) only verify. RSA keys use
+// RS256 and Ed25519 keys EdDSA. activeKID selects the signing key and may be
+// empty when the directory holds a single private key.
+func LoadKeySet(dir, activeKID, tokenType string) (*KeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	ks := &KeySet{
+		tokenType: tokenType,
+		keys:      make(map[string]*SigningKey),
+	}
+
+	var signers []*SigningKey
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		kid := strings.TrimSuffix(filepath.Base(path), ".pem")
+		key, err := parseSigningKey(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		ks.keys[kid] = key
+		if key.private != nil {
+			signers = append(signers, key)
+		}
+	}
+
+	switch {
+	case activeKID != "":
+		key, ok := ks.keys[activeKID]
+		if !ok || key.private == nil {
+			return nil, fmt.Errorf("active key %q: %w", activeKID, ErrNoSigningKey)
+		}
+		ks.active = key
+	case len(signers) == 1:
+		ks.active = signers[0]
+	default:
+		return nil, fmt.Errorf("%d private keys in %s, set the active key id: %w", len(signers), dir, ErrNoSigningKey)
+	}
+
+	return ks, nil
+}
+
+func parseSigningKey(kid string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{ID: kid}
+	if signer, ok := parsed.(crypto.Signer); ok {
+		key.private = signer
+		parsed = signer.Public()
+	}
+
+	switch pub := parsed.(type) {
+	case *rsa.PublicKey:
+		key.Method = jwt.SigningMethodRS256
+		key.public = pub
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+		key.public = pub
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	return key, nil
+}
+
+// Sign signs claims with the active key and sets its kid header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if ks.active == nil || ks.active.private == nil {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(ks.active.Method, claims)
+	if ks.active.ID != "" {
+		token.Header["kid"] = ks.active.ID
+	}
+	if ks.tokenType != "" {
+		token.Header["typ"] = ks.tokenType
+	}
+
+	return token.SignedString(ks.active.private)
+}
+
+// Parse verifies a token with the key named by its kid header. The token has
+// to use that key's algorithm, so a public key can never be used as an HMAC
+// secret.
+func (ks *KeySet) Parse(tokenStr string) (*jwt.Token, error) {
+	return jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := ks.keys[kid]
+		if !ok {
+			return nil, ErrUnknownKeyID
+		}
+
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, ErrUnexpectedAlgorithm
+		}
+
+		if ks.tokenType != "" && token.Header["typ"] != ks.tokenType {
+			return nil, ErrUnexpectedTokenType
+		}
+
+		return key.public, nil
+	})
+}
+
+// JWK is the public part of a signing key in JSON Web Key form.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS lists the public keys of the set, sorted by kid. HMAC secrets are
+// never published, so a key set of shared secrets yields no keys.
+func (ks *KeySet) JWKS() JWKS {
+	doc := JWKS{Keys: []JWK{}}
+
+	for _, key := range ks.keys {
+		jwk := JWK{Kid: key.ID, Alg: key.Method.Alg(), Use: "sig"}
+
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+
+		doc.Keys = append(doc.Keys, jwk)
+	}
+
+	sort.Slice(doc.Keys, func(i, j int) bool { return doc.Keys[i].Kid < doc.Keys[j].Kid })
+
+	return doc
+}
@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"errors"
+	"os"
+	"strings"
+	"unicode"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordList []byte
+
+var (
+	ErrPasswordTooShort      = errors.New("password is too short")
+	ErrPasswordTooLong       = errors.New("password is too long")
+	ErrPasswordTooSimple     = errors.New("password must mix more character classes (lowercase, uppercase, digits, symbols)")
+	ErrPasswordCommon        = errors.New("password is too common or appears in known breaches")
+	ErrPasswordContainsInput = errors.New("password must not contain your name or email")
+)
+
+// PasswordPolicy is the set of rules new passwords must follow.
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	// Minimum number of character classes among lowercase, uppercase, digits
+	// and symbols
+	MinClasses int
+	// Number of previous passwords that cannot be reused
+	HistorySize int
+	// Lowercased passwords that are rejected
+	Blocklist map[string]struct{}
+}
+
+var passwordPolicy = PasswordPolicy{
+	MinLength:   10,
+	MaxLength:   72,
+	MinClasses:  3,
+	HistorySize: 5,
+	Blocklist:   parsePasswordList(commonPasswordList),
+}
+
+// NewPasswordPolicy returns a policy whose blocklist is the shipped common
+// password list plus, when set, the newline separated list in blocklistFile.
+func NewPasswordPolicy(minLength, maxLength, minClasses, historySize int, blocklistFile string) (PasswordPolicy, error) {
+	p := PasswordPolicy{
+		MinLength:   minLength,
+		MaxLength:   maxLength,
+		MinClasses:  minClasses,
+		HistorySize: historySize,
+		Blocklist:   parsePasswordList(commonPasswordList),
+	}
+
+	if blocklistFile != "" {
+		data, err := os.ReadFile(blocklistFile)
+		if err != nil {
+			return p, err
+		}
+
+		for password := range parsePasswordList(data) {
+			p.Blocklist[password] = struct{}{}
+		}
+	}
+
+	return p, nil
+}
+
+// SetPasswordPolicy replaces the policy. It is meant to be called once at
+// startup.
+func SetPasswordPolicy(p PasswordPolicy) {
+	passwordPolicy = p
+}
+
+// CurrentPasswordPolicy returns the policy in effect.
+func CurrentPasswordPolicy() PasswordPolicy {
+	return passwordPolicy
+}
+
+// CheckPasswordPolicy validates a new password. userInputs, such as the
+// user's email and name, must not appear in it.
+func CheckPasswordPolicy(password string, userInputs ...string) error {
+	p := passwordPolicy
+
+	if len([]rune(password)) < p.MinLength {
+		return ErrPasswordTooShort
+	}
+	// bcrypt ignores everything after 72 bytes
+	if (p.MaxLength > 0 && len([]rune(password)) > p.MaxLength) ||
+		(passwordHashing.Algorithm == HashAlgorithmBcrypt && len(password) > 72) {
+		return ErrPasswordTooLong
+	}
+
+	if passwordClasses(password) < p.MinClasses {
+		return ErrPasswordTooSimple
+	}
+
+	lower := strings.ToLower(password)
+	if _, ok := p.Blocklist[lower]; ok {
+		return ErrPasswordCommon
+	}
+
+	for _, input := range userInputs {
+		for _, part := range strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+			return r == '@' || r == '.' || unicode.IsSpace(r)
+		}) {
+			if len([]rune(part)) >= 4 && strings.Contains(lower, part) {
+				return ErrPasswordContainsInput
+			}
+		}
+	}
+
+	return nil
+}
+
+func passwordClasses(password string) int {
+	var lower, upper, digit, symbol bool
+
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	classes := 0
+	for _, ok := range []bool{lower, upper, digit, symbol} {
+		if ok {
+			classes++
+		}
+	}
+
+	return classes
+}
+
+func parsePasswordList(data []byte) map[string]struct{} {
+	list := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		list[strings.ToLower(line)] = struct{}{}
+	}
+
+	return list
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const scimErrorSchema = "urn:ietf:params:scim:api:messages:2.0:Error"
+
+// SCIMAuthMiddleware authenticates identity providers calling the SCIM API.
+// They send a service account API key as "Authorization: Bearer <key>", the
+// only scheme most providers support. The key needs the given permission.
+// Failures are reported as SCIM errors.
+func SCIMAuthMiddleware(apiKeys APIKeyAuthenticator, resolver PermissionResolver, permission int64) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		authHeader := c.Get("Authorization")
+		if !strings.HasPrefix(authHeader, "Bearer ") {
+			return scimError(c, fiber.StatusUnauthorized, ErrTokenMissing.Error())
+		}
+
+		keyUID, accountUID, err := apiKeys.AuthenticateAPIKey(c.UserContext(), strings.TrimPrefix(authHeader, "Bearer "))
+		if err != nil {
+			return scimError(c, fiber.StatusUnauthorized, ErrTokenExpired.Error())
+		}
+
+		granted, err := resolver.GetPermissionIDsByUserUID(c.UserContext(), keyUID)
+		if err != nil || !hasPermissions(granted, []int64{permission}) {
+			return scimError(c, fiber.StatusForbidden, ErrPermissionDenied.Error())
+		}
+
+		c.Locals("userClaims", jwt.MapClaims{
+			"user_id":            keyUID,
+			"roles":              []interface{}{},
+			"service_account_id": accountUID,
+		})
+
+		return c.Next()
+	}
+}
+
+func scimError(c *fiber.Ctx, status int, detail string) error {
+	err := c.Status(status).JSON(fiber.Map{
+		"schemas": []string{scimErrorSchema},
+		"status":  strconv.Itoa(status),
+		"detail":  detail,
+	})
+	c.Set(fiber.HeaderContentType, "application/scim+json")
+
+	return err
+}